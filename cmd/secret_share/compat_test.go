@@ -0,0 +1,217 @@
+package main
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/scosman/secret_share/core"
+	"github.com/scosman/secret_share/tui"
+)
+
+// compatPath is the fixture file shared with core's compatibility tests
+const compatPath = "../../core/testdata/compat.json"
+
+// compatFile is the part of the compatibility fixtures the app's flows are checked against
+type compatFile struct {
+	ReceiverPrivateKey string `json:"receiver_private_key"`
+	Keys               []struct {
+		Name    string `json:"name"`
+		Data    string `json:"data"`
+		Expect  string `json:"expect"`
+		HPKEKey string `json:"hpke_private_key"`
+	} `json:"keys"`
+	Secrets []struct {
+		Name       string `json:"name"`
+		Data       string `json:"data"`
+		Expect     string `json:"expect"`
+		Passphrase string `json:"passphrase"`
+		SSHKey     string `json:"ssh_private_key"`
+		HPKEKey    string `json:"hpke_private_key"`
+		Plaintext  string `json:"plaintext"`
+		Fields     []struct {
+			Key   string `json:"key"`
+			Value string `json:"value"`
+		} `json:"fields"`
+	} `json:"secrets"`
+}
+
+// loadCompat reads the compatibility fixtures and their receiver key
+func loadCompat(t *testing.T) (compatFile, *rsa.PrivateKey) {
+	t.Helper()
+	data, err := os.ReadFile(compatPath)
+	if err != nil {
+		t.Fatalf("Failed to read compatibility fixtures: %v", err)
+	}
+	var file compatFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatalf("Failed to parse compatibility fixtures: %v", err)
+	}
+
+	der, err := base64.StdEncoding.DecodeString(file.ReceiverPrivateKey)
+	if err != nil {
+		t.Fatalf("Invalid private key base64: %v", err)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		t.Fatalf("Invalid private key: %v", err)
+	}
+	privateKey, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		t.Fatal("Expected an RSA private key")
+	}
+	return file, privateKey
+}
+
+func TestCompatSenderKeys(t *testing.T) {
+	file, privateKey := loadCompat(t)
+	if len(file.Keys) == 0 {
+		t.Fatal("Expected key fixtures")
+	}
+
+	for _, fixture := range file.Keys {
+		t.Run(fixture.Name, func(t *testing.T) {
+			sender := tui.NewScriptedConsole(fixture.Data, "s", strongPassword)
+			handleSender(sender, senderOptions{})
+
+			switch fixture.Expect {
+			case "ok":
+				secret, err := decryptInput(core.NewReceiverSessionWithKey(privateKey), sender.Clipboard)
+				if err != nil {
+					t.Fatalf("Failed to decrypt the sender's secret: %v\n%s", err, sender.Transcript())
+				}
+				defer secret.Destroy()
+				if string(secret.Bytes()) != strongPassword {
+					t.Errorf("Expected '%s', got '%s'", strongPassword, secret.Bytes())
+				}
+			case "hpke":
+				session := newCompatHPKESession(t, fixture.HPKEKey)
+				secret, err := decryptInput(session, sender.Clipboard)
+				if err != nil {
+					t.Fatalf("Failed to decrypt the sender's secret: %v\n%s", err, sender.Transcript())
+				}
+				defer secret.Destroy()
+				if string(secret.Bytes()) != strongPassword {
+					t.Errorf("Expected '%s', got '%s'", strongPassword, secret.Bytes())
+				}
+			case "upgrade":
+				if !strings.Contains(sender.Transcript(), "You need to upgrade SecretSend") {
+					t.Errorf("Expected the sender to be asked to upgrade:\n%s", sender.Transcript())
+				}
+				if sender.Clipboard != "" {
+					t.Errorf("Expected nothing to be encrypted, got '%s'", sender.Clipboard)
+				}
+				if sender.Remaining() != 2 {
+					t.Errorf("Expected the sender to stop after the key, %d answers left", sender.Remaining())
+				}
+			default:
+				t.Fatalf("Unknown expectation %s", fixture.Expect)
+			}
+		})
+	}
+}
+
+func TestCompatReceiverSecrets(t *testing.T) {
+	file, _ := loadCompat(t)
+	if len(file.Secrets) == 0 {
+		t.Fatal("Expected secret fixtures")
+	}
+
+	for _, fixture := range file.Secrets {
+		t.Run(fixture.Name, func(t *testing.T) {
+			// The receiver wipes its key once it decrypts a secret, so each needs its own copy
+			_, privateKey := loadCompat(t)
+			answers := []string{fixture.Data, "q"}
+			if fixture.Expect == "passphrase" {
+				// Receivers waiting with a key are asked for the passphrase instead
+				answers = []string{fixture.Data, fixture.Passphrase, "q"}
+			}
+			receiver := tui.NewScriptedConsole(answers...)
+			code := receiveSecret(receiver, receiverOptions{}, core.NewReceiverSessionWithKey(privateKey))
+			if code != 0 {
+				t.Errorf("Expected exit code 0, got %d", code)
+			}
+			transcript := receiver.Transcript()
+
+			if fixture.Expect == "ssh" {
+				// Receivers waiting with a key are told to use their SSH key, which reads the secret
+				if !strings.Contains(transcript, "receive --ssh-key PATH") {
+					t.Errorf("Expected the receiver to be told to use their SSH key:\n%s", transcript)
+				}
+				path := filepath.Join(t.TempDir(), "id_ed25519")
+				if err := os.WriteFile(path, []byte(fixture.SSHKey), 0600); err != nil {
+					t.Fatalf("Failed to write SSH key: %v", err)
+				}
+				receiver = tui.NewScriptedConsole(fixture.Data, "q")
+				if code := handleReceiver(receiver, receiverOptions{sshKey: path}); code != 0 {
+					t.Errorf("Expected exit code 0, got %d", code)
+				}
+				transcript = receiver.Transcript()
+			}
+			if fixture.Expect == "hpke" {
+				// Receivers waiting with an RSA key can't read it, but the HPKE key it was encrypted to can
+				if !strings.Contains(transcript, "This secret was encrypted to a different key than the one above.") {
+					t.Errorf("Expected the receiver to be told the secret is for another key:\n%s", transcript)
+				}
+				receiver = tui.NewScriptedConsole(fixture.Data, "q")
+				if code := receiveSecret(receiver, receiverOptions{}, newCompatHPKESession(t, fixture.HPKEKey)); code != 0 {
+					t.Errorf("Expected exit code 0, got %d", code)
+				}
+				transcript = receiver.Transcript()
+			}
+
+			switch fixture.Expect {
+			case "ok", "passphrase", "ssh", "hpke":
+				if strings.Contains(transcript, "Could not extract secret") || strings.Contains(transcript, "Wrong passphrase") {
+					t.Fatalf("Receiver could not read the secret:\n%s", transcript)
+				}
+				expected := []string{fixture.Plaintext}
+				for _, field := range fixture.Fields {
+					expected = append(expected, field.Key, field.Value)
+				}
+				for _, text := range expected {
+					if !strings.Contains(transcript, text) {
+						t.Errorf("Expected the receiver to see '%s':\n%s", text, transcript)
+					}
+				}
+			case "age":
+				// Only the age identity it was encrypted to can read it, which the receiver doesn't have
+				if !strings.Contains(transcript, "This secret was encrypted with age") {
+					t.Errorf("Expected the receiver to be told the secret was encrypted with age:\n%s", transcript)
+				}
+			case "upgrade":
+				if !strings.Contains(transcript, "sent using a newer version of SecretShare") {
+					t.Errorf("Expected the receiver to be asked to upgrade:\n%s", transcript)
+				}
+			case "checksum":
+				// Altered secrets are reported before decrypting, not as another key's
+				if !strings.Contains(transcript, "Checksum mismatch: message was altered in transit.") || strings.Contains(transcript, "Could not extract secret") {
+					t.Errorf("Expected the receiver to be told the secret was altered:\n%s", transcript)
+				}
+			default:
+				t.Fatalf("Unknown expectation %s", fixture.Expect)
+			}
+		})
+	}
+}
+
+// newCompatHPKESession returns a receiver session for a fixture's hex HPKE private key
+func newCompatHPKESession(t *testing.T, privateKey string) *core.ReceiverSession {
+	t.Helper()
+	key, err := hex.DecodeString(privateKey)
+	if err != nil {
+		t.Fatalf("Invalid HPKE key hex: %v", err)
+	}
+	session, err := core.NewHPKEReceiverSessionWithKey(key)
+	if err != nil {
+		t.Fatalf("Failed to create HPKE session: %v", err)
+	}
+	t.Cleanup(session.Destroy)
+	return session
+}
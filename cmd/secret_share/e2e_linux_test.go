@@ -0,0 +1,257 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// startReceiverPTY starts a receiver and returns it waiting for the encrypted secret, along with
+// the key it copied to the clipboard
+func startReceiverPTY(t *testing.T) (*ptyProcess, string) {
+	t.Helper()
+	receiver := startPTY(t, "receive")
+	screen := receiver.expect("enter it here: ")
+
+	key := receiver.readClipboard()
+	if !strings.HasPrefix(key, "<secret_share_key>\nssv1") || !strings.HasSuffix(key, "</secret_share_key>") {
+		t.Fatalf("Expected a tagged key on the clipboard, got '%s'", key)
+	}
+	// The terminal ends the lines of the armored key with \r\n
+	if !strings.Contains(screen, strings.ReplaceAll(key, "\n", "\r\n")) {
+		t.Fatalf("Expected the key to be shown to the receiver. Output:\n%s", screen)
+	}
+	return receiver, key
+}
+
+// startSenderPTY starts a sender, types in the receiver's key, and waits for the secret type menu
+func startSenderPTY(t *testing.T, key string) *ptyProcess {
+	t.Helper()
+	sender := startPTY(t, "send")
+	sender.expect("<secret_share_key> tags: ")
+	sender.waitForRawMode()
+	sender.paste(key)
+	sender.send("\r")
+	sender.expect("[g]enerate a new secret? ")
+	sender.waitForRawMode()
+	return sender
+}
+
+// finishSender waits for the sender to encrypt the secret, and returns what it copied to the clipboard
+func finishSender(t *testing.T, sender *ptyProcess) string {
+	t.Helper()
+	screen := sender.expect("Copied to clipboard.")
+	if code := sender.wait(); code != 0 {
+		t.Fatalf("Expected sender exit code 0, got %d", code)
+	}
+
+	encrypted := sender.readClipboard()
+	if !strings.HasPrefix(encrypted, "<secret_share_secret>") || !strings.HasSuffix(encrypted, "</secret_share_secret>") {
+		t.Fatalf("Expected a tagged secret on the clipboard, got '%s'", encrypted)
+	}
+	if !strings.Contains(screen, strings.ReplaceAll(encrypted, "\n", "\r\n")) {
+		t.Fatalf("Expected the encrypted secret to be shown to the sender. Output:\n%s", screen)
+	}
+	if !sender.terminalRestored() {
+		t.Error("Sender left the terminal in raw mode")
+	}
+	return encrypted
+}
+
+// finishReceiver pastes the encrypted secret into the receiver and returns what it printed
+func finishReceiver(t *testing.T, receiver *ptyProcess, encrypted string, expected string) string {
+	t.Helper()
+	receiver.waitForRawMode()
+	receiver.paste(encrypted)
+	receiver.send("\r")
+	screen := receiver.expect(expected)
+	if code := receiver.wait(); code != 0 {
+		t.Fatalf("Expected receiver exit code 0, got %d", code)
+	}
+	if !receiver.terminalRestored() {
+		t.Error("Receiver left the terminal in raw mode")
+	}
+	return screen
+}
+
+func TestPTYExchangeSingleSecret(t *testing.T) {
+	receiver, key := startReceiverPTY(t)
+
+	sender := startSenderPTY(t, key)
+	sender.send("s")
+	sender.expect("Enter the secret you want to share: ")
+	sender.waitForMaskedInput()
+	sender.send(strongPassword + "\r")
+	encrypted := finishSender(t, sender)
+
+	finishReceiver(t, receiver, encrypted, "Here's your secret 🤫: "+strongPassword)
+
+	// The secret is typed with echo off, so the sender's terminal never shows it
+	if strings.Contains(sender.screen(), strongPassword) {
+		t.Errorf("The secret was echoed to the sender's terminal:\n%s", sender.screen())
+	}
+}
+
+func TestPTYExchangeMultilineSecret(t *testing.T) {
+	receiver, key := startReceiverPTY(t)
+
+	sender := startSenderPTY(t, key)
+	sender.send("m")
+	sender.expect("JSON file:")
+	sender.waitForRawMode()
+	sender.paste("-----BEGIN DATA-----\r\nline1\r\nline2\r\n-----END DATA-----\r\n")
+	sender.send(".\r")
+	encrypted := finishSender(t, sender)
+
+	screen := finishReceiver(t, receiver, encrypted, "-----END DATA-----")
+	if !strings.Contains(screen, "-----BEGIN DATA-----\r\nline1\r\nline2\r\n-----END DATA-----") {
+		t.Errorf("Receiver did not see the multi-line secret:\n%s", screen)
+	}
+	if strings.Contains(sender.screen(), "line1") {
+		t.Errorf("The secret was echoed to the sender's terminal:\n%s", sender.screen())
+	}
+}
+
+func TestPTYQuitAtRolePrompt(t *testing.T) {
+	app := startPTY(t)
+	app.expect("[r]eceiving a secret? ")
+	app.waitForRawMode()
+	app.send("q")
+	app.expect("Quiting SecretShare")
+
+	if code := app.wait(); code != 0 {
+		t.Errorf("Expected exit code 0, got %d", code)
+	}
+	if !app.terminalRestored() {
+		t.Error("Expected the terminal to be restored after quitting")
+	}
+}
+
+func TestPTYInterruptAtRolePrompt(t *testing.T) {
+	app := startPTY(t)
+	app.expect("[r]eceiving a secret? ")
+	app.waitForRawMode()
+	app.send("\x03")
+
+	if code := app.wait(); code != 0 {
+		t.Errorf("Expected exit code 0, got %d", code)
+	}
+	if !app.terminalRestored() {
+		t.Error("Expected the terminal to be restored after Ctrl-C")
+	}
+	if strings.Contains(app.remaining(), "Invalid input") {
+		t.Errorf("Ctrl-C should not be treated as a choice:\n%s", app.remaining())
+	}
+}
+
+func TestPTYInterruptWhilePastingSecret(t *testing.T) {
+	receiver, _ := startReceiverPTY(t)
+	receiver.waitForRawMode()
+	receiver.send("\x03")
+
+	if code := receiver.wait(); code != 0 {
+		t.Errorf("Expected exit code 0, got %d", code)
+	}
+	if !receiver.terminalRestored() {
+		t.Error("Expected the terminal to be restored after Ctrl-C")
+	}
+}
+
+func TestPTYInterruptAtLinePrompt(t *testing.T) {
+	_, key := startReceiverPTY(t)
+
+	// Line prompts leave Ctrl-C to the terminal, which sends SIGINT
+	sender := startSenderPTY(t, key)
+	sender.send("f")
+	sender.expect("enter fields one at a time: ")
+	sender.send("\x03")
+	sender.expect("Shutting down SecretShare...")
+
+	// The prompt is answered as if the user quit, so the flow cleans up before the app exits
+	sender.expect("Quiting SecretShare")
+
+	if code := sender.wait(); code != 0 {
+		t.Errorf("Expected exit code 0, got %d", code)
+	}
+	if !sender.terminalRestored() {
+		t.Error("Expected the terminal to be restored after Ctrl-C")
+	}
+}
+
+func TestPTYVerboseReportsProtections(t *testing.T) {
+	app := startPTY(t, "-v")
+	screen := app.expect("[r]eceiving a secret? ")
+
+	for _, name := range []string{"PR_SET_DUMPABLE", "RLIMIT_CORE", "mlock"} {
+		if !strings.Contains(screen, name) {
+			t.Errorf("Expected %s to be reported:\n%s", name, screen)
+		}
+	}
+
+	// Core dumps are disabled for the running app
+	limits, err := os.ReadFile(fmt.Sprintf("/proc/%d/limits", app.cmd.Process.Pid))
+	if err != nil {
+		t.Fatalf("Failed to read process limits: %v", err)
+	}
+	if !regexp.MustCompile(`Max core file size\s+0\s+0\s`).Match(limits) {
+		t.Errorf("Expected a core file size limit of 0:\n%s", limits)
+	}
+
+	app.waitForRawMode()
+	app.send("q")
+	if code := app.wait(); code != 0 {
+		t.Errorf("Expected exit code 0, got %d", code)
+	}
+}
+
+func TestPTYReceiverSessionTimeout(t *testing.T) {
+	receiver := startPTY(t, "--timeout", "3s", "receive")
+	receiver.expect("⏱ Times out in 0:0")
+	receiver.expect("enter it here: ")
+	receiver.waitForRawMode()
+
+	// The countdown is redrawn in place while the prompt waits
+	receiver.expect("\0338")
+	screen := receiver.expect("This session expired after 3s, and its key was wiped.")
+	if strings.Contains(screen, "Quiting SecretShare") {
+		t.Errorf("A timeout should not be reported as quitting:\n%s", screen)
+	}
+
+	if code := receiver.wait(); code != 1 {
+		t.Errorf("Expected exit code 1, got %d", code)
+	}
+	if !receiver.terminalRestored() {
+		t.Error("Expected the terminal to be restored after the timeout")
+	}
+}
+
+func TestPTYSenderSecretTimeout(t *testing.T) {
+	_, key := startReceiverPTY(t)
+
+	sender := startPTY(t, "--timeout", "3s", "send")
+	sender.expect("<secret_share_key> tags: ")
+	sender.waitForRawMode()
+	sender.send(key + "\r")
+	sender.expect("[g]enerate a new secret? ")
+	sender.waitForRawMode()
+	sender.send("s")
+	sender.expect("⏱ Times out in 0:0")
+	sender.expect("Enter the secret you want to share: ")
+	sender.waitForMaskedInput()
+	sender.send("typed but never entered")
+
+	sender.expect("No secret was entered within 3s, so nothing was encrypted.")
+	if code := sender.wait(); code != 0 {
+		t.Errorf("Expected exit code 0, got %d", code)
+	}
+	if !sender.terminalRestored() {
+		t.Error("Expected the terminal to be restored after the timeout")
+	}
+	if strings.Contains(sender.screen(), "typed but never entered") {
+		t.Errorf("The secret was echoed to the sender's terminal:\n%s", sender.screen())
+	}
+}
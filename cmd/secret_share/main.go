@@ -1,12 +1,13 @@
 package main
 
 import (
-	"crypto/rsa"
-	"encoding/base64"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/scosman/secret_share/core"
 	"github.com/scosman/secret_share/tui"
@@ -29,182 +30,352 @@ const titleCard = `
  
   Secure One Time Secret Sharing`
 
+// shutdownSignals receives interrupts that should end the app
+var shutdownSignals = make(chan os.Signal, 1)
+
+// defaultTimeout is how long a receiver's key lives, and how long the sender has to enter the secret
+const defaultTimeout = 15 * time.Minute
+
+// globalOptions are the flags that apply to every command
+type globalOptions struct {
+	verbose bool          // report which memory protections are active
+	timeout time.Duration // session lifetime, or 0 for no timeout
+}
+
+// receiverOptions controls where the receiver delivers a decrypted secret
+type receiverOptions struct {
+	envFile  string   // merge the secret into this .env file
+	envName  string   // name for a single secret: the envFile variable or Secret data key
+	execName string   // run execArgs with the secret in this environment variable
+	execArgs []string // command to run in exec mode
+
+	k8sSecret    string // render the secret as a Kubernetes Secret with this name
+	k8sNamespace string // namespace for the Kubernetes Secret
+	output       string // file for the Kubernetes Secret manifest, stdout if empty or "-"
+
+	passphrase bool          // decrypt with a passphrase agreed with the sender, instead of a new key
+	combine    bool          // collect shares of a split secret and recover it
+	sshKey     string        // decrypt with the SSH private key in this file, instead of a new key
+	age        bool          // receive with a one-time age identity, instead of a new RSA key
+	jwe        bool          // share the key as a JWK, for senders using JOSE libraries
+	hpke       bool          // share a one-time X25519 key for ssv4 HPKE envelopes, instead of a new RSA key
+	qr         bool          // show the shared key as a QR code too
+	qrPNG      string        // save the shared key as a QR code PNG to this file
+	words      bool          // show the shared key as words too, to read aloud or type from a printout
+	stdout     io.Writer     // where machine readable output is written when it goes to stdout
+	timeout    time.Duration // how long the key can decrypt a secret, or 0 for no limit
+	verbose    bool          // report whether the key is in locked memory
+}
+
+// senderOptions controls how the sender encrypts a secret
+type senderOptions struct {
+	passphrase bool          // encrypt with a passphrase agreed with the receiver, instead of their key
+	sshKey     string        // encrypt to the SSH public key in this file, instead of a SecretShare key
+	jwe        bool          // output the secret as a JWE compact token, for receivers using JOSE libraries
+	split      int           // split the secret into a share for each of this many receivers, or 0
+	threshold  int           // number of shares needed to recover a split secret
+	qr         bool          // show the encrypted secret as a QR code too
+	qrPNG      string        // save the encrypted secret as a QR code PNG to this file
+	words      bool          // show the encrypted secret as words too, if it's short enough
+	timeout    time.Duration // how long to wait for the secret, or 0 for no limit
+}
+
+// defaultThreshold is how many shares recover a split secret, unless --threshold is given
+const defaultThreshold = 2
+
+// writesToStdout reports whether the receiver's result goes to stdout instead of the terminal UI
+func (opts receiverOptions) writesToStdout() bool {
+	return opts.k8sSecret != "" && (opts.output == "" || opts.output == "-")
+}
+
+const usage = `Usage:
+  secret_share                  interactive mode
+  secret_share send [flags]     send a secret
+  secret_share receive [flags]  receive a secret
+
+Global flags, before the command:
+  -v, --verbose           report which memory protections are active
+  --timeout DURATION      wipe the receiver's key and stop waiting for a secret after DURATION,
+                          such as 5m or 1h (default 15m, 0 for no timeout)
+
+Send flags:
+  --passphrase            encrypt with a passphrase agreed with the receiver, instead of their key
+  --ssh-key PATH          encrypt to the receiver's SSH public key in PATH (ssh-ed25519 or ssh-rsa)
+  --jwe                   output a JWE compact token (RSA-OAEP-256, A256GCM), as for a pasted JWK
+  --split N               split the secret into shares for N receivers, each encrypted to their key
+  --threshold K           number of shares needed to recover a split secret (default 2)
+  --qr                    show the encrypted secret as a QR code too
+  --qr-png PATH           save the encrypted secret as a QR code PNG to PATH
+  --words                 show the encrypted secret as words too, if it's short enough to read out
+
+Receive flags:
+  --passphrase            decrypt with a passphrase agreed with the sender, instead of a new key
+  --combine               collect shares of a split secret until there are enough to recover it
+  --ssh-key PATH          decrypt with your SSH private key in PATH, instead of a new key
+  --age                   share a one-time age recipient (age1...), instead of a new RSA key
+  --jwe                   share the new key as a JWK, for senders using JOSE libraries
+  --hpke                  share a one-time X25519 key (ssv4), so the secret is encrypted with HPKE
+  --qr                    show the key as a QR code too
+  --qr-png PATH           save the key as a QR code PNG to PATH
+  --words                 show the key as words too, to read out or type from a printout (--hpke)
+  --env-file PATH         merge the secret into a .env file (0600, previous file kept as PATH.bak)
+  --name NAME             name for a single secret: the .env variable or Secret data key
+  --exec NAME -- cmd ...  run cmd with the secret in environment variable NAME only
+  --k8s-secret NAME       output a Kubernetes Secret manifest named NAME
+  --namespace NS          namespace for the Kubernetes Secret
+  --output PATH           write the manifest to PATH instead of stdout
+
+Key/value secrets are written or exported using their own field names. Keys and secrets can be
+entered as the path of a QR code PNG, or a screenshot of one, instead of pasted, or typed in as
+words.
+`
+
 func main() {
-	// Handle graceful shutdown
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	// Keep keys and secrets out of core dumps and swap, before any exist
+	protections := core.ProtectProcess()
+
+	global, args, err := parseGlobalArgs(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	role, opts, senderOpts, err := parseArgs(args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	opts.timeout = global.timeout
+	opts.verbose = global.verbose
+	senderOpts.timeout = global.timeout
+
+	// Keep stdout clean for machine readable output
+	opts.stdout = os.Stdout
+	if opts.writesToStdout() {
+		tui.SetOutput(os.Stderr)
+	}
+	console := tui.NewTerminal()
+
+	// Shut down gracefully: prompts are answered as if the user quit, so flows unwind through
+	// their cleanup and wipe keys and secrets before the app exits
+	signal.Notify(shutdownSignals, os.Interrupt, syscall.SIGTERM)
 	go func() {
-		<-c
-		tui.PrintMessage("\nShutting down SecretShare...")
-		os.Exit(0)
+		<-shutdownSignals
+		console.PrintMessage("\nShutting down SecretShare...")
+		tui.Interrupt()
 	}()
 
 	// Welcome message
-	tui.PrintHeader(titleCard)
+	console.PrintHeader(titleCard)
+	if global.verbose {
+		printProtections(console, protections)
+	}
 
 	// Get user role
-	role := getUserRole()
 	if role == "" {
-		return
+		role = getUserRole(console)
+		if role == "" {
+			return
+		}
 	}
 
 	// Handle based on role
 	if role == "receiver" {
-		handleReceiver()
-	} else {
-		handleSender()
+		os.Exit(handleReceiver(console, opts))
 	}
+	handleSender(console, senderOpts)
 }
 
-func getUserRole() string {
-	for {
-		input := tui.PromptUserSingleChar("Are you [s]ending or [r]eceiving a secret? ")
-		if tui.IsQuit(input) {
-			tui.PrintMessage("Quiting SecretShare")
-			return ""
-		}
+// parseGlobalArgs parses the flags that apply to every command from the start of args,
+// returning the rest
+func parseGlobalArgs(args []string) (globalOptions, []string, error) {
+	var global globalOptions
+	flags := flag.NewFlagSet("secret_share", flag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	flags.Usage = func() {}
+	flags.BoolVar(&global.verbose, "v", false, "report which memory protections are active")
+	flags.BoolVar(&global.verbose, "verbose", false, "report which memory protections are active")
+	flags.DurationVar(&global.timeout, "timeout", defaultTimeout, "session lifetime")
+	if err := flags.Parse(args); err != nil {
+		return global, nil, err
+	}
+	if global.timeout < 0 {
+		return global, nil, fmt.Errorf("invalid timeout: %s", global.timeout)
+	}
+	return global, flags.Args(), nil
+}
 
-		role := tui.ParseRoleInput(input)
-		if role == "" {
-			tui.PrintError("Invalid input. Please enter 's' for sending or 'r' for receiving (or 'q' to quit).")
-			continue
+// printProtections reports which memory protections are active
+func printProtections(console tui.Console, protections []core.Protection) {
+	rows := make([][]string, 0, len(protections))
+	for _, protection := range protections {
+		status := "active"
+		if !protection.Active {
+			status = fmt.Sprintf("inactive: %v", protection.Err)
 		}
-
-		return role
+		rows = append(rows, []string{protection.Name, status})
 	}
+	console.PrintTable([]string{"PROTECTION", "STATUS"}, rows)
 }
 
-func handleReceiver() {
-	fmt.Print("\nGenerating key...")
-	// Create a new receiver session
-	session, err := core.NewReceiverSession()
-	if err != nil {
-		tui.PrintError(fmt.Sprintf("Failed to create receiver session: %v", err))
-		return
+// parseArgs parses the optional command and flags. An empty role means the user will be asked.
+func parseArgs(args []string) (string, receiverOptions, senderOptions, error) {
+	var opts receiverOptions
+	var senderOpts senderOptions
+	if len(args) == 0 {
+		return "", opts, senderOpts, nil
 	}
-	// Clear the generating message, and go back up a line
-	fmt.Print("\r                  \r\033[F")
 
-	// Get public key bytes
-	publicKeyBytes, err := core.PublicKeyToBytes(session.GetPublicKey())
-	if err != nil {
-		tui.PrintError(fmt.Sprintf("Failed to serialize public key: %v", err))
-		return
+	role := tui.ParseRoleInput(args[0])
+	if role == "" {
+		return "", opts, senderOpts, fmt.Errorf("unknown command: %s", args[0])
 	}
 
-	// Display public key for sharing
-	publicKeyStr := base64.StdEncoding.EncodeToString(publicKeyBytes)
-	publicKeyFormatted := core.FormatPublicKey([]byte(publicKeyStr))
-	tui.PrintInfo("Here's a new public key:")
-	tui.PrintMessage(publicKeyFormatted)
+	if role == "sender" {
+		flags := flag.NewFlagSet("send", flag.ContinueOnError)
+		flags.SetOutput(os.Stderr)
+		flags.Usage = func() {}
+		flags.BoolVar(&senderOpts.passphrase, "passphrase", false, "encrypt with a passphrase agreed with the receiver")
+		flags.StringVar(&senderOpts.sshKey, "ssh-key", "", "encrypt to the SSH public key in this file")
+		flags.BoolVar(&senderOpts.jwe, "jwe", false, "output the secret as a JWE compact token")
+		flags.IntVar(&senderOpts.split, "split", 0, "split the secret into shares for this many receivers")
+		flags.IntVar(&senderOpts.threshold, "threshold", 0, "number of shares needed to recover the secret")
+		flags.BoolVar(&senderOpts.qr, "qr", false, "show the encrypted secret as a QR code too")
+		flags.StringVar(&senderOpts.qrPNG, "qr-png", "", "save the encrypted secret as a QR code PNG to this file")
+		flags.BoolVar(&senderOpts.words, "words", false, "show the encrypted secret as words too")
+		if err := flags.Parse(args[1:]); err != nil {
+			return "", opts, senderOpts, err
+		}
+		if flags.NArg() > 0 {
+			return "", opts, senderOpts, fmt.Errorf("unexpected arguments: %v", flags.Args())
+		}
+		if senderOpts.sshKey != "" && (senderOpts.passphrase || senderOpts.split != 0) {
+			return "", opts, senderOpts, fmt.Errorf("--ssh-key cannot be used with --passphrase or --split")
+		}
+		if senderOpts.jwe && (senderOpts.passphrase || senderOpts.sshKey != "" || senderOpts.split != 0) {
+			return "", opts, senderOpts, fmt.Errorf("--jwe cannot be used with --passphrase, --ssh-key or --split")
+		}
+		if senderOpts.qrPNG != "" && senderOpts.split != 0 {
+			return "", opts, senderOpts, fmt.Errorf("--qr-png cannot be used with --split")
+		}
+		if senderOpts.words && (senderOpts.jwe || senderOpts.split != 0) {
+			return "", opts, senderOpts, fmt.Errorf("--words cannot be used with --jwe or --split")
+		}
+		if senderOpts.split == 0 {
+			if senderOpts.threshold != 0 {
+				return "", opts, senderOpts, fmt.Errorf("--threshold needs --split")
+			}
+			return role, opts, senderOpts, nil
+		}
+		if senderOpts.passphrase {
+			return "", opts, senderOpts, fmt.Errorf("--split cannot be used with --passphrase")
+		}
+		if senderOpts.threshold == 0 {
+			senderOpts.threshold = defaultThreshold
+		}
+		if senderOpts.split < 2 || senderOpts.split > core.MaxShares {
+			return "", opts, senderOpts, fmt.Errorf("--split must be between 2 and %d", core.MaxShares)
+		}
+		if senderOpts.threshold < 2 || senderOpts.threshold > senderOpts.split {
+			return "", opts, senderOpts, fmt.Errorf("--threshold must be between 2 and the number of shares")
+		}
+		return role, opts, senderOpts, nil
+	}
 
-	// Try to copy public key to clipboard
-	err = tui.SetClipboard(publicKeyFormatted)
-	if err == nil {
-		tui.PrintInfo("Copied to clipboard.")
+	flags := flag.NewFlagSet("receive", flag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	flags.Usage = func() {}
+	flags.StringVar(&opts.envFile, "env-file", "", "merge the secret into a .env file")
+	flags.StringVar(&opts.envName, "name", "", "name for a single secret")
+	flags.StringVar(&opts.execName, "exec", "", "run a command with the secret in this environment variable")
+	flags.StringVar(&opts.k8sSecret, "k8s-secret", "", "output a Kubernetes Secret manifest with this name")
+	flags.StringVar(&opts.k8sNamespace, "namespace", "", "namespace for the Kubernetes Secret")
+	flags.StringVar(&opts.output, "output", "", "file to write the Kubernetes Secret manifest to")
+	flags.BoolVar(&opts.passphrase, "passphrase", false, "decrypt with a passphrase agreed with the sender")
+	flags.BoolVar(&opts.combine, "combine", false, "collect shares of a split secret and recover it")
+	flags.StringVar(&opts.sshKey, "ssh-key", "", "decrypt with the SSH private key in this file")
+	flags.BoolVar(&opts.age, "age", false, "share a one-time age recipient instead of a new key")
+	flags.BoolVar(&opts.jwe, "jwe", false, "share the new key as a JWK")
+	flags.BoolVar(&opts.hpke, "hpke", false, "share a one-time X25519 key for HPKE instead of a new key")
+	flags.BoolVar(&opts.qr, "qr", false, "show the key as a QR code too")
+	flags.StringVar(&opts.qrPNG, "qr-png", "", "save the key as a QR code PNG to this file")
+	flags.BoolVar(&opts.words, "words", false, "show the key as words too")
+	if err := flags.Parse(args[1:]); err != nil {
+		return "", opts, senderOpts, err
+	}
+	if opts.combine && opts.passphrase {
+		return "", opts, senderOpts, fmt.Errorf("--combine cannot be used with --passphrase")
+	}
+	if opts.sshKey != "" && (opts.combine || opts.passphrase) {
+		return "", opts, senderOpts, fmt.Errorf("--ssh-key cannot be used with --combine or --passphrase")
+	}
+	if opts.age && (opts.passphrase || opts.sshKey != "") {
+		return "", opts, senderOpts, fmt.Errorf("--age cannot be used with --passphrase or --ssh-key")
 	}
+	if opts.jwe && (opts.passphrase || opts.sshKey != "" || opts.age) {
+		return "", opts, senderOpts, fmt.Errorf("--jwe cannot be used with --passphrase, --ssh-key or --age")
+	}
+	if opts.hpke && (opts.passphrase || opts.sshKey != "" || opts.age || opts.jwe) {
+		return "", opts, senderOpts, fmt.Errorf("--hpke cannot be used with --passphrase, --ssh-key, --age or --jwe")
+	}
+	if (opts.qr || opts.qrPNG != "") && (opts.passphrase || opts.sshKey != "") {
+		return "", opts, senderOpts, fmt.Errorf("--qr and --qr-png cannot be used with --passphrase or --ssh-key, which share no key")
+	}
+	if opts.words && !opts.hpke {
+		return "", opts, senderOpts, fmt.Errorf("--words needs --hpke, as other keys are too long to read as words")
+	}
+	opts.execArgs = flags.Args()
 
-	// Get encrypted secret from sender with retry logic
-	var decryptedSecret []byte
-	for {
-		input := tui.PromptUser("Send the key above to the person who wants to share a secret with you. When they reply back with the encrypted secret, enter it here: ")
-		if tui.IsQuit(input) {
-			tui.PrintMessage("Quiting SecretShare")
-			return
+	if opts.k8sSecret != "" {
+		if opts.envFile != "" || opts.execName != "" {
+			return "", opts, senderOpts, fmt.Errorf("--k8s-secret cannot be used with --env-file or --exec")
 		}
-
-		// Extract secret from tags
-		secretStr := tui.ExtractSecret(input)
-		// Decode base64 secret
-		encryptedSecret, err := base64.StdEncoding.DecodeString(secretStr)
-		// Decrypt the secret
-		if err == nil {
-			decryptedSecret, err = session.DecryptSecret(encryptedSecret)
+		if !core.IsValidKubernetesName(opts.k8sSecret) {
+			return "", opts, senderOpts, fmt.Errorf("invalid Kubernetes Secret name: %s", opts.k8sSecret)
 		}
-
-		if err != nil || secretStr == "" {
-			tui.PrintError("Could not extract secret from input.")
-			tui.PrintMessage("Ensure you are pasting the exact encrypted secret from the sender. It should be a string wrapped in tags like '<secret_share_secret>'.")
-			continue
+		if opts.k8sNamespace != "" && !core.IsValidKubernetesNamespace(opts.k8sNamespace) {
+			return "", opts, senderOpts, fmt.Errorf("invalid Kubernetes namespace: %s", opts.k8sNamespace)
 		}
-
-		break
+		if opts.envName != "" && !core.IsValidKubernetesKey(opts.envName) {
+			return "", opts, senderOpts, fmt.Errorf("invalid Secret key: %s", opts.envName)
+		}
+	} else if opts.k8sNamespace != "" || opts.output != "" {
+		return "", opts, senderOpts, fmt.Errorf("--namespace and --output need --k8s-secret")
+	} else if opts.envName != "" && !core.IsValidEnvName(opts.envName) {
+		return "", opts, senderOpts, fmt.Errorf("invalid variable name: %s", opts.envName)
+	}
+	if opts.execName != "" {
+		if !core.IsValidEnvName(opts.execName) {
+			return "", opts, senderOpts, fmt.Errorf("invalid variable name: %s", opts.execName)
+		}
+		if len(opts.execArgs) == 0 {
+			return "", opts, senderOpts, fmt.Errorf("--exec needs a command after --")
+		}
+		if opts.envFile != "" {
+			return "", opts, senderOpts, fmt.Errorf("--exec and --env-file cannot be used together")
+		}
+	} else if len(opts.execArgs) > 0 {
+		return "", opts, senderOpts, fmt.Errorf("unexpected arguments: %v", opts.execArgs)
 	}
 
-	// Display the decrypted secret
-	tui.PrintSuccess(fmt.Sprintf("Here's your secret 🤫: %s", string(decryptedSecret)))
+	return role, opts, senderOpts, nil
 }
 
-func handleSender() {
-	// Get receiver's public key with retry logic
-	var receiverPublicKey *rsa.PublicKey
+func getUserRole(console tui.Console) string {
 	for {
-		input := tui.PromptUser("Enter the key sent from the person waiting to receive a secret. It should be a string wrapped in <secret_share_key> tags: ")
+		input := console.PromptUserSingleChar("Are you [s]ending or [r]eceiving a secret? ")
 		if tui.IsQuit(input) {
-			tui.PrintMessage("Quiting SecretShare")
-			return
-		}
-
-		// Extract public key from tags
-		publicKeyStr := tui.ExtractPublicKey(input)
-
-		// Check version prefix.
-		if len(publicKeyStr) >= 4 {
-			if publicKeyStr[0:4] == "ssv1" {
-				// Version prefix supported, strip it
-				publicKeyStr = publicKeyStr[4:]
-			} else if publicKeyStr[0:3] == "ssv" {
-				// Present but it has an unsupported version. The user needs to upgrade.
-				tui.PrintError("You need to upgrade SecretSend. This version is too old to handle this key.")
-				os.Exit(0)
-			}
-		}
-		// Decode base64 public key
-		publicKeyBytes, err := base64.StdEncoding.DecodeString(publicKeyStr)
-		// Parse public key
-		if err == nil {
-			receiverPublicKey, err = core.BytesToPublicKey(publicKeyBytes)
+			console.PrintMessage("Quiting SecretShare")
+			return ""
 		}
 
-		if err != nil || publicKeyStr == "" {
-			tui.PrintError("Could not extract public key from input.")
-			tui.PrintMessage("Ensure you are pasting the exact secret key from the sender. It should be a string wrapped in tags like '<secret_share_key>'.")
+		role := tui.ParseRoleInput(input)
+		if role == "" {
+			console.PrintError("Invalid input. Please enter 's' for sending or 'r' for receiving (or 'q' to quit).")
 			continue
 		}
 
-		break
-	}
-
-	// Create sender session
-	session := core.NewSenderSession(receiverPublicKey)
-
-	// Get secret to share
-	secret := tui.PromptSecret("Enter the secret you want to share: ")
-	if tui.IsQuit(secret) {
-		tui.PrintMessage("Quiting SecretShare")
-		return
-	}
-
-	// Encrypt the secret
-	encryptedSecret, err := session.EncryptSecret([]byte(secret))
-	if err != nil {
-		tui.PrintError(fmt.Sprintf("Failed to encrypt secret: %v", err))
-		return
-	}
-
-	// Encode encrypted secret as base64
-	encryptedSecretStr := base64.StdEncoding.EncodeToString(encryptedSecret)
-	encryptedSecretFormatted := core.FormatSecret([]byte(encryptedSecretStr))
-
-	// Display the encrypted secret for sharing
-	tui.PrintSuccess("Here's the secret encrypted so only they can decrypt it:")
-	tui.PrintMessage(encryptedSecretFormatted)
-
-	// Try to copy encrypted secret to clipboard
-	err = tui.SetClipboard(encryptedSecretFormatted)
-	if err == nil {
-		tui.PrintInfo("Copied to clipboard. Send this secret back to the person who shared their key with you.")
-	} else {
-		tui.PrintInfo("Send this secret back to the person who shared their key with you.")
+		return role
 	}
 }
@@ -0,0 +1,1449 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/scosman/secret_share/core"
+	"github.com/scosman/secret_share/tui"
+	"golang.org/x/crypto/ssh"
+)
+
+// strongPassword passes the sender's mistake checks without a warning
+const strongPassword = "Xk2#pQ9!vL7@mN4$wR8&"
+
+// runExchange runs a full receiver and sender conversation. The sender pastes the key the receiver
+// copied to their clipboard, then gives senderAnswers; the receiver pastes the encrypted secret the
+// sender copied, then gives receiverAnswers. Returns both consoles and the receiver's exit code.
+func runExchange(t *testing.T, opts receiverOptions, senderAnswers []string, receiverAnswers []string) (*tui.ScriptedConsole, *tui.ScriptedConsole, int) {
+	t.Helper()
+
+	sender := tui.NewScriptedConsole()
+	receiver := tui.NewScriptedConsole()
+	receiver.AnswerWith(func(string) string {
+		sender.Answer(receiver.Clipboard)
+		sender.Answer(senderAnswers...)
+		handleSender(sender, senderOptions{})
+		return sender.Clipboard
+	})
+	receiver.Answer(receiverAnswers...)
+
+	if opts.stdout == nil {
+		opts.stdout = &bytes.Buffer{}
+	}
+	code := handleReceiver(receiver, opts)
+	return sender, receiver, code
+}
+
+// newTestKey creates a receiver session and returns it with its key, as shared with the sender
+func newTestKey(t *testing.T) (*core.ReceiverSession, string) {
+	t.Helper()
+	session, err := core.NewReceiverSession()
+	if err != nil {
+		t.Fatalf("Failed to create receiver session: %v", err)
+	}
+	t.Cleanup(session.Destroy)
+	publicKeyBytes, err := core.PublicKeyToBytes(session.GetPublicKey())
+	if err != nil {
+		t.Fatalf("Failed to serialize public key: %v", err)
+	}
+	return session, core.FormatPublicKey([]byte(base64.StdEncoding.EncodeToString(publicKeyBytes)))
+}
+
+func TestExchangeSingleSecret(t *testing.T) {
+	sender, receiver, code := runExchange(t, receiverOptions{}, []string{"s", strongPassword}, nil)
+
+	if code != 0 {
+		t.Errorf("Expected exit code 0, got %d", code)
+	}
+	if !strings.Contains(sender.Clipboard, "<secret_share_secret>") {
+		t.Errorf("Expected the encrypted secret on the sender's clipboard, got '%s'", sender.Clipboard)
+	}
+	if !strings.Contains(receiver.Transcript(), "Here's your secret 🤫: "+strongPassword) {
+		t.Errorf("Receiver did not see the secret:\n%s", receiver.Transcript())
+	}
+	if strings.Contains(sender.Transcript(), strongPassword) {
+		t.Error("The secret should never be shown to the sender")
+	}
+	if len(sender.Unanswered) != 0 || len(receiver.Unanswered) != 0 {
+		t.Errorf("Unexpected prompts: sender %v, receiver %v", sender.Unanswered, receiver.Unanswered)
+	}
+}
+
+func TestExchangeSecretLikeShare(t *testing.T) {
+	// Test case 1: A typed secret starting like an old share payload is shown as it is
+	secret := "sss1-prod-password"
+	_, receiver, code := runExchange(t, receiverOptions{}, []string{"s", secret, "y"}, nil)
+	if code != 0 || !strings.Contains(receiver.Transcript(), "Here's your secret 🤫: "+secret) {
+		t.Errorf("Test 1 failed: Receiver did not see the secret:\n%s", receiver.Transcript())
+	}
+	if strings.Contains(receiver.Transcript(), "You received share") {
+		t.Errorf("Test 1 failed: Expected the secret not to be taken for a share:\n%s", receiver.Transcript())
+	}
+
+	// Test case 2: Secrets starting with the NUL byte that marks shares are refused
+	_, key := newTestKey(t)
+	sender := tui.NewScriptedConsole(key, "s", "\x00sss1"+strongPassword, "q")
+	handleSender(sender, senderOptions{})
+	if !strings.Contains(sender.Transcript(), "Error: Secrets can't start with a NUL byte") {
+		t.Errorf("Test 2 failed: Expected the secret to be refused:\n%s", sender.Transcript())
+	}
+}
+
+func TestExchangeSecretLikeFields(t *testing.T) {
+	// Test case 1: A typed secret starting like an old fields payload is shown as it is
+	secret := `ssf1{"fields":[{"key":"PASSWORD","value":"` + strongPassword + `"}]}`
+	_, receiver, code := runExchange(t, receiverOptions{}, []string{"s", secret}, nil)
+	if code != 0 || !strings.Contains(receiver.Transcript(), "Here's your secret 🤫: "+secret) {
+		t.Errorf("Test 1 failed: Receiver did not see the secret:\n%s", receiver.Transcript())
+	}
+
+	// Test case 2: Secrets starting with the NUL byte that marks fields are refused
+	_, key := newTestKey(t)
+	sender := tui.NewScriptedConsole(key, "s", "\x00"+secret, "q")
+	handleSender(sender, senderOptions{})
+	if !strings.Contains(sender.Transcript(), "Error: Secrets can't start with a NUL byte") {
+		t.Errorf("Test 2 failed: Expected the secret to be refused:\n%s", sender.Transcript())
+	}
+}
+
+func TestExchangeMultilineSecret(t *testing.T) {
+	secret := "-----BEGIN DATA-----\nline1\nline2\n-----END DATA-----\n"
+	_, receiver, _ := runExchange(t, receiverOptions{}, []string{"m", secret}, nil)
+
+	if !strings.Contains(receiver.Transcript(), "Here's your secret 🤫:\n"+secret) {
+		t.Errorf("Receiver did not see the exact multi-line secret:\n%s", receiver.Transcript())
+	}
+}
+
+func TestExchangeFields(t *testing.T) {
+	senderAnswers := []string{
+		"f", "", // enter fields by hand
+		"username", "admin",
+		"password", strongPassword,
+		"", // done
+	}
+	receiverAnswers := []string{"c", "password", "q"}
+	_, receiver, _ := runExchange(t, receiverOptions{}, senderAnswers, receiverAnswers)
+
+	transcript := receiver.Transcript()
+	if !strings.Contains(transcript, "username  admin") {
+		t.Errorf("Expected a table of fields:\n%s", transcript)
+	}
+	if receiver.Clipboard != strongPassword {
+		t.Errorf("Expected the password field on the clipboard, got '%s'", receiver.Clipboard)
+	}
+}
+
+func TestExchangeFieldsInvalidName(t *testing.T) {
+	// A name that isn't a valid environment variable is asked for again
+	senderAnswers := []string{
+		"f", "",
+		"api key", "API_KEY", strongPassword,
+		"",
+	}
+	sender, receiver, _ := runExchange(t, receiverOptions{}, senderAnswers, []string{"q"})
+
+	if !strings.Contains(sender.Transcript(), "'api key' is not a valid environment variable name.") {
+		t.Errorf("Expected the invalid name to be rejected:\n%s", sender.Transcript())
+	}
+	if !strings.Contains(sender.Transcript(), "Sharing 1 fields: API_KEY") {
+		t.Errorf("Expected only the valid field to be shared:\n%s", sender.Transcript())
+	}
+	if !strings.Contains(receiver.Transcript(), "API_KEY") {
+		t.Errorf("Receiver did not get the field:\n%s", receiver.Transcript())
+	}
+}
+
+func TestExchangeWeakSecretIsConfirmed(t *testing.T) {
+	// The sender is warned about a weak password, re-enters it, then accepts a second warning
+	senderAnswers := []string{"s", "hunter2", "n", "s", "password", "y"}
+	sender, receiver, _ := runExchange(t, receiverOptions{}, senderAnswers, nil)
+
+	if strings.Count(sender.Transcript(), "Warning: ") != 2 {
+		t.Errorf("Expected two warnings:\n%s", sender.Transcript())
+	}
+	if !strings.Contains(receiver.Transcript(), "Here's your secret 🤫: password") {
+		t.Errorf("Receiver did not get the confirmed secret:\n%s", receiver.Transcript())
+	}
+}
+
+func TestExchangeEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte("OTHER=1\n"), 0600); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	opts := receiverOptions{envFile: path, envName: "DB_PASSWORD"}
+	_, receiver, _ := runExchange(t, opts, []string{"s", strongPassword}, nil)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read .env file: %v", err)
+	}
+	expected := "OTHER=1\nDB_PASSWORD='" + strongPassword + "'\n"
+	if string(data) != expected {
+		t.Errorf("Expected .env contents %q, got %q", expected, string(data))
+	}
+	if strings.Contains(receiver.Transcript(), strongPassword) {
+		t.Error("The secret should not be displayed when written to a file")
+	}
+}
+
+func TestEnvFileWriteFailure(t *testing.T) {
+	// A .env file that can't be written exits non-zero, so scripts don't assume it was
+	opts := receiverOptions{envFile: filepath.Join(t.TempDir(), "missing", ".env"), envName: "DB_PASSWORD"}
+	_, receiver, code := runExchange(t, opts, []string{"s", strongPassword}, nil)
+	if code != 1 || !strings.Contains(receiver.Transcript(), "Error: The secret was not delivered: failed to write") {
+		t.Errorf("Expected exit code 1 and an error, got %d:\n%s", code, receiver.Transcript())
+	}
+}
+
+func TestRunWithSecret(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	testCases := []struct {
+		script string
+		code   int
+	}{
+		{`test "$TOKEN" = secret`, 0},
+		{"exit 3", 3},
+		{"kill -TERM $$", 128 + int(syscall.SIGTERM)},
+	}
+	for i, tc := range testCases {
+		console := tui.NewScriptedConsole()
+		opts := receiverOptions{execName: "TOKEN", execArgs: []string{"sh", "-c", tc.script}}
+		if code := runWithSecret(console, opts, []byte("secret")); code != tc.code {
+			t.Errorf("Test %d failed: Expected exit code %d, got %d:\n%s", i+1, tc.code, code, console.Transcript())
+		}
+	}
+}
+
+func TestExchangeKubernetesSecret(t *testing.T) {
+	var stdout bytes.Buffer
+	opts := receiverOptions{k8sSecret: "db-creds", k8sNamespace: "prod", envName: "password", stdout: &stdout}
+	runExchange(t, opts, []string{"s", strongPassword}, nil)
+
+	if !strings.Contains(stdout.String(), "kind: Secret") || !strings.Contains(stdout.String(), "  password: ") {
+		t.Errorf("Expected a Secret manifest on stdout, got:\n%s", stdout.String())
+	}
+}
+
+// failingWriter is a writer that always fails, like a closed pipe
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("broken pipe") }
+
+func TestKubernetesSecretWriteFailure(t *testing.T) {
+	// Test case 1: A manifest that can't be written to stdout exits non-zero
+	opts := receiverOptions{k8sSecret: "db-creds", envName: "password", stdout: failingWriter{}}
+	_, receiver, code := runExchange(t, opts, []string{"s", strongPassword}, nil)
+	if code != 1 || !strings.Contains(receiver.Transcript(), "Error: The secret was not delivered: failed to write to stdout: broken pipe") {
+		t.Errorf("Test 1 failed: Expected exit code 1 and an error, got %d:\n%s", code, receiver.Transcript())
+	}
+
+	// Test case 2: So does an output file that can't be written
+	opts = receiverOptions{k8sSecret: "db-creds", envName: "password", output: filepath.Join(t.TempDir(), "missing", "secret.yaml")}
+	_, receiver, code = runExchange(t, opts, []string{"s", strongPassword}, nil)
+	if code != 1 || !strings.Contains(receiver.Transcript(), "Error: The secret was not delivered: failed to write") {
+		t.Errorf("Test 2 failed: Expected exit code 1 and an error, got %d:\n%s", code, receiver.Transcript())
+	}
+}
+
+func TestSenderInvalidKey(t *testing.T) {
+	// Test case 1: Garbage input is rejected and the sender can retry or quit
+	sender := tui.NewScriptedConsole("not a key", "q")
+	handleSender(sender, senderOptions{})
+	if !strings.Contains(sender.Transcript(), "Error: Could not extract public key from input.") {
+		t.Errorf("Expected an invalid key error:\n%s", sender.Transcript())
+	}
+	if len(sender.Unanswered) != 0 {
+		t.Errorf("Unexpected prompts: %v", sender.Unanswered)
+	}
+
+	// Test case 2: Keys from a newer version ask the user to upgrade
+	sender = tui.NewScriptedConsole("<secret_share_key>ssv9AAAA</secret_share_key>")
+	handleSender(sender, senderOptions{})
+	if !strings.Contains(sender.Transcript(), "You need to upgrade") {
+		t.Errorf("Expected an upgrade error:\n%s", sender.Transcript())
+	}
+}
+
+func TestReceiverInvalidSecret(t *testing.T) {
+	receiver := tui.NewScriptedConsole("<secret_share_secret>bm90IGEgc2VjcmV0</secret_share_secret>", "q")
+	code := handleReceiver(receiver, receiverOptions{stdout: &bytes.Buffer{}})
+
+	if code != 0 {
+		t.Errorf("Expected exit code 0, got %d", code)
+	}
+	if !strings.Contains(receiver.Transcript(), "Error: Could not extract secret from input.") {
+		t.Errorf("Expected an invalid secret error:\n%s", receiver.Transcript())
+	}
+}
+
+// alterArmored swaps two characters in the first content line of an armored key or secret, as
+// a chat app might when it reformats a message
+func alterArmored(armored string) string {
+	lines := strings.Split(armored, "\n")
+	line := []byte(lines[1])
+	line[20], line[21] = line[21], line[20]
+	lines[1] = string(line)
+	return strings.Join(lines, "\n")
+}
+
+func TestArmorChecks(t *testing.T) {
+	// Test case 1: Secrets quoted by a chat or email reply still decrypt
+	receiver := tui.NewScriptedConsole()
+	sender := tui.NewScriptedConsole()
+	receiver.AnswerWith(func(string) string {
+		sender.Answer(receiver.Clipboard, "s", strongPassword)
+		handleSender(sender, senderOptions{})
+		return "> " + strings.ReplaceAll(sender.Clipboard, "\n", "\n> ")
+	})
+	handleReceiver(receiver, receiverOptions{stdout: &bytes.Buffer{}})
+	if !strings.Contains(receiver.Transcript(), "Here's your secret 🤫: "+strongPassword) {
+		t.Errorf("Test 1 failed: Receiver did not see the quoted secret:\n%s", receiver.Transcript())
+	}
+
+	// Test case 2: Altered secrets are reported, and the receiver can paste the secret again
+	receiver = tui.NewScriptedConsole()
+	sender = tui.NewScriptedConsole()
+	receiver.AnswerWith(func(string) string {
+		sender.Answer(receiver.Clipboard, "s", strongPassword)
+		handleSender(sender, senderOptions{})
+		return alterArmored(sender.Clipboard)
+	})
+	receiver.AnswerWith(func(string) string { return sender.Clipboard })
+	handleReceiver(receiver, receiverOptions{stdout: &bytes.Buffer{}})
+	if !strings.Contains(receiver.Transcript(), "Error: Checksum mismatch: message was altered in transit.") {
+		t.Errorf("Test 2 failed: Expected a checksum mismatch:\n%s", receiver.Transcript())
+	}
+	if !strings.Contains(receiver.Transcript(), "Here's your secret 🤫: "+strongPassword) {
+		t.Errorf("Test 2 failed: Receiver did not see the secret pasted again:\n%s", receiver.Transcript())
+	}
+
+	// Test case 3: Altered keys are reported to the sender before encrypting
+	_, key := newTestKey(t)
+	sender = tui.NewScriptedConsole(alterArmored(key), "q")
+	handleSender(sender, senderOptions{})
+	if !strings.Contains(sender.Transcript(), "Error: Checksum mismatch: message was altered in transit.") {
+		t.Errorf("Test 3 failed: Expected a checksum mismatch:\n%s", sender.Transcript())
+	}
+	if len(sender.Unanswered) != 0 {
+		t.Errorf("Test 3 failed: Unexpected prompts: %v", sender.Unanswered)
+	}
+}
+
+func TestExchangeQRCode(t *testing.T) {
+	dir := t.TempDir()
+	keyPNG, secretPNG := filepath.Join(dir, "key.png"), filepath.Join(dir, "my secret.png")
+
+	// Test case 1: The key and the secret are shown as QR codes, and sent as their PNGs
+	receiver := tui.NewScriptedConsole()
+	sender := tui.NewScriptedConsole()
+	receiver.AnswerWith(func(string) string {
+		sender.Answer(keyPNG, "s", strongPassword)
+		handleSender(sender, senderOptions{qr: true, qrPNG: secretPNG})
+		return `"` + secretPNG + `"`
+	})
+	code := handleReceiver(receiver, receiverOptions{hpke: true, qr: true, qrPNG: keyPNG, stdout: &bytes.Buffer{}})
+	if code != 0 || !strings.Contains(receiver.Transcript(), "Here's your secret 🤫: "+strongPassword) {
+		t.Errorf("Test 1 failed: Receiver did not see the secret:\n%s", receiver.Transcript())
+	}
+	for _, console := range []*tui.ScriptedConsole{receiver, sender} {
+		qr, err := core.EncodeQR([]byte(console.Clipboard))
+		if err != nil || !strings.Contains(console.Transcript(), qr.HalfBlocks()) {
+			t.Errorf("Test 1 failed: Expected the QR code of '%s' (err: %v):\n%s", console.Clipboard, err, console.Transcript())
+		}
+	}
+	if !strings.Contains(sender.Transcript(), "Read the QR code in "+keyPNG) {
+		t.Errorf("Test 1 failed: Expected the sender to read the key's QR code:\n%s", sender.Transcript())
+	}
+	if info, err := os.Stat(secretPNG); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Test 1 failed: Expected the secret's PNG to be private (err: %v)", err)
+	}
+
+	// Test case 2: Images without a QR code are reported, and the sender can paste the key instead
+	_, key := newTestKey(t)
+	sender = tui.NewScriptedConsole(filepath.Join(dir, "missing.png"), "q")
+	handleSender(sender, senderOptions{})
+	if !strings.Contains(sender.Transcript(), "Error: Could not read a QR code from") {
+		t.Errorf("Test 2 failed: Expected a QR code error:\n%s", sender.Transcript())
+	}
+	sender = tui.NewScriptedConsole(key, "s", strongPassword)
+	handleSender(sender, senderOptions{qr: true})
+	if strings.Contains(sender.Transcript(), "Could not make a QR code") {
+		t.Errorf("Test 2 failed: Expected an RSA secret to fit in a QR code:\n%s", sender.Transcript())
+	}
+}
+
+func TestQRImagePath(t *testing.T) {
+	testCases := []struct {
+		input string
+		path  string
+		ok    bool
+	}{
+		{"key.png", "key.png", true},
+		{" /tmp/Key.PNG\n", "/tmp/Key.PNG", true},
+		{"'/tmp/my key.png'", "/tmp/my key.png", true},
+		{`"/tmp/my key.png"`, "/tmp/my key.png", true},
+		{`/tmp/my\ key.png`, "/tmp/my key.png", true},
+		{"<secret_share_key>\nssv1AAAA\n</secret_share_key>", "", false},
+		{"key.png\nkey.png", "", false},
+		{"key.jpg", "", false},
+	}
+	for i, tc := range testCases {
+		if path, ok := qrImagePath(tc.input); path != tc.path || ok != tc.ok {
+			t.Errorf("Test %d failed: Expected '%s' (%v), got '%s' (%v)", i+1, tc.path, tc.ok, path, ok)
+		}
+	}
+}
+
+// shownWords returns the words of the key or secret console copied, after checking it showed them
+func shownWords(t *testing.T, console *tui.ScriptedConsole, key bool) string {
+	t.Helper()
+	var words string
+	var err error
+	if key {
+		var content string
+		if content, err = core.Dearmor(tui.ExtractPublicKey(console.Clipboard)); err == nil {
+			words, err = core.FormatKeyWords(content)
+		}
+	} else {
+		words, err = core.FormatSecretWords(decodeClipboard(t, console.Clipboard))
+	}
+	if err != nil || !strings.Contains(console.Transcript(), words) {
+		t.Fatalf("Expected the words of '%s' (err: %v):\n%s", console.Clipboard, err, console.Transcript())
+	}
+	return words
+}
+
+// typedWords returns words as typed from a printout: without line numbers, and with the last
+// letter of the first word doubled
+func typedWords(words string) string {
+	list := strings.FieldsFunc(words, func(r rune) bool { return r < 'a' || r > 'z' })
+	list[0] += list[0][len(list[0])-1:]
+	return strings.Join(list, " ")
+}
+
+func TestExchangeWords(t *testing.T) {
+	// Test case 1: The key and the secret are read out as words, and typed in with a misspelling
+	receiver := tui.NewScriptedConsole()
+	sender := tui.NewScriptedConsole()
+	receiver.AnswerWith(func(string) string {
+		sender.Answer(typedWords(shownWords(t, receiver, true)), "s", strongPassword)
+		handleSender(sender, senderOptions{words: true})
+		return typedWords(shownWords(t, sender, false))
+	})
+	code := handleReceiver(receiver, receiverOptions{hpke: true, words: true, stdout: &bytes.Buffer{}})
+	if code != 0 || !strings.Contains(receiver.Transcript(), "Here's your secret 🤫: "+strongPassword) {
+		t.Errorf("Test 1 failed: Receiver did not see the secret:\n%s", receiver.Transcript())
+	}
+	for _, console := range []*tui.ScriptedConsole{receiver, sender} {
+		if !strings.Contains(console.Transcript(), "Read the words, and their checksum word matches.") {
+			t.Errorf("Test 1 failed: Expected the words to be read:\n%s", console.Transcript())
+		}
+	}
+
+	// Test case 2: Wrong and unknown words are reported, and the sender can enter them again
+	session, err := core.NewHPKEReceiverSession()
+	if err != nil {
+		t.Fatalf("Failed to create HPKE session: %v", err)
+	}
+	defer session.Destroy()
+	receiver = tui.NewScriptedConsole()
+	showPublicKey(receiver, receiverOptions{hpke: true, words: true}, session)
+	list := strings.Fields(typedWords(shownWords(t, receiver, true)))
+	list[0], list[1] = list[1], list[0]
+	sender = tui.NewScriptedConsole(strings.Join(list, " "), "xyzzyq "+strings.Join(list[1:], " "), "q")
+	handleSender(sender, senderOptions{})
+	for _, expected := range []string{
+		"Error: Could not read the words: the words don't match their checksum word",
+		"Error: Could not read the words: unknown word: xyzzyq",
+	} {
+		if !strings.Contains(sender.Transcript(), expected) {
+			t.Errorf("Test 2 failed: Expected '%s':\n%s", expected, sender.Transcript())
+		}
+	}
+
+	// Test case 3: Secrets encrypted to RSA keys are too long to read out
+	_, key := newTestKey(t)
+	sender = tui.NewScriptedConsole(key, "s", strongPassword)
+	handleSender(sender, senderOptions{words: true})
+	if !strings.Contains(sender.Transcript(), "Warning: Could not show it as words: too long to read as words.") {
+		t.Errorf("Test 3 failed: Expected the secret to be too long for words:\n%s", sender.Transcript())
+	}
+}
+
+func TestGetUserRole(t *testing.T) {
+	console := tui.NewScriptedConsole("x", "r")
+	if role := getUserRole(console); role != "receiver" {
+		t.Errorf("Expected 'receiver', got '%s'", role)
+	}
+	if !strings.Contains(console.Transcript(), "Error: Invalid input.") {
+		t.Errorf("Expected an invalid input error:\n%s", console.Transcript())
+	}
+
+	console = tui.NewScriptedConsole("q")
+	if role := getUserRole(console); role != "" {
+		t.Errorf("Expected no role after quitting, got '%s'", role)
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	// Test case 1: No arguments is interactive
+	role, _, _, err := parseArgs(nil)
+	if err != nil || role != "" {
+		t.Errorf("Expected interactive mode, got role '%s' (err: %v)", role, err)
+	}
+
+	// Test case 2: Exec mode
+	role, opts, _, err := parseArgs([]string{"receive", "--exec", "TOKEN", "--", "env", "-u", "HOME"})
+	if err != nil {
+		t.Fatalf("Failed to parse exec args: %v", err)
+	}
+	if role != "receiver" || opts.execName != "TOKEN" || strings.Join(opts.execArgs, " ") != "env -u HOME" {
+		t.Errorf("Unexpected exec options: %+v", opts)
+	}
+
+	// Test case 3: Passphrase mode for either side
+	role, _, senderOpts, err := parseArgs([]string{"send", "--passphrase"})
+	if err != nil || role != "sender" || !senderOpts.passphrase {
+		t.Errorf("Expected sender passphrase mode, got role '%s' %+v (err: %v)", role, senderOpts, err)
+	}
+	role, opts, _, err = parseArgs([]string{"receive", "--passphrase", "--env-file", ".env"})
+	if err != nil || role != "receiver" || !opts.passphrase || opts.envFile != ".env" {
+		t.Errorf("Expected receiver passphrase mode, got role '%s' %+v (err: %v)", role, opts, err)
+	}
+
+	// Test case 4: Splitting needs 2 shares unless a threshold is given
+	_, _, senderOpts, err = parseArgs([]string{"send", "--split", "3"})
+	if err != nil || senderOpts.split != 3 || senderOpts.threshold != defaultThreshold {
+		t.Errorf("Expected a 2 of 3 split, got %+v (err: %v)", senderOpts, err)
+	}
+
+	// Test case 5: SSH key mode for either side
+	role, _, senderOpts, err = parseArgs([]string{"send", "--ssh-key", "id_ed25519.pub"})
+	if err != nil || role != "sender" || senderOpts.sshKey != "id_ed25519.pub" {
+		t.Errorf("Expected sender SSH key mode, got role '%s' %+v (err: %v)", role, senderOpts, err)
+	}
+	role, opts, _, err = parseArgs([]string{"receive", "--ssh-key", "id_ed25519"})
+	if err != nil || role != "receiver" || opts.sshKey != "id_ed25519" {
+		t.Errorf("Expected receiver SSH key mode, got role '%s' %+v (err: %v)", role, opts, err)
+	}
+
+	// Test case 6: A one-time age identity for the receiver
+	_, opts, _, err = parseArgs([]string{"receive", "--age", "--combine"})
+	if err != nil || !opts.age || !opts.combine {
+		t.Errorf("Expected receiver age mode, got %+v (err: %v)", opts, err)
+	}
+
+	// Test case 7: JWE output for the sender, and a JWK for the receiver
+	_, _, senderOpts, err = parseArgs([]string{"send", "--jwe"})
+	if err != nil || !senderOpts.jwe {
+		t.Errorf("Expected sender JWE mode, got %+v (err: %v)", senderOpts, err)
+	}
+	_, opts, _, err = parseArgs([]string{"receive", "--jwe"})
+	if err != nil || !opts.jwe {
+		t.Errorf("Expected receiver JWK mode, got %+v (err: %v)", opts, err)
+	}
+
+	// Test case 8: A one-time HPKE key for the receiver
+	_, opts, _, err = parseArgs([]string{"receive", "--hpke", "--combine"})
+	if err != nil || !opts.hpke || !opts.combine {
+		t.Errorf("Expected receiver HPKE mode, got %+v (err: %v)", opts, err)
+	}
+
+	// Test case 9: QR codes for either side
+	_, opts, senderOpts, err = parseArgs([]string{"send", "--qr", "--qr-png", "secret.png"})
+	if err != nil || !senderOpts.qr || senderOpts.qrPNG != "secret.png" {
+		t.Errorf("Expected sender QR codes, got %+v (err: %v)", senderOpts, err)
+	}
+	_, opts, _, err = parseArgs([]string{"receive", "--hpke", "--qr"})
+	if err != nil || !opts.qr || !opts.hpke {
+		t.Errorf("Expected receiver QR code, got %+v (err: %v)", opts, err)
+	}
+
+	// Test case 10: Words for either side
+	_, opts, senderOpts, err = parseArgs([]string{"send", "--words"})
+	if err != nil || !senderOpts.words {
+		t.Errorf("Expected sender words, got %+v (err: %v)", senderOpts, err)
+	}
+	_, opts, _, err = parseArgs([]string{"receive", "--hpke", "--words"})
+	if err != nil || !opts.words || !opts.hpke {
+		t.Errorf("Expected receiver words, got %+v (err: %v)", opts, err)
+	}
+
+	// Test case 11: Invalid combinations
+	invalid := [][]string{
+		{"unknown"},
+		{"send", "--exec", "A"},
+		{"send", "--passphrase", "extra"},
+		{"send", "--split", "1"},
+		{"send", "--split", "3", "--threshold", "4"},
+		{"send", "--threshold", "2"},
+		{"send", "--split", "3", "--passphrase"},
+		{"receive", "--combine", "--passphrase"},
+		{"send", "--ssh-key", "id.pub", "--passphrase"},
+		{"send", "--ssh-key", "id.pub", "--split", "3"},
+		{"receive", "--ssh-key", "id", "--combine"},
+		{"receive", "--ssh-key", "id", "--passphrase"},
+		{"receive", "--age", "--passphrase"},
+		{"receive", "--age", "--ssh-key", "id"},
+		{"send", "--jwe", "--passphrase"},
+		{"send", "--jwe", "--ssh-key", "id.pub"},
+		{"send", "--jwe", "--split", "3"},
+		{"receive", "--jwe", "--age"},
+		{"receive", "--jwe", "--passphrase"},
+		{"receive", "--hpke", "--passphrase"},
+		{"receive", "--hpke", "--ssh-key", "id"},
+		{"receive", "--hpke", "--age"},
+		{"receive", "--hpke", "--jwe"},
+		{"send", "--qr-png", "secret.png", "--split", "3"},
+		{"receive", "--qr", "--passphrase"},
+		{"receive", "--qr-png", "key.png", "--ssh-key", "id"},
+		{"send", "--words", "--jwe"},
+		{"send", "--words", "--split", "3"},
+		{"receive", "--words"},
+		{"receive", "--words", "--age"},
+		{"receive", "--exec", "A"},
+		{"receive", "--exec", "1A", "--", "cmd"},
+		{"receive", "--exec", "A", "--env-file", ".env", "--", "cmd"},
+		{"receive", "--k8s-secret", "Bad_Name"},
+		{"receive", "--namespace", "prod"},
+		{"receive", "extra"},
+	}
+	for _, args := range invalid {
+		if _, _, _, err := parseArgs(args); err == nil {
+			t.Errorf("Expected error for args %v", args)
+		}
+	}
+}
+
+func TestParseGlobalArgs(t *testing.T) {
+	// Test case 1: Flags after the command are left for the command
+	global, rest, err := parseGlobalArgs([]string{"receive", "-v"})
+	if err != nil || global.verbose || len(rest) != 2 {
+		t.Errorf("Test 1 failed: Expected flags after the command to be left alone, got %+v %v (err: %v)", global, rest, err)
+	}
+	if global.timeout != defaultTimeout {
+		t.Errorf("Test 1 failed: Expected the default timeout, got %v", global.timeout)
+	}
+
+	// Test case 2: Verbose before the command
+	global, rest, err = parseGlobalArgs([]string{"--verbose", "send"})
+	if err != nil || !global.verbose || len(rest) != 1 || rest[0] != "send" {
+		t.Errorf("Test 2 failed: Expected verbose send, got %+v %v (err: %v)", global, rest, err)
+	}
+
+	// Test case 3: Verbose on its own
+	global, rest, err = parseGlobalArgs([]string{"-v"})
+	if err != nil || !global.verbose || len(rest) != 0 {
+		t.Errorf("Test 3 failed: Expected verbose interactive mode, got %+v %v (err: %v)", global, rest, err)
+	}
+
+	// Test case 4: Timeouts, including none
+	global, rest, err = parseGlobalArgs([]string{"--timeout", "5m", "-v", "receive", "--name", "TOKEN"})
+	if err != nil || global.timeout != 5*time.Minute || !global.verbose || len(rest) != 3 {
+		t.Errorf("Test 4 failed: Expected a 5 minute timeout, got %+v %v (err: %v)", global, rest, err)
+	}
+	global, _, err = parseGlobalArgs([]string{"--timeout=0"})
+	if err != nil || global.timeout != 0 {
+		t.Errorf("Test 4 failed: Expected no timeout, got %+v (err: %v)", global, err)
+	}
+
+	// Test case 5: Invalid timeouts
+	for _, args := range [][]string{{"--timeout", "soon"}, {"--timeout", "-1m"}, {"--timeout"}} {
+		if _, _, err := parseGlobalArgs(args); err == nil {
+			t.Errorf("Test 5 failed: Expected error for args %v", args)
+		}
+	}
+}
+
+func TestReceiverSessionTimeout(t *testing.T) {
+	// Test case 1: The prompt times out, and the receiver exits explaining the key was wiped
+	receiver := tui.NewScriptedConsole()
+	receiver.AnswerTimeout()
+	code := handleReceiver(receiver, receiverOptions{timeout: 15 * time.Minute, stdout: &bytes.Buffer{}})
+	if code != 1 {
+		t.Errorf("Test 1 failed: Expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(receiver.Transcript(), "Error: This session expired after 15m0s, and its key was wiped.") {
+		t.Errorf("Test 1 failed: Expected an expiry message:\n%s", receiver.Transcript())
+	}
+	if strings.Contains(receiver.Transcript(), "Quiting SecretShare") {
+		t.Errorf("Test 1 failed: A timeout should not be reported as quitting:\n%s", receiver.Transcript())
+	}
+
+	// Test case 2: A secret pasted after the session expired isn't decrypted
+	session, err := core.NewReceiverSession()
+	if err != nil {
+		t.Fatalf("Failed to create receiver session: %v", err)
+	}
+	encrypted, err := core.HybridEncrypt(session.GetPublicKey(), []byte(strongPassword))
+	if err != nil {
+		t.Fatalf("Failed to encrypt secret: %v", err)
+	}
+	opts := receiverOptions{timeout: 50 * time.Millisecond}
+	session.SetLifetime(opts.timeout)
+	receiver = tui.NewScriptedConsole()
+	receiver.AnswerWith(func(string) string {
+		time.Sleep(100 * time.Millisecond)
+		return core.FormatSecret([]byte(base64.StdEncoding.EncodeToString(encrypted)))
+	})
+	code = receiveSecret(receiver, opts, session)
+	if code != 1 {
+		t.Errorf("Test 2 failed: Expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(receiver.Transcript(), "This session expired after 50ms") {
+		t.Errorf("Test 2 failed: Expected an expiry message:\n%s", receiver.Transcript())
+	}
+	if strings.Contains(receiver.Transcript(), strongPassword) {
+		t.Errorf("Test 2 failed: The secret should not be decrypted after expiry:\n%s", receiver.Transcript())
+	}
+}
+
+func TestReceiverDeadlineClearedAfterSecret(t *testing.T) {
+	// Once the secret is decrypted, the receiver can take their time with the fields
+	sender, receiver, code := runExchange(t, receiverOptions{timeout: time.Hour},
+		[]string{"f", "", "username", "admin", "", "y"}, []string{"c", "username", "q"})
+	if code != 0 {
+		t.Errorf("Expected exit code 0, got %d", code)
+	}
+	if receiver.TimedOut() || sender.TimedOut() {
+		t.Error("Expected no timeouts")
+	}
+	if receiver.Clipboard != "admin" {
+		t.Errorf("Expected the field to be copied, got '%s':\n%s", receiver.Clipboard, receiver.Transcript())
+	}
+}
+
+func TestSenderSecretTimeout(t *testing.T) {
+	_, key := newTestKey(t)
+
+	// Test case 1: The sender's secret prompt times out, and nothing is encrypted
+	sender := tui.NewScriptedConsole(key, "s")
+	sender.AnswerTimeout()
+	handleSender(sender, senderOptions{timeout: 10 * time.Minute})
+	if !strings.Contains(sender.Transcript(), "Error: No secret was entered within 10m0s, so nothing was encrypted.") {
+		t.Errorf("Test 1 failed: Expected a timeout message:\n%s", sender.Transcript())
+	}
+	if sender.Clipboard != "" {
+		t.Errorf("Test 1 failed: Expected nothing to be encrypted, got '%s'", sender.Clipboard)
+	}
+
+	// Test case 2: The timeout covers the whole secret, such as a set of fields
+	sender = tui.NewScriptedConsole(key, "f", "", "username")
+	sender.AnswerTimeout()
+	sender.Answer("password", "hunter2", "")
+	handleSender(sender, senderOptions{timeout: 10 * time.Minute})
+	if !strings.Contains(sender.Transcript(), "nothing was encrypted") || sender.Clipboard != "" {
+		t.Errorf("Test 2 failed: Expected the fields to time out:\n%s", sender.Transcript())
+	}
+
+	// Test case 3: The sender has no limit while entering the key
+	sender = tui.NewScriptedConsole(key, "s", strongPassword)
+	handleSender(sender, senderOptions{timeout: 10 * time.Minute})
+	if sender.TimedOut() || !strings.Contains(sender.Clipboard, "<secret_share_secret>") {
+		t.Errorf("Test 3 failed: Expected the secret to be encrypted:\n%s", sender.Transcript())
+	}
+}
+
+func TestExchangePassphrase(t *testing.T) {
+	passphrase := "violet anchor gravel tundra"
+
+	// Test case 1: The secret is decrypted with the agreed passphrase, after a wrong guess
+	sender := tui.NewScriptedConsole(passphrase, passphrase, "s", strongPassword)
+	handleSender(sender, senderOptions{passphrase: true})
+	if !strings.Contains(sender.Clipboard, "<secret_share_secret>") {
+		t.Fatalf("Test 1 failed: Expected the encrypted secret on the sender's clipboard:\n%s", sender.Transcript())
+	}
+	if strings.Contains(sender.Transcript(), "<secret_share_key>") || !strings.Contains(sender.Transcript(), "Never send the passphrase") {
+		t.Errorf("Test 1 failed: Expected no key exchange:\n%s", sender.Transcript())
+	}
+	receiver := tui.NewScriptedConsole(sender.Clipboard, "wrong guess", passphrase)
+	code := handleReceiver(receiver, receiverOptions{passphrase: true})
+	if code != 0 {
+		t.Errorf("Test 1 failed: Expected exit code 0, got %d", code)
+	}
+	transcript := receiver.Transcript()
+	if !strings.Contains(transcript, "Error: Wrong passphrase") || !strings.Contains(transcript, "Here's your secret 🤫: "+strongPassword) {
+		t.Errorf("Test 1 failed: Expected the secret after the right passphrase:\n%s", transcript)
+	}
+	if strings.Contains(transcript, "<secret_share_key>") {
+		t.Errorf("Test 1 failed: Expected no key to be generated:\n%s", transcript)
+	}
+	if len(sender.Unanswered) != 0 || len(receiver.Unanswered) != 0 {
+		t.Errorf("Test 1 failed: Unexpected prompts: sender %v, receiver %v", sender.Unanswered, receiver.Unanswered)
+	}
+
+	// Test case 2: A receiver waiting with a key is asked for the passphrase instead
+	receiver = tui.NewScriptedConsole()
+	receiver.AnswerWith(func(string) string { return sender.Clipboard })
+	receiver.Answer(passphrase)
+	code = handleReceiver(receiver, receiverOptions{})
+	if code != 0 || !strings.Contains(receiver.Transcript(), "Here's your secret 🤫: "+strongPassword) {
+		t.Errorf("Test 2 failed: Expected the secret after the passphrase:\n%s", receiver.Transcript())
+	}
+}
+
+func TestSenderPassphraseChecks(t *testing.T) {
+	passphrase := "violet anchor gravel tundra"
+
+	// Test case 1: Mismatched and empty passphrases are entered again
+	sender := tui.NewScriptedConsole("", passphrase, "violet anchor", passphrase, passphrase, "s", strongPassword)
+	handleSender(sender, senderOptions{passphrase: true})
+	transcript := sender.Transcript()
+	if !strings.Contains(transcript, "Error: The passphrase can't be empty.") || !strings.Contains(transcript, "Error: The passphrases don't match.") {
+		t.Errorf("Test 1 failed: Expected both mistakes to be reported:\n%s", transcript)
+	}
+	if !strings.Contains(sender.Clipboard, "<secret_share_secret>") {
+		t.Errorf("Test 1 failed: Expected the secret to be encrypted:\n%s", transcript)
+	}
+
+	// Test case 2: A weak passphrase is replaced when the sender declines it
+	sender = tui.NewScriptedConsole("hunter2", "hunter2", "n", passphrase, passphrase, "s", strongPassword)
+	handleSender(sender, senderOptions{passphrase: true})
+	if !strings.Contains(sender.Transcript(), "Warning: This passphrase is weak.") || sender.Clipboard == "" {
+		t.Errorf("Test 2 failed: Expected a warning, then the secret to be encrypted:\n%s", sender.Transcript())
+	}
+	secret, err := core.PassphraseDecrypt([]byte(passphrase), decodeClipboard(t, sender.Clipboard))
+	if err != nil || string(secret) != strongPassword {
+		t.Errorf("Test 2 failed: Expected the second passphrase to be used, got '%s' (err: %v)", secret, err)
+	}
+
+	// Test case 3: Quitting at the passphrase encrypts nothing
+	sender = tui.NewScriptedConsole("q")
+	handleSender(sender, senderOptions{passphrase: true})
+	if sender.Clipboard != "" || !strings.Contains(sender.Transcript(), "Quiting SecretShare") {
+		t.Errorf("Test 3 failed: Expected the sender to quit:\n%s", sender.Transcript())
+	}
+}
+
+func TestReceiverPassphraseRejectsKeySecret(t *testing.T) {
+	_, key := newTestKey(t)
+	sender := tui.NewScriptedConsole(key, "s", strongPassword)
+	handleSender(sender, senderOptions{})
+
+	// A secret encrypted to a key can't be decrypted with a passphrase
+	receiver := tui.NewScriptedConsole(sender.Clipboard, "q")
+	code := handleReceiver(receiver, receiverOptions{passphrase: true})
+	if code != 0 {
+		t.Errorf("Expected exit code 0, got %d", code)
+	}
+	if !strings.Contains(receiver.Transcript(), "Error: This secret was encrypted to a key, not a passphrase.") {
+		t.Errorf("Expected the receiver to be told to use a key:\n%s", receiver.Transcript())
+	}
+}
+
+// newTestSSHKey writes a private key to a key file as ssh-keygen would, and returns its path and
+// the key's authorized_keys line
+func newTestSSHKey(t *testing.T, privateKey interface{}, passphrase string) (string, string) {
+	t.Helper()
+	var block *pem.Block
+	var err error
+	if passphrase == "" {
+		block, err = ssh.MarshalPrivateKey(privateKey, "receiver@example")
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(privateKey, "receiver@example", []byte(passphrase))
+	}
+	if err != nil {
+		t.Fatalf("Failed to serialize SSH key: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "id")
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("Failed to write SSH key: %v", err)
+	}
+
+	signer, err := ssh.NewSignerFromKey(privateKey)
+	if err != nil {
+		t.Fatalf("Failed to read SSH key: %v", err)
+	}
+	return path, strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey()))) + " receiver@example"
+}
+
+func TestExchangeSSHKey(t *testing.T) {
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	for _, privateKey := range []interface{}{ed25519Key, rsaKey} {
+		keyPath, line := newTestSSHKey(t, privateKey, "")
+
+		// Test case 1: The sender pastes the receiver's SSH public key, and the receiver decrypts
+		// with their private key without generating a key
+		sender := tui.NewScriptedConsole(line, "s", strongPassword)
+		handleSender(sender, senderOptions{})
+		if !strings.Contains(sender.Transcript(), "receive --ssh-key PATH") {
+			t.Errorf("Test 1 failed: Expected the sender to explain how the secret is received:\n%s", sender.Transcript())
+		}
+		receiver := tui.NewScriptedConsole(sender.Clipboard)
+		code := handleReceiver(receiver, receiverOptions{sshKey: keyPath})
+		if code != 0 {
+			t.Errorf("Test 1 failed: Expected exit code 0, got %d", code)
+		}
+		transcript := receiver.Transcript()
+		if !strings.Contains(transcript, "Here's your secret 🤫: "+strongPassword) || strings.Contains(transcript, "<secret_share_key>") {
+			t.Errorf("Test 1 failed: Expected the secret without a new key:\n%s", transcript)
+		}
+		if !strings.Contains(transcript, strings.TrimSuffix(line, " receiver@example")) {
+			t.Errorf("Test 1 failed: Expected the receiver to see which SSH key is used:\n%s", transcript)
+		}
+
+		// Test case 2: The sender reads the key from the receiver's .pub file
+		pubPath := filepath.Join(t.TempDir(), "id.pub")
+		if err := os.WriteFile(pubPath, []byte("# receiver\n"+line+"\n"), 0644); err != nil {
+			t.Fatalf("Failed to write public key: %v", err)
+		}
+		sender = tui.NewScriptedConsole("s", strongPassword)
+		handleSender(sender, senderOptions{sshKey: pubPath})
+		receiver = tui.NewScriptedConsole(sender.Clipboard)
+		code = handleReceiver(receiver, receiverOptions{sshKey: keyPath})
+		if code != 0 || !strings.Contains(receiver.Transcript(), "Here's your secret 🤫: "+strongPassword) {
+			t.Errorf("Test 2 failed: Expected the secret:\n%s\n%s", sender.Transcript(), receiver.Transcript())
+		}
+		if len(sender.Unanswered) != 0 || len(receiver.Unanswered) != 0 {
+			t.Errorf("Test 2 failed: Unexpected prompts: sender %v, receiver %v", sender.Unanswered, receiver.Unanswered)
+		}
+	}
+}
+
+func TestReceiverSSHKeyChecks(t *testing.T) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	keyPath, line := newTestSSHKey(t, privateKey, "key passphrase")
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	_, otherLine := newTestSSHKey(t, otherKey, "")
+
+	sender := tui.NewScriptedConsole(line, "s", strongPassword)
+	handleSender(sender, senderOptions{})
+	forKey := sender.Clipboard
+	sender = tui.NewScriptedConsole(otherLine, "s", strongPassword)
+	handleSender(sender, senderOptions{})
+	forOtherKey := sender.Clipboard
+
+	// Test case 1: The key's passphrase is asked for until it's right, and a secret for another
+	// key is rejected
+	receiver := tui.NewScriptedConsole("wrong", "key passphrase", forOtherKey, forKey)
+	code := handleReceiver(receiver, receiverOptions{sshKey: keyPath})
+	if code != 0 {
+		t.Errorf("Test 1 failed: Expected exit code 0, got %d", code)
+	}
+	transcript := receiver.Transcript()
+	if !strings.Contains(transcript, "Error: Wrong passphrase for the SSH key.") || !strings.Contains(transcript, "Error: This secret was encrypted to a different key") {
+		t.Errorf("Test 1 failed: Expected both mistakes to be reported:\n%s", transcript)
+	}
+	if !strings.Contains(transcript, "Here's your secret 🤫: "+strongPassword) {
+		t.Errorf("Test 1 failed: Expected the secret:\n%s", transcript)
+	}
+
+	// Test case 2: A receiver waiting with a new key is told to use their SSH key
+	receiver = tui.NewScriptedConsole()
+	receiver.AnswerWith(func(string) string { return forKey })
+	receiver.Answer("q")
+	code = handleReceiver(receiver, receiverOptions{stdout: &bytes.Buffer{}})
+	if code != 0 || !strings.Contains(receiver.Transcript(), "Error: This secret was encrypted to an SSH key") {
+		t.Errorf("Test 2 failed: Expected the receiver to be told to use their SSH key:\n%s", receiver.Transcript())
+	}
+
+	// Test case 3: A missing key file
+	receiver = tui.NewScriptedConsole()
+	handleReceiver(receiver, receiverOptions{sshKey: filepath.Join(t.TempDir(), "missing")})
+	if !strings.Contains(receiver.Transcript(), "Error: Could not read the SSH private key") || len(receiver.Unanswered) != 0 {
+		t.Errorf("Test 3 failed: Expected an error without prompts:\n%s", receiver.Transcript())
+	}
+}
+
+func TestSenderSSHKeyChecks(t *testing.T) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	_, line := newTestSSHKey(t, privateKey, "")
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	_, ecdsaLine := newTestSSHKey(t, ecdsaKey, "")
+
+	// Test case 1: Unsupported SSH keys are rejected until a supported one is pasted
+	sender := tui.NewScriptedConsole(ecdsaLine, line, "s", strongPassword)
+	handleSender(sender, senderOptions{})
+	if !strings.Contains(sender.Transcript(), "Error: Could not use the SSH public key") || !strings.Contains(sender.Clipboard, "<secret_share_secret>") {
+		t.Errorf("Test 1 failed: Expected the ecdsa key to be rejected, then the secret encrypted:\n%s", sender.Transcript())
+	}
+
+	// Test case 2: A file with several keys doesn't say which receiver to use
+	path := filepath.Join(t.TempDir(), "authorized_keys")
+	if err := os.WriteFile(path, []byte(line+"\n"+ecdsaLine+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write public keys: %v", err)
+	}
+	sender = tui.NewScriptedConsole()
+	handleSender(sender, senderOptions{sshKey: path})
+	if !strings.Contains(sender.Transcript(), "found 2 keys") || len(sender.Unanswered) != 0 {
+		t.Errorf("Test 2 failed: Expected an error without prompts:\n%s", sender.Transcript())
+	}
+}
+
+// ageArmorPattern matches an armored age file in a transcript
+var ageArmorPattern = regexp.MustCompile(`(?s)-----BEGIN AGE ENCRYPTED FILE-----.*?-----END AGE ENCRYPTED FILE-----`)
+
+func TestExchangeAge(t *testing.T) {
+	// Test case 1: The receiver shares a one-time age recipient, and the sender encrypts to it
+	sender, receiver, code := runExchange(t, receiverOptions{age: true}, []string{"s", strongPassword}, nil)
+	if code != 0 || !strings.Contains(receiver.Transcript(), "Here's your secret 🤫: "+strongPassword) {
+		t.Errorf("Test 1 failed: Expected the secret:\n%s", receiver.Transcript())
+	}
+	if !core.IsAgeRecipient(receiver.Clipboard) || !strings.Contains(receiver.Transcript(), "Here's a new age recipient:") {
+		t.Errorf("Test 1 failed: Expected an age recipient on the receiver's clipboard, got '%s'", receiver.Clipboard)
+	}
+	if !core.IsAgeSecret(decodeClipboard(t, sender.Clipboard)) || !ageArmorPattern.MatchString(sender.Transcript()) {
+		t.Errorf("Test 1 failed: Expected an age file, also shown armored for age:\n%s", sender.Transcript())
+	}
+
+	// Test case 2: The recipient can be pasted in tags, and the armored age file decrypts too
+	sender = tui.NewScriptedConsole()
+	receiver = tui.NewScriptedConsole()
+	receiver.AnswerWith(func(string) string {
+		sender.Answer("<secret_share_key>"+receiver.Clipboard+"</secret_share_key>", "s", strongPassword)
+		handleSender(sender, senderOptions{})
+		return ageArmorPattern.FindString(sender.Transcript())
+	})
+	code = handleReceiver(receiver, receiverOptions{age: true, stdout: &bytes.Buffer{}})
+	if code != 0 || !strings.Contains(receiver.Transcript(), "Here's your secret 🤫: "+strongPassword) {
+		t.Errorf("Test 2 failed: Expected the secret from the armored file:\n%s\n%s", sender.Transcript(), receiver.Transcript())
+	}
+	if len(sender.Unanswered) != 0 || len(receiver.Unanswered) != 0 {
+		t.Errorf("Test 2 failed: Unexpected prompts: sender %v, receiver %v", sender.Unanswered, receiver.Unanswered)
+	}
+}
+
+func TestAgeChecks(t *testing.T) {
+	ageSession, err := core.NewAgeReceiverSession()
+	if err != nil {
+		t.Fatalf("Failed to create age session: %v", err)
+	}
+	defer ageSession.Destroy()
+	recipient := ageSession.AgeRecipient()
+	_, key := newTestKey(t)
+
+	// Test case 1: An age recipient with a bad checksum is rejected until a valid one is pasted
+	corrupted := recipient[:len(recipient)-1] + "q"
+	if corrupted == recipient {
+		corrupted = recipient[:len(recipient)-1] + "p"
+	}
+	sender := tui.NewScriptedConsole(corrupted, recipient, "s", strongPassword)
+	handleSender(sender, senderOptions{})
+	if !strings.Contains(sender.Transcript(), "Error: Could not use the age recipient") || !strings.Contains(sender.Clipboard, "<secret_share_secret>") {
+		t.Errorf("Test 1 failed: Expected the bad recipient to be rejected, then the secret encrypted:\n%s", sender.Transcript())
+	}
+	forAge := sender.Clipboard
+	sender = tui.NewScriptedConsole(key, "s", strongPassword)
+	handleSender(sender, senderOptions{})
+	forKey := sender.Clipboard
+
+	// Test case 2: A receiver waiting with a new key is told the secret was encrypted with age
+	receiver := tui.NewScriptedConsole()
+	receiver.AnswerWith(func(string) string { return forAge })
+	receiver.Answer("q")
+	code := handleReceiver(receiver, receiverOptions{stdout: &bytes.Buffer{}})
+	if code != 0 || !strings.Contains(receiver.Transcript(), "Error: This secret was encrypted with age") {
+		t.Errorf("Test 2 failed: Expected the receiver to be told about age:\n%s", receiver.Transcript())
+	}
+
+	// Test case 3: A receiver waiting with an age recipient is told about secrets for a key, or for
+	// another age recipient
+	receiver = tui.NewScriptedConsole()
+	receiver.AnswerWith(func(string) string { return forKey })
+	receiver.AnswerWith(func(string) string { return forAge })
+	receiver.Answer("q")
+	code = handleReceiver(receiver, receiverOptions{age: true, stdout: &bytes.Buffer{}})
+	transcript := receiver.Transcript()
+	if code != 0 || !strings.Contains(transcript, "Error: This secret was encrypted to a SecretShare key instead of the age recipient") {
+		t.Errorf("Test 3 failed: Expected the receiver to be told about the key:\n%s", transcript)
+	}
+	if !strings.Contains(transcript, "Error: This secret was encrypted to a different age recipient") {
+		t.Errorf("Test 3 failed: Expected the receiver to be told about the other recipient:\n%s", transcript)
+	}
+}
+
+func TestExchangeJWE(t *testing.T) {
+	// Test case 1: The receiver shares a JWK, and the sender answers with a JWE
+	sender, receiver, code := runExchange(t, receiverOptions{jwe: true}, []string{"s", strongPassword}, nil)
+	if code != 0 || !strings.Contains(receiver.Transcript(), "Here's your secret 🤫: "+strongPassword) {
+		t.Errorf("Test 1 failed: Expected the secret:\n%s", receiver.Transcript())
+	}
+	if !strings.HasPrefix(receiver.Clipboard, `{"kty":"RSA"`) || !strings.Contains(receiver.Transcript(), "Here's a new public key as a JWK:") {
+		t.Errorf("Test 1 failed: Expected a JWK on the receiver's clipboard, got '%s'", receiver.Clipboard)
+	}
+	if !core.IsJWESecret([]byte(sender.Clipboard)) || !strings.Contains(sender.Transcript(), "RSA-OAEP-256 and A256GCM") {
+		t.Errorf("Test 1 failed: Expected a JWE on the sender's clipboard, got '%s'", sender.Clipboard)
+	}
+
+	// Test case 2: With --jwe, a SecretShare key gets a JWE too, which can be pasted in tags
+	session, key := newTestKey(t)
+	sender = tui.NewScriptedConsole(key, "s", strongPassword)
+	handleSender(sender, senderOptions{jwe: true})
+	if !core.IsJWESecret([]byte(sender.Clipboard)) {
+		t.Fatalf("Test 2 failed: Expected a JWE, got '%s'", sender.Clipboard)
+	}
+	secret, err := decryptInput(session, "<secret_share_secret>"+sender.Clipboard+"</secret_share_secret>")
+	if err != nil {
+		t.Fatalf("Test 2 failed: Failed to decrypt the JWE: %v", err)
+	}
+	defer secret.Destroy()
+	if string(secret.Bytes()) != strongPassword {
+		t.Errorf("Test 2 failed: Expected '%s', got '%s'", strongPassword, secret.Bytes())
+	}
+}
+
+func TestSenderJWKChecks(t *testing.T) {
+	session, _ := newTestKey(t)
+	jwk, err := core.PublicKeyToJWK(session.GetPublicKey())
+	if err != nil {
+		t.Fatalf("Failed to convert public key: %v", err)
+	}
+	signingKey := strings.Replace(string(jwk), `"use":"enc"`, `"use":"sig"`, 1)
+
+	// Test case 1: A signing key is rejected until an encryption key is pasted
+	sender := tui.NewScriptedConsole(signingKey, string(jwk), "s", strongPassword)
+	handleSender(sender, senderOptions{})
+	if !strings.Contains(sender.Transcript(), "Error: Could not use the JWK") || !core.IsJWESecret([]byte(sender.Clipboard)) {
+		t.Errorf("Test 1 failed: Expected the signing key to be rejected, then a JWE:\n%s", sender.Transcript())
+	}
+
+	// Test case 2: SSH keys can't have a JWE
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	_, line := newTestSSHKey(t, privateKey, "")
+	sender = tui.NewScriptedConsole(line, "s", strongPassword)
+	handleSender(sender, senderOptions{jwe: true})
+	if !strings.Contains(sender.Transcript(), "Error: A JWE can only be encrypted to a SecretShare key or a JWK") || sender.Clipboard != "" {
+		t.Errorf("Test 2 failed: Expected an error without a secret:\n%s", sender.Transcript())
+	}
+}
+
+func TestExchangeHPKE(t *testing.T) {
+	// Test case 1: The receiver shares a one-time ssv4 key, and the sender encrypts to it with HPKE
+	sender, receiver, code := runExchange(t, receiverOptions{hpke: true}, []string{"s", strongPassword}, nil)
+	if code != 0 || !strings.Contains(receiver.Transcript(), "Here's your secret 🤫: "+strongPassword) {
+		t.Errorf("Test 1 failed: Expected the secret:\n%s", receiver.Transcript())
+	}
+	if !strings.HasPrefix(receiver.Clipboard, "<secret_share_key>\nssv4") {
+		t.Errorf("Test 1 failed: Expected an ssv4 key on the receiver's clipboard, got '%s'", receiver.Clipboard)
+	}
+	if !core.IsHPKESecret(decodeClipboard(t, sender.Clipboard)) {
+		t.Errorf("Test 1 failed: Expected an ssv4 secret, got '%s'", sender.Clipboard)
+	}
+
+	session, err := core.NewHPKEReceiverSession()
+	if err != nil {
+		t.Fatalf("Failed to create HPKE session: %v", err)
+	}
+	defer session.Destroy()
+	key, err := session.SharedKey()
+	if err != nil {
+		t.Fatalf("Failed to format key: %v", err)
+	}
+
+	// Test case 2: A key of the wrong size is rejected until a valid one is pasted
+	short := "<secret_share_key>ssv4AAAAAAAAAAAAAAAAAAAAAA==</secret_share_key>"
+	sender = tui.NewScriptedConsole(short, key, "s", strongPassword)
+	handleSender(sender, senderOptions{})
+	if !strings.Contains(sender.Transcript(), "Error: Could not extract public key from input.") {
+		t.Errorf("Test 2 failed: Expected the short key to be rejected:\n%s", sender.Transcript())
+	}
+	secret, err := decryptInput(session, sender.Clipboard)
+	if err != nil {
+		t.Fatalf("Test 2 failed: Failed to decrypt: %v", err)
+	}
+	defer secret.Destroy()
+	if string(secret.Bytes()) != strongPassword {
+		t.Errorf("Test 2 failed: Expected '%s', got '%s'", strongPassword, secret.Bytes())
+	}
+
+	// Test case 3: HPKE keys can't have a JWE
+	sender = tui.NewScriptedConsole(key, "s", strongPassword)
+	handleSender(sender, senderOptions{jwe: true})
+	if !strings.Contains(sender.Transcript(), "Error: This key isn't an RSA key") || sender.Clipboard != "" {
+		t.Errorf("Test 3 failed: Expected an error without a secret:\n%s", sender.Transcript())
+	}
+}
+
+func TestHPKEChecks(t *testing.T) {
+	session, err := core.NewHPKEReceiverSession()
+	if err != nil {
+		t.Fatalf("Failed to create HPKE session: %v", err)
+	}
+	defer session.Destroy()
+	_, key := newTestKey(t)
+	hpkeKey, err := session.SharedKey()
+	if err != nil {
+		t.Fatalf("Failed to format key: %v", err)
+	}
+	sender := tui.NewScriptedConsole(hpkeKey, "s", strongPassword)
+	handleSender(sender, senderOptions{})
+	forHPKE := sender.Clipboard
+	sender = tui.NewScriptedConsole(key, "s", strongPassword)
+	handleSender(sender, senderOptions{})
+	forKey := sender.Clipboard
+
+	// Test case 1: A receiver waiting with an RSA key is told the secret is for another key
+	receiver := tui.NewScriptedConsole()
+	receiver.AnswerWith(func(string) string { return forHPKE })
+	receiver.Answer("q")
+	code := handleReceiver(receiver, receiverOptions{stdout: &bytes.Buffer{}})
+	if code != 0 || !strings.Contains(receiver.Transcript(), "Error: This secret was encrypted to a different key than the one above.") {
+		t.Errorf("Test 1 failed: Expected the receiver to be told about the other key:\n%s", receiver.Transcript())
+	}
+
+	// Test case 2: A receiver waiting with an HPKE key is told about secrets for an RSA key
+	receiver = tui.NewScriptedConsole()
+	receiver.AnswerWith(func(string) string { return forKey })
+	receiver.Answer("q")
+	code = handleReceiver(receiver, receiverOptions{hpke: true, stdout: &bytes.Buffer{}})
+	if code != 0 || !strings.Contains(receiver.Transcript(), "Error: This secret was encrypted to an RSA key instead of the key above.") {
+		t.Errorf("Test 2 failed: Expected the receiver to be told about the RSA key:\n%s", receiver.Transcript())
+	}
+}
+
+// decodeClipboard returns the encrypted secret the sender copied
+func decodeClipboard(t *testing.T, clipboard string) []byte {
+	t.Helper()
+	encrypted, err := decodeInput(clipboard)
+	if err != nil {
+		t.Fatalf("Failed to decode the sender's secret: %v", err)
+	}
+	return encrypted
+}
+
+func TestExchangeSplitSecret(t *testing.T) {
+	sessions := make([]*core.ReceiverSession, 3)
+	keys := make([]string, 3)
+	for i := range sessions {
+		sessions[i], keys[i] = newTestKey(t)
+	}
+
+	// Test case 1: The sender encrypts one share to each receiver's key
+	sender := tui.NewScriptedConsole(keys[0], keys[0], keys[1], keys[2], "s", strongPassword)
+	handleSender(sender, senderOptions{split: 3, threshold: 2})
+	transcript := sender.Transcript()
+	if !strings.Contains(transcript, "Error: You already entered this key.") {
+		t.Errorf("Test 1 failed: Expected a repeated key to be rejected:\n%s", transcript)
+	}
+	encryptedShares := regexp.MustCompile(`<secret_share_secret>[^<]+</secret_share_secret>`).FindAllString(transcript, -1)
+	if len(encryptedShares) != 3 || !strings.Contains(transcript, "Any 2 of the 3 receivers can recover the secret") {
+		t.Fatalf("Test 1 failed: Expected 3 encrypted shares:\n%s", transcript)
+	}
+	if strings.Contains(transcript, strongPassword) {
+		t.Error("Test 1 failed: The secret should never be shown to the sender")
+	}
+
+	// Test case 2: Each receiver sees only their share, which doesn't reveal the secret
+	shareTexts := make([]string, 3)
+	for i, encryptedShare := range encryptedShares {
+		receiver := tui.NewScriptedConsole(encryptedShare)
+		if code := receiveSecret(receiver, receiverOptions{}, sessions[i]); code != 0 {
+			t.Errorf("Test 2 failed: Expected exit code 0, got %d", code)
+		}
+		transcript := receiver.Transcript()
+		shareTexts[i] = regexp.MustCompile(`<secret_share_share>[^<]+</secret_share_share>`).FindString(transcript)
+		if shareTexts[i] == "" || !strings.Contains(transcript, fmt.Sprintf("You received share %d of a split secret. Any 2 shares recover it", i+1)) {
+			t.Fatalf("Test 2 failed: Expected receiver %d to see their share:\n%s", i+1, transcript)
+		}
+		if strings.Contains(transcript, strongPassword) {
+			t.Errorf("Test 2 failed: Receiver %d should not see the secret", i+1)
+		}
+	}
+
+	// Test case 3: The combiner recovers the secret from one share sent with its key, and one pasted
+	combiner, combinerKey := newTestKey(t)
+	holder := tui.NewScriptedConsole(combinerKey, "s", shareTexts[0])
+	handleSender(holder, senderOptions{})
+	if len(holder.Unanswered) != 0 {
+		t.Errorf("Test 3 failed: Unexpected prompts sending a share: %v", holder.Unanswered)
+	}
+	console := tui.NewScriptedConsole(holder.Clipboard, holder.Clipboard, shareTexts[2])
+	code := combineSecret(console, receiverOptions{}, combiner)
+	if code != 0 {
+		t.Errorf("Test 3 failed: Expected exit code 0, got %d", code)
+	}
+	transcript = console.Transcript()
+	if !strings.Contains(transcript, "Error: You already entered share 1.") {
+		t.Errorf("Test 3 failed: Expected a repeated share to be rejected:\n%s", transcript)
+	}
+	if !strings.Contains(transcript, "Here's your secret 🤫: "+strongPassword) {
+		t.Errorf("Test 3 failed: Expected the secret to be recovered:\n%s", transcript)
+	}
+}
+
+func TestCombineSecretRejectsOtherSecrets(t *testing.T) {
+	first, err := core.SplitSecret([]byte("first"), 2, 2)
+	if err != nil {
+		t.Fatalf("Failed to split secret: %v", err)
+	}
+	second, err := core.SplitSecret([]byte("second"), 2, 2)
+	if err != nil {
+		t.Fatalf("Failed to split secret: %v", err)
+	}
+	shareText := func(share core.Share) string {
+		payload, err := core.EncodeShare(share)
+		if err != nil {
+			t.Fatalf("Failed to encode share: %v", err)
+		}
+		return core.FormatShare([]byte(base64.StdEncoding.EncodeToString(payload)))
+	}
+
+	session, _ := newTestKey(t)
+	console := tui.NewScriptedConsole(shareText(first[0]), "not a share", shareText(second[1]), "q")
+	if code := combineSecret(console, receiverOptions{}, session); code != 0 {
+		t.Errorf("Expected exit code 0, got %d", code)
+	}
+	transcript := console.Transcript()
+	if !strings.Contains(transcript, "Error: Could not extract a share from input.") {
+		t.Errorf("Expected invalid input to be rejected:\n%s", transcript)
+	}
+	if !strings.Contains(transcript, "Error: This share is from a different secret than the first one.") {
+		t.Errorf("Expected a share of another secret to be rejected:\n%s", transcript)
+	}
+}
+
+func TestDecodeShareText(t *testing.T) {
+	shares, err := core.SplitSecret([]byte("secret"), 2, 2)
+	if err != nil {
+		t.Fatalf("Failed to split secret: %v", err)
+	}
+	payload, err := core.EncodeShare(shares[0])
+	if err != nil {
+		t.Fatalf("Failed to encode share: %v", err)
+	}
+	encoded := base64.StdEncoding.EncodeToString(payload)
+	text := core.FormatShare([]byte(encoded))
+
+	testCases := []string{
+		// Test case 1: A share as it's shown to its receiver
+		text,
+		// Test case 2: Wrapped across lines and quoted in a reply
+		"> " + text[:30] + "\n> " + text[30:] + "\n",
+		// Test case 3: Without its tags
+		encoded,
+	}
+	for i, tc := range testCases {
+		share, err := decodeShareText([]byte(tc))
+		if err != nil || share.Index != shares[0].Index || !bytes.Equal(share.Data, shares[0].Data) {
+			t.Errorf("Test %d failed: Expected share %d, got %d (%v)", i+1, shares[0].Index, share.Index, err)
+		}
+	}
+
+	// Test case 4: Text that isn't a share
+	if _, err := decodeShareText([]byte("<secret_share_share>not base64!</secret_share_share>")); err == nil {
+		t.Error("Test 4 failed: Expected an error for text that isn't a share")
+	}
+}
+
+func TestPrintProtections(t *testing.T) {
+	console := tui.NewScriptedConsole()
+	printProtections(console, []core.Protection{
+		{Name: "Locked memory", Active: true},
+		{Name: "No dumps", Err: errors.New("not supported")},
+	})
+
+	transcript := console.Transcript()
+	if !strings.Contains(transcript, "Locked memory  active") {
+		t.Errorf("Expected active protection to be reported:\n%s", transcript)
+	}
+	if !strings.Contains(transcript, "No dumps       inactive: not supported") {
+		t.Errorf("Expected inactive protection to be reported with its reason:\n%s", transcript)
+	}
+}
+
+func TestVerboseReportsUnlockedKey(t *testing.T) {
+	const warning = "Warning: This session's private key isn't in locked memory"
+
+	// Test case 1: RSA keys are never in locked memory, and verbose mode says so
+	receiver := tui.NewScriptedConsole()
+	handleReceiver(receiver, receiverOptions{verbose: true, stdout: &bytes.Buffer{}})
+	if !strings.Contains(receiver.Transcript(), warning) {
+		t.Errorf("Test 1 failed: Expected the unlocked key to be reported:\n%s", receiver.Transcript())
+	}
+
+	// Test case 2: Only in verbose mode
+	receiver = tui.NewScriptedConsole()
+	handleReceiver(receiver, receiverOptions{stdout: &bytes.Buffer{}})
+	if strings.Contains(receiver.Transcript(), warning) {
+		t.Errorf("Test 2 failed: Expected no report without verbose mode:\n%s", receiver.Transcript())
+	}
+
+	// Test case 3: HPKE keys are locked wherever memory can be
+	probe := core.NewSecureBuffer(1)
+	lockable := probe.Locked()
+	probe.Destroy()
+	receiver = tui.NewScriptedConsole()
+	handleReceiver(receiver, receiverOptions{hpke: true, verbose: true, stdout: &bytes.Buffer{}})
+	if strings.Contains(receiver.Transcript(), warning) == lockable {
+		t.Errorf("Test 3 failed: Expected the report to match whether memory can be locked (%v):\n%s", lockable, receiver.Transcript())
+	}
+}
+
+func FuzzDecryptInput(f *testing.F) {
+	session, err := core.NewReceiverSession()
+	if err != nil {
+		f.Fatalf("Failed to create receiver session: %v", err)
+	}
+	encrypted, err := core.HybridEncrypt(session.GetPublicKey(), []byte(strongPassword))
+	if err != nil {
+		f.Fatalf("Failed to encrypt secret: %v", err)
+	}
+	encoded := base64.StdEncoding.EncodeToString(encrypted)
+	tagged := core.FormatSecret([]byte(encoded))
+
+	f.Add(tagged)
+	f.Add(encoded)
+	f.Add(tagged[:len(tagged)/2])
+	f.Add(encoded[:64] + "\n" + encoded[64:])
+	f.Add("extra " + tagged + " extra")
+	f.Add("<secret_share_secret>" + base64.StdEncoding.EncodeToString([]byte("ssv1\xff\xff\xff\xff")) + "</secret_share_secret>")
+	f.Add("<secret_share_secret>c3N2Mg==</secret_share_secret>")
+	f.Add("<secret_share_secret></secret_share_secret>")
+	f.Add("")
+
+	f.Fuzz(func(t *testing.T, input string) {
+		secret, err := decryptInput(session, input)
+		if err != nil {
+			return
+		}
+		if string(secret.Bytes()) != strongPassword {
+			t.Errorf("Expected '%s', got '%s'", strongPassword, secret.Bytes())
+		}
+		secret.Destroy()
+	})
+}
@@ -0,0 +1,297 @@
+//go:build linux
+
+// Go ignores build constraints in the explicit file lists the Makefile and CI pass for this
+// directory, so this file compiles everywhere: the platform-specific calls are in ptytest, and
+// the tests skip where it doesn't support pseudo-terminals.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scosman/secret_share/internal/ptytest"
+)
+
+// expectTimeout is how long to wait for the app to print what a test expects
+const expectTimeout = 15 * time.Second
+
+var (
+	buildOnce   sync.Once
+	buildDir    string
+	builtBinary string
+	buildErr    error
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if buildDir != "" {
+		os.RemoveAll(buildDir)
+	}
+	os.Exit(code)
+}
+
+// buildBinary builds the secret_share binary once for all pseudo-terminal tests
+func buildBinary(t *testing.T) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping pseudo-terminal test in short mode")
+	}
+	if !ptytest.Supported {
+		t.Skipf("skipping pseudo-terminal test on %s", runtime.GOOS)
+	}
+
+	buildOnce.Do(func() {
+		goTool, err := exec.LookPath("go")
+		if err != nil {
+			buildErr = fmt.Errorf("go tool not found: %w", err)
+			return
+		}
+		sources, err := filepath.Glob("*.go")
+		if err != nil {
+			buildErr = err
+			return
+		}
+		buildDir, err = os.MkdirTemp("", "secret_share_e2e")
+		if err != nil {
+			buildErr = err
+			return
+		}
+
+		// Build from the repo root so the app is built against this checkout of core and tui
+		args := []string{"build", "-o", filepath.Join(buildDir, "secret_share")}
+		for _, source := range sources {
+			if !strings.HasSuffix(source, "_test.go") {
+				args = append(args, "./cmd/secret_share/"+source)
+			}
+		}
+		cmd := exec.Command(goTool, args...)
+		cmd.Dir = filepath.Join("..", "..")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			buildErr = fmt.Errorf("failed to build secret_share: %w\n%s", err, out)
+			return
+		}
+		builtBinary = filepath.Join(buildDir, "secret_share")
+	})
+
+	if buildErr != nil {
+		t.Fatal(buildErr)
+	}
+	return builtBinary
+}
+
+// ptyProcess is a running secret_share driven through a pseudo-terminal, like a user at a terminal
+type ptyProcess struct {
+	t         *testing.T
+	cmd       *exec.Cmd
+	master    *os.File
+	clipboard string // file the fake xclip writes copied text to
+
+	mu     sync.Mutex
+	output bytes.Buffer
+	offset int // output before offset has already been matched by expect
+	done   chan struct{}
+}
+
+// startPTY starts the app with its stdin, stdout and stderr attached to a new pseudo-terminal.
+// The clipboard is replaced with a fake xclip that saves copied text to a file.
+func startPTY(t *testing.T, args ...string) *ptyProcess {
+	t.Helper()
+	binary := buildBinary(t)
+
+	master, slave, err := ptytest.Open()
+	if err != nil {
+		t.Fatalf("Failed to open pseudo-terminal: %v", err)
+	}
+
+	binDir := t.TempDir()
+	clipboard := filepath.Join(binDir, "clipboard")
+	fakeXclip := "#!/bin/sh\ncat > \"" + clipboard + "\"\n"
+	if err := os.WriteFile(filepath.Join(binDir, "xclip"), []byte(fakeXclip), 0700); err != nil {
+		t.Fatalf("Failed to write fake xclip: %v", err)
+	}
+
+	cmd := exec.Command(binary, args...)
+	cmd.Env = append(os.Environ(), "PATH="+binDir+string(os.PathListSeparator)+os.Getenv("PATH"), "TERM=xterm")
+	ptytest.Attach(cmd, slave)
+	if err := cmd.Start(); err != nil {
+		master.Close()
+		slave.Close()
+		t.Fatalf("Failed to start secret_share: %v", err)
+	}
+	// Only the app holds the terminal open now, so reads end when it exits
+	slave.Close()
+
+	p := &ptyProcess{t: t, cmd: cmd, master: master, clipboard: clipboard, done: make(chan struct{})}
+	go p.readOutput()
+	t.Cleanup(func() {
+		if p.cmd.ProcessState == nil {
+			p.cmd.Process.Kill()
+			p.cmd.Wait()
+		}
+		p.master.Close()
+	})
+	return p
+}
+
+// readOutput collects everything the app writes to the terminal until it exits
+func (p *ptyProcess) readOutput() {
+	defer close(p.done)
+	buf := make([]byte, 4096)
+	for {
+		n, err := p.master.Read(buf)
+		p.mu.Lock()
+		p.output.Write(buf[:n])
+		p.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// expect waits for text to be printed after the last match, and returns the output up to and including it
+func (p *ptyProcess) expect(text string) string {
+	p.t.Helper()
+	deadline := time.Now().Add(expectTimeout)
+	for {
+		// Check for exit first, so output written just before exiting is still matched
+		exited := false
+		select {
+		case <-p.done:
+			exited = true
+		default:
+		}
+
+		p.mu.Lock()
+		unread := p.output.String()[p.offset:]
+		if idx := strings.Index(unread, text); idx != -1 {
+			p.offset += idx + len(text)
+			p.mu.Unlock()
+			return unread[:idx+len(text)]
+		}
+		p.mu.Unlock()
+
+		if exited {
+			p.t.Fatalf("secret_share exited before printing '%s'. Output:\n%s", text, unread)
+		}
+		if time.Now().After(deadline) {
+			p.t.Fatalf("Timed out waiting for '%s'. Output:\n%s", text, unread)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// send types text into the terminal
+func (p *ptyProcess) send(text string) {
+	p.t.Helper()
+	if _, err := p.master.Write([]byte(text)); err != nil {
+		p.t.Fatalf("Failed to write to terminal: %v", err)
+	}
+}
+
+// paste pastes text into the terminal, wrapped in bracketed paste markers
+func (p *ptyProcess) paste(text string) {
+	p.send("\x1b[200~" + text + "\x1b[201~")
+}
+
+// readClipboard returns the text last copied to the fake clipboard
+func (p *ptyProcess) readClipboard() string {
+	p.t.Helper()
+	data, err := os.ReadFile(p.clipboard)
+	if err != nil {
+		p.t.Fatalf("Failed to read clipboard: %v", err)
+	}
+	return string(data)
+}
+
+// wait waits for the app to exit and returns its exit code
+func (p *ptyProcess) wait() int {
+	p.t.Helper()
+	exited := make(chan error, 1)
+	go func() { exited <- p.cmd.Wait() }()
+
+	select {
+	case err := <-exited:
+		<-p.done
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode()
+		}
+		if err != nil {
+			p.t.Fatalf("Failed to wait for secret_share: %v", err)
+		}
+		return 0
+	case <-time.After(expectTimeout):
+		p.t.Fatalf("Timed out waiting for secret_share to exit. Output:\n%s", p.remaining())
+		return -1
+	}
+}
+
+// screen returns everything the app has written to the terminal
+func (p *ptyProcess) screen() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.output.String()
+}
+
+// remaining returns the output after the last match
+func (p *ptyProcess) remaining() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.output.String()[p.offset:]
+}
+
+// waitForRawMode waits until the app has put the terminal in raw mode to read keys one at a time.
+// Typing earlier would be echoed and line buffered by the terminal.
+func (p *ptyProcess) waitForRawMode() {
+	p.t.Helper()
+	p.waitForTerminal("raw mode", func(echo, canonical bool) bool {
+		return !canonical
+	})
+}
+
+// waitForMaskedInput waits until the app has turned off echo to read a secret
+func (p *ptyProcess) waitForMaskedInput() {
+	p.t.Helper()
+	p.waitForTerminal("masked input", func(echo, canonical bool) bool {
+		return !echo
+	})
+}
+
+// waitForTerminal waits until the terminal settings match ready
+func (p *ptyProcess) waitForTerminal(state string, ready func(echo, canonical bool) bool) {
+	p.t.Helper()
+	deadline := time.Now().Add(expectTimeout)
+	for {
+		echo, canonical, err := ptytest.LocalModes(p.master)
+		if err != nil {
+			p.t.Fatalf("Failed to read terminal state: %v", err)
+		}
+		if ready(echo, canonical) {
+			return
+		}
+		if time.Now().After(deadline) {
+			p.t.Fatalf("Timed out waiting for %s. Output:\n%s", state, p.remaining())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// terminalRestored reports whether the terminal was left with echo and line editing on, not in raw mode
+func (p *ptyProcess) terminalRestored() bool {
+	p.t.Helper()
+	echo, canonical, err := ptytest.LocalModes(p.master)
+	if err != nil {
+		p.t.Fatalf("Failed to read terminal state: %v", err)
+	}
+	return echo && canonical
+}
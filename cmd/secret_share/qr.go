@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/scosman/secret_share/core"
+	"github.com/scosman/secret_share/tui"
+)
+
+// qrPNGScale is the size of each QR code module in exported PNGs, in pixels
+const qrPNGScale = 8
+
+// showQRCode shows text as a QR code in the terminal if show is set, and saves it as a PNG to
+// pngPath if that's set. Text too long for a QR code is reported, and can still be sent as text.
+func showQRCode(console tui.Console, text string, show bool, pngPath string) {
+	if !show && pngPath == "" {
+		return
+	}
+	code, err := core.EncodeQR([]byte(text))
+	if err != nil {
+		console.PrintWarning(fmt.Sprintf("Could not make a QR code: %v. Send it as text instead.", err))
+		return
+	}
+
+	if show {
+		console.PrintQRCode(code.HalfBlocks())
+	}
+	if pngPath != "" {
+		data, err := code.PNG(qrPNGScale)
+		if err == nil {
+			err = core.WritePrivateFile(pngPath, data)
+		}
+		if err != nil {
+			console.PrintError(fmt.Sprintf("Failed to write %s: %v", pngPath, err))
+			return
+		}
+		console.PrintInfo(fmt.Sprintf("Saved the QR code to %s.", pngPath))
+	}
+}
+
+// readQRInput replaces input that's the path of a PNG file with the text of the QR code in it,
+// so a key or secret can be shared as a QR code image. Other input is returned as it is. Returns
+// false if the image can't be read, after explaining why.
+func readQRInput(console tui.Console, input string) (string, bool) {
+	path, ok := qrImagePath(input)
+	if !ok {
+		return input, true
+	}
+	data, err := os.ReadFile(path)
+	if err == nil {
+		data, err = core.DecodeQRPNG(data)
+	}
+	if err != nil {
+		console.PrintError(fmt.Sprintf("Could not read a QR code from %s: %v", path, err))
+		console.PrintMessage("Use a PNG saved with --qr-png, or a screenshot with the whole QR code in view.")
+		return "", false
+	}
+	console.PrintInfo(fmt.Sprintf("Read the QR code in %s.", path))
+	return string(data), true
+}
+
+// qrImagePath returns the path in input if it's a single line naming a PNG file, as typed or
+// dropped into a terminal, which may quote it or escape its spaces
+func qrImagePath(input string) (string, bool) {
+	path := strings.TrimSpace(input)
+	if strings.Contains(path, "\n") || !strings.HasSuffix(strings.ToLower(strings.Trim(path, `'"`)), ".png") {
+		return "", false
+	}
+	if len(path) >= 2 && (path[0] == '\'' || path[0] == '"') && path[len(path)-1] == path[0] {
+		path = path[1 : len(path)-1]
+	} else {
+		path = strings.ReplaceAll(path, `\ `, " ")
+	}
+	return path, true
+}
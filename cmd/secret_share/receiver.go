@@ -0,0 +1,877 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/scosman/secret_share/core"
+	"github.com/scosman/secret_share/tui"
+)
+
+// handleReceiver runs the receiver side of the exchange. Returns the exit code for the app,
+// which is the command's exit code in exec mode.
+func handleReceiver(console tui.Console, opts receiverOptions) int {
+	if opts.passphrase {
+		return receivePassphraseSecret(console, opts)
+	}
+	if opts.sshKey != "" {
+		return receiveSSHKeySecret(console, opts)
+	}
+
+	console.PrintStatus("Generating key...")
+	// Create a new receiver session, with an age identity or HPKE key instead of an RSA key if asked
+	newSession := core.NewReceiverSession
+	if opts.age {
+		newSession = core.NewAgeReceiverSession
+	}
+	if opts.hpke {
+		newSession = core.NewHPKEReceiverSession
+	}
+	session, err := newSession()
+	if err != nil {
+		console.PrintError(fmt.Sprintf("Failed to create receiver session: %v", err))
+		return 0
+	}
+	// Clear the generating message, and go back up a line
+	console.ClearStatus()
+	defer session.Destroy()
+	// The key is wiped once the lifetime passes, even if nobody returns to the prompt
+	session.SetLifetime(opts.timeout)
+	if opts.verbose && !session.KeyLocked() {
+		console.PrintWarning("This session's private key isn't in locked memory, so it could be swapped to disk. RSA keys never are; --age and --hpke keys are where memory can be locked.")
+	}
+
+	if opts.combine {
+		return combineSecret(console, opts, session)
+	}
+	return receiveSecret(console, opts, session)
+}
+
+// showPublicKey displays the session's shared key, in the format of its suite, and copies it to
+// the clipboard. The key is shown as a JWK if opts.jwe is set. Returns false if the key couldn't
+// be shown.
+func showPublicKey(console tui.Console, opts receiverOptions, session *core.ReceiverSession) bool {
+	heading := "Here's a new public key:"
+	key, err := session.SharedKey()
+	if opts.jwe {
+		var jwk []byte
+		jwk, err = core.PublicKeyToJWK(session.GetPublicKey())
+		heading, key = "Here's a new public key as a JWK:", string(jwk)
+	} else if session.AgeRecipient() != "" {
+		heading = "Here's a new age recipient:"
+	}
+	if err != nil {
+		console.PrintError(fmt.Sprintf("Failed to serialize public key: %v", err))
+		return false
+	}
+
+	console.PrintInfo(heading)
+	console.PrintMessage(key)
+	showQRCode(console, key, opts.qr, opts.qrPNG)
+	if opts.words {
+		showKeyWords(console, key)
+	}
+
+	// Try to copy public key to clipboard
+	err = console.SetClipboard(key)
+	if err == nil {
+		console.PrintInfo("Copied to clipboard.")
+	}
+	return true
+}
+
+// receiveSecret shares the session's public key, then decrypts and delivers the secret sent back
+func receiveSecret(console tui.Console, opts receiverOptions, session *core.ReceiverSession) int {
+	if !showPublicKey(console, opts, session) {
+		return 0
+	}
+
+	// Get encrypted secret from sender with retry logic, until the session expires
+	console.SetDeadline(session.ExpiresAt())
+	defer console.SetDeadline(time.Time{})
+	var secretBuffer *core.SecureBuffer
+	var err error
+	for {
+		input := console.PromptBlob("Send the key above to the person who wants to share a secret with you. When they reply back with the encrypted secret, enter it here: ")
+		if console.TimedOut() {
+			printSessionExpired(console, opts)
+			return 1
+		}
+		if tui.IsQuit(input) {
+			console.PrintMessage("Quiting SecretShare")
+			return 0
+		}
+		input, ok := readQRInput(console, input)
+		if !ok {
+			continue
+		}
+		input, ok = readWordsInput(console, input, parseSecretWords)
+		if !ok {
+			continue
+		}
+
+		secretBuffer, err = decryptInput(session, input)
+		if errors.Is(err, core.ErrPassphraseSecret) {
+			// The sender used a passphrase instead of the key, which works if one was agreed
+			console.PrintInfo("This secret was encrypted with a passphrase instead of your key.")
+			encryptedSecret, _ := decodeInput(input)
+			secretBuffer = promptPassphraseAndDecrypt(console, encryptedSecret)
+			if secretBuffer == nil {
+				if console.TimedOut() {
+					printSessionExpired(console, opts)
+					return 1
+				}
+				console.PrintMessage("Quiting SecretShare")
+				return 0
+			}
+			break
+		}
+		if errors.Is(err, core.ErrSessionExpired) {
+			printSessionExpired(console, opts)
+			return 1
+		}
+		var mismatch *core.KeyMismatchError
+		if errors.As(err, &mismatch) {
+			printKeyMismatch(console, mismatch)
+			continue
+		}
+		if errors.Is(err, core.ErrNewerVersion) {
+			console.PrintError("This secret was sent using a newer version of SecretShare. You need to upgrade to receive it.")
+			continue
+		}
+		if errors.Is(err, core.ErrChecksumMismatch) {
+			printChecksumMismatch(console)
+			continue
+		}
+		if err != nil {
+			console.PrintError("Could not extract secret from input.")
+			console.PrintMessage("Ensure you are pasting the exact encrypted secret from the sender. It should be a string wrapped in tags like '<secret_share_secret>'.")
+			continue
+		}
+
+		break
+	}
+
+	// The private key isn't needed once the secret is decrypted, and the secret is wiped once delivered
+	session.Destroy()
+	console.SetDeadline(time.Time{})
+	defer secretBuffer.Destroy()
+	return deliverSecret(console, opts, secretBuffer.Bytes())
+}
+
+// printKeyMismatch explains a secret encrypted to something other than the receiver's key
+func printKeyMismatch(console tui.Console, mismatch *core.KeyMismatchError) {
+	console.PrintError(mismatch.Explanation)
+	if mismatch.Hint != "" {
+		console.PrintMessage(mismatch.Hint)
+	}
+}
+
+// receivePassphraseSecret decrypts a secret encrypted with a passphrase agreed with the sender,
+// and delivers it. No key is generated, so there's nothing to send the sender first.
+func receivePassphraseSecret(console tui.Console, opts receiverOptions) int {
+	if opts.timeout > 0 {
+		console.SetDeadline(time.Now().Add(opts.timeout))
+	}
+	defer console.SetDeadline(time.Time{})
+
+	var secretBuffer *core.SecureBuffer
+	for secretBuffer == nil {
+		input := console.PromptBlob("Enter the encrypted secret from the person you agreed a passphrase with: ")
+		if console.TimedOut() {
+			printPassphraseTimeout(console, opts)
+			return 1
+		}
+		if tui.IsQuit(input) {
+			console.PrintMessage("Quiting SecretShare")
+			return 0
+		}
+		input, ok := readQRInput(console, input)
+		if !ok {
+			continue
+		}
+		input, ok = readWordsInput(console, input, parseSecretWords)
+		if !ok {
+			continue
+		}
+
+		encryptedSecret, err := decodeInput(input)
+		if errors.Is(err, core.ErrChecksumMismatch) {
+			printChecksumMismatch(console)
+			continue
+		}
+		if err != nil {
+			console.PrintError("Could not extract secret from input.")
+			console.PrintMessage("Ensure you are pasting the exact encrypted secret from the sender. It should be a string wrapped in tags like '<secret_share_secret>'.")
+			continue
+		}
+		if !core.IsPassphraseSecret(encryptedSecret) {
+			console.PrintError("This secret was encrypted to a key, not a passphrase.")
+			console.PrintMessage("Run 'secret_share receive' without --passphrase, and send the new key to the sender.")
+			continue
+		}
+
+		secretBuffer = promptPassphraseAndDecrypt(console, encryptedSecret)
+		if secretBuffer == nil {
+			if console.TimedOut() {
+				printPassphraseTimeout(console, opts)
+				return 1
+			}
+			console.PrintMessage("Quiting SecretShare")
+			return 0
+		}
+	}
+
+	console.SetDeadline(time.Time{})
+	defer secretBuffer.Destroy()
+	return deliverSecret(console, opts, secretBuffer.Bytes())
+}
+
+// receiveSSHKeySecret decrypts a secret encrypted to the receiver's SSH public key with the
+// private key in opts.sshKey, and delivers it. No key is generated, so there's nothing to send the
+// sender first.
+func receiveSSHKeySecret(console tui.Console, opts receiverOptions) int {
+	identity := loadSSHIdentity(console, opts.sshKey)
+	if identity == nil {
+		return 0
+	}
+	defer identity.Destroy()
+	console.PrintInfo("Receiving secrets encrypted to your SSH public key:")
+	console.PrintMessage(identity.PublicKey())
+
+	if opts.timeout > 0 {
+		console.SetDeadline(time.Now().Add(opts.timeout))
+	}
+	defer console.SetDeadline(time.Time{})
+
+	var secretBuffer *core.SecureBuffer
+	for secretBuffer == nil {
+		input := console.PromptBlob("Enter the encrypted secret sent to your SSH key: ")
+		if console.TimedOut() {
+			printSSHKeyTimeout(console, opts)
+			return 1
+		}
+		if tui.IsQuit(input) {
+			console.PrintMessage("Quiting SecretShare")
+			return 0
+		}
+		input, ok := readQRInput(console, input)
+		if !ok {
+			continue
+		}
+		input, ok = readWordsInput(console, input, parseSecretWords)
+		if !ok {
+			continue
+		}
+
+		encryptedSecret, err := decodeInput(input)
+		if err == nil {
+			secretBuffer, err = identity.DecryptSecret(encryptedSecret)
+		}
+		if errors.Is(err, core.ErrPassphraseSecret) {
+			// The sender used a passphrase instead of the key, which works if one was agreed
+			console.PrintInfo("This secret was encrypted with a passphrase instead of your key.")
+			secretBuffer = promptPassphraseAndDecrypt(console, encryptedSecret)
+			if secretBuffer == nil {
+				if console.TimedOut() {
+					printSSHKeyTimeout(console, opts)
+					return 1
+				}
+				console.PrintMessage("Quiting SecretShare")
+				return 0
+			}
+			break
+		}
+		if errors.Is(err, core.ErrWrongSSHKey) {
+			console.PrintError(fmt.Sprintf("This secret was encrypted to a different key than the one in %s.", opts.sshKey))
+			console.PrintMessage("Check which key the sender encrypted it to. Secrets for a new SecretShare key are received with 'secret_share receive'.")
+			continue
+		}
+		var mismatch *core.KeyMismatchError
+		if errors.As(err, &mismatch) {
+			printKeyMismatch(console, mismatch)
+			continue
+		}
+		if errors.Is(err, core.ErrNewerVersion) {
+			console.PrintError("This secret was sent using a newer version of SecretShare. You need to upgrade to receive it.")
+			continue
+		}
+		if errors.Is(err, core.ErrChecksumMismatch) {
+			printChecksumMismatch(console)
+			continue
+		}
+		if err != nil {
+			console.PrintError("Could not extract secret from input.")
+			console.PrintMessage("Ensure you are pasting the exact encrypted secret from the sender. It should be a string wrapped in tags like '<secret_share_secret>'.")
+			continue
+		}
+	}
+
+	// The private key isn't needed once the secret is decrypted, and the secret is wiped once delivered
+	identity.Destroy()
+	console.SetDeadline(time.Time{})
+	defer secretBuffer.Destroy()
+	return deliverSecret(console, opts, secretBuffer.Bytes())
+}
+
+// loadSSHIdentity reads the SSH private key at path, asking for its passphrase if it has one.
+// ssh-agent only signs, so the key is held in memory for decrypting instead. Returns nil if the
+// key can't be used, or the user quits.
+func loadSSHIdentity(console tui.Console, path string) *core.SSHIdentity {
+	keyFile, err := os.ReadFile(path)
+	if err != nil {
+		console.PrintError(fmt.Sprintf("Could not read the SSH private key: %v", err))
+		return nil
+	}
+	defer core.Wipe(keyFile)
+
+	identity, err := core.ParseSSHIdentity(keyFile, nil)
+	for errors.Is(err, core.ErrSSHKeyEncrypted) || errors.Is(err, core.ErrWrongSSHKeyPassphrase) {
+		if errors.Is(err, core.ErrWrongSSHKeyPassphrase) {
+			console.PrintError("Wrong passphrase for the SSH key. Try again.")
+		}
+		passphrase := console.PromptSecret(fmt.Sprintf("Enter the passphrase for %s: ", path))
+		if passphrase == nil || tui.IsQuitSecret(passphrase) {
+			core.Wipe(passphrase)
+			console.PrintMessage("Quiting SecretShare")
+			return nil
+		}
+		identity, err = core.ParseSSHIdentity(keyFile, passphrase)
+		core.Wipe(passphrase)
+	}
+	if err != nil {
+		console.PrintError(fmt.Sprintf("Could not use the SSH key in %s: %v", path, err))
+		return nil
+	}
+	return identity
+}
+
+// printSSHKeyTimeout explains that no secret was decrypted with the SSH key before the timeout
+func printSSHKeyTimeout(console tui.Console, opts receiverOptions) {
+	console.PrintError(fmt.Sprintf("No secret was decrypted within %s, and the SSH key was wiped from memory.", opts.timeout))
+	console.PrintMessage("Start SecretShare again when you have the encrypted secret.")
+}
+
+// promptPassphraseAndDecrypt asks for the passphrase until it decrypts the secret.
+// Returns nil if the user quits, or the secret can't be decrypted by this version.
+func promptPassphraseAndDecrypt(console tui.Console, encryptedSecret []byte) *core.SecureBuffer {
+	for {
+		passphrase := console.PromptSecret("Enter the passphrase you agreed with the sender: ")
+		if passphrase == nil || tui.IsQuitSecret(passphrase) {
+			core.Wipe(passphrase)
+			return nil
+		}
+
+		secretBuffer, err := core.DecryptPassphraseSecret(passphrase, encryptedSecret)
+		core.Wipe(passphrase)
+		if errors.Is(err, core.ErrWrongPassphrase) {
+			console.PrintError("Wrong passphrase, or the secret was changed. Check the passphrase and try again.")
+			continue
+		}
+		if errors.Is(err, core.ErrNewerVersion) {
+			console.PrintError("This secret was sent using a newer version of SecretShare. You need to upgrade to receive it.")
+			return nil
+		}
+		if err != nil {
+			console.PrintError(fmt.Sprintf("Could not decrypt the secret: %v", err))
+			return nil
+		}
+		return secretBuffer
+	}
+}
+
+// printPassphraseTimeout explains that no secret was decrypted before the timeout
+func printPassphraseTimeout(console tui.Console, opts receiverOptions) {
+	console.PrintError(fmt.Sprintf("No secret was decrypted within %s.", opts.timeout))
+	console.PrintMessage("Start SecretShare again when you have the encrypted secret and the passphrase.")
+}
+
+// deliverSecret delivers a decrypted secret as the receiver options ask, displaying it by default.
+// Returns the exit code for the app.
+func deliverSecret(console tui.Console, opts receiverOptions, decryptedSecret []byte) int {
+	// A share is kept until the secret is recovered, wherever the secret was to be delivered
+	if core.IsSharePayload(decryptedSecret) {
+		showShare(console, decryptedSecret)
+		return 0
+	}
+
+	// Deliver the secret without displaying it, if requested
+	if opts.envFile != "" {
+		return deliveryExitCode(console, writeSecretToEnvFile(console, opts, decryptedSecret))
+	}
+	if opts.k8sSecret != "" {
+		return deliveryExitCode(console, writeKubernetesSecret(console, opts, decryptedSecret))
+	}
+	if opts.execName != "" {
+		return runWithSecret(console, opts, decryptedSecret)
+	}
+
+	// Structured secrets get their own display and export options
+	if core.IsFieldsPayload(decryptedSecret) {
+		fields, err := core.DecodeFields(decryptedSecret)
+		if err == nil {
+			handleReceivedFields(console, fields)
+			core.WipeFields(fields)
+			return 0
+		}
+	}
+
+	// Display the decrypted secret, starting multi-line secrets on their own line
+	console.PrintSecret("Here's your secret 🤫:", decryptedSecret)
+	return 0
+}
+
+// showShare displays a share of a split secret, for its receiver to keep until the secret is recovered
+func showShare(console tui.Console, payload []byte) {
+	share, err := core.DecodeShare(payload)
+	if err != nil {
+		console.PrintError(fmt.Sprintf("Could not read the share: %v", err))
+		return
+	}
+	share.Wipe()
+
+	console.PrintSuccess(fmt.Sprintf("You received share %d of a split secret. Any %d shares recover it, and this one alone reveals nothing 🧩", share.Index, share.Threshold))
+	console.PrintMessage(core.FormatShare([]byte(base64.StdEncoding.EncodeToString(payload))))
+	console.PrintInfo("Keep this share safe. To recover the secret, whoever combines the shares runs 'secret_share receive --combine', and you send them this share with 'secret_share send'.")
+}
+
+// combineSecret shares the session's public key, then collects shares of a split secret until
+// there are enough to recover it, and delivers the secret
+func combineSecret(console tui.Console, opts receiverOptions, session *core.ReceiverSession) int {
+	if !showPublicKey(console, opts, session) {
+		return 0
+	}
+
+	// Collect shares until the session expires
+	console.SetDeadline(session.ExpiresAt())
+	defer console.SetDeadline(time.Time{})
+	var shares []core.Share
+	defer func() {
+		for _, share := range shares {
+			share.Wipe()
+		}
+	}()
+	for len(shares) == 0 || len(shares) < shares[0].Threshold {
+		prompt := "Send the key above to each person holding a share. When they send their share back with 'secret_share send', enter it here: "
+		if len(shares) > 0 {
+			prompt = fmt.Sprintf("Enter the next share (%d of %d entered): ", len(shares), shares[0].Threshold)
+		}
+		input := console.PromptBlob(prompt)
+		if console.TimedOut() {
+			printSessionExpired(console, opts)
+			return 1
+		}
+		if tui.IsQuit(input) {
+			console.PrintMessage("Quiting SecretShare")
+			return 0
+		}
+		input, ok := readQRInput(console, input)
+		if !ok {
+			continue
+		}
+
+		share, err := decodeShareInput(session, input)
+		if errors.Is(err, core.ErrSessionExpired) {
+			printSessionExpired(console, opts)
+			return 1
+		}
+		if errors.Is(err, core.ErrNewerVersion) {
+			console.PrintError("This share was sent using a newer version of SecretShare. You need to upgrade to receive it.")
+			continue
+		}
+		if errors.Is(err, core.ErrChecksumMismatch) {
+			printChecksumMismatch(console)
+			continue
+		}
+		if err != nil {
+			console.PrintError("Could not extract a share from input.")
+			console.PrintMessage("Ensure you are pasting the exact share. It should be a string wrapped in tags like '<secret_share_secret>' or '<secret_share_share>'.")
+			continue
+		}
+		if problem := checkNewShare(shares, share); problem != "" {
+			share.Wipe()
+			console.PrintError(problem)
+			continue
+		}
+
+		shares = append(shares, share)
+		console.PrintInfo(fmt.Sprintf("Added share %d.", share.Index))
+	}
+
+	// The private key isn't needed once there are enough shares
+	session.Destroy()
+	console.SetDeadline(time.Time{})
+	secretBuffer, err := core.CombineShares(shares)
+	if err != nil {
+		console.PrintError(fmt.Sprintf("Could not recover the secret: %v", err))
+		return 1
+	}
+	defer secretBuffer.Destroy()
+	return deliverSecret(console, opts, secretBuffer.Bytes())
+}
+
+// checkNewShare returns a problem that stops share being combined with the shares entered so far,
+// or an empty string if there's none
+func checkNewShare(shares []core.Share, share core.Share) string {
+	for _, existing := range shares {
+		if !bytes.Equal(existing.ID, share.ID) {
+			return "This share is from a different secret than the first one."
+		}
+		if existing.Index == share.Index {
+			return fmt.Sprintf("You already entered share %d.", share.Index)
+		}
+	}
+	return ""
+}
+
+// decodeShareInput reads a share from pasted input: either a share as its receiver sees it, or a
+// share encrypted to the session's key
+func decodeShareInput(session *core.ReceiverSession, input string) (core.Share, error) {
+	if !strings.Contains(input, "secret_share_share>") {
+		secretBuffer, err := decryptInput(session, input)
+		if err != nil {
+			return core.Share{}, err
+		}
+		defer secretBuffer.Destroy()
+		if core.IsSharePayload(secretBuffer.Bytes()) {
+			return core.DecodeShare(secretBuffer.Bytes())
+		}
+		// Share holders send their share as a secret, as it was shown to them
+		return decodeShareText(secretBuffer.Bytes())
+	}
+
+	payload, err := base64.StdEncoding.DecodeString(tui.ExtractShare(input))
+	if err != nil {
+		return core.Share{}, fmt.Errorf("failed to decode share: %w", err)
+	}
+	defer core.Wipe(payload)
+	return core.DecodeShare(payload)
+}
+
+// decodeShareText decodes a share as it's shown to its receiver, between <secret_share_share>
+// tags, from bytes rather than a string so it can be wiped. Whitespace from wrapping and quote
+// prefixes are skipped, as tui.ExtractShare does for pasted shares.
+func decodeShareText(text []byte) (core.Share, error) {
+	if start := bytes.Index(text, []byte("<secret_share_share>")); start != -1 {
+		text = text[start+len("<secret_share_share>"):]
+	}
+	if end := bytes.Index(text, []byte("</secret_share_share>")); end != -1 {
+		text = text[:end]
+	}
+
+	encoded := make([]byte, 0, len(text))
+	defer func() { core.Wipe(encoded) }()
+	for _, c := range text {
+		if c != '>' && c != ' ' && c != '\t' && c != '\r' && c != '\n' {
+			encoded = append(encoded, c)
+		}
+	}
+	payload := make([]byte, base64.StdEncoding.DecodedLen(len(encoded)))
+	defer core.Wipe(payload)
+	n, err := base64.StdEncoding.Decode(payload, encoded)
+	if err != nil {
+		return core.Share{}, fmt.Errorf("failed to decode share: %w", err)
+	}
+	return core.DecodeShare(payload[:n])
+}
+
+// printChecksumMismatch explains that a pasted secret doesn't match its checksum, so it was
+// changed on the way rather than encrypted to another key
+func printChecksumMismatch(console tui.Console) {
+	console.PrintError("Checksum mismatch: message was altered in transit.")
+	console.PrintMessage("Ask the sender to send it again, and copy every line between the tags. Some chat apps change long lines, so try sending it as a file or snippet.")
+}
+
+// printSessionExpired explains that the receiver's key was wiped before a secret arrived
+func printSessionExpired(console tui.Console, opts receiverOptions) {
+	console.PrintError(fmt.Sprintf("This session expired after %s, and its key was wiped.", opts.timeout))
+	console.PrintMessage("Start SecretShare again to get a new key, and ask the sender to encrypt the secret with it.")
+}
+
+// decryptInput extracts the encrypted secret from the pasted input and decrypts it
+func decryptInput(session *core.ReceiverSession, input string) (*core.SecureBuffer, error) {
+	encryptedSecret, err := decodeInput(input)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decrypt the secret
+	return session.DecryptSecret(encryptedSecret)
+}
+
+// decodeInput extracts the encrypted secret from the pasted input
+func decodeInput(input string) ([]byte, error) {
+	// age files can be pasted in age's ASCII armor as well
+	if strings.Contains(input, "-----BEGIN AGE ENCRYPTED FILE-----") {
+		return core.DecodeAgeArmor([]byte(input))
+	}
+
+	// JWEs from JOSE libraries are pasted as they are, or inside the tags
+	if token := strings.TrimSpace(input); core.IsJWESecret([]byte(token)) {
+		return []byte(token), nil
+	}
+
+	// Extract secret from tags, checking its checksum if it's armored
+	secretStr, err := core.Dearmor(tui.ExtractSecret(input))
+	if err != nil {
+		return nil, err
+	}
+	if secretStr == "" {
+		return nil, fmt.Errorf("no secret found in input")
+	}
+	if core.IsJWESecret([]byte(secretStr)) {
+		return []byte(secretStr), nil
+	}
+
+	// Decode base64 secret
+	encryptedSecret, err := base64.StdEncoding.DecodeString(secretStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode secret: %w", err)
+	}
+	return encryptedSecret, nil
+}
+
+// handleReceivedFields displays a structured secret and lets the receiver export or copy its fields
+func handleReceivedFields(console tui.Console, fields []core.Field) {
+	console.PrintSuccess("Here are your secret fields 🤫:")
+	rows := make([][][]byte, 0, len(fields))
+	for _, field := range fields {
+		rows = append(rows, [][]byte{[]byte(field.Key), field.Value})
+	}
+	console.PrintSecretTable([]string{"NAME", "VALUE"}, rows)
+
+	for {
+		input := console.PromptUserSingleChar("Export as [e]nv or [j]son, [c]opy a field, or [q]uit? ")
+		if tui.IsQuit(input) {
+			return
+		}
+
+		switch strings.ToLower(strings.TrimSpace(input)) {
+		case "e":
+			dotEnv, err := core.FormatDotEnv(fields)
+			if err != nil {
+				console.PrintError(fmt.Sprintf("Could not export as .env: %v", err))
+				continue
+			}
+			showExport(console, ".env", string(dotEnv))
+			core.Wipe(dotEnv)
+		case "j":
+			jsonData, err := core.FormatFieldsJSON(fields)
+			if err != nil {
+				console.PrintError(fmt.Sprintf("Could not export as JSON: %v", err))
+				continue
+			}
+			showExport(console, "JSON", string(jsonData))
+		case "c":
+			name := console.PromptUser("Name of the field to copy: ")
+			value, ok := core.LookupField(fields, strings.TrimSpace(name))
+			if !ok {
+				console.PrintError(fmt.Sprintf("No field named '%s'.", strings.TrimSpace(name)))
+				continue
+			}
+			if err := console.SetClipboard(string(value)); err != nil {
+				console.PrintError("Could not copy to clipboard.")
+				continue
+			}
+			console.PrintInfo(fmt.Sprintf("Copied %s to clipboard.", strings.TrimSpace(name)))
+		default:
+			console.PrintError("Invalid input. Please enter 'e', 'j', 'c' (or 'q' to quit).")
+		}
+	}
+}
+
+// showExport displays exported fields and tries to copy them to the clipboard
+func showExport(console tui.Console, format string, text string) {
+	console.PrintInfo(fmt.Sprintf("Your fields as %s:", format))
+	console.PrintMessage(strings.TrimRight(text, "\n"))
+	if err := console.SetClipboard(text); err == nil {
+		console.PrintInfo("Copied to clipboard.")
+	}
+}
+
+// secretAsFields returns the fields of a structured secret, or a single secret as one field named
+// name. The values are copies of the secret, to be wiped with core.WipeFields.
+func secretAsFields(secret []byte, name string) ([]core.Field, error) {
+	if core.IsFieldsPayload(secret) {
+		return core.DecodeFields(secret)
+	}
+	return []core.Field{{Key: name, Value: bytes.Clone(secret)}}, nil
+}
+
+// writeSecretToEnvFile merges the decrypted secret into the .env file from the receiver options.
+// Returns an error if it couldn't be written, and nil if it was or the user quit.
+func writeSecretToEnvFile(console tui.Console, opts receiverOptions, secret []byte) error {
+	name := opts.envName
+	if name == "" && !core.IsFieldsPayload(secret) {
+		name = promptSecretName(console, fmt.Sprintf("Variable name to save the secret as in %s: ", opts.envFile),
+			core.IsValidEnvName, "Use letters, digits and underscores, not starting with a digit.")
+		if name == "" {
+			return nil
+		}
+	}
+
+	fields, err := secretAsFields(secret, name)
+	if err != nil {
+		return fmt.Errorf("failed to read secret fields: %w", err)
+	}
+	defer core.WipeFields(fields)
+
+	backupPath, err := core.WriteDotEnvFile(opts.envFile, fields)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", opts.envFile, err)
+	}
+
+	names := make([]string, 0, len(fields))
+	for _, field := range fields {
+		names = append(names, field.Key)
+	}
+	console.PrintSuccess(fmt.Sprintf("Saved %s to %s 🤫", strings.Join(names, ", "), opts.envFile))
+	if backupPath != "" {
+		console.PrintInfo(fmt.Sprintf("The previous file was backed up to %s.", backupPath))
+	}
+	return nil
+}
+
+// deliveryExitCode reports an error delivering the secret, and returns the exit code for it, so
+// scripts can tell the secret wasn't written
+func deliveryExitCode(console tui.Console, err error) int {
+	if err != nil {
+		console.PrintError(fmt.Sprintf("The secret was not delivered: %v", err))
+		return 1
+	}
+	return 0
+}
+
+// writeKubernetesSecret renders the decrypted secret as a Kubernetes Secret manifest,
+// written to the output file from the receiver options or to stdout. Returns an error if it
+// couldn't be written, and nil if it was or the user quit.
+func writeKubernetesSecret(console tui.Console, opts receiverOptions, secret []byte) error {
+	name := opts.envName
+	if name == "" && !core.IsFieldsPayload(secret) {
+		name = promptSecretName(console, "Key to store the secret under in the Kubernetes Secret: ",
+			core.IsValidKubernetesKey, "Use letters, digits, '-', '_' and '.'.")
+		if name == "" {
+			return nil
+		}
+	}
+
+	fields, err := secretAsFields(secret, name)
+	if err != nil {
+		return fmt.Errorf("failed to read secret fields: %w", err)
+	}
+	defer core.WipeFields(fields)
+
+	manifest, err := core.FormatKubernetesSecret(opts.k8sSecret, opts.k8sNamespace, fields)
+	if err != nil {
+		return fmt.Errorf("failed to create Kubernetes Secret: %w", err)
+	}
+
+	if opts.writesToStdout() {
+		if _, err := opts.stdout.Write(manifest); err != nil {
+			return fmt.Errorf("failed to write to stdout: %w", err)
+		}
+		console.PrintSuccess(fmt.Sprintf("Wrote Secret %s to stdout 🤫", opts.k8sSecret))
+		return nil
+	}
+
+	if err := core.WritePrivateFile(opts.output, manifest); err != nil {
+		return fmt.Errorf("failed to write %s: %w", opts.output, err)
+	}
+	console.PrintSuccess(fmt.Sprintf("Wrote Secret %s to %s 🤫", opts.k8sSecret, opts.output))
+	console.PrintInfo(fmt.Sprintf("Apply it with: kubectl apply -f %s", opts.output))
+	return nil
+}
+
+// promptSecretName asks for the name to store a single secret under, until it passes valid.
+// Returns an empty string if the user quits.
+func promptSecretName(console tui.Console, prompt string, valid func(string) bool, hint string) string {
+	for {
+		input := console.PromptUser(prompt)
+		if tui.IsQuit(input) {
+			console.PrintMessage("Quiting SecretShare")
+			return ""
+		}
+		input = strings.TrimSpace(input)
+		if !valid(input) {
+			console.PrintError("Invalid name. " + hint)
+			continue
+		}
+		return input
+	}
+}
+
+// runWithSecret runs the exec command with the secret added to its environment only.
+// Returns the exit code to exit with.
+func runWithSecret(console tui.Console, opts receiverOptions, secret []byte) int {
+	fields, err := secretAsFields(secret, opts.execName)
+	if err != nil {
+		console.PrintError(fmt.Sprintf("Failed to read secret fields: %v", err))
+		return 1
+	}
+	defer core.WipeFields(fields)
+
+	env := os.Environ()
+	for _, field := range fields {
+		if !core.IsValidEnvName(field.Key) {
+			console.PrintError(fmt.Sprintf("'%s' is not a valid environment variable name.", field.Key))
+			return 1
+		}
+		// exec.Cmd.Env only takes strings, so this copy of the value can't be wiped. The entry is
+		// built in bytes so it's the only one.
+		entry := make([]byte, 0, len(field.Key)+1+len(field.Value))
+		entry = append(append(append(entry, field.Key...), '='), field.Value...)
+		env = append(env, string(entry))
+		core.Wipe(entry)
+	}
+
+	cmd := exec.Command(opts.execArgs[0], opts.execArgs[1:]...)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	// The command now owns the terminal, so stop treating signals as our shutdown and pass them on
+	signal.Stop(shutdownSignals)
+	forward := make(chan os.Signal, 1)
+	signal.Notify(forward, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+
+	if err := cmd.Start(); err != nil {
+		signal.Stop(forward)
+		console.PrintError(fmt.Sprintf("Failed to run %s: %v", opts.execArgs[0], err))
+		return 1
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for sig := range forward {
+			cmd.Process.Signal(sig)
+		}
+	}()
+
+	err = cmd.Wait()
+	signal.Stop(forward)
+	close(forward)
+	<-done
+
+	// Like a shell, a command killed by a signal exits with 128 plus the signal's number
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return 128 + int(status.Signal())
+		}
+		return exitErr.ExitCode()
+	}
+	if err != nil {
+		console.PrintError(fmt.Sprintf("Failed to run %s: %v", opts.execArgs[0], err))
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,690 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scosman/secret_share/core"
+	"github.com/scosman/secret_share/tui"
+)
+
+// receiverKind is the kind of key the receiver gave the sender
+type receiverKind int
+
+const (
+	secretShareReceiver receiverKind = iota // a SecretShare key
+	sshReceiver                             // an SSH public key
+	ageReceiver                             // an age X25519 recipient
+	jwkReceiver                             // an RSA public key as a JWK
+)
+
+// handleSender runs the sender side of the exchange. The secret is encrypted to the receiver's
+// key, with a passphrase agreed with the receiver if opts.passphrase is set, to the SSH public key
+// in opts.sshKey if set, or split into shares for several receivers if opts.split is set. It's
+// shown as a JWE if opts.jwe is set or the receiver gave a JWK.
+func handleSender(console tui.Console, opts senderOptions) {
+	var encrypt func(secret []byte) ([]byte, error)
+	var shareSessions []*core.SenderSession
+	kind := secretShareReceiver
+	jwe := opts.jwe
+	if opts.split > 0 {
+		shareSessions = promptReceiverKeys(console, opts.split)
+		if shareSessions == nil {
+			return
+		}
+	} else if opts.sshKey != "" {
+		session, err := readSSHPublicKey(opts.sshKey)
+		if err != nil {
+			console.PrintError(fmt.Sprintf("Could not read an SSH public key from %s: %v", opts.sshKey, err))
+			return
+		}
+		encrypt = session.EncryptSecret
+		kind = sshReceiver
+	} else if opts.passphrase {
+		passphrase := promptNewPassphrase(console)
+		if passphrase == nil {
+			console.PrintMessage("Quiting SecretShare")
+			return
+		}
+		defer core.Wipe(passphrase)
+		encrypt = func(secret []byte) ([]byte, error) {
+			return core.PassphraseEncrypt(passphrase, secret)
+		}
+	} else {
+		var session *core.SenderSession
+		session, kind = promptReceiverKey(console, "Enter the key sent from the person waiting to receive a secret, or their SSH public key, age recipient or JWK. A SecretShare key is a string wrapped in <secret_share_key> tags: ")
+		if session == nil {
+			return
+		}
+		encrypt = session.EncryptSecret
+
+		// JWEs are encrypted to an RSA key, so SSH, age and other suites' keys can't have one
+		jwe = jwe || kind == jwkReceiver
+		if jwe && (kind == sshReceiver || kind == ageReceiver) {
+			console.PrintError("A JWE can only be encrypted to a SecretShare key or a JWK. Run 'secret_share send' without --jwe.")
+			return
+		}
+		if jwe && !session.CanEncryptJWE() {
+			console.PrintError("This key isn't an RSA key, which a JWE needs. Run 'secret_share send' without --jwe.")
+			return
+		}
+		if jwe {
+			encrypt = func(secret []byte) ([]byte, error) {
+				token, err := session.EncryptSecretJWE(secret)
+				return []byte(token), err
+			}
+		}
+	}
+
+	// Get secret to share, checking for likely mistakes before it's encrypted
+	if opts.timeout > 0 {
+		console.SetDeadline(time.Now().Add(opts.timeout))
+	}
+	var secret []byte
+	for {
+		secret = promptSecretPayload(console)
+		if secret == nil {
+			quitSender(console, opts.timeout)
+			return
+		}
+		confirmed, ok := confirmSecret(console, secret)
+		if !ok || console.TimedOut() {
+			core.Wipe(secret)
+			quitSender(console, opts.timeout)
+			return
+		}
+		if confirmed {
+			break
+		}
+		core.Wipe(secret)
+	}
+	console.SetDeadline(time.Time{})
+
+	if shareSessions != nil {
+		sendShares(console, shareSessions, opts.threshold, opts.qr, secret)
+		core.Wipe(secret)
+		return
+	}
+
+	// Encrypt the secret, then wipe it since it's no longer needed
+	encryptedSecret, err := encrypt(secret)
+	core.Wipe(secret)
+	if err != nil {
+		console.PrintError(fmt.Sprintf("Failed to encrypt secret: %v", err))
+		return
+	}
+
+	// Encode encrypted secret as base64. A JWE is already text, and shared as it is.
+	encryptedSecretFormatted := string(encryptedSecret)
+	if !jwe {
+		encryptedSecretStr := base64.StdEncoding.EncodeToString(encryptedSecret)
+		encryptedSecretFormatted = core.FormatSecret([]byte(encryptedSecretStr))
+	}
+
+	// Display the encrypted secret for sharing
+	console.PrintSuccess("Here's the secret encrypted so only they can decrypt it:")
+	console.PrintMessage(encryptedSecretFormatted)
+	showQRCode(console, encryptedSecretFormatted, opts.qr, opts.qrPNG)
+	if opts.words {
+		showSecretWords(console, encryptedSecret)
+	}
+
+	// Try to copy encrypted secret to clipboard
+	instructions := "Send this secret back to the person who shared their key with you."
+	if opts.passphrase {
+		instructions = "Send this secret to the person you agreed the passphrase with. Never send the passphrase along with it."
+	} else if kind == sshReceiver {
+		instructions = "Send this secret to the owner of the SSH key. They can decrypt it with 'secret_share receive --ssh-key PATH', using their private key."
+	} else if kind == ageReceiver {
+		instructions = "Send this secret back to the person who shared their age recipient with you."
+	} else if jwe {
+		instructions = "Send this JWE back to the person who shared their key with you. JOSE libraries can decrypt it with RSA-OAEP-256 and A256GCM."
+	}
+	err = console.SetClipboard(encryptedSecretFormatted)
+	if err == nil {
+		console.PrintInfo("Copied to clipboard. " + instructions)
+	} else {
+		console.PrintInfo(instructions)
+	}
+	if kind == ageReceiver {
+		// The same age file in age's own format, for receivers using age instead of SecretShare
+		console.PrintInfo("If they use age instead, send this and they can decrypt it with 'age -d -i KEYFILE':")
+		console.PrintMessage(core.EncodeAgeArmor(encryptedSecret))
+	}
+}
+
+// sendShares splits the secret into a share for each receiver, and shows each share encrypted to
+// its receiver's key, with a QR code too if qr is set
+func sendShares(console tui.Console, sessions []*core.SenderSession, threshold int, qr bool, secret []byte) {
+	shares, err := core.SplitSecret(secret, threshold, len(sessions))
+	if err != nil {
+		console.PrintError(fmt.Sprintf("Failed to split secret: %v", err))
+		return
+	}
+
+	encryptedShares := make([]string, len(shares))
+	for i, share := range shares {
+		payload, err := core.EncodeShare(share)
+		if err == nil {
+			var encrypted []byte
+			encrypted, err = sessions[i].EncryptSecret(payload)
+			encryptedShares[i] = core.FormatSecret([]byte(base64.StdEncoding.EncodeToString(encrypted)))
+		}
+		core.Wipe(payload)
+		share.Wipe()
+		if err != nil {
+			console.PrintError(fmt.Sprintf("Failed to encrypt share %d: %v", share.Index, err))
+			return
+		}
+	}
+
+	for i, encryptedShare := range encryptedShares {
+		console.PrintSuccess(fmt.Sprintf("Here's share %d of %d, encrypted so only receiver %d can decrypt it:", i+1, len(shares), i+1))
+		console.PrintMessage(encryptedShare)
+		showQRCode(console, encryptedShare, qr, "")
+	}
+	console.PrintInfo(fmt.Sprintf("Send each share back to the receiver whose key it was encrypted to. Any %d of the %d receivers can recover the secret together, and fewer learn nothing about it.", threshold, len(shares)))
+}
+
+// promptReceiverKeys asks for a different public key from each of count receivers, and returns a
+// session for each. Returns nil if the user quits, or a key needs a newer version.
+func promptReceiverKeys(console tui.Console, count int) []*core.SenderSession {
+	sessions := make([]*core.SenderSession, 0, count)
+	for len(sessions) < count {
+		session, _ := promptReceiverKey(console, fmt.Sprintf("Enter the key from receiver %d of %d, or their SSH public key, age recipient or JWK. A SecretShare key is a string wrapped in <secret_share_key> tags: ", len(sessions)+1, count))
+		if session == nil {
+			return nil
+		}
+
+		duplicate := false
+		for _, existing := range sessions {
+			duplicate = duplicate || existing.SameReceiver(session)
+		}
+		if duplicate {
+			console.PrintError("You already entered this key. Each receiver needs their own key, so no one holds more than one share.")
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions
+}
+
+// promptReceiverKey asks for a receiver's public key until a valid one is entered, and returns a
+// session encrypting to it, and the kind of key. Returns nil if the user quits, or the key needs a
+// newer version.
+func promptReceiverKey(console tui.Console, prompt string) (*core.SenderSession, receiverKind) {
+	for {
+		input := console.PromptBlob(prompt)
+		if tui.IsQuit(input) {
+			console.PrintMessage("Quiting SecretShare")
+			return nil, secretShareReceiver
+		}
+		input, ok := readQRInput(console, input)
+		if !ok {
+			continue
+		}
+		input, ok = readWordsInput(console, input, core.ParseKeyWords)
+		if !ok {
+			continue
+		}
+
+		// SSH public keys are pasted as a line from authorized_keys or a .pub file
+		if line := strings.TrimSpace(input); isSSHPublicKeyLine(line) {
+			session, err := core.NewSenderSessionForSSHKey([]byte(line))
+			if err != nil {
+				console.PrintError(fmt.Sprintf("Could not use the SSH public key: %v", err))
+				console.PrintMessage("SecretShare can encrypt to ssh-ed25519 keys, and ssh-rsa keys of at least 2048 bits.")
+				continue
+			}
+			return session, sshReceiver
+		}
+
+		// JWKs are pasted as JSON, for receivers using JOSE libraries
+		if core.IsJWK(input) {
+			publicKey, err := core.JWKToPublicKey([]byte(strings.TrimSpace(input)))
+			if err != nil {
+				console.PrintError(fmt.Sprintf("Could not use the JWK: %v", err))
+				console.PrintMessage("SecretShare can encrypt to RSA keys of at least 2048 bits, for RSA-OAEP-256.")
+				continue
+			}
+			return core.NewSenderSession(publicKey), jwkReceiver
+		}
+
+		// Extract public key from tags. age recipients are pasted as they are, or inside the tags.
+		publicKeyStr := tui.ExtractPublicKey(input)
+		if recipient := strings.TrimSpace(input); publicKeyStr == "" && core.IsAgeRecipient(recipient) {
+			publicKeyStr = recipient
+		}
+		if core.IsAgeRecipient(publicKeyStr) {
+			session, err := core.NewSenderSessionForAgeRecipient(publicKeyStr)
+			if err != nil {
+				console.PrintError(fmt.Sprintf("Could not use the age recipient: %v", err))
+				console.PrintMessage("Ensure you are pasting the exact age recipient, which starts with 'age1'.")
+				continue
+			}
+			return session, ageReceiver
+		}
+
+		// The key's version prefix picks its suite, once its checksum is checked
+		publicKeyStr, err := core.Dearmor(publicKeyStr)
+		if errors.Is(err, core.ErrChecksumMismatch) {
+			console.PrintError("Checksum mismatch: message was altered in transit.")
+			console.PrintMessage("Ask the receiver to send their key again, and copy every line between the tags.")
+			continue
+		}
+		session, err := core.NewSenderSessionForKey(publicKeyStr)
+		if errors.Is(err, core.ErrNewerVersion) {
+			// Present but it has an unsupported version. The user needs to upgrade.
+			console.PrintError("You need to upgrade SecretSend. This version is too old to handle this key.")
+			return nil, secretShareReceiver
+		}
+		if err != nil || publicKeyStr == "" {
+			console.PrintError("Could not extract public key from input.")
+			console.PrintMessage("Ensure you are pasting the exact secret key from the sender. It should be a string wrapped in tags like '<secret_share_key>'.")
+			continue
+		}
+
+		return session, secretShareReceiver
+	}
+}
+
+// isSSHPublicKeyLine reports whether input looks like an OpenSSH public key, rather than a
+// SecretShare key
+func isSSHPublicKeyLine(input string) bool {
+	if strings.Contains(input, "secret_share_key>") {
+		return false
+	}
+	for _, field := range strings.Fields(input) {
+		if strings.HasPrefix(field, "ssh-") || strings.HasPrefix(field, "ecdsa-") || strings.HasPrefix(field, "sk-") {
+			return true
+		}
+	}
+	return false
+}
+
+// readSSHPublicKey reads the receiver's OpenSSH public key from a .pub or authorized_keys file
+// holding a single key, and returns a session encrypting to it
+func readSSHPublicKey(path string) (*core.SenderSession, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("no public key found")
+	}
+	if len(lines) > 1 {
+		return nil, fmt.Errorf("found %d keys, use a file with only the receiver's key", len(lines))
+	}
+	return core.NewSenderSessionForSSHKey([]byte(lines[0]))
+}
+
+// promptNewPassphrase asks for the passphrase agreed with the receiver, twice to catch typos, and
+// warns if it's weak. Returns nil if the user quits.
+func promptNewPassphrase(console tui.Console) []byte {
+	for {
+		passphrase := console.PromptSecret("Enter the passphrase you agreed with the receiver: ")
+		if passphrase == nil || tui.IsQuitSecret(passphrase) {
+			core.Wipe(passphrase)
+			return nil
+		}
+		if len(passphrase) == 0 {
+			console.PrintError("The passphrase can't be empty.")
+			continue
+		}
+
+		confirmation := console.PromptSecret("Enter the passphrase again: ")
+		matches := bytes.Equal(passphrase, confirmation)
+		core.Wipe(confirmation)
+		if confirmation == nil {
+			core.Wipe(passphrase)
+			return nil
+		}
+		if !matches {
+			core.Wipe(passphrase)
+			console.PrintError("The passphrases don't match. Enter them again.")
+			continue
+		}
+
+		// Anyone who sees the encrypted secret can try to guess the passphrase offline
+		if core.EstimatePasswordEntropy(passphrase) >= core.WeakPasswordBits {
+			return passphrase
+		}
+		console.PrintWarning("This passphrase is weak. Anyone who sees the encrypted secret can try to guess it, so use something long, such as several random words.")
+		confirmed, ok := confirmWeakPassphrase(console)
+		if confirmed {
+			return passphrase
+		}
+		core.Wipe(passphrase)
+		if !ok {
+			return nil
+		}
+	}
+}
+
+// confirmWeakPassphrase asks whether to use a weak passphrase anyway. Returns whether to use it,
+// and false for ok if the user quits.
+func confirmWeakPassphrase(console tui.Console) (confirmed bool, ok bool) {
+	for {
+		input := console.PromptUserSingleChar("Use it anyway? [y]es, or [n]o to enter another: ")
+		if tui.IsQuit(input) {
+			return false, false
+		}
+
+		switch strings.ToLower(strings.TrimSpace(input)) {
+		case "y":
+			return true, true
+		case "n":
+			return false, true
+		}
+
+		console.PrintError("Invalid input. Please enter 'y' or 'n' (or 'q' to quit).")
+	}
+}
+
+// quitSender ends the sender's session before a secret was encrypted, explaining if it timed out
+func quitSender(console tui.Console, timeout time.Duration) {
+	timedOut := console.TimedOut()
+	console.SetDeadline(time.Time{})
+	if timedOut {
+		console.PrintError(fmt.Sprintf("No secret was entered within %s, so nothing was encrypted.", timeout))
+		return
+	}
+	console.PrintMessage("Quiting SecretShare")
+}
+
+// confirmSecret warns about likely mistakes in the secret, such as stray whitespace or a pasted
+// public key, and asks the sender to confirm. Returns whether to send it, and false for ok if the user quits.
+func confirmSecret(console tui.Console, payload []byte) (confirmed bool, ok bool) {
+	var warnings []string
+	if core.IsFieldsPayload(payload) {
+		fields, err := core.DecodeFields(payload)
+		if err == nil {
+			warnings = core.CheckFields(fields)
+			core.WipeFields(fields)
+		}
+	} else {
+		warnings = core.CheckSecret(payload)
+	}
+	if len(warnings) == 0 {
+		return true, true
+	}
+
+	for _, warning := range warnings {
+		console.PrintWarning(warning)
+	}
+
+	for {
+		input := console.PromptUserSingleChar("Send it anyway? [y]es, or [n]o to enter it again: ")
+		if tui.IsQuit(input) {
+			return false, false
+		}
+
+		switch strings.ToLower(strings.TrimSpace(input)) {
+		case "y":
+			return true, true
+		case "n":
+			return false, true
+		}
+
+		console.PrintError("Invalid input. Please enter 'y' or 'n' (or 'q' to quit).")
+	}
+}
+
+// promptSecretPayload asks the sender what to share and returns the payload to encrypt.
+// Returns nil if the user quits.
+func promptSecretPayload(console tui.Console) []byte {
+	for {
+		input := console.PromptUserSingleChar("Share a single [s]ecret, a [m]ulti-line secret, a set of key/value [f]ields, or [g]enerate a new secret? ")
+		if tui.IsQuit(input) {
+			return nil
+		}
+
+		var secret []byte
+		switch strings.ToLower(strings.TrimSpace(input)) {
+		case "s":
+			secret = console.PromptSecret("Enter the secret you want to share: ")
+			if secret == nil || tui.IsQuitSecret(secret) {
+				core.Wipe(secret)
+				return nil
+			}
+		case "m":
+			secret = console.PromptSecretMultiline("Enter the secret you want to share, such as a private key or JSON file:")
+			if secret == nil {
+				return nil
+			}
+		case "f":
+			return promptFields(console)
+		case "g":
+			return promptGeneratedSecret(console)
+		}
+
+		if secret == nil {
+			console.PrintError("Invalid input. Please enter 's' for a single secret, 'm' for a multi-line secret, 'f' for fields or 'g' to generate one (or 'q' to quit).")
+			continue
+		}
+
+		// Typed secrets can't look like the payloads SecretShare makes itself
+		if core.IsReservedSecret(secret) {
+			core.Wipe(secret)
+			console.PrintError("Secrets can't start with a NUL byte, which SecretShare uses to mark shares and fields.")
+			continue
+		}
+		return secret
+	}
+}
+
+// promptFields collects key/value fields, either typed in or imported from a .env file.
+// Returns the encoded payload, or nil if the user quits.
+func promptFields(console tui.Console) []byte {
+	var fields []core.Field
+	defer func() { core.WipeFields(fields) }()
+	for {
+		path := console.PromptUser("Enter the path of a .env file to import, or leave blank to enter fields one at a time: ")
+		if tui.IsQuit(path) {
+			return nil
+		}
+		path = strings.TrimSpace(path)
+		if path == "" {
+			break
+		}
+
+		data, err := os.ReadFile(path)
+		if err == nil {
+			fields, err = core.ParseDotEnv(data)
+			core.Wipe(data)
+		}
+		if err == nil && len(fields) == 0 {
+			err = fmt.Errorf("no variables found")
+		}
+		if err != nil {
+			console.PrintError(fmt.Sprintf("Could not import %s: %v", path, err))
+			continue
+		}
+		break
+	}
+
+	// Enter fields by hand if nothing was imported
+	for len(fields) == 0 {
+		for {
+			name := console.PromptUser("Field name (leave blank when done): ")
+			if tui.IsQuit(name) {
+				return nil
+			}
+			name = strings.TrimSpace(name)
+			if name == "" {
+				break
+			}
+			if !core.IsValidEnvName(name) {
+				// Fields are written to .env files and the environment, so the name must suit both
+				console.PrintError(fmt.Sprintf("'%s' is not a valid environment variable name.", name))
+				console.PrintMessage("Use letters, digits and underscores, not starting with a digit.")
+				continue
+			}
+			if _, exists := core.LookupField(fields, name); exists {
+				console.PrintError(fmt.Sprintf("You already entered a field named '%s'.", name))
+				continue
+			}
+
+			value := console.PromptSecret(fmt.Sprintf("Value for %s: ", name))
+			if value == nil {
+				return nil
+			}
+			fields = append(fields, core.Field{Key: name, Value: value})
+		}
+
+		if len(fields) == 0 {
+			console.PrintError("Enter at least one field (or 'q' to quit).")
+		}
+	}
+
+	names := make([]string, 0, len(fields))
+	for _, field := range fields {
+		names = append(names, field.Key)
+	}
+	console.PrintInfo(fmt.Sprintf("Sharing %d fields: %s", len(fields), strings.Join(names, ", ")))
+
+	payload, err := core.EncodeFields(fields)
+	if err != nil {
+		console.PrintError(fmt.Sprintf("Failed to encode fields: %v", err))
+		return nil
+	}
+	return payload
+}
+
+// promptGeneratedSecret generates a new random secret using a policy chosen by the sender.
+// Returns nil if the user quits.
+func promptGeneratedSecret(console tui.Console) []byte {
+	var secret []byte
+	var err error
+	for secret == nil {
+		input := console.PromptUserSingleChar("Generate a [p]assword, a [w]ord passphrase, a [h]ex token, or a [b]ase64 token? ")
+		if tui.IsQuit(input) {
+			return nil
+		}
+
+		switch strings.ToLower(strings.TrimSpace(input)) {
+		case "p":
+			policy, ok := promptPasswordPolicy(console)
+			if !ok {
+				return nil
+			}
+			secret, err = core.GeneratePassword(policy)
+		case "w":
+			words, ok := promptNumber(console, "Number of words", 6, 1, core.MaxPassphraseWords)
+			if !ok {
+				return nil
+			}
+			secret, err = core.GeneratePassphrase(words)
+		case "h":
+			size, ok := promptNumber(console, "Number of random bytes", 32, 1, core.MaxTokenBytes)
+			if !ok {
+				return nil
+			}
+			secret, err = core.GenerateHexToken(size)
+		case "b":
+			size, ok := promptNumber(console, "Number of random bytes", 32, 1, core.MaxTokenBytes)
+			if !ok {
+				return nil
+			}
+			secret, err = core.GenerateBase64Token(size)
+		default:
+			console.PrintError("Invalid input. Please enter 'p', 'w', 'h' or 'b' (or 'q' to quit).")
+			continue
+		}
+
+		if err != nil {
+			console.PrintError(fmt.Sprintf("Failed to generate secret: %v", err))
+			secret = nil
+		}
+	}
+
+	// The generated secret is never shown unless the sender asks, and only this once
+	for {
+		input := console.PromptUserSingleChar("Secret generated. Show it to you once before it's encrypted? [y/n] ")
+		if tui.IsQuit(input) {
+			return nil
+		}
+
+		switch strings.ToLower(strings.TrimSpace(input)) {
+		case "y":
+			console.PrintSecret("Your new secret 🤫:", secret)
+			console.PrintInfo("Save it now if you need it, it won't be shown again.")
+			return secret
+		case "n":
+			return secret
+		}
+
+		console.PrintError("Invalid input. Please enter 'y' or 'n' (or 'q' to quit).")
+	}
+}
+
+// promptPasswordPolicy asks for the length and character classes of a generated password.
+// Returns false if the user quits.
+func promptPasswordPolicy(console tui.Console) (core.PasswordPolicy, bool) {
+	policy := core.DefaultPasswordPolicy
+
+	length, ok := promptNumber(console, "Password length", policy.Length, 4, core.MaxPasswordLength)
+	if !ok {
+		return policy, false
+	}
+	policy.Length = length
+
+	for {
+		input := console.PromptUser("Character classes: [l]owercase, [u]ppercase, [d]igits, [s]ymbols (default: luds): ")
+		if tui.IsQuit(input) {
+			return policy, false
+		}
+
+		classes := strings.ToLower(strings.TrimSpace(input))
+		if classes == "" {
+			return policy, true
+		}
+		if strings.Trim(classes, "luds") != "" {
+			console.PrintError("Invalid input. Enter any combination of 'l', 'u', 'd' and 's'.")
+			continue
+		}
+
+		policy.Lowercase = strings.Contains(classes, "l")
+		policy.Uppercase = strings.Contains(classes, "u")
+		policy.Digits = strings.Contains(classes, "d")
+		policy.Symbols = strings.Contains(classes, "s")
+		return policy, true
+	}
+}
+
+// promptNumber asks for a number between min and max, using defaultValue if the input is blank.
+// Returns false if the user quits.
+func promptNumber(console tui.Console, label string, defaultValue, min, max int) (int, bool) {
+	for {
+		input := console.PromptUser(fmt.Sprintf("%s (default: %d): ", label, defaultValue))
+		if tui.IsQuit(input) {
+			return 0, false
+		}
+
+		input = strings.TrimSpace(input)
+		if input == "" {
+			return defaultValue, true
+		}
+
+		value, err := strconv.Atoi(input)
+		if err != nil || value < min || value > max {
+			console.PrintError(fmt.Sprintf("Invalid input. Enter a number between %d and %d.", min, max))
+			continue
+		}
+		return value, true
+	}
+}
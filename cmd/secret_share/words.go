@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/scosman/secret_share/core"
+	"github.com/scosman/secret_share/tui"
+)
+
+// showKeyWords shows a shared key as words too, so it can be read aloud or typed from a printout
+func showKeyWords(console tui.Console, key string) {
+	content, err := core.Dearmor(tui.ExtractPublicKey(key))
+	words := ""
+	if err == nil {
+		words, err = core.FormatKeyWords(content)
+	}
+	printWords(console, words, err)
+}
+
+// showSecretWords shows an encrypted secret as words too, like showKeyWords
+func showSecretWords(console tui.Console, encryptedSecret []byte) {
+	words, err := core.FormatSecretWords(encryptedSecret)
+	printWords(console, words, err)
+}
+
+// printWords prints words formatted by core, or why they couldn't be. Text too long for words is
+// reported, and can still be sent as text.
+func printWords(console tui.Console, words string, err error) {
+	if err != nil {
+		console.PrintWarning(fmt.Sprintf("Could not show it as words: %v. Send it as text instead.", err))
+		return
+	}
+	console.PrintInfo("Or read out these words. The last one is a checksum word:")
+	console.PrintMessage(words)
+}
+
+// readWordsInput replaces input that's a key or secret as words with its text, as parse reads
+// it, so it can be typed in from a call or printout. Other input is returned as it is. Returns
+// false if the words can't be read, after explaining why.
+func readWordsInput(console tui.Console, input string, parse func(string) (string, error)) (string, bool) {
+	if !core.IsWords(input) {
+		return input, true
+	}
+	text, err := parse(input)
+	if err != nil {
+		console.PrintError(fmt.Sprintf("Could not read the words: %v", err))
+		console.PrintMessage("Check each word with the person who sent them, and enter them all again. Words can be shortened to their first four letters.")
+		return "", false
+	}
+	console.PrintInfo("Read the words, and their checksum word matches.")
+	return text, true
+}
+
+// parseSecretWords reads an encrypted secret as words, as the base64 text it's pasted as
+func parseSecretWords(input string) (string, error) {
+	encryptedSecret, err := core.ParseSecretWords(input)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(encryptedSecret), nil
+}
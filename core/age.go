@@ -0,0 +1,491 @@
+package core
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// ErrAgeSecret is returned when a secret encrypted with age is decrypted with a SecretShare key
+var ErrAgeSecret = errors.New("this secret was encrypted with age")
+
+// ErrWrongAgeIdentity is returned when an age secret wasn't encrypted to the session's recipient
+var ErrWrongAgeIdentity = errors.New("this secret was encrypted to a different age recipient")
+
+// ErrNotAgeSecret is returned when an age session is given a secret encrypted to a SecretShare key
+var ErrNotAgeSecret = errors.New("this secret was encrypted to a SecretShare key, not an age recipient")
+
+// ageVersionLine starts every age file, in place of an "ssv" version prefix
+const ageVersionLine = "age-encryption.org/v1"
+
+// ageX25519Label binds keys wrapping the file key to age's X25519 recipient type
+const ageX25519Label = "age-encryption.org/v1/X25519"
+
+// ageRecipientHRP is the Bech32 prefix of age recipients
+const ageRecipientHRP = "age"
+
+// ageFileKeySize is the size of the key that encrypts an age file's payload
+const ageFileKeySize = 16
+
+// ageNonceSize is the size of the random nonce the payload key is derived with
+const ageNonceSize = 16
+
+// ageChunkSize is the size of each plaintext chunk of the payload
+const ageChunkSize = 64 * 1024
+
+// ageColumns is the width stanza bodies are wrapped at
+const ageColumns = 64
+
+// ageArmorType is the PEM type of ASCII armored age files
+const ageArmorType = "AGE ENCRYPTED FILE"
+
+// ageEncoding is the base64 encoding used in age headers: standard alphabet, without padding
+var ageEncoding = base64.RawStdEncoding.Strict()
+
+// IsAgeSecret reports whether encrypted data is an age file
+func IsAgeSecret(encryptedData []byte) bool {
+	return bytes.HasPrefix(encryptedData, []byte(ageVersionLine+"\n"))
+}
+
+// IsAgeRecipient reports whether input looks like an age X25519 recipient, such as
+// age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p
+func IsAgeRecipient(input string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(input)), ageRecipientHRP+"1")
+}
+
+// ParseAgeRecipient parses an age X25519 recipient into its public key
+func ParseAgeRecipient(recipient string) ([]byte, error) {
+	hrp, publicKey, err := bech32Decode(strings.TrimSpace(recipient))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse age recipient: %w", err)
+	}
+	if hrp != ageRecipientHRP || len(publicKey) != curve25519.PointSize {
+		return nil, fmt.Errorf("not an age X25519 recipient")
+	}
+	return publicKey, nil
+}
+
+// FormatAgeRecipient formats an X25519 public key as an age recipient
+func FormatAgeRecipient(publicKey []byte) string {
+	recipient, err := bech32Encode(ageRecipientHRP, publicKey)
+	if err != nil {
+		return ""
+	}
+	return recipient
+}
+
+// NewSenderSessionForAgeRecipient creates a sender session that encrypts secrets as age files to an
+// age X25519 recipient, so they can be decrypted by age as well as SecretShare
+func NewSenderSessionForAgeRecipient(recipient string) (*SenderSession, error) {
+	publicKey, err := ParseAgeRecipient(recipient)
+	if err != nil {
+		return nil, err
+	}
+	return &SenderSession{key: &ageRecipientKey{recipient: publicKey}}, nil
+}
+
+// NewAgeReceiverSession creates a receiver session with a one-time age X25519 identity instead of
+// an RSA key pair. Share its AgeRecipient with the sender.
+func NewAgeReceiverSession() (*ReceiverSession, error) {
+	return newSuiteReceiverSession(ageVersionLine + "\n")
+}
+
+func init() {
+	// Age files start with their version line, and receivers share an age recipient rather than
+	// a SecretShare key
+	registerSuite(&suite{prefix: ageVersionLine + "\n", newKey: newAgeKey, readBy: ageKeys, otherKeyErr: &KeyMismatchError{
+		Err:         ErrAgeSecret,
+		Explanation: "This secret was encrypted with age instead of the key above.",
+		Hint:        "Decrypt it with 'age -d' and the matching identity, or run 'secret_share receive --age' and send the new age recipient to the sender.",
+	}})
+}
+
+// notAgeSecret is returned when an age session is given a secret for a suite it doesn't read
+var notAgeSecret = &KeyMismatchError{
+	Err:         ErrNotAgeSecret,
+	Explanation: "This secret was encrypted to a SecretShare key instead of the age recipient above.",
+	Hint:        "Send the age recipient above to the sender, or run 'secret_share receive' without --age.",
+}
+
+// wrongAgeIdentity is returned when an age session is given an age file for other recipients
+var wrongAgeIdentity = &KeyMismatchError{
+	Err:         ErrWrongAgeIdentity,
+	Explanation: "This secret was encrypted to a different age recipient than the one above.",
+}
+
+// ageKey is a one-time age X25519 identity, which reads age files
+type ageKey struct {
+	identity  *SecureBuffer
+	recipient []byte
+}
+
+// newAgeKey generates a one-time age X25519 identity
+func newAgeKey() (receiverKey, error) {
+	scalar := NewSecureBuffer(curve25519.ScalarSize)
+	if _, err := io.ReadFull(rand.Reader, scalar.Bytes()); err != nil {
+		scalar.Destroy()
+		return nil, fmt.Errorf("failed to generate age identity: %w", err)
+	}
+	recipient, err := curve25519.X25519(scalar.Bytes(), curve25519.Basepoint)
+	if err != nil {
+		scalar.Destroy()
+		return nil, fmt.Errorf("failed to generate age identity: %w", err)
+	}
+	return &ageKey{identity: scalar, recipient: recipient}, nil
+}
+
+func (k *ageKey) reads(s *suite) bool {
+	return s.readBy == ageKeys
+}
+
+func (k *ageKey) decrypt(s *suite, encryptedData []byte, alloc func(size int) []byte) ([]byte, error) {
+	plaintext, err := ageDecrypt(k.identity.Bytes(), k.recipient, encryptedData, alloc)
+	if errors.Is(err, ErrWrongAgeIdentity) {
+		return nil, wrongAgeIdentity
+	}
+	return plaintext, err
+}
+
+func (k *ageKey) otherSuiteErr() error {
+	return notAgeSecret
+}
+
+func (k *ageKey) share() (string, error) {
+	return FormatAgeRecipient(k.recipient), nil
+}
+
+func (k *ageKey) wiped() bool {
+	return k.identity == nil
+}
+
+func (k *ageKey) locked() bool {
+	return k.identity != nil && k.identity.Locked()
+}
+
+func (k *ageKey) destroy() {
+	if k.identity != nil {
+		k.identity.Destroy()
+		k.identity = nil
+	}
+}
+
+// ageRecipientKey is a receiver's age X25519 recipient, which secrets are encrypted to as age files
+type ageRecipientKey struct {
+	recipient []byte
+}
+
+func (k *ageRecipientKey) encrypt(random io.Reader, data []byte) ([]byte, error) {
+	return ageEncrypt(random, k.recipient, data)
+}
+
+func (k *ageRecipientKey) equal(other senderKey) bool {
+	otherKey, ok := other.(*ageRecipientKey)
+	return ok && bytes.Equal(k.recipient, otherKey.recipient)
+}
+
+// EncodeAgeArmor returns an age file in age's ASCII armor, for pasting into age directly
+func EncodeAgeArmor(file []byte) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: ageArmorType, Bytes: file}))
+}
+
+// DecodeAgeArmor returns the age file in ASCII armored input, as written by age -a
+func DecodeAgeArmor(input []byte) ([]byte, error) {
+	block, _ := pem.Decode(bytes.TrimSpace(input))
+	if block == nil || block.Type != ageArmorType || len(block.Headers) != 0 {
+		return nil, fmt.Errorf("no armored age file found")
+	}
+	return block.Bytes, nil
+}
+
+// ageEncrypt encrypts data as an age file to an X25519 recipient, reading the file key, the
+// ephemeral scalar and the payload nonce from random in that order. Tests use a fixed random to
+// reproduce known answers.
+func ageEncrypt(random io.Reader, recipient, data []byte) ([]byte, error) {
+	fileKey := make([]byte, ageFileKeySize)
+	defer Wipe(fileKey)
+	if _, err := io.ReadFull(random, fileKey); err != nil {
+		return nil, fmt.Errorf("failed to generate file key: %w", err)
+	}
+	ephemeral := make([]byte, curve25519.ScalarSize)
+	defer Wipe(ephemeral)
+	if _, err := io.ReadFull(random, ephemeral); err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+
+	// Wrap the file key in an X25519 stanza
+	share, err := curve25519.X25519(ephemeral, curve25519.Basepoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive ephemeral public key: %w", err)
+	}
+	shared, err := curve25519.X25519(ephemeral, recipient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to agree key: %w", err)
+	}
+	defer Wipe(shared)
+	wrap, err := ageWrapCipher(shared, share, recipient)
+	if err != nil {
+		return nil, err
+	}
+	body := wrap.Seal(nil, make([]byte, chacha20poly1305.NonceSize), fileKey, nil)
+
+	// The header is authenticated with a MAC keyed by the file key, up to and including "---"
+	var header bytes.Buffer
+	header.WriteString(ageVersionLine + "\n")
+	header.WriteString("-> X25519 " + ageEncoding.EncodeToString(share) + "\n")
+	writeAgeBody(&header, body)
+	header.WriteString("---")
+	mac, err := ageHeaderMAC(fileKey, header.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	header.WriteString(" " + ageEncoding.EncodeToString(mac) + "\n")
+
+	nonce := make([]byte, ageNonceSize)
+	if _, err := io.ReadFull(random, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	payload, err := agePayloadCipher(fileKey, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	// Format: [header][nonce][chunks], each chunk sealed with its counter and a final flag
+	chunks := (len(data) + ageChunkSize - 1) / ageChunkSize
+	if chunks == 0 {
+		chunks = 1
+	}
+	result := make([]byte, 0, header.Len()+len(nonce)+len(data)+chunks*payload.Overhead())
+	result = append(result, header.Bytes()...)
+	result = append(result, nonce...)
+	for i := 0; i < chunks; i++ {
+		end := (i + 1) * ageChunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		result = payload.Seal(result, ageChunkNonce(uint64(i), i == chunks-1), data[i*ageChunkSize:end], nil)
+	}
+	return result, nil
+}
+
+// ageDecrypt decrypts an age file with an X25519 identity into memory from alloc, so the caller
+// controls where the plaintext is kept. Returns ErrWrongAgeIdentity if no stanza is for recipient.
+func ageDecrypt(scalar, recipient, file []byte, alloc func(size int) []byte) ([]byte, error) {
+	header, macLine, payload, stanzas, err := parseAgeHeader(file)
+	if err != nil {
+		return nil, err
+	}
+
+	fileKey := unwrapAgeFileKey(scalar, recipient, stanzas)
+	if fileKey == nil {
+		return nil, ErrWrongAgeIdentity
+	}
+	defer Wipe(fileKey)
+
+	mac, err := ageHeaderMAC(fileKey, header)
+	if err != nil {
+		return nil, err
+	}
+	expected, err := ageEncoding.DecodeString(macLine)
+	if err != nil || !hmac.Equal(mac, expected) {
+		return nil, fmt.Errorf("age header was changed")
+	}
+
+	// Each chunk is full size except the last, which is only empty if the whole payload is
+	if len(payload) < ageNonceSize+chacha20poly1305.Overhead {
+		return nil, fmt.Errorf("invalid age payload")
+	}
+	aead, err := agePayloadCipher(fileKey, payload[:ageNonceSize])
+	if err != nil {
+		return nil, err
+	}
+	payload = payload[ageNonceSize:]
+	sealedChunk := ageChunkSize + aead.Overhead()
+	chunks := (len(payload) + sealedChunk - 1) / sealedChunk
+	lastSize := len(payload) - (chunks-1)*sealedChunk
+	if lastSize < aead.Overhead() || (chunks > 1 && lastSize == aead.Overhead()) {
+		return nil, fmt.Errorf("invalid age payload")
+	}
+
+	plaintext := alloc(len(payload) - chunks*aead.Overhead())
+	for i := 0; i < chunks; i++ {
+		end := (i + 1) * sealedChunk
+		if end > len(payload) {
+			end = len(payload)
+		}
+		out := plaintext[i*ageChunkSize : i*ageChunkSize]
+		if _, err := aead.Open(out, ageChunkNonce(uint64(i), i == chunks-1), payload[i*sealedChunk:end], nil); err != nil {
+			return nil, fmt.Errorf("failed to decrypt age payload: %w", err)
+		}
+	}
+	return plaintext, nil
+}
+
+// ageStanza is a recipient stanza from an age header
+type ageStanza struct {
+	kind string
+	args []string
+	body []byte
+}
+
+// parseAgeHeader splits an age file into its header up to and including "---", the encoded MAC,
+// the payload and the recipient stanzas
+func parseAgeHeader(file []byte) (header []byte, mac string, payload []byte, stanzas []ageStanza, err error) {
+	if !IsAgeSecret(file) {
+		return nil, "", nil, nil, fmt.Errorf("not an age file")
+	}
+	invalid := fmt.Errorf("invalid age header")
+
+	rest := file[len(ageVersionLine)+1:]
+	readLine := func() (string, bool) {
+		end := bytes.IndexByte(rest, '\n')
+		if end < 0 {
+			return "", false
+		}
+		line := string(rest[:end])
+		rest = rest[end+1:]
+		return line, true
+	}
+
+	for {
+		line, ok := readLine()
+		if !ok {
+			return nil, "", nil, nil, invalid
+		}
+		if strings.HasPrefix(line, "--- ") {
+			header = file[:len(file)-len(rest)-len(line)-1+3]
+			return header, line[4:], rest, stanzas, nil
+		}
+		if !strings.HasPrefix(line, "-> ") {
+			return nil, "", nil, nil, invalid
+		}
+		args := strings.Split(line[3:], " ")
+		for _, arg := range args {
+			if arg == "" {
+				return nil, "", nil, nil, invalid
+			}
+		}
+
+		// The body is wrapped at 64 columns, and ends with a shorter line, which may be empty
+		stanza := ageStanza{kind: args[0], args: args[1:]}
+		var encoded strings.Builder
+		for {
+			bodyLine, ok := readLine()
+			if !ok || len(bodyLine) > ageColumns {
+				return nil, "", nil, nil, invalid
+			}
+			encoded.WriteString(bodyLine)
+			if len(bodyLine) < ageColumns {
+				break
+			}
+		}
+		stanza.body, err = ageEncoding.DecodeString(encoded.String())
+		if err != nil {
+			return nil, "", nil, nil, invalid
+		}
+		stanzas = append(stanzas, stanza)
+	}
+}
+
+// unwrapAgeFileKey returns the file key from the first X25519 stanza the identity opens, or nil
+func unwrapAgeFileKey(scalar, recipient []byte, stanzas []ageStanza) []byte {
+	for _, stanza := range stanzas {
+		if stanza.kind != "X25519" || len(stanza.args) != 1 || len(stanza.body) != ageFileKeySize+chacha20poly1305.Overhead {
+			continue
+		}
+		share, err := ageEncoding.DecodeString(stanza.args[0])
+		if err != nil || len(share) != curve25519.PointSize {
+			continue
+		}
+		shared, err := curve25519.X25519(scalar, share)
+		if err != nil {
+			// A low order share gives an all zero secret, which X25519 rejects
+			continue
+		}
+		wrap, err := ageWrapCipher(shared, share, recipient)
+		Wipe(shared)
+		if err != nil {
+			continue
+		}
+		fileKey, err := wrap.Open(nil, make([]byte, chacha20poly1305.NonceSize), stanza.body, nil)
+		if err == nil {
+			return fileKey
+		}
+	}
+	return nil
+}
+
+// writeAgeBody writes a stanza body wrapped at 64 columns, always ending with a shorter line
+func writeAgeBody(header *bytes.Buffer, body []byte) {
+	encoded := ageEncoding.EncodeToString(body)
+	for len(encoded) >= ageColumns {
+		header.WriteString(encoded[:ageColumns] + "\n")
+		encoded = encoded[ageColumns:]
+	}
+	header.WriteString(encoded + "\n")
+}
+
+// ageWrapCipher returns the cipher wrapping the file key for an X25519 stanza
+func ageWrapCipher(shared, share, recipient []byte) (cipher.AEAD, error) {
+	salt := append(append([]byte{}, share...), recipient...)
+	key, err := ageHKDF(shared, salt, ageX25519Label)
+	if err != nil {
+		return nil, err
+	}
+	defer Wipe(key)
+	return chacha20poly1305.New(key)
+}
+
+// ageHeaderMAC returns the HMAC-SHA256 authenticating an age header
+func ageHeaderMAC(fileKey, header []byte) ([]byte, error) {
+	key, err := ageHKDF(fileKey, nil, "header")
+	if err != nil {
+		return nil, err
+	}
+	defer Wipe(key)
+	mac := hmac.New(sha256.New, key)
+	mac.Write(header)
+	return mac.Sum(nil), nil
+}
+
+// agePayloadCipher returns the cipher encrypting an age payload's chunks
+func agePayloadCipher(fileKey, nonce []byte) (cipher.AEAD, error) {
+	key, err := ageHKDF(fileKey, nonce, "payload")
+	if err != nil {
+		return nil, err
+	}
+	defer Wipe(key)
+	return chacha20poly1305.New(key)
+}
+
+// ageHKDF derives a 32 byte key with HKDF-SHA256
+func ageHKDF(secret, salt []byte, info string) ([]byte, error) {
+	key := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, []byte(info)), key); err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	return key, nil
+}
+
+// ageChunkNonce returns the STREAM nonce of a payload chunk: an 11 byte big endian counter, then
+// 1 for the final chunk
+func ageChunkNonce(counter uint64, last bool) []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	binary.BigEndian.PutUint64(nonce[3:11], counter)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// ErrChecksumMismatch is returned when armored content doesn't match its checksum line, because
+// characters were dropped or changed on the way
+var ErrChecksumMismatch = errors.New("checksum mismatch: message was altered in transit")
+
+// armorColumns is the width armored content is wrapped at
+const armorColumns = 64
+
+// armorChecksumSize is the length of the encoded checksum, after its "=" marker
+const armorChecksumSize = 4
+
+// crc24Init and crc24Poly are the CRC-24 parameters of OpenPGP ASCII armor (RFC 4880 section 6.1)
+const (
+	crc24Init = 0xb704ce
+	crc24Poly = 0x1864cfb
+)
+
+// armor formats content between XML-like tags on their own lines, wrapped at armorColumns, with
+// a checksum line so changes in transit are caught before decrypting:
+//
+//	<tag>
+//	ssv1MIIBojANBgkqhkiG9w0BAQEFAAOCAY8AMIIBigKCAYEA...
+//	=XqJf
+//	</tag>
+func armor(tag, content string) string {
+	var b strings.Builder
+	b.WriteString("<" + tag + ">\n")
+	for line := content; line != ""; {
+		n := min(len(line), armorColumns)
+		b.WriteString(line[:n] + "\n")
+		line = line[n:]
+	}
+	b.WriteString(armorChecksum(content) + "\n")
+	b.WriteString("</" + tag + ">")
+	return b.String()
+}
+
+// armorChecksum returns the checksum line of content: "=" and its CRC-24 in base64
+func armorChecksum(content string) string {
+	crc := crc24([]byte(content))
+	return "=" + base64.StdEncoding.EncodeToString([]byte{byte(crc >> 16), byte(crc >> 8), byte(crc)})
+}
+
+// Dearmor checks the checksum of content extracted from armored tags, with whitespace and quote
+// prefixes already removed, and returns the content without it. Content without a checksum, as
+// formatted by earlier versions or pasted bare, is returned as it is. Returns ErrChecksumMismatch
+// if the content was altered.
+func Dearmor(content string) (string, error) {
+	// Base64 only has padding at the end, so a "=" followed by the encoded checksum can't be part
+	// of the content, even when line breaks were lost and the checksum joined its last line
+	marker := len(content) - armorChecksumSize - 1
+	if marker < 0 || content[marker] != '=' || strings.Contains(content[marker+1:], "=") {
+		return content, nil
+	}
+	if armorChecksum(content[:marker]) != content[marker:] {
+		return "", ErrChecksumMismatch
+	}
+	return content[:marker], nil
+}
+
+// crc24 computes the CRC-24 used by OpenPGP ASCII armor
+func crc24(data []byte) uint32 {
+	crc := uint32(crc24Init)
+	for _, b := range data {
+		crc ^= uint32(b) << 16
+		for i := 0; i < 8; i++ {
+			crc <<= 1
+			if crc&0x1000000 != 0 {
+				crc ^= crc24Poly
+			}
+		}
+	}
+	return crc & 0xffffff
+}
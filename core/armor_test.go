@@ -0,0 +1,68 @@
+package core
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// unarmor returns the content of an armored key or secret, as the app extracts it from its tags
+func unarmor(t *testing.T, formatted, tag string) string {
+	t.Helper()
+	content := strings.TrimSuffix(strings.TrimPrefix(formatted, "<"+tag+">"), "</"+tag+">")
+	content, err := Dearmor(strings.Join(strings.Fields(content), ""))
+	if err != nil {
+		t.Fatalf("Failed to dearmor %q: %v", formatted, err)
+	}
+	return content
+}
+
+func TestCRC24(t *testing.T) {
+	// The CRC-24 check value for "123456789", as used by OpenPGP
+	if crc := crc24([]byte("123456789")); crc != 0x21cf02 {
+		t.Errorf("Expected 0x21cf02, got %#06x", crc)
+	}
+}
+
+func TestDearmor(t *testing.T) {
+	random := make([]byte, 200)
+	for i := range random {
+		random[i] = byte(i * 7)
+	}
+	content := "ssv1" + base64.StdEncoding.EncodeToString(random)
+	formatted := FormatSecret([]byte(content))
+	body := strings.Join(strings.Fields(strings.TrimSuffix(strings.TrimPrefix(formatted, "<secret_share_secret>"), "</secret_share_secret>")), "")
+
+	// Test case 1: Armored content round trips
+	if result := unarmor(t, formatted, "secret_share_secret"); result != content {
+		t.Errorf("Test 1 failed: Expected '%s', got '%s'", content, result)
+	}
+
+	// Test case 2: Content without a checksum, from earlier versions, is returned as it is
+	for _, unarmored := range []string{content, "ssv1AB==", "ssv1", "", "eyJhbGciOiJSU0EtT0FFUC0yNTYifQ.a.b.c.d"} {
+		result, err := Dearmor(unarmored)
+		if err != nil || result != unarmored {
+			t.Errorf("Test 2 failed: Expected '%s', got '%s' (err: %v)", unarmored, result, err)
+		}
+	}
+
+	// Test case 3: The checksum follows padding, even when its line break was lost
+	padded := "ssv1AB=="
+	result, err := Dearmor(padded + armorChecksum(padded))
+	if err != nil || result != padded {
+		t.Errorf("Test 3 failed: Expected '%s', got '%s' (err: %v)", padded, result, err)
+	}
+
+	// Test case 4: A changed, dropped or added character is caught
+	altered := []string{
+		strings.Replace(body, "ssv1", "ssv2", 1),
+		body[:10] + body[11:],
+		body[:10] + "A" + body[10:],
+	}
+	for i, input := range altered {
+		if _, err := Dearmor(input); !errors.Is(err, ErrChecksumMismatch) {
+			t.Errorf("Test 4 failed: Expected checksum mismatch for change %d, got %v", i+1, err)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// bech32Charset maps 5-bit values to the characters of a Bech32 string
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// bech32Generator holds the BCH code's generator coefficients from BIP 173
+var bech32Generator = [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+// bech32Polymod computes the Bech32 checksum polynomial over 5-bit values
+func bech32Polymod(values []byte) uint32 {
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i, g := range bech32Generator {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= g
+			}
+		}
+	}
+	return chk
+}
+
+// bech32HRPExpand expands the human readable part for the checksum
+func bech32HRPExpand(hrp string) []byte {
+	expanded := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]>>5)
+	}
+	expanded = append(expanded, 0)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]&31)
+	}
+	return expanded
+}
+
+// convertBits regroups data from fromBits to toBits per value. Encoding pads the last group with
+// zeros, and decoding rejects padding that isn't all zeros or is a whole group.
+func convertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+	var acc uint32
+	var bits uint
+	maxValue := uint32(1)<<toBits - 1
+	out := make([]byte, 0, len(data)*int(fromBits)/int(toBits)+1)
+	for _, b := range data {
+		if uint32(b)>>fromBits != 0 {
+			return nil, fmt.Errorf("invalid data")
+		}
+		acc = acc<<fromBits | uint32(b)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			out = append(out, byte(acc>>bits&maxValue))
+		}
+	}
+	if pad {
+		if bits > 0 {
+			out = append(out, byte(acc<<(toBits-bits)&maxValue))
+		}
+	} else if bits >= fromBits || acc<<(toBits-bits)&maxValue != 0 {
+		return nil, fmt.Errorf("invalid padding")
+	}
+	return out, nil
+}
+
+// bech32Encode encodes data as a lowercase Bech32 string with the human readable part hrp. Unlike
+// BIP 173 there's no length limit, as in age.
+func bech32Encode(hrp string, data []byte) (string, error) {
+	values, err := convertBits(data, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	hrp = strings.ToLower(hrp)
+	checksumInput := append(bech32HRPExpand(hrp), values...)
+	checksumInput = append(checksumInput, 0, 0, 0, 0, 0, 0)
+	polymod := bech32Polymod(checksumInput) ^ 1
+
+	var encoded strings.Builder
+	encoded.WriteString(hrp)
+	encoded.WriteByte('1')
+	for _, v := range values {
+		encoded.WriteByte(bech32Charset[v])
+	}
+	for i := 0; i < 6; i++ {
+		encoded.WriteByte(bech32Charset[polymod>>uint(5*(5-i))&31])
+	}
+	return encoded.String(), nil
+}
+
+// bech32Decode decodes a Bech32 string into its lowercase human readable part and data. Mixed
+// case is rejected, and there's no length limit.
+func bech32Decode(encoded string) (string, []byte, error) {
+	if strings.ToLower(encoded) != encoded && strings.ToUpper(encoded) != encoded {
+		return "", nil, fmt.Errorf("mixed case")
+	}
+	encoded = strings.ToLower(encoded)
+	separator := strings.LastIndexByte(encoded, '1')
+	if separator < 1 || separator+7 > len(encoded) {
+		return "", nil, fmt.Errorf("invalid separator position")
+	}
+	hrp := encoded[:separator]
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return "", nil, fmt.Errorf("invalid character in human readable part")
+		}
+	}
+
+	values := make([]byte, 0, len(encoded)-separator-1)
+	for i := separator + 1; i < len(encoded); i++ {
+		v := strings.IndexByte(bech32Charset, encoded[i])
+		if v < 0 {
+			return "", nil, fmt.Errorf("invalid character: %q", encoded[i])
+		}
+		values = append(values, byte(v))
+	}
+	if bech32Polymod(append(bech32HRPExpand(hrp), values...)) != 1 {
+		return "", nil, fmt.Errorf("invalid checksum")
+	}
+
+	data, err := convertBits(values[:len(values)-6], 5, 8, false)
+	if err != nil {
+		return "", nil, err
+	}
+	return hrp, data, nil
+}
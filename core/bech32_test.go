@@ -0,0 +1,59 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBech32Decode(t *testing.T) {
+	// Test case 1: Valid strings from BIP 173
+	valid := []string{
+		"A12UEL5L",
+		"a12uel5l",
+		"abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw",
+		"11qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqc8247j",
+		"split1checkupstagehandshakeupstreamerranterredcaperred2y9e3w",
+		"?1ezyfcl",
+	}
+	for _, encoded := range valid {
+		if _, _, err := bech32Decode(encoded); err != nil {
+			t.Errorf("Test 1 failed: Expected '%s' to decode, got %v", encoded, err)
+		}
+	}
+
+	// Test case 2: Invalid strings from BIP 173, and mixed case
+	invalid := []string{
+		"pzry9x0s0muk",  // no separator
+		"1pzry9x0s0muk", // empty human readable part
+		"x1b4n0q5v",     // invalid data character
+		"li1dgmt3",      // checksum too short
+		"A1G7SGD8",      // checksum calculated with an uppercase human readable part
+		"10a06t8",       // empty human readable part
+		"A12uEL5L",      // mixed case
+	}
+	for _, encoded := range invalid {
+		if _, _, err := bech32Decode(encoded); err == nil {
+			t.Errorf("Test 2 failed: Expected error decoding '%s'", encoded)
+		}
+	}
+}
+
+func TestBech32RoundTrip(t *testing.T) {
+	for size := 0; size <= 40; size++ {
+		data := make([]byte, size)
+		for i := range data {
+			data[i] = byte(i*37 + size)
+		}
+		encoded, err := bech32Encode("test", data)
+		if err != nil {
+			t.Fatalf("Failed to encode %d bytes: %v", size, err)
+		}
+		hrp, decoded, err := bech32Decode(encoded)
+		if err != nil {
+			t.Fatalf("Failed to decode %d bytes: %v", size, err)
+		}
+		if hrp != "test" || !bytes.Equal(decoded, data) {
+			t.Errorf("Expected test and %x, got %s and %x", data, hrp, decoded)
+		}
+	}
+}
@@ -0,0 +1,579 @@
+package core
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/ssh"
+)
+
+var updateCompat = flag.Bool("update-compat", false, "regenerate testdata/compat.json with a new key")
+
+// compatPath holds keys and secrets as written by each envelope version, including versions
+// newer than this one, and the outcome each reader should give. The app's tests read it too.
+const compatPath = "testdata/compat.json"
+
+// compatFile is the JSON layout of the compatibility fixtures
+type compatFile struct {
+	Description        string          `json:"description"`
+	ReceiverPrivateKey string          `json:"receiver_private_key"` // base64 PKCS#8 DER, the receiver of every secret
+	Keys               []compatFixture `json:"keys"`
+	Secrets            []compatFixture `json:"secrets"`
+}
+
+// compatFixture is a key or encrypted secret as it was shared, with tags
+type compatFixture struct {
+	Name        string        `json:"name"`
+	Data        string        `json:"data"`
+	Expect      string        `json:"expect"` // "ok", "passphrase", "ssh", "age" or "hpke" when it needs the passphrase, SSH key, age identity or HPKE key, "upgrade" when this version should ask the user to upgrade, or "checksum" when it was altered after it was armored
+	Passphrase  string        `json:"passphrase,omitempty"`
+	SSHKey      string        `json:"ssh_private_key,omitempty"`  // OpenSSH private key of the receiver's SSH key
+	AgeIdentity string        `json:"age_identity,omitempty"`     // the receiver's age identity, as written by age-keygen
+	HPKEKey     string        `json:"hpke_private_key,omitempty"` // hex, the receiver's X25519 private key for ssv4
+	Plaintext   string        `json:"plaintext,omitempty"`
+	Fields      []compatField `json:"fields,omitempty"`
+}
+
+// compatField is a field of a fixture's expected payload, with its value as a JSON string
+type compatField struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// compatFields returns fields as they're written in the fixtures
+func compatFields(fields []Field) []compatField {
+	compat := make([]compatField, 0, len(fields))
+	for _, field := range fields {
+		compat = append(compat, compatField{Key: field.Key, Value: string(field.Value)})
+	}
+	return compat
+}
+
+const compatDescription = "Keys and encrypted secrets from each envelope version, and whether this version reads them with the receiver key (ok), " +
+	"with the passphrase (passphrase), with the receiver's SSH key (ssh), with the receiver's age identity (age), with the receiver's ssv4 HPKE key (hpke), or asks the user to upgrade (upgrade). " +
+	"Secrets altered after they were armored fail their checksum (checksum). JWKs and JWEs are shared as they are, without tags."
+
+// compatWriters are the envelope versions this build can write
+var compatWriters = []struct {
+	version string
+	prefix  string // how the version's output starts
+	encrypt func(*rsa.PublicKey, []byte) ([]byte, error)
+}{
+	{"ssv1", "ssv1", HybridEncrypt},
+	{"jwe", "eyJ", func(publicKey *rsa.PublicKey, data []byte) ([]byte, error) {
+		token, err := JWEEncrypt(publicKey, data)
+		return []byte(token), err
+	}},
+}
+
+// compatReaders are the ways this build decrypts a secret
+var compatReaders = []struct {
+	name    string
+	decrypt func(*rsa.PrivateKey, []byte) ([]byte, error)
+}{
+	{"HybridDecrypt", HybridDecrypt},
+	{"ReceiverSession", func(privateKey *rsa.PrivateKey, data []byte) ([]byte, error) {
+		buffer, err := NewReceiverSessionWithKey(privateKey).DecryptSecret(data)
+		if err != nil {
+			return nil, err
+		}
+		// Copy the secret out so the buffer can be released
+		defer buffer.Destroy()
+		return append([]byte{}, buffer.Bytes()...), nil
+	}},
+}
+
+// loadCompat reads the compatibility fixtures and their receiver key
+func loadCompat(t *testing.T) (compatFile, *rsa.PrivateKey) {
+	t.Helper()
+	if *updateCompat {
+		writeCompat(t)
+	}
+
+	data, err := os.ReadFile(compatPath)
+	if err != nil {
+		t.Fatalf("Failed to read compatibility fixtures: %v", err)
+	}
+	var file compatFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatalf("Failed to parse compatibility fixtures: %v", err)
+	}
+
+	der, err := base64.StdEncoding.DecodeString(file.ReceiverPrivateKey)
+	if err != nil {
+		t.Fatalf("Invalid private key base64: %v", err)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		t.Fatalf("Invalid private key: %v", err)
+	}
+	privateKey, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		t.Fatal("Expected an RSA private key")
+	}
+	return file, privateKey
+}
+
+func TestCompatSecrets(t *testing.T) {
+	file, privateKey := loadCompat(t)
+	if len(file.Secrets) == 0 {
+		t.Fatal("Expected secret fixtures")
+	}
+
+	for _, fixture := range file.Secrets {
+		for _, reader := range compatReaders {
+			t.Run(fixture.Name+"/"+reader.name, func(t *testing.T) {
+				// JWEs are shared as they are, without tags or base64
+				envelope := []byte(fixture.Data)
+				if !IsJWESecret(envelope) {
+					encoded := strings.TrimSuffix(strings.TrimPrefix(fixture.Data, "<secret_share_secret>"), "</secret_share_secret>")
+					encoded, err := Dearmor(strings.Join(strings.Fields(encoded), ""))
+					if fixture.Expect == "checksum" {
+						if !errors.Is(err, ErrChecksumMismatch) {
+							t.Errorf("Expected checksum mismatch error, got %v", err)
+						}
+						return
+					}
+					if err != nil {
+						t.Fatalf("Failed to dearmor: %v", err)
+					}
+					if envelope, err = base64.StdEncoding.DecodeString(encoded); err != nil {
+						t.Fatalf("Invalid secret base64: %v", err)
+					}
+				}
+
+				decrypted, err := reader.decrypt(privateKey, envelope)
+				switch fixture.Expect {
+				case "ok":
+					if err != nil {
+						t.Fatalf("Failed to decrypt: %v", err)
+					}
+					checkCompatPayload(t, fixture, decrypted)
+				case "passphrase":
+					if !errors.Is(err, ErrPassphraseSecret) {
+						t.Errorf("Expected passphrase secret error, got %v", err)
+					}
+					decrypted, err := PassphraseDecrypt([]byte(fixture.Passphrase), envelope)
+					if err != nil {
+						t.Fatalf("Failed to decrypt with the passphrase: %v", err)
+					}
+					checkCompatPayload(t, fixture, decrypted)
+				case "ssh":
+					if !errors.Is(err, ErrSSHKeySecret) {
+						t.Errorf("Expected SSH key secret error, got %v", err)
+					}
+					identity, err := ParseSSHIdentity([]byte(fixture.SSHKey), nil)
+					if err != nil {
+						t.Fatalf("Failed to parse SSH key: %v", err)
+					}
+					defer identity.Destroy()
+					buffer, err := identity.DecryptSecret(envelope)
+					if err != nil {
+						t.Fatalf("Failed to decrypt with the SSH key: %v", err)
+					}
+					defer buffer.Destroy()
+					checkCompatPayload(t, fixture, buffer.Bytes())
+				case "age":
+					if !errors.Is(err, ErrAgeSecret) {
+						t.Errorf("Expected age secret error, got %v", err)
+					}
+					scalar, err := parseAgeIdentity(fixture.AgeIdentity)
+					if err != nil {
+						t.Fatalf("Failed to parse age identity: %v", err)
+					}
+					recipient, err := curve25519.X25519(scalar, curve25519.Basepoint)
+					if err != nil {
+						t.Fatalf("Failed to derive age recipient: %v", err)
+					}
+					decrypted, err := ageDecrypt(scalar, recipient, envelope, func(size int) []byte { return make([]byte, size) })
+					if err != nil {
+						t.Fatalf("Failed to decrypt with the age identity: %v", err)
+					}
+					checkCompatPayload(t, fixture, decrypted)
+				case "hpke":
+					if !errors.Is(err, ErrHPKESecret) {
+						t.Errorf("Expected HPKE secret error, got %v", err)
+					}
+					key, err := hex.DecodeString(fixture.HPKEKey)
+					if err != nil {
+						t.Fatalf("Invalid HPKE key hex: %v", err)
+					}
+					session, err := NewHPKEReceiverSessionWithKey(key)
+					if err != nil {
+						t.Fatalf("Failed to create HPKE session: %v", err)
+					}
+					defer session.Destroy()
+					buffer, err := session.DecryptSecret(envelope)
+					if err != nil {
+						t.Fatalf("Failed to decrypt with the HPKE key: %v", err)
+					}
+					defer buffer.Destroy()
+					checkCompatPayload(t, fixture, buffer.Bytes())
+				case "upgrade":
+					if !errors.Is(err, ErrNewerVersion) {
+						t.Errorf("Expected newer version error, got %v", err)
+					}
+				default:
+					t.Fatalf("Unknown expectation %s", fixture.Expect)
+				}
+			})
+		}
+	}
+}
+
+func TestCompatWritersAndReaders(t *testing.T) {
+	_, privateKey := loadCompat(t)
+	fields, err := EncodeFields([]Field{{Key: "username", Value: []byte("admin")}})
+	if err != nil {
+		t.Fatalf("Failed to encode fields: %v", err)
+	}
+
+	// Every version this build writes must be read by every reader
+	for _, writer := range compatWriters {
+		for _, reader := range compatReaders {
+			for _, plaintext := range [][]byte{[]byte("Xk2#pQ9!vL7@mN4$wR8&"), fields, {}} {
+				encrypted, err := writer.encrypt(&privateKey.PublicKey, plaintext)
+				if err != nil {
+					t.Fatalf("%s failed to encrypt: %v", writer.version, err)
+				}
+				if !bytes.HasPrefix(encrypted, []byte(writer.prefix)) {
+					t.Errorf("Expected %s envelope, got prefix %q", writer.version, encrypted[:4])
+				}
+
+				decrypted, err := reader.decrypt(privateKey, encrypted)
+				if err != nil {
+					t.Fatalf("%s failed to decrypt %s: %v", reader.name, writer.version, err)
+				}
+				if !bytes.Equal(decrypted, plaintext) {
+					t.Errorf("%s read %s as %q, expected %q", reader.name, writer.version, decrypted, plaintext)
+				}
+			}
+		}
+	}
+}
+
+// checkCompatPayload checks a decrypted fixture matches its expected plaintext or fields
+func checkCompatPayload(t *testing.T, fixture compatFixture, decrypted []byte) {
+	t.Helper()
+	if fixture.Fields == nil {
+		if string(decrypted) != fixture.Plaintext {
+			t.Errorf("Expected '%s', got '%s'", fixture.Plaintext, decrypted)
+		}
+		return
+	}
+
+	// Properties added by newer versions are ignored
+	decoded, err := DecodeFields(decrypted)
+	if err != nil {
+		t.Fatalf("Failed to decode fields: %v", err)
+	}
+	if !reflect.DeepEqual(compatFields(decoded), fixture.Fields) {
+		t.Errorf("Expected fields %v, got %v", fixture.Fields, decoded)
+	}
+}
+
+// writeCompat regenerates the compatibility fixtures with a new receiver key
+func writeCompat(t *testing.T) {
+	t.Helper()
+	privateKey, publicKey, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatalf("Failed to serialize private key: %v", err)
+	}
+	publicKeyBytes, err := PublicKeyToBytes(publicKey)
+	if err != nil {
+		t.Fatalf("Failed to serialize public key: %v", err)
+	}
+	publicKeyStr := base64.StdEncoding.EncodeToString(publicKeyBytes)
+
+	file := compatFile{
+		Description:        compatDescription,
+		ReceiverPrivateKey: base64.StdEncoding.EncodeToString(der),
+		Keys: []compatFixture{
+			{Name: "ssv1 key", Data: "<secret_share_key>ssv1" + publicKeyStr + "</secret_share_key>", Expect: "ok"},
+			{Name: "unversioned key", Data: "<secret_share_key>" + publicKeyStr + "</secret_share_key>", Expect: "ok"},
+			{Name: "newer ssv9 key", Data: "<secret_share_key>ssv9" + publicKeyStr + "</secret_share_key>", Expect: "upgrade"},
+			jwkCompatKey(t, publicKey),
+		},
+	}
+
+	encryptFixture := func(name string, payload []byte) string {
+		encrypted, err := HybridEncrypt(publicKey, payload)
+		if err != nil {
+			t.Fatalf("Failed to encrypt %s: %v", name, err)
+		}
+		return formatLegacySecret(base64.StdEncoding.EncodeToString(encrypted))
+	}
+
+	plaintext := "Xk2#pQ9!vL7@mN4$wR8&"
+	multiline := "-----BEGIN DATA-----\nline1\nline2\n-----END DATA-----\n"
+	fields := []Field{{Key: "username", Value: []byte("admin")}, {Key: "password", Value: []byte(plaintext)}}
+	fieldsPayload, err := EncodeFields(fields)
+	if err != nil {
+		t.Fatalf("Failed to encode fields: %v", err)
+	}
+	// A payload from a newer version, with properties this version doesn't know about
+	newerFieldsPayload := []byte(fieldsPayloadPrefix + `{"fields":[{"key":"username","value":"admin","type":"login"},` +
+		`{"key":"password","value":"` + plaintext + `","type":"password","expires":"2030-01-01"}],` +
+		`"created_by":"secret_share v9","ttl":3600}`)
+
+	newerSecret := make([]byte, 420)
+	if _, err := rand.Read(newerSecret); err != nil {
+		t.Fatalf("Failed to generate fixture: %v", err)
+	}
+	copy(newerSecret, "ssv9")
+
+	file.Secrets = []compatFixture{
+		{Name: "ssv1 secret", Data: encryptFixture("ssv1 secret", []byte(plaintext)), Expect: "ok", Plaintext: plaintext},
+		{Name: "ssv1 multi-line secret", Data: encryptFixture("ssv1 multi-line secret", []byte(multiline)), Expect: "ok", Plaintext: multiline},
+		{Name: "ssv1 fields", Data: encryptFixture("ssv1 fields", fieldsPayload), Expect: "ok", Fields: compatFields(fields)},
+		{Name: "ssv1 fields with newer properties", Data: encryptFixture("ssv1 fields with newer properties", newerFieldsPayload), Expect: "ok", Fields: compatFields(fields)},
+		{Name: "newer ssv9 secret", Data: formatLegacySecret(base64.StdEncoding.EncodeToString(newerSecret)), Expect: "upgrade"},
+	}
+
+	file.Secrets = append(file.Secrets, ssv2CompatSecrets(t)...)
+	file.Secrets = append(file.Secrets, ssv3CompatSecrets(t)...)
+	file.Secrets = append(file.Secrets, ageCompatSecrets(t)...)
+	file.Secrets = append(file.Secrets, jweCompatSecrets(t, publicKey)...)
+	hpkeKey, hpkeSecrets := ssv4CompatFixtures(t)
+	file.Keys = append(file.Keys, hpkeKey)
+	file.Secrets = append(file.Secrets, hpkeSecrets...)
+	armoredKey, armoredSecrets := armoredCompatFixtures(t, publicKey)
+	file.Keys = append(file.Keys, armoredKey)
+	file.Secrets = append(file.Secrets, armoredSecrets...)
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(file); err != nil {
+		t.Fatalf("Failed to encode compatibility fixtures: %v", err)
+	}
+	if err := os.WriteFile(compatPath, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write compatibility fixtures: %v", err)
+	}
+}
+
+// ssv2CompatSecrets generates secrets encrypted with a passphrase
+func ssv2CompatSecrets(t *testing.T) []compatFixture {
+	t.Helper()
+	passphrase := "correct horse battery staple"
+	plaintext := "Xk2#pQ9!vL7@mN4$wR8&"
+	fields := []Field{{Key: "username", Value: []byte("admin")}, {Key: "password", Value: []byte(plaintext)}}
+	fieldsPayload, err := EncodeFields(fields)
+	if err != nil {
+		t.Fatalf("Failed to encode fields: %v", err)
+	}
+
+	encryptFixture := func(name string, payload []byte) string {
+		encrypted, err := PassphraseEncrypt([]byte(passphrase), payload)
+		if err != nil {
+			t.Fatalf("Failed to encrypt %s: %v", name, err)
+		}
+		return formatLegacySecret(base64.StdEncoding.EncodeToString(encrypted))
+	}
+	return []compatFixture{
+		{Name: "ssv2 passphrase secret", Data: encryptFixture("ssv2 passphrase secret", []byte(plaintext)), Expect: "passphrase", Passphrase: passphrase, Plaintext: plaintext},
+		{Name: "ssv2 passphrase fields", Data: encryptFixture("ssv2 passphrase fields", fieldsPayload), Expect: "passphrase", Passphrase: passphrase, Fields: compatFields(fields)},
+	}
+}
+
+// ssv3CompatSecrets generates secrets encrypted to an ssh-ed25519 key
+func ssv3CompatSecrets(t *testing.T) []compatFixture {
+	t.Helper()
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	block, err := ssh.MarshalPrivateKey(privateKey, "compat")
+	if err != nil {
+		t.Fatalf("Failed to serialize SSH key: %v", err)
+	}
+	sshPrivateKey := string(pem.EncodeToMemory(block))
+	sshKey, err := ssh.NewPublicKey(publicKey)
+	if err != nil {
+		t.Fatalf("Failed to convert key: %v", err)
+	}
+	session, err := NewSenderSessionForSSHKey(ssh.MarshalAuthorizedKey(sshKey))
+	if err != nil {
+		t.Fatalf("Failed to parse SSH public key: %v", err)
+	}
+
+	plaintext := "Xk2#pQ9!vL7@mN4$wR8&"
+	fields := []Field{{Key: "username", Value: []byte("admin")}, {Key: "password", Value: []byte(plaintext)}}
+	fieldsPayload, err := EncodeFields(fields)
+	if err != nil {
+		t.Fatalf("Failed to encode fields: %v", err)
+	}
+	encryptFixture := func(name string, payload []byte) string {
+		encrypted, err := session.EncryptSecret(payload)
+		if err != nil {
+			t.Fatalf("Failed to encrypt %s: %v", name, err)
+		}
+		return formatLegacySecret(base64.StdEncoding.EncodeToString(encrypted))
+	}
+	return []compatFixture{
+		{Name: "ssv3 ssh-ed25519 secret", Data: encryptFixture("ssv3 ssh-ed25519 secret", []byte(plaintext)), Expect: "ssh", SSHKey: sshPrivateKey, Plaintext: plaintext},
+		{Name: "ssv3 ssh-ed25519 fields", Data: encryptFixture("ssv3 ssh-ed25519 fields", fieldsPayload), Expect: "ssh", SSHKey: sshPrivateKey, Fields: compatFields(fields)},
+	}
+}
+
+// ageCompatSecrets generates age files encrypted to an age identity
+func ageCompatSecrets(t *testing.T) []compatFixture {
+	t.Helper()
+	scalar := make([]byte, curve25519.ScalarSize)
+	if _, err := rand.Read(scalar); err != nil {
+		t.Fatalf("Failed to generate identity: %v", err)
+	}
+	identity, err := formatAgeIdentity(scalar)
+	if err != nil {
+		t.Fatalf("Failed to format identity: %v", err)
+	}
+	recipient, err := curve25519.X25519(scalar, curve25519.Basepoint)
+	if err != nil {
+		t.Fatalf("Failed to derive recipient: %v", err)
+	}
+	session, err := NewSenderSessionForAgeRecipient(FormatAgeRecipient(recipient))
+	if err != nil {
+		t.Fatalf("Failed to parse age recipient: %v", err)
+	}
+
+	plaintext := "Xk2#pQ9!vL7@mN4$wR8&"
+	fields := []Field{{Key: "username", Value: []byte("admin")}, {Key: "password", Value: []byte(plaintext)}}
+	fieldsPayload, err := EncodeFields(fields)
+	if err != nil {
+		t.Fatalf("Failed to encode fields: %v", err)
+	}
+	encryptFixture := func(name string, payload []byte) string {
+		encrypted, err := session.EncryptSecret(payload)
+		if err != nil {
+			t.Fatalf("Failed to encrypt %s: %v", name, err)
+		}
+		return formatLegacySecret(base64.StdEncoding.EncodeToString(encrypted))
+	}
+	return []compatFixture{
+		{Name: "age secret", Data: encryptFixture("age secret", []byte(plaintext)), Expect: "age", AgeIdentity: identity, Plaintext: plaintext},
+		{Name: "age fields", Data: encryptFixture("age fields", fieldsPayload), Expect: "age", AgeIdentity: identity, Fields: compatFields(fields)},
+	}
+}
+
+// jwkCompatKey returns the receiver's key as a JWK, as shared for JOSE senders
+func jwkCompatKey(t *testing.T, publicKey *rsa.PublicKey) compatFixture {
+	t.Helper()
+	jwk, err := PublicKeyToJWK(publicKey)
+	if err != nil {
+		t.Fatalf("Failed to convert public key: %v", err)
+	}
+	return compatFixture{Name: "jwk key", Data: string(jwk), Expect: "ok"}
+}
+
+// jweCompatSecrets generates JWEs encrypted to the receiver's key
+func jweCompatSecrets(t *testing.T, publicKey *rsa.PublicKey) []compatFixture {
+	t.Helper()
+	plaintext := "Xk2#pQ9!vL7@mN4$wR8&"
+	fields := []Field{{Key: "username", Value: []byte("admin")}, {Key: "password", Value: []byte(plaintext)}}
+	fieldsPayload, err := EncodeFields(fields)
+	if err != nil {
+		t.Fatalf("Failed to encode fields: %v", err)
+	}
+	encryptFixture := func(name string, payload []byte) string {
+		token, err := JWEEncrypt(publicKey, payload)
+		if err != nil {
+			t.Fatalf("Failed to encrypt %s: %v", name, err)
+		}
+		return token
+	}
+	return []compatFixture{
+		{Name: "jwe secret", Data: encryptFixture("jwe secret", []byte(plaintext)), Expect: "ok", Plaintext: plaintext},
+		{Name: "jwe fields", Data: encryptFixture("jwe fields", fieldsPayload), Expect: "ok", Fields: compatFields(fields)},
+	}
+}
+
+// ssv4CompatFixtures generates an ssv4 key and secrets encrypted to it with HPKE
+func ssv4CompatFixtures(t *testing.T) (compatFixture, []compatFixture) {
+	t.Helper()
+	session, err := NewHPKEReceiverSession()
+	if err != nil {
+		t.Fatalf("Failed to create HPKE session: %v", err)
+	}
+	defer session.Destroy()
+	privateKey := hex.EncodeToString(session.key.(*hpkeKey).privateKey.Bytes())
+	sender, err := NewSenderSessionForHPKEKey(session.key.(*hpkeKey).publicKey)
+	if err != nil {
+		t.Fatalf("Failed to create sender session: %v", err)
+	}
+
+	plaintext := "Xk2#pQ9!vL7@mN4$wR8&"
+	fields := []Field{{Key: "username", Value: []byte("admin")}, {Key: "password", Value: []byte(plaintext)}}
+	fieldsPayload, err := EncodeFields(fields)
+	if err != nil {
+		t.Fatalf("Failed to encode fields: %v", err)
+	}
+	encryptFixture := func(name string, payload []byte) string {
+		encrypted, err := sender.EncryptSecret(payload)
+		if err != nil {
+			t.Fatalf("Failed to encrypt %s: %v", name, err)
+		}
+		return formatLegacySecret(base64.StdEncoding.EncodeToString(encrypted))
+	}
+	sharedKey := "<secret_share_key>ssv4" + base64.StdEncoding.EncodeToString(session.key.(*hpkeKey).publicKey) + "</secret_share_key>"
+	key := compatFixture{Name: "ssv4 key", Data: sharedKey, Expect: "hpke", HPKEKey: privateKey}
+	return key, []compatFixture{
+		{Name: "ssv4 secret", Data: encryptFixture("ssv4 secret", []byte(plaintext)), Expect: "hpke", HPKEKey: privateKey, Plaintext: plaintext},
+		{Name: "ssv4 fields", Data: encryptFixture("ssv4 fields", fieldsPayload), Expect: "hpke", HPKEKey: privateKey, Fields: compatFields(fields)},
+	}
+}
+
+// formatLegacySecret formats an encoded secret as versions before armoring did, on one line
+func formatLegacySecret(encoded string) string {
+	return "<secret_share_secret>" + encoded + "</secret_share_secret>"
+}
+
+// armoredCompatFixtures generates the receiver's key and a secret for it in the armored format,
+// with a copy of the secret altered in transit
+func armoredCompatFixtures(t *testing.T, publicKey *rsa.PublicKey) (compatFixture, []compatFixture) {
+	t.Helper()
+	publicKeyBytes, err := PublicKeyToBytes(publicKey)
+	if err != nil {
+		t.Fatalf("Failed to serialize public key: %v", err)
+	}
+	plaintext := "Xk2#pQ9!vL7@mN4$wR8&"
+	encrypted, err := HybridEncrypt(publicKey, []byte(plaintext))
+	if err != nil {
+		t.Fatalf("Failed to encrypt armored secret: %v", err)
+	}
+	secret := FormatSecret([]byte(base64.StdEncoding.EncodeToString(encrypted)))
+
+	// Swap two characters of the first line, as a mangled paste might
+	lines := strings.Split(secret, "\n")
+	line := []byte(lines[1])
+	line[20], line[21] = line[21], line[20]
+	if line[20] == line[21] {
+		line[20] ^= 1
+	}
+	lines[1] = string(line)
+	altered := strings.Join(lines, "\n")
+
+	key := compatFixture{Name: "armored ssv1 key", Data: FormatPublicKey([]byte(base64.StdEncoding.EncodeToString(publicKeyBytes))), Expect: "ok"}
+	return key, []compatFixture{
+		{Name: "armored ssv1 secret", Data: secret, Expect: "ok", Plaintext: plaintext},
+		{Name: "altered armored ssv1 secret", Data: altered, Expect: "checksum"},
+	}
+}
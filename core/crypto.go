@@ -7,9 +7,16 @@ import (
 	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
 )
 
+// ErrNewerVersion is returned when data was created by a newer version of SecretShare
+var ErrNewerVersion = errors.New("this secret was sent using a newer version of SecretShare - please upgrade")
+
 // GenerateKeyPair generates a new RSA key pair with 3072 bits
 func GenerateKeyPair() (*rsa.PrivateKey, *rsa.PublicKey, error) {
 	privateKey, err := rsa.GenerateKey(rand.Reader, 3072)
@@ -21,8 +28,13 @@ func GenerateKeyPair() (*rsa.PrivateKey, *rsa.PublicKey, error) {
 
 // GenerateSymmetricKey generates a random 256-bit AES key
 func GenerateSymmetricKey() ([]byte, error) {
+	return generateSymmetricKey(rand.Reader)
+}
+
+// generateSymmetricKey reads a 256-bit AES key from random
+func generateSymmetricKey(random io.Reader) ([]byte, error) {
 	key := make([]byte, 32) // 32 bytes = 256 bits
-	_, err := rand.Read(key)
+	_, err := io.ReadFull(random, key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate symmetric key: %w", err)
 	}
@@ -31,46 +43,67 @@ func GenerateSymmetricKey() ([]byte, error) {
 
 // GenerateNonce generates a random nonce for AES-GCM
 func GenerateNonce() ([]byte, error) {
+	return generateNonce(rand.Reader)
+}
+
+// generateNonce reads a nonce for AES-GCM from random
+func generateNonce(random io.Reader) ([]byte, error) {
 	nonce := make([]byte, 12) // Standard GCM nonce size
-	_, err := rand.Read(nonce)
+	_, err := io.ReadFull(random, nonce)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate nonce: %w", err)
 	}
 	return nonce, nil
 }
 
+// newAESGCM creates an AES-GCM cipher with a 256-bit key
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	// Create AES cipher
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AES cipher: %w", err)
+	}
+
+	// Create GCM mode
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM mode: %w", err)
+	}
+	return gcm, nil
+}
+
 // HybridEncrypt encrypts data using hybrid encryption:
 // 1. Generates a random AES-256 key
 // 2. Encrypts the AES key with RSA-OAEP
 // 3. Encrypts the data with AES-GCM
 // 4. Prepends "ssv1" format version identifier
 func HybridEncrypt(publicKey *rsa.PublicKey, data []byte) ([]byte, error) {
+	return hybridEncrypt(rand.Reader, publicKey, data)
+}
+
+// hybridEncrypt implements HybridEncrypt, reading the AES key, the RSA-OAEP seed and the nonce
+// from random in that order. Tests use a fixed random to reproduce known answers.
+func hybridEncrypt(random io.Reader, publicKey *rsa.PublicKey, data []byte) ([]byte, error) {
 	// Generate a random symmetric key
-	symmetricKey, err := GenerateSymmetricKey()
+	symmetricKey, err := generateSymmetricKey(random)
 	if err != nil {
 		return nil, err
 	}
+	defer Wipe(symmetricKey)
 
 	// Encrypt the symmetric key with RSA-OAEP
-	encryptedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, publicKey, symmetricKey, nil)
+	encryptedKey, err := rsa.EncryptOAEP(sha256.New(), random, publicKey, symmetricKey, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to encrypt symmetric key: %w", err)
 	}
 
-	// Create AES cipher
-	block, err := aes.NewCipher(symmetricKey)
+	gcm, err := newAESGCM(symmetricKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create AES cipher: %w", err)
-	}
-
-	// Create GCM mode
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create GCM mode: %w", err)
+		return nil, err
 	}
 
 	// Generate nonce
-	nonce, err := GenerateNonce()
+	nonce, err := generateNonce(random)
 	if err != nil {
 		return nil, err
 	}
@@ -108,34 +141,34 @@ func HybridEncrypt(publicKey *rsa.PublicKey, data []byte) ([]byte, error) {
 // 1. Checks for format version prefix
 // 2. Decrypts the AES key with RSA-OAEP
 // 3. Decrypts the data with AES-GCM
+// JWEs in compact serialization using the same algorithms are decrypted too.
 func HybridDecrypt(privateKey *rsa.PrivateKey, encryptedData []byte) ([]byte, error) {
-	if len(encryptedData) < 4 {
-		return nil, fmt.Errorf("invalid encrypted data format")
-	}
+	return hybridDecrypt(privateKey, encryptedData, func(size int) []byte {
+		return make([]byte, size)
+	})
+}
 
-	// Check format version
-	versionPrefix := encryptedData[0:4]
-	if string(versionPrefix) == "ssv1" {
-		// Valid format version, proceed with decryption (skip the 4-byte prefix)
-		encryptedData = encryptedData[4:]
-	} else if len(encryptedData) >= 3 && string(encryptedData[0:3]) == "ssv" {
-		// Recognizable format but newer version
-		return nil, fmt.Errorf("this secret was sent using a newer version of SecretShare - please upgrade")
-	} else {
-		// Invalid format
-		return nil, fmt.Errorf("invalid encrypted data format")
-	}
+// hybridDecrypt implements HybridDecrypt, decrypting into memory from alloc so the caller
+// controls where the plaintext is kept. Envelopes of other suites report what they need.
+func hybridDecrypt(privateKey *rsa.PrivateKey, encryptedData []byte, alloc func(size int) []byte) ([]byte, error) {
+	return openEnvelope(&rsaKey{privateKey: privateKey}, encryptedData, alloc)
+}
 
+// ssv1Decrypt decrypts an ssv1 envelope with an RSA private key, into memory from alloc
+func ssv1Decrypt(privateKey *rsa.PrivateKey, encryptedData []byte, alloc func(size int) []byte) ([]byte, error) {
+	// Skip the 4-byte version prefix
+	encryptedData = encryptedData[4:]
 	if len(encryptedData) < 4 {
 		return nil, fmt.Errorf("invalid encrypted data format")
 	}
 
-	// Extract key length
-	keyLen := int(encryptedData[0])<<24 | int(encryptedData[1])<<16 | int(encryptedData[2])<<8 | int(encryptedData[3])
-
-	if len(encryptedData) < 4+keyLen+12 {
+	// Extract key length. It's attacker controlled, so compare it unsigned before any int
+	// conversion, which could overflow to a negative length on 32-bit platforms.
+	keyLen64 := uint64(binary.BigEndian.Uint32(encryptedData[0:4]))
+	if keyLen64+12 > uint64(len(encryptedData)-4) {
 		return nil, fmt.Errorf("invalid encrypted data format")
 	}
+	keyLen := int(keyLen64)
 
 	// Extract encrypted key
 	encryptedKey := encryptedData[4 : 4+keyLen]
@@ -152,21 +185,19 @@ func HybridDecrypt(privateKey *rsa.PrivateKey, encryptedData []byte) ([]byte, er
 	if err != nil {
 		return nil, fmt.Errorf("failed to decrypt symmetric key: %w", err)
 	}
+	defer Wipe(symmetricKey)
 
-	// Create AES cipher
-	block, err := aes.NewCipher(symmetricKey)
+	gcm, err := newAESGCM(symmetricKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create AES cipher: %w", err)
+		return nil, err
 	}
 
-	// Create GCM mode
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create GCM mode: %w", err)
+	// Decrypt data, straight into the plaintext's memory
+	size := len(ciphertext) - gcm.Overhead()
+	if size < 0 {
+		size = 0
 	}
-
-	// Decrypt data
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	plaintext, err := gcm.Open(alloc(size)[:0], nonce, ciphertext, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decrypt data: %w", err)
 	}
@@ -193,3 +224,93 @@ func BytesToPublicKey(data []byte) (*rsa.PublicKey, error) {
 
 	return rsaPub, nil
 }
+
+func init() {
+	registerSuite(&suite{
+		prefix:   "ssv1",
+		newKey:   newRSAKey,
+		parseKey: parseRSAKey,
+		readBy:   rsaKeys,
+		openRSA:  ssv1Decrypt,
+		untagged: true,
+	})
+}
+
+// rsaKey is an RSA private key, which reads ssv1 envelopes and JWEs. Unlike other suites' keys it
+// isn't kept in locked memory: crypto/rsa holds the key in big.Ints it allocates itself, along
+// with unexported copies, so it's in ordinary memory that can be swapped to disk. Destroy wipes
+// what it can reach, and the rest is left to the garbage collector.
+type rsaKey struct {
+	privateKey *rsa.PrivateKey
+	publicKey  *rsa.PublicKey
+}
+
+// newRSAKey generates a one-time RSA key for an ssv1 receiver
+func newRSAKey() (receiverKey, error) {
+	privateKey, publicKey, err := GenerateKeyPair()
+	if err != nil {
+		return nil, err
+	}
+	return &rsaKey{privateKey: privateKey, publicKey: publicKey}, nil
+}
+
+func (k *rsaKey) reads(s *suite) bool {
+	return s.readBy == rsaKeys
+}
+
+func (k *rsaKey) decrypt(s *suite, encryptedData []byte, alloc func(size int) []byte) ([]byte, error) {
+	return s.openRSA(k.privateKey, encryptedData, alloc)
+}
+
+func (k *rsaKey) otherSuiteErr() error {
+	return fmt.Errorf("this secret was encrypted to a different key")
+}
+
+func (k *rsaKey) share() (string, error) {
+	publicKeyBytes, err := PublicKeyToBytes(k.publicKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize public key: %w", err)
+	}
+	return FormatPublicKey([]byte(base64.StdEncoding.EncodeToString(publicKeyBytes))), nil
+}
+
+func (k *rsaKey) wiped() bool {
+	return k.privateKey == nil
+}
+
+func (k *rsaKey) locked() bool {
+	return false
+}
+
+func (k *rsaKey) destroy() {
+	if k.privateKey != nil {
+		wipePrivateKey(k.privateKey)
+		k.privateKey = nil
+	}
+}
+
+// rsaPublicKey is a receiver's RSA public key, from a SecretShare key, an ssh-rsa key or a JWK
+type rsaPublicKey struct {
+	publicKey *rsa.PublicKey
+}
+
+// parseRSAKey parses a shared ssv1 key, which is a PKIX public key
+func parseRSAKey(data []byte) (senderKey, error) {
+	publicKey, err := BytesToPublicKey(data)
+	if err != nil {
+		return nil, err
+	}
+	return &rsaPublicKey{publicKey}, nil
+}
+
+func (k *rsaPublicKey) encrypt(random io.Reader, data []byte) ([]byte, error) {
+	if k.publicKey == nil {
+		return nil, fmt.Errorf("receiver public key is not set")
+	}
+	return hybridEncrypt(random, k.publicKey, data)
+}
+
+func (k *rsaPublicKey) equal(other senderKey) bool {
+	otherKey, ok := other.(*rsaPublicKey)
+	return ok && k.publicKey != nil && k.publicKey.Equal(otherKey.publicKey)
+}
@@ -1,7 +1,12 @@
 package core
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/rsa"
+	"crypto/x509"
+	"sync"
 	"testing"
 )
 
@@ -132,11 +137,11 @@ func TestReceiverSenderSession(t *testing.T) {
 		t.Fatalf("Failed to create receiver session: %v", err)
 	}
 
-	if receiverSession.privateKey == nil {
+	if receiverSession.key.(*rsaKey).privateKey == nil {
 		t.Error("Receiver session private key should not be nil")
 	}
 
-	if receiverSession.publicKey == nil {
+	if receiverSession.key.(*rsaKey).publicKey == nil {
 		t.Error("Receiver session public key should not be nil")
 	}
 
@@ -152,7 +157,7 @@ func TestReceiverSenderSession(t *testing.T) {
 		t.Error("Sender session should not be nil")
 	}
 
-	if senderSession.receiverPublicKey == nil {
+	if senderSession.key.(*rsaPublicKey).publicKey == nil {
 		t.Error("Sender session receiver public key should not be nil")
 	}
 
@@ -168,10 +173,12 @@ func TestReceiverSenderSession(t *testing.T) {
 	}
 
 	// Test secret decryption
-	decryptedSecret, err := receiverSession.DecryptSecret(encryptedSecret)
+	decryptedBuffer, err := receiverSession.DecryptSecret(encryptedSecret)
 	if err != nil {
 		t.Fatalf("Failed to decrypt secret: %v", err)
 	}
+	defer decryptedBuffer.Destroy()
+	decryptedSecret := decryptedBuffer.Bytes()
 
 	if string(secret) != string(decryptedSecret) {
 		t.Errorf("Decrypted secret does not match original. Expected: %s, Got: %s",
@@ -193,8 +200,7 @@ func TestSenderSessionWithNilPublicKey(t *testing.T) {
 func TestReceiverSessionWithNilPrivateKey(t *testing.T) {
 	// Create receiver session with nil private key
 	receiverSession := &ReceiverSession{
-		privateKey: nil,
-		publicKey:  &rsa.PublicKey{},
+		key: &rsaKey{privateKey: nil, publicKey: &rsa.PublicKey{}},
 	}
 
 	// Try to decrypt secret
@@ -240,8 +246,8 @@ func TestHybridDecryptNewerVersion(t *testing.T) {
 		t.Fatalf("Failed to generate key pair: %v", err)
 	}
 
-	// Create test data with "ssv" prefix but not "ssv1"
-	testData := []byte("ssv2some data that would normally be encrypted")
+	// Create test data with an "ssv" prefix for a version this build doesn't know
+	testData := []byte("ssv9some data that would normally be encrypted")
 
 	// Try to decrypt
 	_, err = HybridDecrypt(privateKey, testData)
@@ -278,3 +284,126 @@ func TestHybridDecryptInvalidFormat(t *testing.T) {
 		t.Errorf("Expected error message '%s', got '%s'", expectedMsg, err.Error())
 	}
 }
+
+func TestHybridDecryptInvalidKeyLength(t *testing.T) {
+	privateKey := fuzzPrivateKey(t)
+	body := make([]byte, 400)
+
+	// Test case 1: Key length with the top bit set, which is negative as an int on 32-bit platforms
+	_, err := HybridDecrypt(privateKey, append([]byte("ssv1\x80\x00\x00\x00"), body...))
+	if err == nil || err.Error() != "invalid encrypted data format" {
+		t.Errorf("Test 1 failed: Expected invalid format error, got %v", err)
+	}
+
+	// Test case 2: Largest key length, which overflows when the header size is added on 32-bit platforms
+	_, err = HybridDecrypt(privateKey, append([]byte("ssv1\xff\xff\xff\xff"), body...))
+	if err == nil || err.Error() != "invalid encrypted data format" {
+		t.Errorf("Test 2 failed: Expected invalid format error, got %v", err)
+	}
+
+	// Test case 3: Key length past the end of the data
+	_, err = HybridDecrypt(privateKey, append([]byte("ssv1\x00\x00\x01\x85"), body...))
+	if err == nil || err.Error() != "invalid encrypted data format" {
+		t.Errorf("Test 3 failed: Expected invalid format error, got %v", err)
+	}
+
+	// Test case 4: Key length leaving no room for the nonce
+	_, err = HybridDecrypt(privateKey, append([]byte("ssv1\x00\x00\x01\x88"), body[:392]...))
+	if err == nil || err.Error() != "invalid encrypted data format" {
+		t.Errorf("Test 4 failed: Expected invalid format error, got %v", err)
+	}
+
+	// Test case 5: Truncated key length
+	_, err = HybridDecrypt(privateKey, []byte("ssv1\x00\x01"))
+	if err == nil || err.Error() != "invalid encrypted data format" {
+		t.Errorf("Test 5 failed: Expected invalid format error, got %v", err)
+	}
+}
+
+var (
+	fuzzKeyOnce sync.Once
+	fuzzKey     *rsa.PrivateKey
+	fuzzKeyErr  error
+)
+
+// fuzzPrivateKey returns a key pair shared by the fuzz targets, since generating one is slow
+func fuzzPrivateKey(tb testing.TB) *rsa.PrivateKey {
+	tb.Helper()
+	fuzzKeyOnce.Do(func() {
+		fuzzKey, _, fuzzKeyErr = GenerateKeyPair()
+	})
+	if fuzzKeyErr != nil {
+		tb.Fatalf("Failed to generate key pair: %v", fuzzKeyErr)
+	}
+	return fuzzKey
+}
+
+func FuzzHybridDecrypt(f *testing.F) {
+	privateKey := fuzzPrivateKey(f)
+	valid, err := HybridEncrypt(&privateKey.PublicKey, []byte("fuzz secret"))
+	if err != nil {
+		f.Fatalf("Failed to encrypt data: %v", err)
+	}
+
+	f.Add(valid)
+	f.Add(valid[:len(valid)-1])
+	f.Add(valid[:8+384])
+	f.Add(valid[:8])
+	f.Add([]byte("ssv1"))
+	f.Add([]byte("ssv2"))
+	f.Add([]byte("ssv"))
+	f.Add([]byte{})
+	f.Add([]byte("ssv1\x80\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"))
+	f.Add([]byte("ssv1\xff\xff\xff\xf4\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"))
+	f.Add([]byte("ssv1\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		plaintext, err := HybridDecrypt(privateKey, data)
+		if err == nil && plaintext == nil {
+			t.Error("Expected plaintext when decryption succeeds")
+		}
+	})
+}
+
+func FuzzBytesToPublicKey(f *testing.F) {
+	privateKey := fuzzPrivateKey(f)
+	rsaBytes, err := PublicKeyToBytes(&privateKey.PublicKey)
+	if err != nil {
+		f.Fatalf("Failed to serialize public key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		f.Fatalf("Failed to generate EC key: %v", err)
+	}
+	ecBytes, err := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+	if err != nil {
+		f.Fatalf("Failed to serialize EC key: %v", err)
+	}
+
+	f.Add(rsaBytes)
+	f.Add(rsaBytes[:len(rsaBytes)-1])
+	f.Add(rsaBytes[:len(rsaBytes)/2])
+	f.Add(ecBytes)
+	f.Add([]byte{})
+	f.Add([]byte{0x30, 0x82, 0xff, 0xff})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		publicKey, err := BytesToPublicKey(data)
+		if err != nil {
+			return
+		}
+
+		// Any key that parses must serialize and parse back to the same key
+		encoded, err := PublicKeyToBytes(publicKey)
+		if err != nil {
+			t.Fatalf("Failed to serialize parsed key: %v", err)
+		}
+		parsed, err := BytesToPublicKey(encoded)
+		if err != nil {
+			t.Fatalf("Failed to parse serialized key: %v", err)
+		}
+		if parsed.N.Cmp(publicKey.N) != 0 || parsed.E != publicKey.E {
+			t.Error("Parsed key does not match the original")
+		}
+	})
+}
@@ -0,0 +1,203 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// ParseDotEnv parses the contents of a .env file into fields.
+// Supports comments, blank lines, an optional "export " prefix, and single or double quoted values.
+// Values are copied out of data into their own bytes, so data and the fields can be wiped separately.
+func ParseDotEnv(data []byte) ([]Field, error) {
+	var fields []Field
+	seen := make(map[string]int)
+
+	lineNum := 0
+	for len(data) > 0 {
+		lineNum++
+		var line []byte
+		if end := bytes.IndexByte(data, '\n'); end != -1 {
+			line, data = data[:end], data[end+1:]
+		} else {
+			line, data = data, nil
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		line = bytes.TrimPrefix(line, []byte("export "))
+
+		eqIdx := bytes.IndexByte(line, '=')
+		if eqIdx == -1 {
+			WipeFields(fields)
+			return nil, fmt.Errorf("line %d: expected NAME=value", lineNum)
+		}
+
+		key := string(bytes.TrimSpace(line[:eqIdx]))
+		if !IsValidEnvName(key) {
+			WipeFields(fields)
+			return nil, fmt.Errorf("line %d: invalid variable name %q", lineNum, key)
+		}
+
+		value, err := parseDotEnvValue(bytes.TrimSpace(line[eqIdx+1:]))
+		if err != nil {
+			WipeFields(fields)
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+
+		// Later definitions override earlier ones, matching how shells source .env files
+		if idx, ok := seen[key]; ok {
+			Wipe(fields[idx].Value)
+			fields[idx].Value = value
+			continue
+		}
+		seen[key] = len(fields)
+		fields = append(fields, Field{Key: key, Value: value})
+	}
+
+	return fields, nil
+}
+
+// parseDotEnvValue parses the value side of a NAME=value line into a new slice
+func parseDotEnvValue(raw []byte) ([]byte, error) {
+	if len(raw) == 0 {
+		return []byte{}, nil
+	}
+
+	switch raw[0] {
+	case '\'':
+		// Single quoted values are literal
+		end := bytes.IndexByte(raw[1:], '\'')
+		if end == -1 {
+			return nil, fmt.Errorf("unterminated single quoted value")
+		}
+		return bytes.Clone(raw[1 : end+1]), nil
+	case '"':
+		// Double quoted values support backslash escapes, and are never longer than raw
+		value := make([]byte, 0, len(raw))
+		for i := 1; i < len(raw); i++ {
+			c := raw[i]
+			switch {
+			case c == '"':
+				return value, nil
+			case c == '\\' && i+1 < len(raw):
+				i++
+				switch raw[i] {
+				case 'n':
+					value = append(value, '\n')
+				case 'r':
+					value = append(value, '\r')
+				case 't':
+					value = append(value, '\t')
+				default:
+					value = append(value, raw[i])
+				}
+			default:
+				value = append(value, c)
+			}
+		}
+		Wipe(value)
+		return nil, fmt.Errorf("unterminated double quoted value")
+	}
+
+	// Unquoted values end at an inline comment
+	if commentIdx := bytes.Index(raw, []byte(" #")); commentIdx != -1 {
+		raw = raw[:commentIdx]
+	}
+	return bytes.Clone(bytes.TrimSpace(raw)), nil
+}
+
+// FormatDotEnv formats fields as the contents of a .env file. The contents hold the values, so
+// the caller wipes them once they're written.
+func FormatDotEnv(fields []Field) ([]byte, error) {
+	lines := make([][]byte, 0, len(fields))
+	defer func() {
+		for _, line := range lines {
+			Wipe(line)
+		}
+	}()
+	size := 0
+	for _, field := range fields {
+		line, err := FormatDotEnvLine(field)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+		size += len(line) + 1
+	}
+
+	// One buffer sized up front, so no outgrown copies of the values are left behind
+	data := make([]byte, 0, size)
+	for _, line := range lines {
+		data = append(append(data, line...), '\n')
+	}
+	return data, nil
+}
+
+// FormatDotEnvLine formats a single field as a NAME=value line, quoting the value when needed.
+// The line holds the value, so the caller wipes it once it's written.
+func FormatDotEnvLine(field Field) ([]byte, error) {
+	if !IsValidEnvName(field.Key) {
+		return nil, fmt.Errorf("%q is not a valid environment variable name", field.Key)
+	}
+	quoted := quoteDotEnvValue(field.Value)
+	defer Wipe(quoted)
+	line := make([]byte, 0, len(field.Key)+1+len(quoted))
+	return append(append(append(line, field.Key...), '='), quoted...), nil
+}
+
+// quoteDotEnvValue quotes a value if it contains characters a .env parser would interpret. The
+// result holds the value, so the caller wipes it once it's written.
+func quoteDotEnvValue(value []byte) []byte {
+	if len(value) > 0 && !bytes.ContainsAny(value, " \t\r\n\"'\\#$`=") {
+		return append(make([]byte, 0, len(value)), value...)
+	}
+
+	// Single quotes are literal, so prefer them to avoid $ interpolation by other .env readers
+	if !bytes.ContainsAny(value, "'\r\n") {
+		quoted := make([]byte, 0, len(value)+2)
+		return append(append(append(quoted, '\''), value...), '\'')
+	}
+
+	// Every escape adds one byte, so the quoted value is sized up front
+	size := len(value) + 2
+	for _, c := range value {
+		if strings.IndexByte("\n\r\t\"\\$`", c) != -1 {
+			size++
+		}
+	}
+	quoted := make([]byte, 0, size)
+	quoted = append(quoted, '"')
+	for _, c := range value {
+		switch c {
+		case '\n':
+			quoted = append(quoted, '\\', 'n')
+		case '\r':
+			quoted = append(quoted, '\\', 'r')
+		case '\t':
+			quoted = append(quoted, '\\', 't')
+		case '"', '\\', '$', '`':
+			// Escape interpolation too, for .env readers that expand $VAR and `command`
+			quoted = append(quoted, '\\', c)
+		default:
+			quoted = append(quoted, c)
+		}
+	}
+	return append(quoted, '"')
+}
+
+// IsValidEnvName checks if a name can be used as an environment variable name
+func IsValidEnvName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		isLetter := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_'
+		isDigit := c >= '0' && c <= '9'
+		if !isLetter && !(isDigit && i > 0) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,142 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseDotEnv(t *testing.T) {
+	data := []byte(`# Database credentials
+DB_USER=admin
+export DB_PASS="p@ss \"word\"\nline2"
+DB_HOST = db.example.com # primary
+
+SINGLE='literal $HOME \n'
+EMPTY=
+DB_USER=override
+`)
+
+	fields, err := ParseDotEnv(data)
+	if err != nil {
+		t.Fatalf("Failed to parse .env data: %v", err)
+	}
+
+	expected := []Field{
+		{Key: "DB_USER", Value: []byte("override")},
+		{Key: "DB_PASS", Value: []byte("p@ss \"word\"\nline2")},
+		{Key: "DB_HOST", Value: []byte("db.example.com")},
+		{Key: "SINGLE", Value: []byte("literal $HOME \\n")},
+		{Key: "EMPTY", Value: []byte("")},
+	}
+
+	if len(fields) != len(expected) {
+		t.Fatalf("Expected %d fields, got %d: %v", len(expected), len(fields), fields)
+	}
+	for i := range expected {
+		if fields[i].Key != expected[i].Key || !bytes.Equal(fields[i].Value, expected[i].Value) {
+			t.Errorf("Field %d does not match. Expected: %q, Got: %q", i, expected[i], fields[i])
+		}
+	}
+}
+
+func TestParseDotEnvInvalid(t *testing.T) {
+	// Test case 1: Missing equals sign
+	if _, err := ParseDotEnv([]byte("NOT_A_VARIABLE\n")); err == nil {
+		t.Error("Expected error for line without '='")
+	}
+
+	// Test case 2: Invalid variable name
+	if _, err := ParseDotEnv([]byte("1BAD=value\n")); err == nil {
+		t.Error("Expected error for invalid variable name")
+	}
+
+	// Test case 3: Unterminated quote
+	if _, err := ParseDotEnv([]byte("KEY=\"value\n")); err == nil {
+		t.Error("Expected error for unterminated quoted value")
+	}
+}
+
+func TestFormatDotEnvRoundTrip(t *testing.T) {
+	fields := []Field{
+		{Key: "PLAIN", Value: []byte("simple")},
+		{Key: "SPACES", Value: []byte("has spaces")},
+		{Key: "DOLLAR", Value: []byte("cost$5")},
+		{Key: "MULTILINE", Value: []byte("line1\nline2 'quoted'")},
+		{Key: "BACKSLASH", Value: []byte(`C:\path`)},
+		{Key: "EMPTY", Value: []byte("")},
+	}
+
+	data, err := FormatDotEnv(fields)
+	if err != nil {
+		t.Fatalf("Failed to format .env data: %v", err)
+	}
+
+	parsed, err := ParseDotEnv(data)
+	if err != nil {
+		t.Fatalf("Failed to parse formatted .env data: %v\n%s", err, string(data))
+	}
+
+	if len(parsed) != len(fields) {
+		t.Fatalf("Expected %d fields, got %d", len(fields), len(parsed))
+	}
+	for i := range fields {
+		if parsed[i].Key != fields[i].Key || !bytes.Equal(parsed[i].Value, fields[i].Value) {
+			t.Errorf("Field %d did not round trip. Expected: %q, Got: %q", i, fields[i], parsed[i])
+		}
+	}
+}
+
+func TestQuoteDotEnvValue(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected string
+	}{
+		// Test case 1: Plain values aren't quoted
+		{"simple", "simple"},
+		// Test case 2: Values without single quotes or newlines are single quoted, which is literal
+		{"cost $HOME `id` C:\\path", "'cost $HOME `id` C:\\path'"},
+		// Test case 3: Double quoted values escape $
+		{"it's $HOME", `"it's \$HOME"`},
+		// Test case 4: Double quoted values escape backticks
+		{"it's `id`", "\"it's \\`id\\`\""},
+		// Test case 5: Double quoted values escape backslashes
+		{"it's C:\\path", `"it's C:\\path"`},
+		// Test case 6: Newlines and quotes are escaped alongside them
+		{"$\n`\\\"", "\"\\$\\n\\`\\\\\\\"\""},
+	}
+	for i, tc := range testCases {
+		quoted := quoteDotEnvValue([]byte(tc.value))
+		if string(quoted) != tc.expected {
+			t.Errorf("Test %d failed: Expected %s, got %s", i+1, tc.expected, quoted)
+		}
+		if cap(quoted) != len(quoted) {
+			t.Errorf("Test %d failed: Expected the quoted value to be sized up front, got length %d and capacity %d", i+1, len(quoted), cap(quoted))
+		}
+		parsed, err := parseDotEnvValue(quoted)
+		if err != nil || string(parsed) != tc.value {
+			t.Errorf("Test %d failed: Expected %q to round trip, got %q (%v)", i+1, tc.value, parsed, err)
+		}
+	}
+}
+
+func TestFormatDotEnvInvalidName(t *testing.T) {
+	if _, err := FormatDotEnv([]Field{{Key: "not valid", Value: []byte("x")}}); err == nil {
+		t.Error("Expected error when formatting a field with an invalid name")
+	}
+}
+
+func TestIsValidEnvName(t *testing.T) {
+	valid := []string{"A", "_A", "API_KEY", "key2"}
+	for _, name := range valid {
+		if !IsValidEnvName(name) {
+			t.Errorf("Expected '%s' to be a valid name", name)
+		}
+	}
+
+	invalid := []string{"", "2KEY", "MY-KEY", "MY KEY", "KEY="}
+	for _, name := range invalid {
+		if IsValidEnvName(name) {
+			t.Errorf("Expected '%s' to be an invalid name", name)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// MergeDotEnv returns the contents of an existing .env file with the given fields set.
+// Existing variables are updated in place, keeping comments and ordering; new variables are appended.
+// The contents hold the values, so the caller wipes them once they're written.
+func MergeDotEnv(existing []byte, fields []Field) ([]byte, error) {
+	pending := make(map[string][]byte, len(fields))
+	defer func() {
+		for _, line := range pending {
+			Wipe(line)
+		}
+	}()
+	size := len(existing) + 1
+	for _, field := range fields {
+		line, err := FormatDotEnvLine(field)
+		if err != nil {
+			return nil, err
+		}
+		pending[field.Key] = line
+		size += len("export ") + len(line) + 1
+	}
+	written := make(map[string]bool, len(fields))
+
+	// One buffer sized up front, so no outgrown copies of the values are left behind
+	merged := make([]byte, 0, size)
+	if len(existing) > 0 {
+		lines := bytes.Split(bytes.TrimSuffix(existing, []byte("\n")), []byte("\n"))
+		for _, line := range lines {
+			key, exported := dotEnvLineKey(line)
+			if newLine, ok := pending[key]; ok {
+				// Drop repeated definitions so the merged value is the one that takes effect
+				if written[key] {
+					continue
+				}
+				written[key] = true
+				if exported {
+					merged = append(merged, "export "...)
+				}
+				line = newLine
+			}
+			merged = append(append(merged, line...), '\n')
+		}
+	}
+
+	for _, field := range fields {
+		if !written[field.Key] {
+			merged = append(append(merged, pending[field.Key]...), '\n')
+			written[field.Key] = true
+		}
+	}
+
+	return merged, nil
+}
+
+// dotEnvLineKey returns the variable name defined on a .env line, if any
+func dotEnvLineKey(line []byte) (string, bool) {
+	trimmed := bytes.TrimSpace(line)
+	if len(trimmed) == 0 || bytes.HasPrefix(trimmed, []byte("#")) {
+		return "", false
+	}
+
+	exported := bytes.HasPrefix(trimmed, []byte("export "))
+	trimmed = bytes.TrimPrefix(trimmed, []byte("export "))
+
+	eqIdx := bytes.IndexByte(trimmed, '=')
+	if eqIdx == -1 {
+		return "", false
+	}
+	return string(bytes.TrimSpace(trimmed[:eqIdx])), exported
+}
+
+// WriteDotEnvFile merges fields into the .env file at path.
+// The file is replaced atomically with 0600 permissions. If it already existed, the previous
+// contents are kept in path + ".bak" and the backup path is returned.
+func WriteDotEnvFile(path string, fields []Field) (string, error) {
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	fileExists := err == nil
+
+	defer Wipe(existing)
+
+	merged, err := MergeDotEnv(existing, fields)
+	if err != nil {
+		return "", err
+	}
+	defer Wipe(merged)
+
+	// Write to a temporary file in the same directory so the final rename is atomic
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to set permissions: %w", err)
+	}
+	if _, err := tmp.Write(merged); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write %s: %w", tmpPath, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write %s: %w", tmpPath, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", tmpPath, err)
+	}
+
+	backupPath := ""
+	if fileExists {
+		backupPath = path + ".bak"
+		if err := WritePrivateFile(backupPath, existing); err != nil {
+			return "", fmt.Errorf("failed to write backup: %w", err)
+		}
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return "", fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+
+	return backupPath, nil
+}
+
+// WritePrivateFile writes data to path, readable only by the current user
+func WritePrivateFile(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	// Tighten permissions in case the file already existed with looser ones
+	if err := file.Chmod(0600); err != nil {
+		file.Close()
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestMergeDotEnv(t *testing.T) {
+	existing := []byte("# comment\nexport DB_PASS=old\nOTHER=1\nDB_PASS=duplicate\n")
+	fields := []Field{
+		{Key: "DB_PASS", Value: []byte("new value")},
+		{Key: "NEW_KEY", Value: []byte("abc")},
+	}
+
+	merged, err := MergeDotEnv(existing, fields)
+	if err != nil {
+		t.Fatalf("Failed to merge .env data: %v", err)
+	}
+
+	expected := "# comment\nexport DB_PASS='new value'\nOTHER=1\nNEW_KEY=abc\n"
+	if string(merged) != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, string(merged))
+	}
+}
+
+func TestMergeDotEnvEmpty(t *testing.T) {
+	merged, err := MergeDotEnv(nil, []Field{{Key: "A", Value: []byte("1")}})
+	if err != nil {
+		t.Fatalf("Failed to merge .env data: %v", err)
+	}
+
+	if string(merged) != "A=1\n" {
+		t.Errorf("Expected 'A=1\\n', got '%s'", string(merged))
+	}
+}
+
+func TestWriteDotEnvFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".env")
+
+	// Test case 1: New file, no backup
+	backupPath, err := WriteDotEnvFile(path, []Field{{Key: "A", Value: []byte("1")}})
+	if err != nil {
+		t.Fatalf("Failed to write .env file: %v", err)
+	}
+	if backupPath != "" {
+		t.Errorf("Expected no backup for a new file, got '%s'", backupPath)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read .env file: %v", err)
+	}
+	if string(data) != "A=1\n" {
+		t.Errorf("Expected 'A=1\\n', got '%s'", string(data))
+	}
+
+	// Test case 2: Existing file is merged and backed up
+	if err := os.WriteFile(path, []byte("A=1\nB=2\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	backupPath, err = WriteDotEnvFile(path, []Field{{Key: "B", Value: []byte("3")}})
+	if err != nil {
+		t.Fatalf("Failed to write .env file: %v", err)
+	}
+	if backupPath != path+".bak" {
+		t.Errorf("Expected backup at '%s', got '%s'", path+".bak", backupPath)
+	}
+
+	data, _ = os.ReadFile(path)
+	if string(data) != "A=1\nB=3\n" {
+		t.Errorf("Expected 'A=1\\nB=3\\n', got '%s'", string(data))
+	}
+
+	backup, _ := os.ReadFile(backupPath)
+	if string(backup) != "A=1\nB=2\n" {
+		t.Errorf("Expected backup to hold the previous contents, got '%s'", string(backup))
+	}
+
+	// Both files should only be readable by the owner
+	if runtime.GOOS != "windows" {
+		for _, p := range []string{path, backupPath} {
+			info, err := os.Stat(p)
+			if err != nil {
+				t.Fatalf("Failed to stat %s: %v", p, err)
+			}
+			if info.Mode().Perm() != 0600 {
+				t.Errorf("Expected %s to have 0600 permissions, got %o", p, info.Mode().Perm())
+			}
+		}
+	}
+
+	// No temporary files should be left behind
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("Expected only the .env file and its backup, found %d entries", len(entries))
+	}
+}
+
+func TestWriteDotEnvFileInvalidName(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	if _, err := WriteDotEnvFile(path, []Field{{Key: "bad name", Value: []byte("x")}}); err == nil {
+		t.Error("Expected error when writing an invalid variable name")
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("No file should be written when the merge fails")
+	}
+}
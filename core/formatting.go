@@ -4,13 +4,20 @@ import (
 	"fmt"
 )
 
-// FormatPublicKey formats a public key with XML-like tags for sharing
+// FormatPublicKey formats a public key with XML-like tags for sharing, armored with line
+// wrapping and a checksum line
 func FormatPublicKey(key []byte) string {
 	// We add a version number for future upgradeability
-	return fmt.Sprintf("<secret_share_key>ssv1%s</secret_share_key>", string(key))
+	return formatKey("ssv1", string(key))
 }
 
-// FormatSecret formats an encrypted secret with XML-like tags for sharing
+// FormatSecret formats an encrypted secret with XML-like tags for sharing, armored with line
+// wrapping and a checksum line
 func FormatSecret(secret []byte) string {
-	return fmt.Sprintf("<secret_share_secret>%s</secret_share_secret>", string(secret))
+	return armor("secret_share_secret", string(secret))
+}
+
+// FormatShare formats a share of a split secret with XML-like tags, for its receiver to keep
+func FormatShare(share []byte) string {
+	return fmt.Sprintf("<secret_share_share>%s</secret_share_share>", string(share))
 }
@@ -1,13 +1,14 @@
 package core
 
 import (
+	"strings"
 	"testing"
 )
 
 func TestFormatPublicKey(t *testing.T) {
-	// Test case 1: Basic formatting
+	// Test case 1: Basic formatting, with the tags and checksum on their own lines
 	key := []byte("test_public_key")
-	expected := "<secret_share_key>ssv1test_public_key</secret_share_key>"
+	expected := "<secret_share_key>\nssv1test_public_key\n" + armorChecksum("ssv1test_public_key") + "\n</secret_share_key>"
 	result := FormatPublicKey(key)
 	if result != expected {
 		t.Errorf("Test 1 failed: Expected '%s', got '%s'", expected, result)
@@ -15,7 +16,7 @@ func TestFormatPublicKey(t *testing.T) {
 
 	// Test case 2: Empty key
 	emptyKey := []byte("")
-	expectedEmpty := "<secret_share_key>ssv1</secret_share_key>"
+	expectedEmpty := "<secret_share_key>\nssv1\n" + armorChecksum("ssv1") + "\n</secret_share_key>"
 	resultEmpty := FormatPublicKey(emptyKey)
 	if resultEmpty != expectedEmpty {
 		t.Errorf("Test 2 failed: Expected '%s', got '%s'", expectedEmpty, resultEmpty)
@@ -23,17 +24,24 @@ func TestFormatPublicKey(t *testing.T) {
 
 	// Test case 3: Key with special characters
 	specialKey := []byte("key_with_special_chars_!@#$%^&*()")
-	expectedSpecial := "<secret_share_key>ssv1key_with_special_chars_!@#$%^&*()</secret_share_key>"
+	expectedSpecial := "<secret_share_key>\nssv1key_with_special_chars_!@#$%^&*()\n" + armorChecksum("ssv1key_with_special_chars_!@#$%^&*()") + "\n</secret_share_key>"
 	resultSpecial := FormatPublicKey(specialKey)
 	if resultSpecial != expectedSpecial {
 		t.Errorf("Test 3 failed: Expected '%s', got '%s'", expectedSpecial, resultSpecial)
 	}
+
+	// Test case 4: Long keys are wrapped at 64 columns
+	longKey := []byte(strings.Repeat("A", 130))
+	lines := strings.Split(FormatPublicKey(longKey), "\n")
+	if len(lines) != 6 || len(lines[1]) != 64 || len(lines[2]) != 64 || lines[3] != strings.Repeat("A", 6) {
+		t.Errorf("Test 4 failed: Expected 3 lines wrapped at 64 columns, got %q", lines)
+	}
 }
 
 func TestFormatSecret(t *testing.T) {
 	// Test case 1: Basic formatting
 	secret := []byte("test_secret")
-	expected := "<secret_share_secret>test_secret</secret_share_secret>"
+	expected := "<secret_share_secret>\ntest_secret\n" + armorChecksum("test_secret") + "\n</secret_share_secret>"
 	result := FormatSecret(secret)
 	if result != expected {
 		t.Errorf("Test 1 failed: Expected '%s', got '%s'", expected, result)
@@ -41,7 +49,7 @@ func TestFormatSecret(t *testing.T) {
 
 	// Test case 2: Empty secret
 	emptySecret := []byte("")
-	expectedEmpty := "<secret_share_secret></secret_share_secret>"
+	expectedEmpty := "<secret_share_secret>\n" + armorChecksum("") + "\n</secret_share_secret>"
 	resultEmpty := FormatSecret(emptySecret)
 	if resultEmpty != expectedEmpty {
 		t.Errorf("Test 2 failed: Expected '%s', got '%s'", expectedEmpty, resultEmpty)
@@ -49,9 +57,16 @@ func TestFormatSecret(t *testing.T) {
 
 	// Test case 3: Secret with special characters
 	specialSecret := []byte("secret_with_special_chars_!@#$%^&*()")
-	expectedSpecial := "<secret_share_secret>secret_with_special_chars_!@#$%^&*()</secret_share_secret>"
+	expectedSpecial := "<secret_share_secret>\nsecret_with_special_chars_!@#$%^&*()\n" + armorChecksum("secret_with_special_chars_!@#$%^&*()") + "\n</secret_share_secret>"
 	resultSpecial := FormatSecret(specialSecret)
 	if resultSpecial != expectedSpecial {
 		t.Errorf("Test 3 failed: Expected '%s', got '%s'", expectedSpecial, resultSpecial)
 	}
 }
+
+func TestFormatShare(t *testing.T) {
+	expected := "<secret_share_share>test_share</secret_share_share>"
+	if result := FormatShare([]byte("test_share")); result != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, result)
+	}
+}
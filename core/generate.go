@@ -0,0 +1,173 @@
+package core
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Character classes for generated passwords
+const (
+	LowercaseChars = "abcdefghijklmnopqrstuvwxyz"
+	UppercaseChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	DigitChars     = "0123456789"
+	SymbolChars    = "!#$%&*+-.:=?@^_~"
+)
+
+// Limits for generated secrets
+const (
+	MaxPasswordLength  = 1024
+	MaxPassphraseWords = 64
+	MaxTokenBytes      = 1024
+)
+
+// PasswordPolicy selects the length and character classes of a generated password
+type PasswordPolicy struct {
+	Length    int
+	Lowercase bool
+	Uppercase bool
+	Digits    bool
+	Symbols   bool
+}
+
+// DefaultPasswordPolicy is a 24 character password using every character class
+var DefaultPasswordPolicy = PasswordPolicy{Length: 24, Lowercase: true, Uppercase: true, Digits: true, Symbols: true}
+
+// classes returns the character sets selected by the policy
+func (p PasswordPolicy) classes() []string {
+	var classes []string
+	if p.Lowercase {
+		classes = append(classes, LowercaseChars)
+	}
+	if p.Uppercase {
+		classes = append(classes, UppercaseChars)
+	}
+	if p.Digits {
+		classes = append(classes, DigitChars)
+	}
+	if p.Symbols {
+		classes = append(classes, SymbolChars)
+	}
+	return classes
+}
+
+// GeneratePassword generates a random password following the policy.
+// The password contains at least one character from every selected class.
+func GeneratePassword(policy PasswordPolicy) ([]byte, error) {
+	classes := policy.classes()
+	if len(classes) == 0 {
+		return nil, fmt.Errorf("select at least one character class")
+	}
+	if policy.Length < len(classes) || policy.Length > MaxPasswordLength {
+		return nil, fmt.Errorf("password length must be between %d and %d", len(classes), MaxPasswordLength)
+	}
+
+	password := make([]byte, 0, policy.Length)
+
+	// One character from each class, so every class is represented
+	for _, class := range classes {
+		c, err := randomChar(class)
+		if err != nil {
+			return nil, err
+		}
+		password = append(password, c)
+	}
+
+	// Fill the rest from all selected classes
+	allChars := strings.Join(classes, "")
+	for len(password) < policy.Length {
+		c, err := randomChar(allChars)
+		if err != nil {
+			return nil, err
+		}
+		password = append(password, c)
+	}
+
+	// Shuffle so the required characters aren't always at the start (Fisher-Yates)
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := randomIndex(i + 1)
+		if err != nil {
+			return nil, err
+		}
+		password[i], password[j] = password[j], password[i]
+	}
+
+	return password, nil
+}
+
+// GeneratePassphrase generates a diceware style passphrase of random words separated by dashes
+func GeneratePassphrase(words int) ([]byte, error) {
+	if words < 1 || words > MaxPassphraseWords {
+		return nil, fmt.Errorf("word count must be between 1 and %d", MaxPassphraseWords)
+	}
+
+	chosen := make([]string, words)
+	for i := range chosen {
+		idx, err := randomIndex(len(Wordlist))
+		if err != nil {
+			return nil, err
+		}
+		chosen[i] = Wordlist[idx]
+	}
+
+	return []byte(strings.Join(chosen, "-")), nil
+}
+
+// GenerateHexToken generates a token of numBytes random bytes, hex encoded
+func GenerateHexToken(numBytes int) ([]byte, error) {
+	raw, err := randomTokenBytes(numBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	token := make([]byte, hex.EncodedLen(len(raw)))
+	hex.Encode(token, raw)
+	return token, nil
+}
+
+// GenerateBase64Token generates a token of numBytes random bytes, encoded as unpadded URL-safe base64
+func GenerateBase64Token(numBytes int) ([]byte, error) {
+	raw, err := randomTokenBytes(numBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	token := make([]byte, base64.RawURLEncoding.EncodedLen(len(raw)))
+	base64.RawURLEncoding.Encode(token, raw)
+	return token, nil
+}
+
+// randomTokenBytes generates numBytes random bytes for a token
+func randomTokenBytes(numBytes int) ([]byte, error) {
+	if numBytes < 1 || numBytes > MaxTokenBytes {
+		return nil, fmt.Errorf("token size must be between 1 and %d bytes", MaxTokenBytes)
+	}
+
+	raw := make([]byte, numBytes)
+	_, err := rand.Read(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	return raw, nil
+}
+
+// randomChar picks a uniformly random character from chars
+func randomChar(chars string) (byte, error) {
+	idx, err := randomIndex(len(chars))
+	if err != nil {
+		return 0, err
+	}
+	return chars[idx], nil
+}
+
+// randomIndex returns a uniformly random integer in [0, n)
+func randomIndex(n int) (int, error) {
+	idx, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to generate random number: %w", err)
+	}
+	return int(idx.Int64()), nil
+}
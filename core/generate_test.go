@@ -0,0 +1,135 @@
+package core
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestWordlist(t *testing.T) {
+	if len(Wordlist) != 2048 {
+		t.Fatalf("Expected 2048 words, got %d", len(Wordlist))
+	}
+
+	// Words must be unique in their first four letters
+	prefixes := make(map[string]bool)
+	for _, word := range Wordlist {
+		prefix := word
+		if len(prefix) > 4 {
+			prefix = prefix[:4]
+		}
+		if prefixes[prefix] {
+			t.Errorf("Duplicate word prefix: %s", prefix)
+		}
+		prefixes[prefix] = true
+	}
+}
+
+func TestGeneratePassword(t *testing.T) {
+	password, err := GeneratePassword(DefaultPasswordPolicy)
+	if err != nil {
+		t.Fatalf("Failed to generate password: %v", err)
+	}
+
+	if len(password) != DefaultPasswordPolicy.Length {
+		t.Errorf("Expected password length of %d, got %d", DefaultPasswordPolicy.Length, len(password))
+	}
+
+	// Every class must be represented
+	for _, class := range []string{LowercaseChars, UppercaseChars, DigitChars, SymbolChars} {
+		if !strings.ContainsAny(string(password), class) {
+			t.Errorf("Password %q is missing a character from %q", password, class)
+		}
+	}
+
+	// Two passwords should never match
+	other, err := GeneratePassword(DefaultPasswordPolicy)
+	if err != nil {
+		t.Fatalf("Failed to generate password: %v", err)
+	}
+	if string(password) == string(other) {
+		t.Error("Two generated passwords should not be equal")
+	}
+}
+
+func TestGeneratePasswordSingleClass(t *testing.T) {
+	password, err := GeneratePassword(PasswordPolicy{Length: 40, Digits: true})
+	if err != nil {
+		t.Fatalf("Failed to generate password: %v", err)
+	}
+
+	for _, c := range string(password) {
+		if !strings.ContainsRune(DigitChars, c) {
+			t.Errorf("Unexpected character %q in digits-only password", c)
+		}
+	}
+}
+
+func TestGeneratePasswordInvalidPolicy(t *testing.T) {
+	// Test case 1: No character classes
+	if _, err := GeneratePassword(PasswordPolicy{Length: 10}); err == nil {
+		t.Error("Expected error when no character classes are selected")
+	}
+
+	// Test case 2: Too short to include every class
+	if _, err := GeneratePassword(PasswordPolicy{Length: 3, Lowercase: true, Uppercase: true, Digits: true, Symbols: true}); err == nil {
+		t.Error("Expected error when length is shorter than the number of classes")
+	}
+
+	// Test case 3: Too long
+	if _, err := GeneratePassword(PasswordPolicy{Length: MaxPasswordLength + 1, Lowercase: true}); err == nil {
+		t.Error("Expected error when length is over the maximum")
+	}
+}
+
+func TestGeneratePassphrase(t *testing.T) {
+	passphrase, err := GeneratePassphrase(6)
+	if err != nil {
+		t.Fatalf("Failed to generate passphrase: %v", err)
+	}
+
+	words := strings.Split(string(passphrase), "-")
+	if len(words) != 6 {
+		t.Fatalf("Expected 6 words, got %d: %s", len(words), passphrase)
+	}
+
+	known := make(map[string]bool)
+	for _, word := range Wordlist {
+		known[word] = true
+	}
+	for _, word := range words {
+		if !known[word] {
+			t.Errorf("Word %q is not in the word list", word)
+		}
+	}
+
+	if _, err := GeneratePassphrase(0); err == nil {
+		t.Error("Expected error for zero words")
+	}
+}
+
+func TestGenerateTokens(t *testing.T) {
+	hexToken, err := GenerateHexToken(32)
+	if err != nil {
+		t.Fatalf("Failed to generate hex token: %v", err)
+	}
+	if decoded, err := hex.DecodeString(string(hexToken)); err != nil || len(decoded) != 32 {
+		t.Errorf("Expected 32 bytes of hex, got %q", hexToken)
+	}
+
+	base64Token, err := GenerateBase64Token(32)
+	if err != nil {
+		t.Fatalf("Failed to generate base64 token: %v", err)
+	}
+	if decoded, err := base64.RawURLEncoding.DecodeString(string(base64Token)); err != nil || len(decoded) != 32 {
+		t.Errorf("Expected 32 bytes of base64, got %q", base64Token)
+	}
+
+	if _, err := GenerateHexToken(0); err == nil {
+		t.Error("Expected error for an empty token")
+	}
+	if _, err := GenerateBase64Token(MaxTokenBytes + 1); err == nil {
+		t.Error("Expected error for a token over the maximum size")
+	}
+}
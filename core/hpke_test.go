@@ -0,0 +1,305 @@
+package core
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+// hpkeRFCVectors are the base mode X25519 and HKDF-SHA256 test vectors from RFC 9180 appendix
+// A.1.1 (AES-128-GCM) and A.2.1 (ChaCha20-Poly1305), with the first encryption of each
+var hpkeRFCVectors = []struct {
+	name         string
+	aeadID       uint16
+	ikmE         string
+	skEm         string
+	pkEm         string
+	ikmR         string
+	skRm         string
+	pkRm         string
+	sharedSecret string
+	baseNonce    string
+	ciphertext   string
+}{
+	{
+		name:         "A.1.1",
+		aeadID:       hpkeAES128GCM,
+		ikmE:         "7268600d403fce431561aef583ee1613527cff655c1343f29812e66706df3234",
+		skEm:         "52c4a758a802cd8b936eceea314432798d5baf2d7e9235dc084ab1b9cfa2f736",
+		pkEm:         "37fda3567bdbd628e88668c3c8d7e97d1d1253b6d4ea6d44c150f741f1bf4431",
+		ikmR:         "6db9df30aa07dd42ee5e8181afdb977e538f5e1fec8a06223f33f7013e525037",
+		skRm:         "4612c550263fc8ad58375df3f557aac531d26850903e55a9f23f21d8534e8ac8",
+		pkRm:         "3948cfe0ad1ddb695d780e59077195da6c56506b027329794ab02bca80815c4d",
+		sharedSecret: "fe0e18c9f024ce43799ae393c7e8fe8fce9d218875e8227b0187c04e7d2ea1fc",
+		baseNonce:    "56d890e5accaaf011cff4b7d",
+		ciphertext:   "f938558b5d72f1a23810b4be2ab4f84331acc02fc97babc53a52ae8218a355a96d8770ac83d07bea87e13c512a",
+	},
+	{
+		name:         "A.2.1",
+		aeadID:       hpkeChaCha20Poly1305,
+		ikmE:         "909a9b35d3dc4713a5e72a4da274b55d3d3821a37e5d099e74a647db583a904b",
+		skEm:         "f4ec9b33b792c372c1d2c2063507b684ef925b8c75a42dbcbf57d63ccd381600",
+		pkEm:         "1afa08d3dec047a643885163f1180476fa7ddb54c6a8029ea33f95796bf2ac4a",
+		ikmR:         "1ac01f181fdf9f352797655161c58b75c656a6cc2716dcb66372da835542e1df",
+		skRm:         "8057991eef8f1f1af18f4a9491d16a1ce333f695d4db8e38da75975c4478e0fb",
+		pkRm:         "4310ee97d88cc1f088a5576c77ab0cf5c3ac797f3d95139c6c84b5429c59662a",
+		sharedSecret: "0bbe78490412b4bbea4812666f7916932b828bba79942424abb65244930d69a7",
+		baseNonce:    "5c4d98150661b848853b547f",
+		ciphertext:   "1c5250d8034ec2b784ba2cfd69dbdb8af406cfe3ff938e131f0def8c8b60b4db21993c62ce81883d2dd1b51a28",
+	},
+}
+
+// newHPKETestKey returns a random X25519 private key and its public key
+func newHPKETestKey(t *testing.T) ([]byte, []byte) {
+	t.Helper()
+	privateKey := make([]byte, curve25519.ScalarSize)
+	if _, err := rand.Read(privateKey); err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	publicKey, err := curve25519.X25519(privateKey, curve25519.Basepoint)
+	if err != nil {
+		t.Fatalf("Failed to derive public key: %v", err)
+	}
+	return privateKey, publicKey
+}
+
+// hpkeDecryptBytes decrypts an ssv4 envelope into ordinary memory
+func hpkeDecryptBytes(privateKey, publicKey, encryptedData []byte) ([]byte, error) {
+	return hpkeDecrypt(privateKey, publicKey, encryptedData, func(size int) []byte { return make([]byte, size) })
+}
+
+func TestHPKERFCVectors(t *testing.T) {
+	info := []byte("Ode on a Grecian Urn")
+	plaintext := []byte("Beauty is truth, truth beauty")
+	aad := []byte("Count-0")
+
+	for _, vector := range hpkeRFCVectors {
+		hexBytes := func(s string) []byte {
+			b, err := hex.DecodeString(s)
+			if err != nil {
+				t.Fatalf("%s: invalid hex: %v", vector.name, err)
+			}
+			return b
+		}
+
+		skE, pkE, err := hpkeDeriveKeyPair(hexBytes(vector.ikmE))
+		if err != nil || hex.EncodeToString(skE) != vector.skEm || hex.EncodeToString(pkE) != vector.pkEm {
+			t.Errorf("%s: Expected ephemeral key %s, got %x (err: %v)", vector.name, vector.pkEm, pkE, err)
+		}
+		skR, pkR, err := hpkeDeriveKeyPair(hexBytes(vector.ikmR))
+		if err != nil || hex.EncodeToString(skR) != vector.skRm || hex.EncodeToString(pkR) != vector.pkRm {
+			t.Errorf("%s: Expected receiver key %s, got %x (err: %v)", vector.name, vector.pkRm, pkR, err)
+		}
+
+		sharedSecret, enc, err := hpkeEncap(hexBytes(vector.ikmE), hexBytes(vector.pkRm))
+		if err != nil {
+			t.Fatalf("%s: Failed to encapsulate: %v", vector.name, err)
+		}
+		if hex.EncodeToString(enc) != vector.pkEm || hex.EncodeToString(sharedSecret) != vector.sharedSecret {
+			t.Errorf("%s: Expected shared secret %s, got %x", vector.name, vector.sharedSecret, sharedSecret)
+		}
+		decapsulated, err := hpkeDecap(enc, hexBytes(vector.skRm), hexBytes(vector.pkRm))
+		if err != nil || !bytes.Equal(decapsulated, sharedSecret) {
+			t.Errorf("%s: Expected the receiver to decapsulate the same secret (err: %v)", vector.name, err)
+		}
+
+		aead, nonce, err := hpkeKeySchedule(vector.aeadID, sharedSecret, info)
+		if err != nil {
+			t.Fatalf("%s: Failed key schedule: %v", vector.name, err)
+		}
+		if hex.EncodeToString(nonce) != vector.baseNonce {
+			t.Errorf("%s: Expected base nonce %s, got %x", vector.name, vector.baseNonce, nonce)
+		}
+		if ciphertext := aead.Seal(nil, nonce, plaintext, aad); hex.EncodeToString(ciphertext) != vector.ciphertext {
+			t.Errorf("%s: Expected ciphertext %s, got %x", vector.name, vector.ciphertext, ciphertext)
+		}
+	}
+}
+
+func TestHPKESessions(t *testing.T) {
+	receiver, err := NewHPKEReceiverSession()
+	if err != nil {
+		t.Fatalf("Failed to create receiver session: %v", err)
+	}
+	defer receiver.Destroy()
+	key, err := receiver.SharedKey()
+	if err != nil {
+		t.Fatalf("Failed to format key: %v", err)
+	}
+	if !strings.HasPrefix(key, "<secret_share_key>\nssv4") || receiver.GetPublicKey() != nil {
+		t.Fatalf("Expected an ssv4 key and no RSA key, got '%s'", key)
+	}
+
+	sender, err := NewSenderSessionForHPKEKey(receiver.key.(*hpkeKey).publicKey)
+	if err != nil {
+		t.Fatalf("Failed to create sender session: %v", err)
+	}
+	secret := []byte("Xk2#pQ9!vL7@mN4$wR8&")
+	encrypted, err := sender.EncryptSecret(secret)
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+	if !IsHPKESecret(encrypted) || len(encrypted) != hpkeHeaderSize+len(secret)+16 {
+		t.Errorf("Expected an ssv4 envelope, got %q", encrypted[:4])
+	}
+
+	decrypted, err := receiver.DecryptSecret(encrypted)
+	if err != nil {
+		t.Fatalf("Failed to decrypt: %v", err)
+	}
+	if !bytes.Equal(decrypted.Bytes(), secret) {
+		t.Errorf("Expected '%s', got '%s'", secret, decrypted.Bytes())
+	}
+	decrypted.Destroy()
+
+	// Senders read both AEADs
+	chacha, err := hpkeEncrypt(rand.Reader, receiver.key.(*hpkeKey).publicKey, hpkeChaCha20Poly1305, secret)
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+	decrypted, err = receiver.DecryptSecret(chacha)
+	if err != nil || !bytes.Equal(decrypted.Bytes(), secret) {
+		t.Fatalf("Expected ChaCha20-Poly1305 to decrypt (err: %v)", err)
+	}
+	decrypted.Destroy()
+
+	other, err := NewSenderSessionForHPKEKey(append([]byte{}, receiver.key.(*hpkeKey).publicKey...))
+	if err != nil {
+		t.Fatalf("Failed to create sender session: %v", err)
+	}
+	if !sender.SameReceiver(other) || sender.SameReceiver(NewSenderSession(&fuzzPrivateKey(t).PublicKey)) {
+		t.Error("Expected only the same key to be the same receiver")
+	}
+	if _, err := sender.EncryptSecretJWE(secret); err == nil {
+		t.Error("Expected error encrypting a JWE to an HPKE key")
+	}
+
+	// The key is gone once the session is destroyed
+	receiver.Destroy()
+	if _, err := receiver.DecryptSecret(encrypted); err == nil {
+		t.Error("Expected an error decrypting after destroy")
+	}
+}
+
+func TestHPKEDecryptErrors(t *testing.T) {
+	privateKey, publicKey := newHPKETestKey(t)
+	otherKey, otherPublicKey := newHPKETestKey(t)
+	encrypted, err := hpkeEncrypt(rand.Reader, publicKey, hpkeAES256GCM, []byte("secret"))
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+
+	// Test case 1: Another key can't decrypt it
+	if _, err := hpkeDecryptBytes(otherKey, otherPublicKey, encrypted); err == nil {
+		t.Error("Test 1 failed: Expected error decrypting with another key")
+	}
+
+	// Test case 2: Changes are detected, including to the header
+	withAEAD := func(aeadID byte) []byte {
+		changed := append([]byte{}, encrypted...)
+		changed[5] = aeadID
+		return changed
+	}
+	flipped := func(i int) []byte {
+		changed := append([]byte{}, encrypted...)
+		changed[i] ^= 1
+		return changed
+	}
+	changed := map[string][]byte{
+		"ChaCha20-Poly1305": withAEAD(byte(hpkeChaCha20Poly1305)),
+		"AES-128-GCM":       withAEAD(byte(hpkeAES128GCM)),
+		"unknown AEAD":      withAEAD(0xff),
+		"encapsulated key":  flipped(10),
+		"ciphertext":        flipped(len(encrypted) - 1),
+		"truncated":         encrypted[:hpkeHeaderSize+15],
+		"header only":       encrypted[:hpkeHeaderSize-1],
+		"low order key":     append(append([]byte{}, encrypted[:6]...), make([]byte, len(encrypted)-6)...),
+	}
+	for name, data := range changed {
+		if _, err := hpkeDecryptBytes(privateKey, publicKey, data); err == nil {
+			t.Errorf("Test 2 failed: Expected an error for a changed %s", name)
+		}
+	}
+
+	// Test case 3: An AEAD this version doesn't know is from a newer version
+	if _, err := hpkeDecryptBytes(privateKey, publicKey, withAEAD(0xff)); !errors.Is(err, ErrNewerVersion) {
+		t.Errorf("Test 3 failed: Expected ErrNewerVersion for an unknown AEAD, got %v", err)
+	}
+	if _, err := hpkeDecryptBytes(privateKey, publicKey, withAEAD(byte(hpkeAES128GCM))); err == nil || errors.Is(err, ErrNewerVersion) {
+		t.Errorf("Test 3 failed: Expected AES-128-GCM to be unsupported, got %v", err)
+	}
+
+	// Test case 4: Sessions report secrets they can't read
+	session, err := NewHPKEReceiverSessionWithKey(privateKey)
+	if err != nil {
+		t.Fatalf("Failed to create receiver session: %v", err)
+	}
+	defer session.Destroy()
+	forKey, err := HybridEncrypt(&fuzzPrivateKey(t).PublicKey, []byte("secret"))
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+	forPassphrase, err := passphraseEncrypt(rand.Reader, fastPassphraseParams, []byte("p"), []byte("secret"))
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+	tests := []struct {
+		name     string
+		data     []byte
+		expected error
+	}{
+		{"a SecretShare key", forKey, ErrNotHPKESecret},
+		{"a passphrase", forPassphrase, ErrPassphraseSecret},
+		{"a newer version", []byte("ssv9 newer"), ErrNewerVersion},
+	}
+	for _, test := range tests {
+		if _, err := session.DecryptSecret(test.data); !errors.Is(err, test.expected) {
+			t.Errorf("Test 4 failed: %s: Expected %v, got %v", test.name, test.expected, err)
+		}
+	}
+	if _, err := HybridDecrypt(fuzzPrivateKey(t), encrypted); !errors.Is(err, ErrHPKESecret) {
+		t.Errorf("Test 4 failed: Expected HPKE secret error from a key, got %v", err)
+	}
+
+	// Test case 5: Invalid keys are rejected
+	if _, err := NewSenderSessionForHPKEKey(publicKey[:31]); err == nil {
+		t.Error("Test 5 failed: Expected error for a short public key")
+	}
+	if _, err := NewHPKEReceiverSessionWithKey(privateKey[:31]); err == nil {
+		t.Error("Test 5 failed: Expected error for a short private key")
+	}
+	if _, err := hpkeEncrypt(rand.Reader, make([]byte, curve25519.PointSize), hpkeAES256GCM, []byte("secret")); err == nil {
+		t.Error("Test 5 failed: Expected error encrypting to a low order key")
+	}
+}
+
+func FuzzHPKEDecrypt(f *testing.F) {
+	// A fixed key, so every input is decrypted with the same key
+	privateKey := make([]byte, curve25519.ScalarSize)
+	privateKey[0] = 1
+	publicKey, err := curve25519.X25519(privateKey, curve25519.Basepoint)
+	if err != nil {
+		f.Fatalf("Failed to derive public key: %v", err)
+	}
+	for _, aeadID := range []uint16{hpkeAES256GCM, hpkeChaCha20Poly1305} {
+		valid, err := hpkeEncrypt(rand.Reader, publicKey, aeadID, []byte("fuzz secret"))
+		if err != nil {
+			f.Fatalf("Failed to encrypt: %v", err)
+		}
+		f.Add(valid)
+	}
+	f.Add([]byte("ssv4\x00\x02"))
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		decrypted, err := hpkeDecryptBytes(privateKey, publicKey, data)
+		if err == nil && !bytes.Equal(decrypted, []byte("fuzz secret")) {
+			t.Fatalf("Decrypted a changed envelope to %q", decrypted)
+		}
+	})
+}
@@ -0,0 +1,274 @@
+package core
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// realJOSEPrivateKey, realJOSEKey and realJWE were made with go-jose v4.0.1: a 2048-bit key as
+// base64 PKCS#8 and as a JWK, and a JWE encrypted to the JWK with RSA-OAEP-256 and A256GCM. These
+// tests check SecretShare reads what JOSE libraries write.
+const realJOSEPrivateKey = "MIIEvgIBADANBgkqhkiG9w0BAQEFAASCBKgwggSkAgEAAoIBAQDYA9Nu2VmM/z6U+D+oZ1DXbhVO6J4mHr/lAvDjl3YpDd1W" +
+	"WpZh6pxY6CTQYK2NH6G9CFGzroExRJl8USy/UOw4jhfVk0nj7xb/ev7eiMt5ZggxpKR1IRkEtCkCRlQFPQIRJgkamCqYg/IT" +
+	"XUoviucjM3HkJpza9GGApP8i29bXWC3yklQHmFZiwYxiq50aLB4E1+najTejMVtkRxKaASRc4TMswoG5k5HX30XECXIXMV1l" +
+	"+TWzuYhXt9U1GU2iTsyb1DcaWyrf2wOOOClv7T3OFHdtmuL9HBn5yDPBCLUvrC3iVrqIrucMsee1jLewmyeED/sxIzCHDLBe" +
+	"UsrhRvAxAgMBAAECggEAFu1xB5byNlDxJ4ah2xH8NqFsiY+PGaSx4XDKeJ2e4QSjtYKv63ynU5hwL93TDeOJmzLC2TtwYvZR" +
+	"BCzpfvTr3aTXSsfzU8oQutUnMB982UnHHN3IxtXYffNRVvh3+oZbLc7HsJbuDnoSu5NvSFSJ6+aN9BVVGLhPkQPyQJTs3LZB" +
+	"XrkhngJui/nh8OXRpVfzi66y+Ch7MBJYa4JDBWWzrEmcKX+kyxCymFtiftNRmeYrXcnYAFEmmkIjmVvn112d++NCr+J+nzxz" +
+	"jRbCrs9dhPP9uJtMKzgBxWZtsdsnZ2WOa4nIJrIojCKTOgcrSr6TfcC8PEglDFYBGx0iVmyNMQKBgQDr867VP71oDWJ69MUc" +
+	"9ChEA33v3LnoC5UwxfLwosG09HA9QBT68R6ho+H4iXqxEUqJlcAux5lIxMRuXaGKeBEDMulkNVoZRKZY5Mk5yZFV9XPK/8bE" +
+	"3VPAb0cxv6cBiUA4c8ALoXu1dsTi2knHq5n3k1mTUR08na4B7Zsdf6iWvwKBgQDqXnvYnG0XURYgyL0KaRWhow3FVP6dck8o" +
+	"MeHzdazrumP9UWCZFbAOV7BaP8pb2CCif80ONBHchdJbrA8NF33rzUT4K586d51g2jjrB0uzR7dkkWqJ7+68RJJDzFq8iT0U" +
+	"KKfrGEiRMDw988Hun81JZUuaxc5CEB8IfggARwOlDwKBgExDzIvy+UarpNw3R4sPRACP3GHuR0zAia1lHhw3j0LtC2ZQ0zc1" +
+	"PoBil0SdM0IOY7hK5f5414vUxeUicHdwTZdqGlQghonKTbZH5N6/zzEokA/aUqYJH+wAS9PBrbpqYY6do0JfJLxHsmXcxqI6" +
+	"Th7eysI6IqgYE0leQpfrTlPDAoGBALQtJQrW3yswxFE54dLyDJYx8zFEcWX7bg1Zo/p1+hVTOvGRMTEfMjUkhsgrCFfpZNVo" +
+	"aO1FbIHbQ2gEc6G98KlqKl6bspLRSGUf/uf4uOK//KZNANnAYgnMsaF7pjoRS6qGwukCxy8PJRD/p5Tat4h4XlJDRZkWKbVu" +
+	"ppoH/i5TAoGBAOVeEcjjxBg5DvMaO6Lrh59pTjyb8UuQBJ8ATVGB27btL2oxZ/Za5j5RxvtDms8Y2Pnnrm1SwnKmInbw7Z1L" +
+	"4cULPp1Y6PLwSeqxIOneNNGtNeGPtm7HjFrNHq7OL09UXh4csqev1eXsrwlmRMAu5fnpq91OizAMUNzCTWWW8lWd"
+
+const realJOSEKey = `{"use":"enc","kty":"RSA","alg":"RSA-OAEP-256","n":"2APTbtlZjP8-lPg_qGdQ124VTuieJh6_5QLw45d2KQ3dVlqWYeqcWOgk0GCtjR-hvQhRs66BMUSZfFEsv1DsOI4X1ZNJ4-8W_3r-3ojLeWYIMaSkdSEZBLQpAkZUBT0CESYJGpgqmIPyE11KL4rnIzNx5Cac2vRhgKT_ItvW11gt8pJUB5hWYsGMYqudGiweBNfp2o03ozFbZEcSmgEkXOEzLMKBuZOR199FxAlyFzFdZfk1s7mIV7fVNRlNok7Mm9Q3Glsq39sDjjgpb-09zhR3bZri_RwZ-cgzwQi1L6wt4la6iK7nDLHntYy3sJsnhA_7MSMwhwywXlLK4UbwMQ","e":"AQAB"}`
+
+const realJWE = "eyJhbGciOiJSU0EtT0FFUC0yNTYiLCJlbmMiOiJBMjU2R0NNIn0.e5OzUt33JMpEit77P_-F6dk4ljPMOgtytZnsUFZ5vDsR" +
+	"5KCmB-U2oDFzdBB-BPAjd_ENtBIAcpvAgVmHcmqg0x5V_SgoxX2i6XyqqrjwqfQTZ-Az_l22k1L0OVrEFqtGnhpYQz_YcN9T" +
+	"T6FgkjTqHJXooKWGP_l3zo02sO-kK8fu3rHHoB06HE7Tz6J9psjftSdsJCB-qt3AwwFCjVi8g6QOhQG3YeEnQXHeeOiRefwI" +
+	"kV1LjDCY1VLpWtMWNIN_8IohfCGfqrgCpkWA97g0zI1iTmA59rsiJbdrJ5qs__IO1e4zvOGSfy4itcqfqP5gR5nf7zPv_TMx" +
+	"TIKm-ZIwhw.iyQB_7K5Q3JRey7n.HUGUVb8_OBf_isMMP6gQsn9TZvg.6yDdEJO0to7W-4Rz6dqUog"
+
+// parseRealJOSEKey parses the go-jose private key
+func parseRealJOSEKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	der, err := base64.StdEncoding.DecodeString(realJOSEPrivateKey)
+	if err != nil {
+		t.Fatalf("Invalid private key base64: %v", err)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		t.Fatalf("Invalid private key: %v", err)
+	}
+	return parsed.(*rsa.PrivateKey)
+}
+
+// jweDecryptBytes decrypts a JWE into ordinary memory
+func jweDecryptBytes(privateKey *rsa.PrivateKey, token string) ([]byte, error) {
+	return jweDecrypt(privateKey, []byte(token), func(size int) []byte { return make([]byte, size) })
+}
+
+func TestJWEReadsRealJWE(t *testing.T) {
+	privateKey := parseRealJOSEKey(t)
+	if !IsJWESecret([]byte(realJWE)) {
+		t.Error("Expected the token to be detected as a JWE")
+	}
+	decrypted, err := NewReceiverSessionWithKey(privateKey).DecryptSecret([]byte(realJWE))
+	if err != nil {
+		t.Fatalf("Failed to decrypt: %v", err)
+	}
+	defer decrypted.Destroy()
+	if string(decrypted.Bytes()) != "Xk2#pQ9!vL7@mN4$wR8&" {
+		t.Errorf("Expected 'Xk2#pQ9!vL7@mN4$wR8&', got '%s'", decrypted.Bytes())
+	}
+
+	// The JWK go-jose wrote for the key parses to the same public key
+	publicKey, err := JWKToPublicKey([]byte(realJOSEKey))
+	if err != nil {
+		t.Fatalf("Failed to parse JWK: %v", err)
+	}
+	if !publicKey.Equal(&parseRealJOSEKey(t).PublicKey) {
+		t.Error("Expected the JWK to be the private key's public key")
+	}
+}
+
+func TestJWESessions(t *testing.T) {
+	privateKey := fuzzPrivateKey(t)
+	jwkBytes, err := PublicKeyToJWK(&privateKey.PublicKey)
+	if err != nil {
+		t.Fatalf("Failed to convert public key: %v", err)
+	}
+	publicKey, err := JWKToPublicKey(jwkBytes)
+	if err != nil {
+		t.Fatalf("Failed to parse JWK: %v", err)
+	}
+	if !publicKey.Equal(&privateKey.PublicKey) {
+		t.Error("Expected the JWK to round trip")
+	}
+
+	for _, secret := range [][]byte{[]byte("Xk2#pQ9!vL7@mN4$wR8&"), {}, bytes.Repeat([]byte{0xff}, 100000)} {
+		token, err := NewSenderSession(publicKey).EncryptSecretJWE(secret)
+		if err != nil {
+			t.Fatalf("Failed to encrypt: %v", err)
+		}
+		if !IsJWESecret([]byte(token)) || strings.ContainsAny(token, "+/=") {
+			t.Errorf("Expected a compact JWE, got '%.40s'", token)
+		}
+
+		// The header names the algorithms and the key, and authenticates nothing else
+		header, err := jweEncoding.DecodeString(strings.Split(token, ".")[0])
+		if err != nil {
+			t.Fatalf("Invalid header: %v", err)
+		}
+		expected := `{"alg":"RSA-OAEP-256","enc":"A256GCM","kid":"` + jwkThumbprint(publicKey) + `"}`
+		if string(header) != expected {
+			t.Errorf("Expected header %s, got %s", expected, header)
+		}
+
+		decrypted, err := NewReceiverSessionWithKey(privateKey).DecryptSecret([]byte(token))
+		if err != nil {
+			t.Fatalf("Failed to decrypt: %v", err)
+		}
+		if !bytes.Equal(decrypted.Bytes(), secret) {
+			t.Errorf("Expected %d bytes back, got %d", len(secret), len(decrypted.Bytes()))
+		}
+		decrypted.Destroy()
+	}
+
+	// JWE needs an RSA key
+	ageReceiver, err := NewAgeReceiverSession()
+	if err != nil {
+		t.Fatalf("Failed to create age session: %v", err)
+	}
+	defer ageReceiver.Destroy()
+	ageSender, err := NewSenderSessionForAgeRecipient(ageReceiver.AgeRecipient())
+	if err != nil {
+		t.Fatalf("Failed to create age sender: %v", err)
+	}
+	if _, err := ageSender.EncryptSecretJWE([]byte("secret")); err == nil {
+		t.Error("Expected an error encrypting a JWE to an age recipient")
+	}
+
+	// An age session is told a JWE is for a SecretShare key
+	token, err := JWEEncrypt(publicKey, []byte("secret"))
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+	if _, err := ageReceiver.DecryptSecret([]byte(token)); !errors.Is(err, ErrNotAgeSecret) {
+		t.Errorf("Expected ErrNotAgeSecret, got %v", err)
+	}
+}
+
+func TestJWKThumbprint(t *testing.T) {
+	// The example from RFC 7638 section 3.1
+	rfcKey := `{"kty":"RSA","n":"0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw","e":"AQAB","alg":"RS256","kid":"2011-04-29"}`
+	var key jwk
+	if err := json.Unmarshal([]byte(rfcKey), &key); err != nil {
+		t.Fatalf("Failed to parse key: %v", err)
+	}
+	key.Algorithm = ""
+	data, _ := json.Marshal(key)
+	publicKey, err := JWKToPublicKey(data)
+	if err != nil {
+		t.Fatalf("Failed to parse JWK: %v", err)
+	}
+	if thumbprint := jwkThumbprint(publicKey); thumbprint != "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs" {
+		t.Errorf("Expected thumbprint NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs, got %s", thumbprint)
+	}
+}
+
+func TestJWKToPublicKeyInvalid(t *testing.T) {
+	var real map[string]string
+	if err := json.Unmarshal([]byte(realJOSEKey), &real); err != nil {
+		t.Fatalf("Failed to parse JWK: %v", err)
+	}
+	with := func(name, value string) string {
+		key := map[string]string{}
+		for k, v := range real {
+			key[k] = v
+		}
+		if value == "" {
+			delete(key, name)
+		} else {
+			key[name] = value
+		}
+		data, _ := json.Marshal(key)
+		return string(data)
+	}
+	small, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	invalid := map[string]string{
+		"not JSON":         "{kty: RSA}",
+		"EC key":           with("kty", "EC"),
+		"signing key":      with("use", "sig"),
+		"RSA-OAEP (SHA-1)": with("alg", "RSA-OAEP"),
+		"private key":      with("d", "AQAB"),
+		"missing modulus":  with("n", ""),
+		"padded modulus":   with("n", real["n"]+"="),
+		"even exponent":    with("e", "AQAA"),
+		"huge exponent":    with("e", "AQAAAAE"),
+		"small key":        with("n", jweEncoding.EncodeToString(small.N.Bytes())),
+	}
+	for name, data := range invalid {
+		if _, err := JWKToPublicKey([]byte(data)); err == nil {
+			t.Errorf("Expected error for %s", name)
+		}
+	}
+
+	// alg and use are optional
+	if _, err := JWKToPublicKey([]byte(with("alg", ""))); err != nil {
+		t.Errorf("Expected a JWK without alg to parse, got %v", err)
+	}
+}
+
+func TestJWEDecryptErrors(t *testing.T) {
+	privateKey := parseRealJOSEKey(t)
+	parts := strings.Split(realJWE, ".")
+	withHeader := func(header string) string {
+		return strings.Join(append([]string{jweEncoding.EncodeToString([]byte(header))}, parts[1:]...), ".")
+	}
+	withPart := func(i int, part []byte) string {
+		changed := append([]string{}, parts...)
+		changed[i] = jweEncoding.EncodeToString(part)
+		return strings.Join(changed, ".")
+	}
+	tag, _ := jweEncoding.DecodeString(parts[4])
+	tag[0] ^= 1
+
+	invalid := map[string]string{
+		"four parts":       strings.Join(parts[:4], "."),
+		"padded part":      realJWE + "=",
+		"RSA-OAEP (SHA-1)": withHeader(`{"alg":"RSA-OAEP","enc":"A256GCM"}`),
+		"A128GCM":          withHeader(`{"alg":"RSA-OAEP-256","enc":"A128GCM"}`),
+		"compressed":       withHeader(`{"alg":"RSA-OAEP-256","enc":"A256GCM","zip":"DEF"}`),
+		"critical":         withHeader(`{"alg":"RSA-OAEP-256","enc":"A256GCM","crit":["exp"],"exp":1}`),
+		"changed header":   withHeader(`{"alg":"RSA-OAEP-256","enc":"A256GCM","kid":"x"}`),
+		"short IV":         withPart(2, make([]byte, 8)),
+		"changed tag":      withPart(4, tag),
+		"changed key":      withPart(1, make([]byte, 256)),
+	}
+	for name, token := range invalid {
+		if _, err := jweDecryptBytes(privateKey, token); err == nil {
+			t.Errorf("Expected error for %s", name)
+		}
+	}
+
+	// A key wrapped for another receiver doesn't decrypt
+	if _, err := jweDecryptBytes(fuzzPrivateKey(t), realJWE); err == nil {
+		t.Error("Expected error decrypting with another key")
+	}
+}
+
+func FuzzJWEDecrypt(f *testing.F) {
+	privateKey := fuzzPrivateKey(f)
+	valid, err := JWEEncrypt(&privateKey.PublicKey, []byte("fuzz secret"))
+	if err != nil {
+		f.Fatalf("Failed to encrypt: %v", err)
+	}
+	f.Add(valid)
+	f.Add(realJWE)
+	f.Add("eyJ....")
+	f.Add("")
+
+	f.Fuzz(func(t *testing.T, token string) {
+		decrypted, err := jweDecryptBytes(privateKey, token)
+		if err == nil && !bytes.Equal(decrypted, []byte("fuzz secret")) {
+			t.Fatalf("Decrypted a changed token to %q", decrypted)
+		}
+	})
+}
@@ -0,0 +1,147 @@
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FormatKubernetesSecret renders fields as a v1 Secret manifest with base64 encoded data,
+// ready for `kubectl apply -f -`. The namespace is omitted if empty.
+func FormatKubernetesSecret(name, namespace string, fields []Field) ([]byte, error) {
+	if !IsValidKubernetesName(name) {
+		return nil, fmt.Errorf("invalid Secret name %q: use lowercase letters, digits, '-' and '.'", name)
+	}
+	if namespace != "" && !IsValidKubernetesNamespace(namespace) {
+		return nil, fmt.Errorf("invalid namespace %q: use lowercase letters, digits and '-'", namespace)
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no fields to include in the Secret")
+	}
+
+	seen := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		if !IsValidKubernetesKey(field.Key) {
+			return nil, fmt.Errorf("invalid Secret key %q: use letters, digits, '-', '_' and '.'", field.Key)
+		}
+		if seen[field.Key] {
+			return nil, fmt.Errorf("duplicate Secret key: %s", field.Key)
+		}
+		seen[field.Key] = true
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("apiVersion: v1\n")
+	buf.WriteString("kind: Secret\n")
+	buf.WriteString("metadata:\n")
+	buf.WriteString("  name: " + yamlScalar(name) + "\n")
+	if namespace != "" {
+		buf.WriteString("  namespace: " + yamlScalar(namespace) + "\n")
+	}
+	buf.WriteString("type: Opaque\n")
+	buf.WriteString("data:\n")
+	for _, field := range fields {
+		buf.WriteString("  " + yamlScalar(field.Key) + ": ")
+		encoded := make([]byte, base64.StdEncoding.EncodedLen(len(field.Value)))
+		base64.StdEncoding.Encode(encoded, field.Value)
+		writeYAMLBase64(&buf, encoded)
+		Wipe(encoded)
+		buf.WriteString("\n")
+	}
+
+	return buf.Bytes(), nil
+}
+
+// IsValidKubernetesName checks if a name is a valid DNS-1123 subdomain, as required for Secret names
+func IsValidKubernetesName(name string) bool {
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(name, ".") {
+		if !isDNSLabel(label, 63) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsValidKubernetesNamespace checks if a name is a valid DNS-1123 label, as required for namespaces
+func IsValidKubernetesNamespace(namespace string) bool {
+	return isDNSLabel(namespace, 63)
+}
+
+// IsValidKubernetesKey checks if a name can be used as a key in a Secret's data
+func IsValidKubernetesKey(key string) bool {
+	if key == "" || len(key) > 253 || key == "." || key == ".." {
+		return false
+	}
+	for _, c := range key {
+		isAlnum := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+		if !isAlnum && c != '-' && c != '_' && c != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// isDNSLabel checks for lowercase alphanumerics and '-', starting and ending with an alphanumeric
+func isDNSLabel(label string, maxLen int) bool {
+	if label == "" || len(label) > maxLen {
+		return false
+	}
+	for i, c := range label {
+		isAlnum := (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9')
+		if !isAlnum && (c != '-' || i == 0 || i == len(label)-1) {
+			return false
+		}
+	}
+	return true
+}
+
+// writeYAMLBase64 writes base64 data to buf as a YAML scalar, without copying it into a string.
+// Base64 never needs escaping, so it's only quoted where YAML could read it as something else, and
+// also whenever it starts with a digit, as numbers are too many forms to check for.
+func writeYAMLBase64(buf *bytes.Buffer, encoded []byte) {
+	plain := len(encoded) > 0 && encoded[0] != '+' && encoded[0] != '/' && (encoded[0] < '0' || encoded[0] > '9')
+	for _, word := range []string{"true", "null", "infinity"} {
+		if bytes.EqualFold(encoded, []byte(word)) {
+			plain = false
+		}
+	}
+
+	if !plain {
+		buf.WriteByte('"')
+	}
+	buf.Write(encoded)
+	if !plain {
+		buf.WriteByte('"')
+	}
+}
+
+// yamlScalar returns s as a YAML scalar, quoting it if YAML would read it as anything but a string
+func yamlScalar(s string) string {
+	plain := s != ""
+	for i, c := range s {
+		isAlnum := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+		if !isAlnum && (i == 0 || !strings.ContainsRune("-_./+=", c)) {
+			plain = false
+			break
+		}
+	}
+
+	// Values like "true", "null" or "123" would be parsed as other types
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "null", "y", "n":
+		plain = false
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		plain = false
+	}
+
+	if plain {
+		return s
+	}
+	return strconv.Quote(s)
+}
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"testing"
+)
+
+func TestFormatKubernetesSecret(t *testing.T) {
+	fields := []Field{
+		{Key: "username", Value: []byte("admin")},
+		{Key: "password", Value: []byte("hunter2")},
+		{Key: "true", Value: []byte("")},
+	}
+
+	manifest, err := FormatKubernetesSecret("db-creds", "prod", fields)
+	if err != nil {
+		t.Fatalf("Failed to format Secret manifest: %v", err)
+	}
+
+	expected := `apiVersion: v1
+kind: Secret
+metadata:
+  name: db-creds
+  namespace: prod
+type: Opaque
+data:
+  username: YWRtaW4=
+  password: aHVudGVyMg==
+  "true": ""
+`
+	if string(manifest) != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, string(manifest))
+	}
+}
+
+func TestFormatKubernetesSecretWithoutNamespace(t *testing.T) {
+	manifest, err := FormatKubernetesSecret("token", "", []Field{{Key: "token", Value: []byte("abc")}})
+	if err != nil {
+		t.Fatalf("Failed to format Secret manifest: %v", err)
+	}
+
+	expected := "apiVersion: v1\nkind: Secret\nmetadata:\n  name: token\ntype: Opaque\ndata:\n  token: YWJj\n"
+	if string(manifest) != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, string(manifest))
+	}
+}
+
+func TestFormatKubernetesSecretInvalid(t *testing.T) {
+	fields := []Field{{Key: "key", Value: []byte("value")}}
+
+	// Test case 1: Invalid Secret name
+	if _, err := FormatKubernetesSecret("Bad_Name", "", fields); err == nil {
+		t.Error("Expected error for invalid Secret name")
+	}
+
+	// Test case 2: Invalid namespace
+	if _, err := FormatKubernetesSecret("name", "my.namespace", fields); err == nil {
+		t.Error("Expected error for invalid namespace")
+	}
+
+	// Test case 3: Invalid data key
+	if _, err := FormatKubernetesSecret("name", "", []Field{{Key: "bad key", Value: []byte("x")}}); err == nil {
+		t.Error("Expected error for invalid data key")
+	}
+
+	// Test case 4: No fields
+	if _, err := FormatKubernetesSecret("name", "", nil); err == nil {
+		t.Error("Expected error for empty Secret")
+	}
+}
+
+func TestIsValidKubernetesName(t *testing.T) {
+	valid := []string{"a", "db-creds", "app.example.com", "x1"}
+	for _, name := range valid {
+		if !IsValidKubernetesName(name) {
+			t.Errorf("Expected '%s' to be a valid name", name)
+		}
+	}
+
+	invalid := []string{"", "-a", "a-", "A", "a_b", "a..b", "a b"}
+	for _, name := range invalid {
+		if IsValidKubernetesName(name) {
+			t.Errorf("Expected '%s' to be an invalid name", name)
+		}
+	}
+}
+
+func TestYAMLScalar(t *testing.T) {
+	cases := map[string]string{
+		"plain":     "plain",
+		"YWJj+/==":  "YWJj+/==",
+		"123":       `"123"`,
+		"1e5":       `"1e5"`,
+		"null":      `"null"`,
+		"":          `""`,
+		"-dash":     `"-dash"`,
+		"has space": `"has space"`,
+	}
+	for input, expected := range cases {
+		if result := yamlScalar(input); result != expected {
+			t.Errorf("yamlScalar(%q): Expected '%s', got '%s'", input, expected, result)
+		}
+	}
+}
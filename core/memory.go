@@ -0,0 +1,48 @@
+package core
+
+import (
+	"crypto/rsa"
+	"math/big"
+	"runtime"
+)
+
+// Wipe overwrites a buffer holding key material or a secret with zeros
+func Wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+	runtime.KeepAlive(b)
+}
+
+// wipeInt overwrites the limbs of a big.Int holding private key material, then sets it to zero
+func wipeInt(x *big.Int) {
+	if x == nil {
+		return
+	}
+	words := x.Bits()
+	for i := range words {
+		words[i] = 0
+	}
+	runtime.KeepAlive(words)
+	x.SetInt64(0)
+}
+
+// wipePrivateKey overwrites the private exponent, primes and CRT values of an RSA key.
+// The crypto/rsa package also keeps unexported copies for its own use, which can't be
+// reached and are left to the garbage collector.
+func wipePrivateKey(key *rsa.PrivateKey) {
+	wipeInt(key.D)
+	for _, prime := range key.Primes {
+		wipeInt(prime)
+	}
+	wipeInt(key.Precomputed.Dp)
+	wipeInt(key.Precomputed.Dq)
+	wipeInt(key.Precomputed.Qinv)
+	for _, values := range key.Precomputed.CRTValues {
+		wipeInt(values.Exp)
+		wipeInt(values.Coeff)
+		wipeInt(values.R)
+	}
+	key.Primes = nil
+	key.Precomputed = rsa.PrecomputedValues{}
+}
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestWipe(t *testing.T) {
+	// Test case 1: Every byte is zeroed
+	secret := []byte("hunter2")
+	Wipe(secret)
+	for i, b := range secret {
+		if b != 0 {
+			t.Errorf("Test 1 failed: Expected byte %d to be zero, got %d", i, b)
+		}
+	}
+
+	// Test case 2: Empty and nil buffers are fine
+	Wipe([]byte{})
+	Wipe(nil)
+}
+
+func TestReceiverSessionDestroy(t *testing.T) {
+	session, err := NewReceiverSession()
+	if err != nil {
+		t.Fatalf("Failed to create receiver session: %v", err)
+	}
+	encrypted, err := NewSenderSession(session.GetPublicKey()).EncryptSecret([]byte("test secret"))
+	if err != nil {
+		t.Fatalf("Failed to encrypt secret: %v", err)
+	}
+
+	// Keep references to the key's memory to check it's overwritten, not just dropped
+	privateKey := session.key.(*rsaKey).privateKey
+	dWords := privateKey.D.Bits()
+	primeWords := privateKey.Primes[0].Bits()
+	dpWords := privateKey.Precomputed.Dp.Bits()
+
+	session.Destroy()
+
+	for _, words := range [][]big.Word{dWords, primeWords, dpWords} {
+		for _, word := range words {
+			if word != 0 {
+				t.Fatal("Expected private key memory to be wiped")
+			}
+		}
+	}
+	if privateKey.D.Sign() != 0 || len(privateKey.Primes) != 0 || privateKey.Precomputed.Dp != nil {
+		t.Error("Expected private key values to be cleared")
+	}
+
+	// Destroyed sessions can't decrypt
+	if _, err := session.DecryptSecret(encrypted); err == nil {
+		t.Error("Expected error decrypting with a destroyed session")
+	}
+
+	// Destroying twice is fine
+	session.Destroy()
+}
+
+func TestReceiverSessionKeyLocked(t *testing.T) {
+	probe := NewSecureBuffer(1)
+	lockable := probe.Locked()
+	probe.Destroy()
+
+	// Test case 1: RSA keys are in ordinary memory, as crypto/rsa allocates them, and are only
+	// wiped on Destroy
+	session, err := NewReceiverSession()
+	if err != nil {
+		t.Fatalf("Failed to create receiver session: %v", err)
+	}
+	if session.KeyLocked() {
+		t.Error("Test 1 failed: Expected an RSA key not to be reported as locked")
+	}
+	session.Destroy()
+
+	// Test case 2: HPKE keys and age identities are locked wherever memory can be
+	for _, newSession := range []func() (*ReceiverSession, error){NewHPKEReceiverSession, NewAgeReceiverSession} {
+		session, err := newSession()
+		if err != nil {
+			t.Fatalf("Failed to create receiver session: %v", err)
+		}
+		if session.KeyLocked() != lockable {
+			t.Errorf("Test 2 failed: Expected the key to be locked: %v, got %v", lockable, session.KeyLocked())
+		}
+
+		// Test case 3: Destroyed keys aren't locked memory any more
+		session.Destroy()
+		if session.KeyLocked() {
+			t.Error("Test 3 failed: Expected a destroyed key not to be reported as locked")
+		}
+	}
+}
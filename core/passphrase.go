@@ -0,0 +1,185 @@
+package core
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// ErrPassphraseSecret is returned when a secret encrypted with a passphrase is decrypted with a key
+var ErrPassphraseSecret = errors.New("this secret was encrypted with a passphrase")
+
+// ErrWrongPassphrase is returned when a passphrase secret can't be decrypted, because the passphrase
+// is wrong or the secret was changed
+var ErrWrongPassphrase = errors.New("wrong passphrase, or the secret was changed")
+
+// kdfArgon2id identifies Argon2id in the ssv2 header
+const kdfArgon2id = 1
+
+// passphraseSaltSize is the size of the random salt stored in each ssv2 secret
+const passphraseSaltSize = 16
+
+// passphraseHeaderSize is the size of the ssv2 header: version, KDF, time, memory, threads and salt
+const passphraseHeaderSize = 4 + 1 + 4 + 4 + 1 + passphraseSaltSize
+
+// PassphraseParams are the Argon2id settings used to derive a key from a passphrase
+type PassphraseParams struct {
+	Time    uint32 // number of passes over memory
+	Memory  uint32 // memory in KiB
+	Threads uint8  // degree of parallelism
+}
+
+// DefaultPassphraseParams are the settings for new secrets, the second recommended option of RFC 9106
+var DefaultPassphraseParams = PassphraseParams{Time: 3, Memory: 64 * 1024, Threads: 4}
+
+// maxPassphraseParams limits the settings accepted when decrypting, so a crafted secret can't make
+// the receiver use unbounded memory or time
+var maxPassphraseParams = PassphraseParams{Time: 16, Memory: 1024 * 1024, Threads: 16}
+
+// valid reports whether the settings are usable, and no more expensive than the limits
+func (p PassphraseParams) valid() bool {
+	return p.Time >= 1 && p.Time <= maxPassphraseParams.Time &&
+		p.Memory >= 8*uint32(p.Threads) && p.Memory <= maxPassphraseParams.Memory &&
+		p.Threads >= 1 && p.Threads <= maxPassphraseParams.Threads
+}
+
+func init() {
+	// Passphrase secrets are decrypted with DecryptPassphraseSecret, never with a key
+	registerSuite(&suite{prefix: "ssv2", otherKeyErr: &KeyMismatchError{
+		Err:         ErrPassphraseSecret,
+		Explanation: "This secret was encrypted with a passphrase instead of your key.",
+		Hint:        "Run 'secret_share receive --passphrase' with the passphrase agreed with the sender.",
+	}})
+}
+
+// IsPassphraseSecret reports whether encrypted data was encrypted with a passphrase
+func IsPassphraseSecret(encryptedData []byte) bool {
+	return bytes.HasPrefix(encryptedData, []byte("ssv2"))
+}
+
+// PassphraseEncrypt encrypts data with a key derived from a passphrase:
+// 1. Derives an AES-256 key from the passphrase and a random salt with Argon2id
+// 2. Encrypts the data with AES-GCM, authenticating the header as additional data
+// 3. Prepends "ssv2" and the KDF settings and salt, so the receiver can derive the same key
+func PassphraseEncrypt(passphrase, data []byte) ([]byte, error) {
+	return passphraseEncrypt(rand.Reader, DefaultPassphraseParams, passphrase, data)
+}
+
+// passphraseEncrypt implements PassphraseEncrypt, reading the salt and the nonce from random in
+// that order. Tests use a fixed random to reproduce known answers.
+func passphraseEncrypt(random io.Reader, params PassphraseParams, passphrase, data []byte) ([]byte, error) {
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("passphrase is empty")
+	}
+	if !params.valid() {
+		return nil, fmt.Errorf("invalid passphrase settings")
+	}
+
+	salt := make([]byte, passphraseSaltSize)
+	if _, err := io.ReadFull(random, salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	nonce, err := generateNonce(random)
+	if err != nil {
+		return nil, err
+	}
+
+	// Format: [ssv2][kdf][time][memory][threads][salt][nonce][ciphertext]
+	header := make([]byte, passphraseHeaderSize)
+	copy(header[0:4], "ssv2")
+	header[4] = kdfArgon2id
+	binary.BigEndian.PutUint32(header[5:9], params.Time)
+	binary.BigEndian.PutUint32(header[9:13], params.Memory)
+	header[13] = params.Threads
+	copy(header[14:], salt)
+
+	gcm, err := passphraseCipher(passphrase, params, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]byte, 0, len(header)+len(nonce)+len(data)+gcm.Overhead())
+	result = append(result, header...)
+	result = append(result, nonce...)
+	return gcm.Seal(result, nonce, data, header), nil
+}
+
+// PassphraseDecrypt decrypts data encrypted by PassphraseEncrypt. Returns ErrWrongPassphrase if the
+// passphrase doesn't match.
+func PassphraseDecrypt(passphrase, encryptedData []byte) ([]byte, error) {
+	return passphraseDecrypt(passphrase, encryptedData, func(size int) []byte {
+		return make([]byte, size)
+	})
+}
+
+// DecryptPassphraseSecret decrypts a secret encrypted with a passphrase into a SecureBuffer,
+// which the caller destroys once it's done with the secret
+func DecryptPassphraseSecret(passphrase, encryptedSecret []byte) (*SecureBuffer, error) {
+	var buffer *SecureBuffer
+	_, err := passphraseDecrypt(passphrase, encryptedSecret, func(size int) []byte {
+		buffer = NewSecureBuffer(size)
+		return buffer.Bytes()
+	})
+	if err != nil {
+		buffer.Destroy()
+		return nil, err
+	}
+	return buffer, nil
+}
+
+// passphraseDecrypt implements PassphraseDecrypt, decrypting into memory from alloc so the caller
+// controls where the plaintext is kept
+func passphraseDecrypt(passphrase, encryptedData []byte, alloc func(size int) []byte) ([]byte, error) {
+	if !IsPassphraseSecret(encryptedData) {
+		return nil, fmt.Errorf("not a passphrase secret")
+	}
+	if len(encryptedData) < passphraseHeaderSize+12 {
+		return nil, fmt.Errorf("invalid encrypted data format")
+	}
+
+	header := encryptedData[:passphraseHeaderSize]
+	if header[4] != kdfArgon2id {
+		// Only a newer version would use another KDF
+		return nil, ErrNewerVersion
+	}
+	params := PassphraseParams{
+		Time:    binary.BigEndian.Uint32(header[5:9]),
+		Memory:  binary.BigEndian.Uint32(header[9:13]),
+		Threads: header[13],
+	}
+	if !params.valid() {
+		return nil, fmt.Errorf("invalid passphrase settings")
+	}
+	salt := header[14:]
+	nonce := encryptedData[passphraseHeaderSize : passphraseHeaderSize+12]
+	ciphertext := encryptedData[passphraseHeaderSize+12:]
+
+	gcm, err := passphraseCipher(passphrase, params, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decrypt data, straight into the plaintext's memory
+	size := len(ciphertext) - gcm.Overhead()
+	if size < 0 {
+		size = 0
+	}
+	plaintext, err := gcm.Open(alloc(size)[:0], nonce, ciphertext, header)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return plaintext, nil
+}
+
+// passphraseCipher derives an AES-256-GCM cipher from a passphrase with Argon2id
+func passphraseCipher(passphrase []byte, params PassphraseParams, salt []byte) (cipher.AEAD, error) {
+	key := argon2.IDKey(passphrase, salt, params.Time, params.Memory, params.Threads, 32)
+	defer Wipe(key)
+	return newAESGCM(key)
+}
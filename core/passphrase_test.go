@@ -0,0 +1,142 @@
+package core
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// fastPassphraseParams keep tests quick. Real secrets use DefaultPassphraseParams.
+var fastPassphraseParams = PassphraseParams{Time: 1, Memory: 64, Threads: 1}
+
+func TestPassphraseEncryptDecrypt(t *testing.T) {
+	passphrase := []byte("correct horse battery staple")
+	secret := []byte("Xk2#pQ9!vL7@mN4$wR8&")
+
+	// Test case 1: Round trip with the default settings
+	encrypted, err := PassphraseEncrypt(passphrase, secret)
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+	if !IsPassphraseSecret(encrypted) {
+		t.Errorf("Expected an ssv2 envelope, got prefix %q", encrypted[:4])
+	}
+	if time := binary.BigEndian.Uint32(encrypted[5:9]); time != DefaultPassphraseParams.Time {
+		t.Errorf("Expected the default time to be stored, got %d", time)
+	}
+	decrypted, err := PassphraseDecrypt(passphrase, encrypted)
+	if err != nil {
+		t.Fatalf("Failed to decrypt: %v", err)
+	}
+	if !bytes.Equal(decrypted, secret) {
+		t.Errorf("Expected '%s', got '%s'", secret, decrypted)
+	}
+
+	// Test case 2: The same secret encrypts differently each time, with a new salt and nonce
+	again, err := PassphraseEncrypt(passphrase, secret)
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+	if bytes.Equal(encrypted, again) {
+		t.Error("Expected a new salt and nonce for each secret")
+	}
+
+	// Test case 3: Decrypting into a SecureBuffer
+	buffer, err := DecryptPassphraseSecret(passphrase, encrypted)
+	if err != nil {
+		t.Fatalf("Failed to decrypt into a secure buffer: %v", err)
+	}
+	defer buffer.Destroy()
+	if !bytes.Equal(buffer.Bytes(), secret) {
+		t.Errorf("Expected '%s', got '%s'", secret, buffer.Bytes())
+	}
+}
+
+func TestPassphraseDecryptWrongPassphrase(t *testing.T) {
+	encrypted, err := passphraseEncrypt(rand.Reader, fastPassphraseParams, []byte("right"), []byte("secret"))
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+
+	// Test case 1: Wrong passphrase
+	if _, err := PassphraseDecrypt([]byte("wrong"), encrypted); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Expected wrong passphrase error, got %v", err)
+	}
+
+	// Test case 2: The settings in the header are authenticated
+	tampered := append([]byte{}, encrypted...)
+	tampered[8]++ // time
+	if _, err := PassphraseDecrypt([]byte("right"), tampered); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Expected tampered settings to be rejected, got %v", err)
+	}
+
+	// Test case 3: Tampered ciphertext
+	tampered = append([]byte{}, encrypted...)
+	tampered[len(tampered)-1] ^= 1
+	if _, err := PassphraseDecrypt([]byte("right"), tampered); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Expected tampered ciphertext to be rejected, got %v", err)
+	}
+}
+
+func TestPassphraseDecryptInvalid(t *testing.T) {
+	encrypted, err := passphraseEncrypt(rand.Reader, fastPassphraseParams, []byte("right"), []byte("secret"))
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+
+	withHeader := func(change func(header []byte)) []byte {
+		data := append([]byte{}, encrypted...)
+		change(data)
+		return data
+	}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"not ssv2", append([]byte("ssv1"), encrypted[4:]...)},
+		{"truncated", encrypted[:passphraseHeaderSize+11]},
+		{"zero time", withHeader(func(h []byte) { binary.BigEndian.PutUint32(h[5:9], 0) })},
+		{"too many passes", withHeader(func(h []byte) { binary.BigEndian.PutUint32(h[5:9], 1000) })},
+		{"too much memory", withHeader(func(h []byte) { binary.BigEndian.PutUint32(h[9:13], 0xffffffff) })},
+		{"zero threads", withHeader(func(h []byte) { h[13] = 0 })},
+		{"too many threads", withHeader(func(h []byte) { h[13] = 255 })},
+	}
+	for _, test := range tests {
+		if _, err := PassphraseDecrypt([]byte("right"), test.data); err == nil {
+			t.Errorf("%s: Expected an error", test.name)
+		}
+	}
+
+	// An unknown KDF is from a newer version
+	unknownKDF := withHeader(func(h []byte) { h[4] = 9 })
+	if _, err := PassphraseDecrypt([]byte("right"), unknownKDF); !errors.Is(err, ErrNewerVersion) {
+		t.Errorf("Expected newer version error for an unknown KDF, got %v", err)
+	}
+}
+
+func TestPassphraseEncryptInvalid(t *testing.T) {
+	// Test case 1: An empty passphrase protects nothing
+	if _, err := PassphraseEncrypt(nil, []byte("secret")); err == nil {
+		t.Error("Test 1 failed: Expected error for an empty passphrase")
+	}
+
+	// Test case 2: Settings the receiver would reject
+	if _, err := passphraseEncrypt(rand.Reader, PassphraseParams{Time: 1, Memory: 64, Threads: 0}, []byte("p"), []byte("s")); err == nil {
+		t.Error("Test 2 failed: Expected error for invalid settings")
+	}
+}
+
+func TestHybridDecryptPassphraseSecret(t *testing.T) {
+	privateKey := fuzzPrivateKey(t)
+	encrypted, err := passphraseEncrypt(rand.Reader, fastPassphraseParams, []byte("right"), []byte("secret"))
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+
+	// Passphrase secrets can't be decrypted with a key, and say so
+	if _, err := HybridDecrypt(privateKey, encrypted); !errors.Is(err, ErrPassphraseSecret) {
+		t.Errorf("Expected passphrase secret error, got %v", err)
+	}
+}
@@ -0,0 +1,286 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// fieldsPayloadPrefix marks a decrypted payload as a structured set of fields
+// rather than a plain secret. Plain secrets are encrypted as-is, so older
+// versions still decrypt them unchanged. It starts with a NUL byte, which the
+// sender refuses at the start of a secret, so a typed secret that happens to
+// start with "ssf1{" is never taken for fields.
+const fieldsPayloadPrefix = "\x00ssf1"
+
+// Field is a single named value in a structured secret. The value is kept in bytes rather than a
+// string, so it can be wiped once it's delivered.
+type Field struct {
+	Key   string
+	Value []byte
+}
+
+// fieldsPayload is the JSON body of a structured payload. Values are read as raw JSON strings and
+// unquoted into bytes, rather than into strings that can't be wiped.
+type fieldsPayload struct {
+	Fields []struct {
+		Key   string          `json:"key"`
+		Value json.RawMessage `json:"value"`
+	} `json:"fields"`
+}
+
+// WipeFields overwrites the values of fields with zeros
+func WipeFields(fields []Field) {
+	for _, field := range fields {
+		Wipe(field.Value)
+	}
+}
+
+// EncodeFields encodes a set of key/value fields as a structured payload for encryption
+func EncodeFields(fields []Field) ([]byte, error) {
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no fields to encode")
+	}
+
+	seen := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		if field.Key == "" {
+			return nil, fmt.Errorf("field name cannot be empty")
+		}
+		if seen[field.Key] {
+			return nil, fmt.Errorf("duplicate field name: %s", field.Key)
+		}
+		seen[field.Key] = true
+	}
+
+	// Format: \x00ssf1{"fields":[{"key":...,"value":...},...]}, written into one buffer sized up front
+	// so growing it never leaves a copy of a value behind
+	keys := make([][]byte, len(fields))
+	size := len(fieldsPayloadPrefix) + len(`{"fields":[]}`)
+	for i, field := range fields {
+		key, err := json.Marshal(field.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode fields: %w", err)
+		}
+		keys[i] = key
+		size += len(`{"key":,"value":},`) + len(key) + maxJSONStringSize(field.Value)
+	}
+
+	payload := make([]byte, 0, size)
+	payload = append(payload, fieldsPayloadPrefix+`{"fields":[`...)
+	for i, field := range fields {
+		if i > 0 {
+			payload = append(payload, ',')
+		}
+		payload = append(payload, `{"key":`...)
+		payload = append(payload, keys[i]...)
+		payload = append(payload, `,"value":`...)
+		payload = appendJSONString(payload, field.Value)
+		payload = append(payload, '}')
+	}
+	return append(payload, "]}"...), nil
+}
+
+// IsFieldsPayload reports whether a decrypted payload holds structured fields
+func IsFieldsPayload(payload []byte) bool {
+	return bytes.HasPrefix(payload, []byte(fieldsPayloadPrefix+"{"))
+}
+
+// DecodeFields decodes a structured payload produced by EncodeFields.
+// Unknown JSON properties are ignored so newer senders can add metadata.
+func DecodeFields(payload []byte) ([]Field, error) {
+	if !IsFieldsPayload(payload) {
+		return nil, fmt.Errorf("payload does not contain fields")
+	}
+
+	var decoded fieldsPayload
+	if err := json.Unmarshal(payload[len(fieldsPayloadPrefix):], &decoded); err != nil {
+		return nil, fmt.Errorf("failed to decode fields: %w", err)
+	}
+	defer func() {
+		for _, field := range decoded.Fields {
+			Wipe(field.Value)
+		}
+	}()
+
+	if len(decoded.Fields) == 0 {
+		return nil, fmt.Errorf("payload does not contain fields")
+	}
+
+	fields := make([]Field, 0, len(decoded.Fields))
+	for _, field := range decoded.Fields {
+		value, err := parseJSONString(field.Value)
+		if err != nil {
+			WipeFields(fields)
+			return nil, fmt.Errorf("failed to decode field %s: %w", field.Key, err)
+		}
+		fields = append(fields, Field{Key: field.Key, Value: value})
+	}
+	return fields, nil
+}
+
+// LookupField returns the value of the named field, if present
+func LookupField(fields []Field, key string) ([]byte, bool) {
+	for _, field := range fields {
+		if field.Key == key {
+			return field.Value, true
+		}
+	}
+	return nil, false
+}
+
+// FormatFieldsJSON formats fields as a JSON object, preserving field order
+func FormatFieldsJSON(fields []Field) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("{\n")
+	for i, field := range fields {
+		key, err := json.Marshal(field.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode field name: %w", err)
+		}
+
+		buf.WriteString("  ")
+		buf.Write(key)
+		buf.WriteString(": ")
+		buf.Write(appendJSONString(nil, field.Value))
+		if i < len(fields)-1 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\n")
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes(), nil
+}
+
+// maxJSONStringSize returns the most bytes value takes as a quoted JSON string, with every byte
+// escaped as \u00XX
+func maxJSONStringSize(value []byte) int {
+	return 2 + 6*len(value)
+}
+
+// appendJSONString appends value to dst as a quoted JSON string, escaped the way json.Marshal
+// escapes strings, without copying it into a string first
+func appendJSONString(dst, value []byte) []byte {
+	const hex = "0123456789abcdef"
+	dst = append(dst, '"')
+	for i := 0; i < len(value); {
+		c := value[i]
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				dst = append(dst, '\\', c)
+			case c == '\n':
+				dst = append(dst, '\\', 'n')
+			case c == '\r':
+				dst = append(dst, '\\', 'r')
+			case c == '\t':
+				dst = append(dst, '\\', 't')
+			case c == '\b':
+				dst = append(dst, '\\', 'b')
+			case c == '\f':
+				dst = append(dst, '\\', 'f')
+			case c < 0x20 || c == '<' || c == '>' || c == '&':
+				dst = append(dst, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+			default:
+				dst = append(dst, c)
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRune(value[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			dst = utf8.AppendRune(dst, utf8.RuneError)
+		case r == '\u2028' || r == '\u2029':
+			dst = append(dst, '\\', 'u', '2', '0', '2', hex[r&0xf])
+		default:
+			dst = append(dst, value[i:i+size]...)
+		}
+		i += size
+	}
+	return append(dst, '"')
+}
+
+// parseJSONString unquotes a JSON string into a new slice of bytes, without copying it into a
+// string first
+func parseJSONString(raw []byte) ([]byte, error) {
+	if len(raw) < 2 || raw[0] != '"' || raw[len(raw)-1] != '"' {
+		return nil, fmt.Errorf("value is not a string")
+	}
+	raw = raw[1 : len(raw)-1]
+
+	// Unquoting never makes a string longer
+	value := make([]byte, 0, len(raw))
+	for i := 0; i < len(raw); i++ {
+		if raw[i] != '\\' {
+			value = append(value, raw[i])
+			continue
+		}
+		if i++; i == len(raw) {
+			Wipe(value)
+			return nil, fmt.Errorf("invalid escape in string")
+		}
+		switch raw[i] {
+		case '"', '\\', '/':
+			value = append(value, raw[i])
+		case 'b':
+			value = append(value, '\b')
+		case 'f':
+			value = append(value, '\f')
+		case 'n':
+			value = append(value, '\n')
+		case 'r':
+			value = append(value, '\r')
+		case 't':
+			value = append(value, '\t')
+		case 'u':
+			r, ok := parseJSONHex(raw[i+1:])
+			if !ok {
+				Wipe(value)
+				return nil, fmt.Errorf("invalid escape in string")
+			}
+			i += 4
+			if utf16.IsSurrogate(r) {
+				// A surrogate pair is two escapes, and a lone surrogate is read as U+FFFD
+				low, ok := rune(0), false
+				if i+2 < len(raw) && raw[i+1] == '\\' && raw[i+2] == 'u' {
+					low, ok = parseJSONHex(raw[i+3:])
+				}
+				if decoded := utf16.DecodeRune(r, low); ok && decoded != utf8.RuneError {
+					r = decoded
+					i += 6
+				} else {
+					r = utf8.RuneError
+				}
+			}
+			value = utf8.AppendRune(value, r)
+		default:
+			Wipe(value)
+			return nil, fmt.Errorf("invalid escape in string")
+		}
+	}
+	return value, nil
+}
+
+// parseJSONHex parses the four hex digits of a \u escape at the start of b
+func parseJSONHex(b []byte) (rune, bool) {
+	if len(b) < 4 {
+		return 0, false
+	}
+	var r rune
+	for _, c := range b[:4] {
+		switch {
+		case c >= '0' && c <= '9':
+			r = r<<4 | rune(c-'0')
+		case c >= 'a' && c <= 'f':
+			r = r<<4 | rune(c-'a'+10)
+		case c >= 'A' && c <= 'F':
+			r = r<<4 | rune(c-'A'+10)
+		default:
+			return 0, false
+		}
+	}
+	return r, true
+}
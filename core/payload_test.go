@@ -0,0 +1,223 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestEncodeDecodeFields(t *testing.T) {
+	fields := []Field{
+		{Key: "username", Value: []byte("admin")},
+		{Key: "password", Value: []byte("hunter2 \"quoted\"\nsecond line")},
+		{Key: "host", Value: []byte("db.example.com")},
+	}
+
+	payload, err := EncodeFields(fields)
+	if err != nil {
+		t.Fatalf("Failed to encode fields: %v", err)
+	}
+
+	if !IsFieldsPayload(payload) {
+		t.Error("Encoded payload should be detected as a fields payload")
+	}
+
+	decoded, err := DecodeFields(payload)
+	if err != nil {
+		t.Fatalf("Failed to decode fields: %v", err)
+	}
+
+	if len(decoded) != len(fields) {
+		t.Fatalf("Expected %d fields, got %d", len(fields), len(decoded))
+	}
+	for i := range fields {
+		if decoded[i].Key != fields[i].Key || !bytes.Equal(decoded[i].Value, fields[i].Value) {
+			t.Errorf("Field %d does not match. Expected: %q, Got: %q", i, fields[i], decoded[i])
+		}
+	}
+}
+
+func TestEncodeFieldsInvalid(t *testing.T) {
+	// Test case 1: No fields
+	if _, err := EncodeFields(nil); err == nil {
+		t.Error("Expected error when encoding no fields")
+	}
+
+	// Test case 2: Empty field name
+	if _, err := EncodeFields([]Field{{Key: "", Value: []byte("value")}}); err == nil {
+		t.Error("Expected error when encoding a field with an empty name")
+	}
+
+	// Test case 3: Duplicate field names
+	if _, err := EncodeFields([]Field{{Key: "a", Value: []byte("1")}, {Key: "a", Value: []byte("2")}}); err == nil {
+		t.Error("Expected error when encoding duplicate field names")
+	}
+}
+
+func TestEncodeFieldsMatchesJSON(t *testing.T) {
+	// Values are encoded by hand, and must come out exactly as json.Marshal encoded them
+	values := []string{"", "plain", "<tag> & \"quotes\" \\ /", "\x00\x01\b\f\n\r\t\x7f", "caf\u00e9 \U0001f600", "\u2028\u2029", "bad \xff\xfe utf-8"}
+	for _, value := range values {
+		payload, err := EncodeFields([]Field{{Key: "key <1>", Value: []byte(value)}})
+		if err != nil {
+			t.Fatalf("Failed to encode fields: %v", err)
+		}
+
+		expected, err := json.Marshal(map[string][]map[string]string{"fields": {{"key": "key <1>", "value": value}}})
+		if err != nil {
+			t.Fatalf("Failed to marshal fields: %v", err)
+		}
+		if string(payload) != fieldsPayloadPrefix+string(expected) {
+			t.Errorf("Encoding %q: Expected '%s', got '%s'", value, expected, payload[len(fieldsPayloadPrefix):])
+		}
+	}
+}
+
+func TestDecodeFieldsEscapes(t *testing.T) {
+	// Test case 1: Escapes other JSON encoders write
+	fields, err := DecodeFields([]byte(fieldsPayloadPrefix + `{"fields":[{"key":"a","value":"\u0041\/\ud83d\ude00\u00E9"}]}`))
+	if err != nil {
+		t.Fatalf("Failed to decode fields: %v", err)
+	}
+	if string(fields[0].Value) != "A/\U0001f600\u00e9" {
+		t.Errorf("Test 1 failed: Expected 'A/\U0001f600\u00e9', got %q", fields[0].Value)
+	}
+
+	// Test case 2: Lone surrogates are read as U+FFFD, like json.Unmarshal reads them
+	fields, err = DecodeFields([]byte(fieldsPayloadPrefix + `{"fields":[{"key":"a","value":"\ud83dx\ude00\ud83d\u0041"}]}`))
+	if err != nil {
+		t.Fatalf("Failed to decode fields: %v", err)
+	}
+	if string(fields[0].Value) != "\ufffdx\ufffd\ufffdA" {
+		t.Errorf("Test 2 failed: Expected '\ufffdx\ufffd\ufffdA', got %q", fields[0].Value)
+	}
+
+	// Test case 3: Values must be strings
+	if _, err := DecodeFields([]byte(fieldsPayloadPrefix + `{"fields":[{"key":"a","value":1}]}`)); err == nil {
+		t.Error("Test 3 failed: Expected error for a value that isn't a string")
+	}
+}
+
+func TestWipeFields(t *testing.T) {
+	fields := []Field{{Key: "a", Value: []byte("secret")}, {Key: "b", Value: []byte("other")}}
+	WipeFields(fields)
+	for _, field := range fields {
+		if !bytes.Equal(field.Value, make([]byte, len(field.Value))) {
+			t.Errorf("Field %s was not wiped: %q", field.Key, field.Value)
+		}
+	}
+}
+
+func FuzzFieldsPayload(f *testing.F) {
+	f.Add([]byte("hunter2"))
+	f.Add([]byte("<\"\\\u2028\xff>"))
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, value []byte) {
+		payload, err := EncodeFields([]Field{{Key: "a", Value: value}})
+		if err != nil {
+			t.Fatalf("Failed to encode fields: %v", err)
+		}
+		expected, err := json.Marshal(map[string][]map[string]string{"fields": {{"key": "a", "value": string(value)}}})
+		if err != nil {
+			t.Fatalf("Failed to marshal fields: %v", err)
+		}
+		if string(payload) != fieldsPayloadPrefix+string(expected) {
+			t.Fatalf("Encoding %q: Expected '%s', got '%s'", value, expected, payload)
+		}
+
+		// Decoding must read values as json.Unmarshal does
+		fields, err := DecodeFields(payload)
+		if err != nil {
+			t.Fatalf("Failed to decode fields: %v", err)
+		}
+		var decoded string
+		if err := json.Unmarshal(expected[len(`{"fields":[{"key":"a","value":`):len(expected)-3], &decoded); err != nil {
+			t.Fatalf("Failed to unmarshal value: %v", err)
+		}
+		if string(fields[0].Value) != decoded {
+			t.Errorf("Decoding %q: Expected %q, got %q", value, decoded, fields[0].Value)
+		}
+	})
+}
+
+func TestDecodeFieldsPlainSecret(t *testing.T) {
+	// Plain secrets must never be mistaken for structured payloads
+	plain := []byte("just a password")
+	if IsFieldsPayload(plain) {
+		t.Error("Plain secret should not be detected as a fields payload")
+	}
+
+	if _, err := DecodeFields(plain); err == nil {
+		t.Error("Expected error when decoding a plain secret as fields")
+	}
+}
+
+func TestDecodeFieldsIgnoresUnknownProperties(t *testing.T) {
+	payload := []byte(fieldsPayloadPrefix + `{"fields":[{"key":"a","value":"1","note":"x"}],"expires":"tomorrow"}`)
+
+	fields, err := DecodeFields(payload)
+	if err != nil {
+		t.Fatalf("Failed to decode fields with unknown properties: %v", err)
+	}
+
+	if len(fields) != 1 || fields[0].Key != "a" || string(fields[0].Value) != "1" {
+		t.Errorf("Unexpected fields: %v", fields)
+	}
+}
+
+func TestFieldsPayloadRoundTripThroughSession(t *testing.T) {
+	receiverSession, err := NewReceiverSession()
+	if err != nil {
+		t.Fatalf("Failed to create receiver session: %v", err)
+	}
+	senderSession := NewSenderSession(receiverSession.GetPublicKey())
+
+	payload, err := EncodeFields([]Field{{Key: "API_KEY", Value: []byte("abc123")}})
+	if err != nil {
+		t.Fatalf("Failed to encode fields: %v", err)
+	}
+
+	encrypted, err := senderSession.EncryptSecret(payload)
+	if err != nil {
+		t.Fatalf("Failed to encrypt payload: %v", err)
+	}
+
+	decrypted, err := receiverSession.DecryptSecret(encrypted)
+	if err != nil {
+		t.Fatalf("Failed to decrypt payload: %v", err)
+	}
+	defer decrypted.Destroy()
+
+	fields, err := DecodeFields(decrypted.Bytes())
+	if err != nil {
+		t.Fatalf("Failed to decode decrypted payload: %v", err)
+	}
+
+	if value, ok := LookupField(fields, "API_KEY"); !ok || string(value) != "abc123" {
+		t.Errorf("Expected API_KEY=abc123, got %q (found: %v)", value, ok)
+	}
+}
+
+func TestFormatFieldsJSON(t *testing.T) {
+	fields := []Field{
+		{Key: "b", Value: []byte("2")},
+		{Key: "a", Value: []byte("line\n\"quoted\"")},
+	}
+
+	result, err := FormatFieldsJSON(fields)
+	if err != nil {
+		t.Fatalf("Failed to format fields as JSON: %v", err)
+	}
+
+	expected := "{\n  \"b\": \"2\",\n  \"a\": \"line\\n\\\"quoted\\\"\"\n}\n"
+	if string(result) != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, string(result))
+	}
+
+	// Field order must be preserved, not sorted
+	if strings.Index(string(result), "\"b\"") > strings.Index(string(result), "\"a\"") {
+		t.Error("Field order was not preserved")
+	}
+}
@@ -0,0 +1,8 @@
+package core
+
+// Protection is a process-wide safeguard for keys and secrets, and whether it's active
+type Protection struct {
+	Name   string
+	Active bool
+	Err    error // why the protection isn't active
+}
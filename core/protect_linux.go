@@ -0,0 +1,36 @@
+package core
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// ProtectProcess stops the process's memory from being written to a core dump or read by a debugger,
+// and checks secrets can be kept in locked memory. Call it at startup, before any keys exist.
+func ProtectProcess() []Protection {
+	dumpable := Protection{Name: "Core dumps and debugger attach disabled (PR_SET_DUMPABLE)"}
+	if err := unix.Prctl(unix.PR_SET_DUMPABLE, 0, 0, 0, 0); err != nil {
+		dumpable.Err = fmt.Errorf("failed to disable dumps: %w", err)
+	} else {
+		dumpable.Active = true
+	}
+
+	coreLimit := Protection{Name: "Core file size limit set to 0 (RLIMIT_CORE)"}
+	if err := unix.Setrlimit(unix.RLIMIT_CORE, &unix.Rlimit{Cur: 0, Max: 0}); err != nil {
+		coreLimit.Err = fmt.Errorf("failed to set core limit: %w", err)
+	} else {
+		coreLimit.Active = true
+	}
+
+	locked := Protection{Name: "Secrets, age and HPKE keys kept in locked, guard-paged memory (mlock)"}
+	probe := NewSecureBuffer(1)
+	if probe.Locked() {
+		locked.Active = true
+	} else {
+		locked.Err = fmt.Errorf("memory could not be locked, check the RLIMIT_MEMLOCK limit (ulimit -l)")
+	}
+	probe.Destroy()
+
+	return []Protection{dumpable, coreLimit, locked}
+}
@@ -0,0 +1,35 @@
+package core
+
+import (
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestProtectProcess(t *testing.T) {
+	protections := ProtectProcess()
+	if len(protections) != 3 {
+		t.Fatalf("Expected 3 protections, got %d", len(protections))
+	}
+	for _, protection := range protections {
+		if !protection.Active && protection.Err == nil {
+			t.Errorf("Expected a reason %s is inactive", protection.Name)
+		}
+	}
+
+	dumpable, err := unix.PrctlRetInt(unix.PR_GET_DUMPABLE, 0, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to read dumpable flag: %v", err)
+	}
+	if dumpable != 0 {
+		t.Errorf("Expected the process not to be dumpable, got %d", dumpable)
+	}
+
+	var limit unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_CORE, &limit); err != nil {
+		t.Fatalf("Failed to read core limit: %v", err)
+	}
+	if limit.Cur != 0 || limit.Max != 0 {
+		t.Errorf("Expected a core limit of 0, got %d/%d", limit.Cur, limit.Max)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+
+package core
+
+import (
+	"fmt"
+)
+
+// ProtectProcess reports the process protections, which are only supported on Linux
+func ProtectProcess() []Protection {
+	err := fmt.Errorf("not supported on this platform")
+	return []Protection{
+		{Name: "Core dumps and debugger attach disabled (PR_SET_DUMPABLE)", Err: err},
+		{Name: "Core file size limit set to 0 (RLIMIT_CORE)", Err: err},
+		{Name: "Secrets, age and HPKE keys kept in locked, guard-paged memory (mlock)", Err: err},
+	}
+}
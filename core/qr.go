@@ -0,0 +1,567 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+)
+
+// ErrQRTooLarge is returned when data doesn't fit in the largest QR code
+var ErrQRTooLarge = errors.New("too long for a QR code")
+
+// qrQuietZone is the width of the light border around a QR code, in modules, which scanners need
+// to find it
+const qrQuietZone = 4
+
+// qrLevel is a QR error correction level, in the order of the tables below
+type qrLevel int
+
+const (
+	qrLevelL qrLevel = iota // recovers about 7% of codewords
+	qrLevelM                // about 15%
+	qrLevelQ                // about 25%
+	qrLevelH                // about 30%
+)
+
+// qrFormatLevelBits are the bits each level is stored as in the format information
+var qrFormatLevelBits = [4]int{1, 0, 3, 2}
+
+// qrECCodewordsPerBlock is the number of error correction codewords in each block, by level and
+// version (ISO/IEC 18004 table 9)
+var qrECCodewordsPerBlock = [4][41]int{
+	{0, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{0, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+	{0, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{0, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+}
+
+// qrECBlocks is the number of error correction blocks, by level and version (ISO/IEC 18004 table 9)
+var qrECBlocks = [4][41]int{
+	{0, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+	{0, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+	{0, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+	{0, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+}
+
+// QRCode is a QR code, as a square of dark and light modules
+type QRCode struct {
+	size    int
+	modules []bool // dark modules, row by row
+}
+
+// EncodeQR encodes data as a QR code in byte mode, using the smallest version it fits in and then
+// the highest error correction level that still fits. Returns ErrQRTooLarge if it doesn't fit.
+func EncodeQR(data []byte) (*QRCode, error) {
+	for version := 1; version <= 40; version++ {
+		if len(data) > qrByteCapacity(version, qrLevelL) {
+			continue
+		}
+		level := qrLevelL
+		for level < qrLevelH && len(data) <= qrByteCapacity(version, level+1) {
+			level++
+		}
+		return newQRCode(version, level, qrEncodeBytes(data, version, level)), nil
+	}
+	return nil, ErrQRTooLarge
+}
+
+// Size returns the width and height of the code in modules, without the quiet zone
+func (q *QRCode) Size() int {
+	return q.size
+}
+
+// Dark reports whether the module at column x and row y is dark. Modules outside the code, in the
+// quiet zone, are light.
+func (q *QRCode) Dark(x, y int) bool {
+	if x < 0 || y < 0 || x >= q.size || y >= q.size {
+		return false
+	}
+	return q.modules[y*q.size+x]
+}
+
+// HalfBlocks renders the code as text for a terminal, with the quiet zone, two rows of modules
+// to a line using Unicode half blocks. Dark modules are drawn, so the text must be shown dark on
+// light to scan.
+func (q *QRCode) HalfBlocks() string {
+	var b strings.Builder
+	for y := -qrQuietZone; y < q.size+qrQuietZone; y += 2 {
+		for x := -qrQuietZone; x < q.size+qrQuietZone; x++ {
+			top, bottom := q.Dark(x, y), q.Dark(x, y+1) && y+1 < q.size+qrQuietZone
+			switch {
+			case top && bottom:
+				b.WriteString("█")
+			case top:
+				b.WriteString("▀")
+			case bottom:
+				b.WriteString("▄")
+			default:
+				b.WriteString(" ")
+			}
+		}
+		if y+2 < q.size+qrQuietZone {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// PNG renders the code as a black and white PNG image, with the quiet zone, each module scale
+// pixels square
+func (q *QRCode) PNG(scale int) ([]byte, error) {
+	if scale < 1 {
+		return nil, fmt.Errorf("invalid QR code scale: %d", scale)
+	}
+	width := (q.size + 2*qrQuietZone) * scale
+	img := image.NewPaletted(image.Rect(0, 0, width, width), color.Palette{color.White, color.Black})
+	for y := 0; y < width; y++ {
+		for x := 0; x < width; x++ {
+			if q.Dark(x/scale-qrQuietZone, y/scale-qrQuietZone) {
+				img.SetColorIndex(x, y, 1)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode PNG: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// qrSize returns the width of a code of version, in modules
+func qrSize(version int) int {
+	return version*4 + 17
+}
+
+// qrRawCodewords returns the number of codewords a code of version holds, data and error
+// correction, after the function patterns
+func qrRawCodewords(version int) int {
+	modules := (16*version+128)*version + 64
+	if version >= 2 {
+		alignments := version/7 + 2
+		modules -= (25*alignments-10)*alignments - 55
+		if version >= 7 {
+			modules -= 36
+		}
+	}
+	return modules / 8
+}
+
+// qrDataCodewords returns the number of data codewords a code of version and level holds
+func qrDataCodewords(version int, level qrLevel) int {
+	return qrRawCodewords(version) - qrECCodewordsPerBlock[level][version]*qrECBlocks[level][version]
+}
+
+// qrByteCapacity returns the most bytes a code of version and level holds in byte mode
+func qrByteCapacity(version int, level qrLevel) int {
+	return (qrDataCodewords(version, level)*8 - 4 - qrByteCountBits(version)) / 8
+}
+
+// qrByteCountBits returns the size of a byte mode segment's length, for version
+func qrByteCountBits(version int) int {
+	if version < 10 {
+		return 8
+	}
+	return 16
+}
+
+// qrEncodeBytes encodes data as a single byte mode segment, padded to the data codewords of
+// version and level
+func qrEncodeBytes(data []byte, version int, level qrLevel) []byte {
+	var bits qrBitBuffer
+	bits.append(0x4, 4)
+	bits.append(len(data), qrByteCountBits(version))
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+
+	// Terminator, then pad to a whole codeword and fill with the alternating pad codewords
+	capacity := qrDataCodewords(version, level) * 8
+	bits.append(0, min(4, capacity-len(bits)))
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xec; len(bits) < capacity; pad ^= 0xec ^ 0x11 {
+		bits.append(pad, 8)
+	}
+	return bits.bytes()
+}
+
+// qrBitBuffer is a sequence of bits, most significant first
+type qrBitBuffer []bool
+
+// append appends the low n bits of value
+func (b *qrBitBuffer) append(value, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, (value>>i)&1 == 1)
+	}
+}
+
+// bytes packs the bits into bytes, padding the last with zeros
+func (b qrBitBuffer) bytes() []byte {
+	data := make([]byte, (len(b)+7)/8)
+	for i, bit := range b {
+		if bit {
+			data[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	return data
+}
+
+// newQRCode lays out the data codewords of version and level, with error correction, using the
+// mask with the lowest penalty
+func newQRCode(version int, level qrLevel, data []byte) *QRCode {
+	size := qrSize(version)
+	function := qrFunctionModules(version)
+	codewords := qrInterleave(data, version, level)
+
+	var best *QRCode
+	bestPenalty := 0
+	for mask := 0; mask < 8; mask++ {
+		q := &QRCode{size: size, modules: make([]bool, size*size)}
+		q.drawFunctionPatterns(version)
+		q.drawFormat(level, mask)
+		i := 0
+		qrDataPositions(size, function, func(x, y int) {
+			if i < len(codewords)*8 {
+				q.modules[y*size+x] = codewords[i/8]&(0x80>>(i%8)) != 0
+				i++
+			}
+			if qrMask(mask, x, y) {
+				q.modules[y*size+x] = !q.modules[y*size+x]
+			}
+		})
+		if penalty := q.penalty(); best == nil || penalty < bestPenalty {
+			best, bestPenalty = q, penalty
+		}
+	}
+	return best
+}
+
+// qrInterleave splits data into the error correction blocks of version and level, appends each
+// block's error correction codewords, and interleaves the blocks as they're placed in the code
+func qrInterleave(data []byte, version int, level qrLevel) []byte {
+	numBlocks := qrECBlocks[level][version]
+	ecLen := qrECCodewordsPerBlock[level][version]
+	raw := qrRawCodewords(version)
+	numShort := numBlocks - raw%numBlocks
+	shortLen := raw / numBlocks
+	generator := rsGenerator(ecLen)
+
+	// Blocks after the short ones have one more data codeword
+	dataBlocks := make([][]byte, numBlocks)
+	ecBlocks := make([][]byte, numBlocks)
+	for i, offset := 0, 0; i < numBlocks; i++ {
+		dataLen := shortLen - ecLen
+		if i >= numShort {
+			dataLen++
+		}
+		dataBlocks[i] = data[offset : offset+dataLen]
+		ecBlocks[i] = rsRemainder(dataBlocks[i], generator)
+		offset += dataLen
+	}
+
+	result := make([]byte, 0, raw)
+	for i := 0; i <= shortLen-ecLen; i++ {
+		for _, block := range dataBlocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := 0; i < ecLen; i++ {
+		for _, block := range ecBlocks {
+			result = append(result, block[i])
+		}
+	}
+	return result
+}
+
+// qrFunctionModules returns which modules of a code of version hold function patterns, format or
+// version information, rather than data
+func qrFunctionModules(version int) []bool {
+	size := qrSize(version)
+	function := make([]bool, size*size)
+	mark := func(x, y int) {
+		if x >= 0 && y >= 0 && x < size && y < size {
+			function[y*size+x] = true
+		}
+	}
+
+	// Finder patterns with their separators
+	for _, corner := range [][2]int{{0, 0}, {size - 7, 0}, {0, size - 7}} {
+		for dy := -1; dy <= 7; dy++ {
+			for dx := -1; dx <= 7; dx++ {
+				mark(corner[0]+dx, corner[1]+dy)
+			}
+		}
+	}
+	// Timing patterns
+	for i := 0; i < size; i++ {
+		mark(6, i)
+		mark(i, 6)
+	}
+	// Alignment patterns
+	for _, center := range qrAlignmentCenters(version) {
+		for dy := -2; dy <= 2; dy++ {
+			for dx := -2; dx <= 2; dx++ {
+				mark(center[0]+dx, center[1]+dy)
+			}
+		}
+	}
+	// Format information and the dark module
+	for _, position := range qrFormatPositions(size) {
+		mark(position[0], position[1])
+	}
+	mark(8, size-8)
+	// Version information
+	if version >= 7 {
+		for i := 0; i < 18; i++ {
+			mark(size-11+i%3, i/3)
+			mark(i/3, size-11+i%3)
+		}
+	}
+	return function
+}
+
+// drawFunctionPatterns draws the finder, timing and alignment patterns, and the version
+// information, of a code of version
+func (q *QRCode) drawFunctionPatterns(version int) {
+	size := q.size
+	set := func(x, y int, dark bool) {
+		if x >= 0 && y >= 0 && x < size && y < size {
+			q.modules[y*size+x] = dark
+		}
+	}
+
+	// Timing patterns, drawn first so the finders overwrite their ends
+	for i := 0; i < size; i++ {
+		set(6, i, i%2 == 0)
+		set(i, 6, i%2 == 0)
+	}
+
+	// Finder patterns: a dark square ring, a light ring and a dark center, then a light separator
+	for _, center := range [][2]int{{3, 3}, {size - 4, 3}, {3, size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				dist := max(abs(dx), abs(dy))
+				set(center[0]+dx, center[1]+dy, dist != 2 && dist != 4)
+			}
+		}
+	}
+
+	// Alignment patterns: a dark ring around a light ring and a dark center
+	for _, center := range qrAlignmentCenters(version) {
+		for dy := -2; dy <= 2; dy++ {
+			for dx := -2; dx <= 2; dx++ {
+				set(center[0]+dx, center[1]+dy, max(abs(dx), abs(dy)) != 1)
+			}
+		}
+	}
+
+	// Version information, in two copies beside the top right and bottom left finders
+	if version >= 7 {
+		bits := qrVersionBits(version)
+		for i := 0; i < 18; i++ {
+			dark := (bits>>i)&1 == 1
+			set(size-11+i%3, i/3, dark)
+			set(i/3, size-11+i%3, dark)
+		}
+	}
+}
+
+// drawFormat draws the format information for level and mask, in two copies, and the dark
+// module beside the bottom left finder
+func (q *QRCode) drawFormat(level qrLevel, mask int) {
+	size := q.size
+	bits := qrFormatBits(level, mask)
+	for i, position := range qrFormatPositions(size) {
+		dark := (bits>>(i%15))&1 == 1
+		q.modules[position[1]*size+position[0]] = dark
+	}
+	q.modules[(size-8)*size+8] = true
+}
+
+// qrFormatPositions returns where each bit of the format information goes, from the least
+// significant: first the copy around the top left finder, then the copy split between the others
+func qrFormatPositions(size int) [][2]int {
+	positions := make([][2]int, 0, 30)
+	for i := 0; i <= 5; i++ {
+		positions = append(positions, [2]int{8, i})
+	}
+	positions = append(positions, [2]int{8, 7}, [2]int{8, 8}, [2]int{7, 8})
+	for i := 9; i < 15; i++ {
+		positions = append(positions, [2]int{14 - i, 8})
+	}
+	for i := 0; i < 8; i++ {
+		positions = append(positions, [2]int{size - 1 - i, 8})
+	}
+	for i := 8; i < 15; i++ {
+		positions = append(positions, [2]int{8, size - 15 + i})
+	}
+	return positions
+}
+
+// qrFormatBits returns the 15 bit format information for level and mask: 5 data bits, 10 bits
+// of BCH error correction, masked so it's never all light
+func qrFormatBits(level qrLevel, mask int) int {
+	data := qrFormatLevelBits[level]<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+// qrVersionBits returns the 18 bit version information for version: 6 data bits and 12 bits of
+// BCH error correction
+func qrVersionBits(version int) int {
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = rem<<1 ^ (rem>>11)*0x1f25
+	}
+	return version<<12 | rem
+}
+
+// qrAlignmentCenters returns the centers of the alignment patterns of a code of version,
+// leaving out the three that would overlap finder patterns
+func qrAlignmentCenters(version int) [][2]int {
+	if version == 1 {
+		return nil
+	}
+	count := version/7 + 2
+	step := (version*8 + count*3 + 5) / (count*4 - 4) * 2
+	positions := make([]int, count)
+	positions[0] = 6
+	for i, pos := count-1, qrSize(version)-7; i >= 1; i, pos = i-1, pos-step {
+		positions[i] = pos
+	}
+
+	var centers [][2]int
+	for i, y := range positions {
+		for j, x := range positions {
+			if (i == 0 && j == 0) || (i == 0 && j == count-1) || (i == count-1 && j == 0) {
+				continue
+			}
+			centers = append(centers, [2]int{x, y})
+		}
+	}
+	return centers
+}
+
+// qrDataPositions calls visit for each data module of a code, in the order codeword bits are
+// placed: up and down two column wide strips from the right, skipping the vertical timing pattern
+func qrDataPositions(size int, function []bool, visit func(x, y int)) {
+	for right := size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < size; vert++ {
+			y := vert
+			if upward {
+				y = size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				if x := right - j; !function[y*size+x] {
+					visit(x, y)
+				}
+			}
+		}
+	}
+}
+
+// qrMask reports whether mask inverts the data module at column x and row y
+func qrMask(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
+
+// penalty scores how hard the code is to scan, by the rules masks are chosen with: runs of one
+// color, 2x2 blocks, patterns like finders, and an uneven balance of dark and light
+func (q *QRCode) penalty() int {
+	size := q.size
+	result := 0
+	finderLike := []bool{true, false, true, true, true, false, true}
+	for _, transpose := range []bool{false, true} {
+		at := func(i, j int) bool {
+			if transpose {
+				return q.Dark(j, i)
+			}
+			return q.Dark(i, j)
+		}
+		for j := 0; j < size; j++ {
+			run := 1
+			for i := 1; i <= size; i++ {
+				if i < size && at(i, j) == at(i-1, j) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					result += run - 2
+				}
+				run = 1
+			}
+			// Finder-like patterns with four light modules on either side, counting the quiet zone
+			for i := -4; i+7 <= size+4; i++ {
+				matches := true
+				for k, dark := range finderLike {
+					matches = matches && at(i+k, j) == dark
+				}
+				if !matches {
+					continue
+				}
+				before, after := true, true
+				for k := 1; k <= 4; k++ {
+					before = before && !at(i-k, j)
+					after = after && !at(i+6+k, j)
+				}
+				if before || after {
+					result += 40
+				}
+			}
+		}
+	}
+
+	dark := 0
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if q.Dark(x, y) {
+				dark++
+			}
+			if x+1 < size && y+1 < size {
+				if c := q.Dark(x, y); c == q.Dark(x+1, y) && c == q.Dark(x, y+1) && c == q.Dark(x+1, y+1) {
+					result += 3
+				}
+			}
+		}
+	}
+	total := size * size
+	result += ((abs(dark*20-total*10)+total-1)/total - 1) * 10
+	return result
+}
+
+// abs returns the absolute value of n
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
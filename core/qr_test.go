@@ -0,0 +1,199 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image/png"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestQRFormatAndVersionBits(t *testing.T) {
+	// Test case 1: Format information from ISO/IEC 18004 and its published examples
+	if bits := qrFormatBits(qrLevelM, 0); bits != 0b101010000010010 {
+		t.Errorf("Test 1 failed: Expected 101010000010010 for M and mask 0, got %015b", bits)
+	}
+	if bits := qrFormatBits(qrLevelL, 4); bits != 0b110011000101111 {
+		t.Errorf("Test 1 failed: Expected 110011000101111 for L and mask 4, got %015b", bits)
+	}
+
+	// Test case 2: Version information for version 7 and 40
+	if bits := qrVersionBits(7); bits != 0x07c94 {
+		t.Errorf("Test 2 failed: Expected 0x07c94 for version 7, got %#05x", bits)
+	}
+	if bits := qrVersionBits(40); bits != 0x28c69 {
+		t.Errorf("Test 2 failed: Expected 0x28c69 for version 40, got %#05x", bits)
+	}
+}
+
+func TestQRCapacity(t *testing.T) {
+	testCases := []struct {
+		version  int
+		level    qrLevel
+		raw      int
+		capacity int
+	}{
+		{1, qrLevelL, 26, 17},
+		{1, qrLevelH, 26, 7},
+		{7, qrLevelM, 196, 122},
+		{10, qrLevelM, 346, 213},
+		{40, qrLevelL, 3706, 2953},
+		{40, qrLevelM, 3706, 2331},
+	}
+	for i, tc := range testCases {
+		if raw := qrRawCodewords(tc.version); raw != tc.raw {
+			t.Errorf("Test %d failed: Expected %d codewords, got %d", i+1, tc.raw, raw)
+		}
+		if capacity := qrByteCapacity(tc.version, tc.level); capacity != tc.capacity {
+			t.Errorf("Test %d failed: Expected %d bytes, got %d", i+1, tc.capacity, capacity)
+		}
+	}
+}
+
+func TestQRAlignmentCenters(t *testing.T) {
+	// Test case 1: Version 1 has none
+	if centers := qrAlignmentCenters(1); len(centers) != 0 {
+		t.Errorf("Test 1 failed: Expected no alignment patterns, got %v", centers)
+	}
+
+	// Test case 2: Rows and columns from ISO/IEC 18004 annex E, less the three under finders
+	testCases := []struct {
+		version   int
+		positions []int
+	}{
+		{2, []int{6, 18}},
+		{7, []int{6, 22, 38}},
+		{32, []int{6, 34, 60, 86, 112, 138}},
+		{40, []int{6, 30, 58, 86, 114, 142, 170}},
+	}
+	for _, tc := range testCases {
+		var expected [][2]int
+		last := len(tc.positions) - 1
+		for i, y := range tc.positions {
+			for j, x := range tc.positions {
+				if !(i == 0 && j == 0) && !(i == 0 && j == last) && !(i == last && j == 0) {
+					expected = append(expected, [2]int{x, y})
+				}
+			}
+		}
+		if centers := qrAlignmentCenters(tc.version); fmt.Sprint(centers) != fmt.Sprint(expected) {
+			t.Errorf("Test 2 failed: Expected %v for version %d, got %v", expected, tc.version, centers)
+		}
+	}
+}
+
+func TestEncodeQR(t *testing.T) {
+	// Test case 1: The smallest version is used, with the highest level that fits in it
+	testCases := []struct {
+		size    int
+		version int
+		level   qrLevel
+	}{
+		{0, 1, qrLevelH},
+		{7, 1, qrLevelH},
+		{8, 1, qrLevelQ},
+		{17, 1, qrLevelL},
+		{18, 2, qrLevelQ},
+		{620, 17, qrLevelL},
+		{2953, 40, qrLevelL},
+	}
+	for _, tc := range testCases {
+		data := bytes.Repeat([]byte("s"), tc.size)
+		code, err := EncodeQR(data)
+		if err != nil {
+			t.Fatalf("Test 1 failed: Failed to encode %d bytes: %v", tc.size, err)
+		}
+		if code.Size() != qrSize(tc.version) {
+			t.Errorf("Test 1 failed: Expected version %d for %d bytes, got size %d", tc.version, tc.size, code.Size())
+		}
+		level, _, err := qrReadFormat(code.Size(), code.Dark)
+		if err != nil || level != tc.level {
+			t.Errorf("Test 1 failed: Expected level %d for %d bytes, got %d (err: %v)", tc.level, tc.size, level, err)
+		}
+	}
+
+	// Test case 2: Data past the capacity of version 40 is too large
+	if _, err := EncodeQR(make([]byte, 2954)); !errors.Is(err, ErrQRTooLarge) {
+		t.Errorf("Test 2 failed: Expected ErrQRTooLarge, got %v", err)
+	}
+
+	// Test case 3: Codes read back as their data, at every version
+	for version := 1; version <= 40; version++ {
+		data := make([]byte, qrByteCapacity(version, qrLevelL))
+		for i := range data {
+			data[i] = byte(i*31 + version)
+		}
+		code, err := EncodeQR(data)
+		if err != nil {
+			t.Fatalf("Test 3 failed: Failed to encode version %d: %v", version, err)
+		}
+		decoded, err := qrDecodeModules(code.Size(), code.Dark)
+		if err != nil || !bytes.Equal(decoded, data) {
+			t.Errorf("Test 3 failed: Expected version %d to read back (err: %v)", version, err)
+		}
+	}
+}
+
+func TestQRHalfBlocks(t *testing.T) {
+	code, err := EncodeQR([]byte("<secret_share_key>\nssv4AAAA\n</secret_share_key>"))
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	lines := strings.Split(code.HalfBlocks(), "\n")
+	width := code.Size() + 2*qrQuietZone
+
+	// Test case 1: Two rows of modules to a line, with the quiet zone around them
+	if len(lines) != (width+1)/2 {
+		t.Errorf("Test 1 failed: Expected %d lines, got %d", (width+1)/2, len(lines))
+	}
+	for i, line := range lines {
+		if utf8.RuneCountInString(line) != width {
+			t.Errorf("Test 1 failed: Expected line %d to be %d wide, got %d", i+1, width, utf8.RuneCountInString(line))
+		}
+	}
+
+	// Test case 2: Each character shows the modules above and below
+	blocks := map[[2]bool]rune{{true, true}: '█', {true, false}: '▀', {false, true}: '▄', {false, false}: ' '}
+	for i, line := range lines {
+		y := i*2 - qrQuietZone
+		for j, char := range []rune(line) {
+			x := j - qrQuietZone
+			if expected := blocks[[2]bool{code.Dark(x, y), code.Dark(x, y+1)}]; char != expected {
+				t.Fatalf("Test 2 failed: Expected '%c' at line %d column %d, got '%c'", expected, i+1, j+1, char)
+			}
+		}
+	}
+}
+
+func TestQRPNG(t *testing.T) {
+	code, err := EncodeQR([]byte("test"))
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+
+	// Test case 1: Each module is scale pixels, with the quiet zone
+	data, err := code.PNG(4)
+	if err != nil {
+		t.Fatalf("Test 1 failed: Failed to render PNG: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Test 1 failed: Failed to decode PNG: %v", err)
+	}
+	if width := (code.Size() + 2*qrQuietZone) * 4; img.Bounds().Dx() != width || img.Bounds().Dy() != width {
+		t.Errorf("Test 1 failed: Expected %dx%d pixels, got %v", width, width, img.Bounds())
+	}
+	if r, _, _, _ := img.At(0, 0).RGBA(); r != 0xffff {
+		t.Error("Test 1 failed: Expected a light quiet zone")
+	}
+	if r, _, _, _ := img.At(qrQuietZone*4, qrQuietZone*4).RGBA(); r != 0 {
+		t.Error("Test 1 failed: Expected the finder's corner to be dark")
+	}
+
+	// Test case 2: Invalid scales are rejected
+	if _, err := code.PNG(0); err == nil {
+		t.Error("Test 2 failed: Expected an error for scale 0")
+	}
+}
@@ -0,0 +1,460 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"math/bits"
+)
+
+// ErrNoQRCode is returned when an image doesn't contain a QR code that can be read
+var ErrNoQRCode = errors.New("no readable QR code found")
+
+// qrAlphanumericCharset maps the values of alphanumeric mode to characters
+const qrAlphanumericCharset = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+// DecodeQRPNG reads the QR code in a PNG image, such as one written by QRCode.PNG or a screenshot
+// of one, and returns its data. The code must be upright and square to the image, as on a screen;
+// photos taken at an angle aren't supported.
+func DecodeQRPNG(data []byte) ([]byte, error) {
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode PNG: %w", err)
+	}
+	return decodeQRImage(img)
+}
+
+// qrBitmap is an image reduced to dark and light pixels
+type qrBitmap struct {
+	width, height int
+	pixels        []bool // dark pixels, row by row
+}
+
+// dark reports whether the pixel at x, y is dark. Pixels beyond the edges are light.
+func (b *qrBitmap) dark(x, y int) bool {
+	if x < 0 || y < 0 || x >= b.width || y >= b.height {
+		return false
+	}
+	return b.pixels[y*b.width+x]
+}
+
+// qrFinder is the center of a finder pattern found in an image, and the size of its modules
+type qrFinder struct {
+	x, y   float64
+	module float64
+	hits   int // rows the pattern was found on
+}
+
+// decodeQRImage finds the finder patterns of a QR code in img, then samples and decodes its
+// modules. Versions near the one the finders suggest are tried until one decodes, since the
+// module size is only estimated.
+func decodeQRImage(img image.Image) ([]byte, error) {
+	bitmap := qrThreshold(img)
+	if bitmap == nil {
+		return nil, ErrNoQRCode
+	}
+	finders := bitmap.finders()
+
+	var lastErr error = ErrNoQRCode
+	for _, a := range finders {
+		for _, b := range finders {
+			for _, c := range finders {
+				// a is the top left finder, b the top right and c the bottom left
+				tolerance := 2 * a.module
+				across, down := b.x-a.x, c.y-a.y
+				if across <= 0 || down <= 0 || math.Abs(b.y-a.y) > tolerance || math.Abs(c.x-a.x) > tolerance || math.Abs(across-down) > tolerance {
+					continue
+				}
+				distance := (across + down) / 2
+				module := (a.module + b.module + c.module) / 3
+				estimate := int(math.Round((distance/module - 10) / 4))
+				for _, version := range []int{estimate, estimate - 1, estimate + 1, estimate - 2, estimate + 2} {
+					if version < 1 || version > 40 {
+						continue
+					}
+					// Finder centers are 3.5 modules in from the code's edges
+					size := qrSize(version)
+					pitch := distance / float64(size-7)
+					data, err := qrDecodeModules(size, func(x, y int) bool {
+						px := a.x + (float64(x)-3)*pitch
+						py := a.y + (float64(y)-3)*pitch
+						return bitmap.dark(int(math.Floor(px)), int(math.Floor(py)))
+					})
+					if err == nil {
+						return data, nil
+					}
+					lastErr = err
+				}
+			}
+		}
+	}
+	return nil, lastErr
+}
+
+// qrThreshold reduces img to dark and light pixels, by their brightness over a white background,
+// or returns nil if the image is a single shade
+func qrThreshold(img image.Image) *qrBitmap {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	luminance := make([]uint32, width*height)
+	lo, hi := uint32(math.MaxUint32), uint32(0)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			r, g, b, a := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			// Colors are premultiplied, so transparent pixels show the white behind them
+			l := (299*(r+0xffff-a) + 587*(g+0xffff-a) + 114*(b+0xffff-a)) / 1000
+			luminance[y*width+x] = l
+			lo, hi = min(lo, l), max(hi, l)
+		}
+	}
+	if width == 0 || height == 0 || hi-lo < 0x4000 {
+		return nil
+	}
+
+	threshold := (lo + hi) / 2
+	bitmap := &qrBitmap{width: width, height: height, pixels: make([]bool, width*height)}
+	for i, l := range luminance {
+		bitmap.pixels[i] = l < threshold
+	}
+	return bitmap
+}
+
+// finders returns the finder patterns in the bitmap: squares of dark, light, dark, light and dark
+// runs in the ratio 1:1:3:1:1 across and down their center. Patterns found on nearby rows are
+// merged, and those found on the most rows come first, as they're least likely to be chance.
+func (b *qrBitmap) finders() []qrFinder {
+	var finders []qrFinder
+	for y := 0; y < b.height; y++ {
+		runs, starts := b.rowRuns(y)
+		for i := 0; i+5 <= len(runs); i++ {
+			if !b.dark(starts[i], y) || !qrFinderRatio(runs[i:i+5]) {
+				continue
+			}
+			width := runs[i] + runs[i+1] + runs[i+2] + runs[i+3] + runs[i+4]
+			cx := float64(starts[i+2]) + float64(runs[i+2])/2
+			cy, height, ok := b.crossCheck(int(cx), y)
+			if !ok || math.Abs(float64(height-width)) > float64(width)/2 {
+				continue
+			}
+			module := float64(width+height) / 14
+
+			merged := false
+			for j := range finders {
+				f := &finders[j]
+				if math.Abs(f.x-cx) <= f.module && math.Abs(f.y-cy) <= f.module {
+					n := float64(f.hits)
+					f.x, f.y = (f.x*n+cx)/(n+1), (f.y*n+cy)/(n+1)
+					f.module = (f.module*n + module) / (n + 1)
+					f.hits++
+					merged = true
+					break
+				}
+			}
+			if !merged {
+				finders = append(finders, qrFinder{x: cx, y: cy, module: module, hits: 1})
+			}
+		}
+	}
+
+	for i := 1; i < len(finders); i++ {
+		for j := i; j > 0 && finders[j].hits > finders[j-1].hits; j-- {
+			finders[j], finders[j-1] = finders[j-1], finders[j]
+		}
+	}
+	return finders
+}
+
+// rowRuns splits row y into runs of dark and light pixels, returning each run's length and start
+func (b *qrBitmap) rowRuns(y int) ([]int, []int) {
+	var runs, starts []int
+	for x := 0; x < b.width; x++ {
+		if x == 0 || b.dark(x, y) != b.dark(x-1, y) {
+			runs = append(runs, 0)
+			starts = append(starts, x)
+		}
+		runs[len(runs)-1]++
+	}
+	return runs, starts
+}
+
+// crossCheck checks for a finder pattern down column x through the dark pixel at row y, and
+// returns the center row and height of the pattern
+func (b *qrBitmap) crossCheck(x, y int) (float64, int, bool) {
+	if !b.dark(x, y) {
+		return 0, 0, false
+	}
+	top, bottom := y, y
+	for top > 0 && b.dark(x, top-1) {
+		top--
+	}
+	for bottom+1 < b.height && b.dark(x, bottom+1) {
+		bottom++
+	}
+
+	// Walk out from the center run: light, then dark, on each side
+	runs := []int{0, 0, bottom - top + 1, 0, 0}
+	up := top - 1
+	for ; up >= 0 && !b.dark(x, up); up-- {
+		runs[1]++
+	}
+	for ; up >= 0 && b.dark(x, up); up-- {
+		runs[0]++
+	}
+	down := bottom + 1
+	for ; down < b.height && !b.dark(x, down); down++ {
+		runs[3]++
+	}
+	for ; down < b.height && b.dark(x, down); down++ {
+		runs[4]++
+	}
+	if !qrFinderRatio(runs) {
+		return 0, 0, false
+	}
+	return float64(top) + float64(runs[2])/2, runs[0] + runs[1] + runs[2] + runs[3] + runs[4], true
+}
+
+// qrFinderRatio reports whether five runs are in the ratio 1:1:3:1:1 of a finder pattern, within
+// half a module each
+func qrFinderRatio(runs []int) bool {
+	total := 0
+	for _, run := range runs {
+		total += run
+	}
+	if total < 7 {
+		return false
+	}
+	module := float64(total) / 7
+	variance := module / 2
+	return math.Abs(module-float64(runs[0])) < variance &&
+		math.Abs(module-float64(runs[1])) < variance &&
+		math.Abs(3*module-float64(runs[2])) < 3*variance &&
+		math.Abs(module-float64(runs[3])) < variance &&
+		math.Abs(module-float64(runs[4])) < variance
+}
+
+// qrDecodeModules decodes a code of size modules, read with dark, into its data: the format
+// information first, then the codewords, corrected block by block, then the segments they hold
+func qrDecodeModules(size int, dark func(x, y int) bool) ([]byte, error) {
+	version := (size - 17) / 4
+	level, mask, err := qrReadFormat(size, dark)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := qrRawCodewords(version)
+	codewords := make([]byte, raw)
+	i := 0
+	qrDataPositions(size, qrFunctionModules(version), func(x, y int) {
+		if i < raw*8 {
+			if dark(x, y) != qrMask(mask, x, y) {
+				codewords[i/8] |= 0x80 >> (i % 8)
+			}
+			i++
+		}
+	})
+
+	data, err := qrDeinterleave(codewords, version, level)
+	if err != nil {
+		return nil, err
+	}
+	return qrParseSegments(data, version)
+}
+
+// qrReadFormat reads the error correction level and mask from either copy of the format
+// information, correcting up to 3 wrong bits
+func qrReadFormat(size int, dark func(x, y int) bool) (qrLevel, int, error) {
+	var copies [2]int
+	for i, position := range qrFormatPositions(size) {
+		if dark(position[0], position[1]) {
+			copies[i/15] |= 1 << (i % 15)
+		}
+	}
+	bestLevel, bestMask, bestDistance := qrLevelL, 0, 4
+	for level := qrLevelL; level <= qrLevelH; level++ {
+		for mask := 0; mask < 8; mask++ {
+			bits := qrFormatBits(level, mask)
+			for _, read := range copies {
+				if distance := qrBitDistance(bits, read); distance < bestDistance {
+					bestLevel, bestMask, bestDistance = level, mask, distance
+				}
+			}
+		}
+	}
+	if bestDistance > 3 {
+		return 0, 0, fmt.Errorf("failed to read QR format information: %w", ErrNoQRCode)
+	}
+	return bestLevel, bestMask, nil
+}
+
+// qrBitDistance returns the number of bits that differ between a and b
+func qrBitDistance(a, b int) int {
+	return bits.OnesCount(uint(a ^ b))
+}
+
+// qrDeinterleave splits codewords back into the error correction blocks of version and level,
+// corrects each, and returns their data codewords in order
+func qrDeinterleave(codewords []byte, version int, level qrLevel) ([]byte, error) {
+	numBlocks := qrECBlocks[level][version]
+	ecLen := qrECCodewordsPerBlock[level][version]
+	raw := qrRawCodewords(version)
+	numShort := numBlocks - raw%numBlocks
+	shortLen := raw / numBlocks
+
+	blocks := make([][]byte, numBlocks)
+	for i := range blocks {
+		dataLen := shortLen - ecLen
+		if i >= numShort {
+			dataLen++
+		}
+		blocks[i] = make([]byte, 0, dataLen+ecLen)
+	}
+	next := 0
+	for i := 0; i <= shortLen-ecLen; i++ {
+		for j := range blocks {
+			if i < cap(blocks[j])-ecLen {
+				blocks[j] = append(blocks[j], codewords[next])
+				next++
+			}
+		}
+	}
+	for i := 0; i < ecLen; i++ {
+		for j := range blocks {
+			blocks[j] = append(blocks[j], codewords[next])
+			next++
+		}
+	}
+
+	data := make([]byte, 0, qrDataCodewords(version, level))
+	for i, block := range blocks {
+		if err := rsCorrect(block, ecLen); err != nil {
+			return nil, fmt.Errorf("failed to correct QR block %d: %w", i+1, err)
+		}
+		data = append(data, block[:len(block)-ecLen]...)
+	}
+	return data, nil
+}
+
+// qrBitReader reads bits from codewords, most significant first
+type qrBitReader struct {
+	data []byte
+	pos  int
+}
+
+// remaining returns the number of bits left
+func (r *qrBitReader) remaining() int {
+	return len(r.data)*8 - r.pos
+}
+
+// read returns the next n bits, or an error if there aren't that many
+func (r *qrBitReader) read(n int) (int, error) {
+	if n > r.remaining() {
+		return 0, fmt.Errorf("QR data is truncated")
+	}
+	value := 0
+	for i := 0; i < n; i++ {
+		value = value<<1 | int(r.data[r.pos/8]>>(7-r.pos%8)&1)
+		r.pos++
+	}
+	return value, nil
+}
+
+// qrParseSegments decodes the segments in the data codewords of a code of version, until the
+// terminator or the end of the data. Numeric, alphanumeric and byte segments are read, and ECI
+// designators are skipped, as text is expected to be UTF-8 anyway.
+func qrParseSegments(data []byte, version int) ([]byte, error) {
+	// Length sizes for numeric, alphanumeric and byte mode, for versions 1-9, 10-26 and 27-40
+	countBits := map[int][3]int{1: {10, 12, 14}, 2: {9, 11, 13}, 4: {8, 16, 16}}
+	sizeClass := 0
+	if version >= 27 {
+		sizeClass = 2
+	} else if version >= 10 {
+		sizeClass = 1
+	}
+
+	r := &qrBitReader{data: data}
+	var result []byte
+	for r.remaining() >= 4 {
+		mode, _ := r.read(4)
+		if mode == 0 {
+			break
+		}
+		if mode == 7 {
+			if err := qrSkipECI(r); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		sizes, ok := countBits[mode]
+		if !ok {
+			return nil, fmt.Errorf("unsupported QR mode %d", mode)
+		}
+		count, err := r.read(sizes[sizeClass])
+		if err != nil {
+			return nil, err
+		}
+
+		switch mode {
+		case 1:
+			// Numeric: groups of three digits in 10 bits, with two or one left over in 7 or 4
+			for ; count > 0; count -= 3 {
+				digits := min(count, 3)
+				value, err := r.read(digits*3 + 1)
+				if err != nil {
+					return nil, err
+				}
+				if value >= []int{10, 100, 1000}[digits-1] {
+					return nil, fmt.Errorf("invalid QR numeric data")
+				}
+				result = fmt.Appendf(result, "%0*d", digits, value)
+			}
+		case 2:
+			// Alphanumeric: pairs of characters in 11 bits, with one left over in 6
+			for ; count > 0; count -= 2 {
+				if count == 1 {
+					value, err := r.read(6)
+					if err != nil || value >= 45 {
+						return nil, fmt.Errorf("invalid QR alphanumeric data")
+					}
+					result = append(result, qrAlphanumericCharset[value])
+					break
+				}
+				value, err := r.read(11)
+				if err != nil || value >= 45*45 {
+					return nil, fmt.Errorf("invalid QR alphanumeric data")
+				}
+				result = append(result, qrAlphanumericCharset[value/45], qrAlphanumericCharset[value%45])
+			}
+		default:
+			for ; count > 0; count-- {
+				value, err := r.read(8)
+				if err != nil {
+					return nil, err
+				}
+				result = append(result, byte(value))
+			}
+		}
+	}
+	return result, nil
+}
+
+// qrSkipECI skips an ECI designator of 1, 2 or 3 bytes, its length given by its leading bits
+func qrSkipECI(r *qrBitReader) error {
+	first, err := r.read(8)
+	if err != nil {
+		return err
+	}
+	switch {
+	case first&0x80 == 0:
+		return nil
+	case first&0xc0 == 0x80:
+		_, err = r.read(8)
+	case first&0xe0 == 0xc0:
+		_, err = r.read(16)
+	default:
+		err = fmt.Errorf("invalid QR ECI designator")
+	}
+	return err
+}
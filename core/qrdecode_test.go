@@ -0,0 +1,148 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+// drawQR draws code on a canvas of width pixels, its top left corner at offset and each module
+// scale pixels, like a screenshot of a code on a screen
+func drawQR(t *testing.T, code *QRCode, width int, offset, scale float64, dark, light color.Color) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, width))
+	for y := 0; y < width; y++ {
+		for x := 0; x < width; x++ {
+			c := light
+			mx, my := (float64(x)-offset)/scale, (float64(y)-offset)/scale
+			if mx >= 0 && my >= 0 && code.Dark(int(mx), int(my)) {
+				c = dark
+			}
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Failed to encode PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeQRPNG(t *testing.T) {
+	data := []byte("<secret_share_key>\nssv4MCowBQYDK2VuAyEAq7Hb9SPtqsYdWXuxRzvBqxlrN3Tx6HxZ0S8FxBHnvlA=\n=Ab3d\n</secret_share_key>")
+	code, err := EncodeQR(data)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+
+	// Test case 1: Codes exported by SecretShare, at any scale
+	for _, scale := range []int{1, 3, 8} {
+		exported, err := code.PNG(scale)
+		if err != nil {
+			t.Fatalf("Test 1 failed: Failed to render PNG: %v", err)
+		}
+		decoded, err := DecodeQRPNG(exported)
+		if err != nil || !bytes.Equal(decoded, data) {
+			t.Errorf("Test 1 failed: Expected scale %d to decode (err: %v)", scale, err)
+		}
+	}
+
+	// Test case 2: Screenshots, with the code off center, scaled unevenly and in other colors
+	gray, navy := color.RGBA{0xee, 0xee, 0xee, 0xff}, color.RGBA{0x10, 0x20, 0x40, 0xff}
+	screenshot := drawQR(t, code, 300, 37, 2.5, navy, gray)
+	decoded, err := DecodeQRPNG(screenshot)
+	if err != nil || !bytes.Equal(decoded, data) {
+		t.Errorf("Test 2 failed: Expected the screenshot to decode (err: %v)", err)
+	}
+
+	// Test case 3: Damaged modules are corrected
+	damaged := &QRCode{size: code.size, modules: append([]bool{}, code.modules...)}
+	for _, position := range [][2]int{{12, 12}, {20, 15}, {14, 25}} {
+		damaged.modules[position[1]*damaged.size+position[0]] = !damaged.modules[position[1]*damaged.size+position[0]]
+	}
+	exported, err := damaged.PNG(2)
+	if err != nil {
+		t.Fatalf("Test 3 failed: Failed to render PNG: %v", err)
+	}
+	decoded, err = DecodeQRPNG(exported)
+	if err != nil || !bytes.Equal(decoded, data) {
+		t.Errorf("Test 3 failed: Expected damaged modules to be corrected (err: %v)", err)
+	}
+
+	// Test case 4: Images without a code are rejected
+	blank := drawQR(t, &QRCode{size: 21, modules: make([]bool, 21*21)}, 50, 0, 1, color.Black, color.White)
+	if _, err := DecodeQRPNG(blank); !errors.Is(err, ErrNoQRCode) {
+		t.Errorf("Test 4 failed: Expected ErrNoQRCode for a blank image, got %v", err)
+	}
+	if _, err := DecodeQRPNG([]byte("not a PNG")); err == nil {
+		t.Error("Test 4 failed: Expected an error for data that isn't a PNG")
+	}
+}
+
+func TestQRParseSegments(t *testing.T) {
+	// Test case 1: An alphanumeric segment, the data codewords of "HELLO WORLD" in a 1-M code
+	hello := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	if result, err := qrParseSegments(hello, 1); err != nil || string(result) != "HELLO WORLD" {
+		t.Errorf("Test 1 failed: Expected 'HELLO WORLD', got '%s' (err: %v)", result, err)
+	}
+
+	// Test case 2: A numeric segment, an ECI designator, and a byte segment, without a terminator
+	var bits qrBitBuffer
+	bits.append(1, 4)
+	bits.append(8, 10)
+	for _, group := range [][2]int{{12, 10}, {345, 10}, {67, 7}} {
+		bits.append(group[0], group[1])
+	}
+	bits.append(7, 4)
+	bits.append(26, 8)
+	bits.append(4, 4)
+	bits.append(2, 8)
+	bits.append('o', 8)
+	bits.append('k', 8)
+	if result, err := qrParseSegments(bits.bytes(), 1); err != nil || string(result) != "01234567ok" {
+		t.Errorf("Test 2 failed: Expected '01234567ok', got '%s' (err: %v)", result, err)
+	}
+
+	// Test case 3: Unsupported modes and truncated segments are rejected
+	for _, data := range [][]byte{{0x80, 0x00}, {0x40, 0x05, 0x61}} {
+		if _, err := qrParseSegments(data, 1); err == nil {
+			t.Errorf("Test 3 failed: Expected an error for %x", data)
+		}
+	}
+}
+
+func FuzzQRDecodeModules(f *testing.F) {
+	// Modules of a valid code, as a version byte followed by one byte for each module
+	code, err := EncodeQR([]byte("fuzz secret"))
+	if err != nil {
+		f.Fatalf("Failed to encode: %v", err)
+	}
+	valid := []byte{byte((code.size - 17) / 4)}
+	for _, dark := range code.modules {
+		module := byte(0)
+		if dark {
+			module = 1
+		}
+		valid = append(valid, module)
+	}
+	f.Add(valid)
+	f.Add(valid[:len(valid)/2])
+	f.Add([]byte{7})
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if len(data) < 2 {
+			return
+		}
+		// Modules past the end of the data repeat it, so any input fills a code
+		version := int(data[0])%40 + 1
+		modules := data[1:]
+		size := qrSize(version)
+		qrDecodeModules(size, func(x, y int) bool {
+			return modules[(y*size+x)%len(modules)]&1 == 1
+		})
+	})
+}
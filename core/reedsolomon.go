@@ -0,0 +1,180 @@
+package core
+
+import "errors"
+
+// errTooManyErrors is returned when a Reed-Solomon block has more errors than it can correct
+var errTooManyErrors = errors.New("too many errors to correct")
+
+// rsExp and rsLog are exponent and logarithm tables for GF(256) with the QR code polynomial
+// x^8 + x^4 + x^3 + x^2 + 1 (0x11d), and generator 2
+var rsExp, rsLog = rsTables()
+
+// rsTables builds the exponent and logarithm tables. Exponents are doubled in length, so products
+// of two logarithms don't need reducing.
+func rsTables() ([512]byte, [256]int) {
+	var exp [512]byte
+	var log [256]int
+	x := 1
+	for i := 0; i < 255; i++ {
+		exp[i] = byte(x)
+		log[x] = i
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+	for i := 255; i < 512; i++ {
+		exp[i] = exp[i-255]
+	}
+	return exp, log
+}
+
+// rsMul multiplies two elements of GF(256)
+func rsMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return rsExp[rsLog[a]+rsLog[b]]
+}
+
+// rsDiv divides a by b, which must not be zero, in GF(256)
+func rsDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return rsExp[rsLog[a]+255-rsLog[b]]
+}
+
+// rsGenerator returns the generator polynomial for degree error correction codewords, the
+// product of (x - 2^i) for i below degree. Coefficients are highest first, without the leading 1.
+func rsGenerator(degree int) []byte {
+	generator := make([]byte, degree)
+	generator[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range generator {
+			generator[j] = rsMul(generator[j], root)
+			if j+1 < degree {
+				generator[j] ^= generator[j+1]
+			}
+		}
+		root = rsMul(root, 2)
+	}
+	return generator
+}
+
+// rsRemainder returns the error correction codewords for data: the remainder of dividing it by
+// generator
+func rsRemainder(data, generator []byte) []byte {
+	remainder := make([]byte, len(generator))
+	for _, b := range data {
+		factor := b ^ remainder[0]
+		copy(remainder, remainder[1:])
+		remainder[len(remainder)-1] = 0
+		for i, coefficient := range generator {
+			remainder[i] ^= rsMul(coefficient, factor)
+		}
+	}
+	return remainder
+}
+
+// rsCorrect corrects errors in place in block, data followed by ecLen error correction codewords,
+// with Berlekamp-Massey and Forney. Returns errTooManyErrors if there are more than ecLen/2.
+func rsCorrect(block []byte, ecLen int) error {
+	// Syndromes: the block evaluated at each root of the generator, all zero without errors
+	syndromes := make([]byte, ecLen)
+	clean := true
+	for i := range syndromes {
+		x := rsExp[i]
+		var s byte
+		for _, b := range block {
+			s = rsMul(s, x) ^ b
+		}
+		syndromes[i] = s
+		clean = clean && s == 0
+	}
+	if clean {
+		return nil
+	}
+
+	// Error locator with Berlekamp-Massey, coefficients lowest first
+	locator := []byte{1}
+	previous := []byte{1}
+	length, shift, lastDiscrepancy := 0, 1, byte(1)
+	for n := 0; n < ecLen; n++ {
+		discrepancy := syndromes[n]
+		for i := 1; i <= length && i < len(locator); i++ {
+			discrepancy ^= rsMul(locator[i], syndromes[n-i])
+		}
+		if discrepancy == 0 {
+			shift++
+			continue
+		}
+		scale := rsDiv(discrepancy, lastDiscrepancy)
+		updated := make([]byte, max(len(locator), len(previous)+shift))
+		copy(updated, locator)
+		for i, coefficient := range previous {
+			updated[i+shift] ^= rsMul(scale, coefficient)
+		}
+		if 2*length <= n {
+			previous, locator = locator, updated
+			length, shift, lastDiscrepancy = n+1-length, 1, discrepancy
+		} else {
+			locator = updated
+			shift++
+		}
+	}
+	if 2*length > ecLen {
+		return errTooManyErrors
+	}
+
+	// Error evaluator: syndromes times locator, mod x^ecLen
+	evaluator := make([]byte, ecLen)
+	for i := range evaluator {
+		for j := 0; j <= i && j < len(locator); j++ {
+			evaluator[i] ^= rsMul(locator[j], syndromes[i-j])
+		}
+	}
+
+	// Chien search for the error positions, then Forney for their values. The codeword at index
+	// i is the coefficient of x^(len-1-i), so its locator is 2^(len-1-i).
+	found := 0
+	for i := range block {
+		power := len(block) - 1 - i
+		inverse := rsExp[(255-power%255)%255]
+		if rsEval(locator, inverse) != 0 {
+			continue
+		}
+		var derivative byte
+		for j := 1; j < len(locator); j += 2 {
+			derivative ^= rsMul(locator[j], rsPow(inverse, j-1))
+		}
+		if derivative == 0 {
+			return errTooManyErrors
+		}
+		block[i] ^= rsMul(rsExp[power%255], rsDiv(rsEval(evaluator, inverse), derivative))
+		found++
+	}
+	if found != length {
+		return errTooManyErrors
+	}
+	return nil
+}
+
+// rsEval evaluates a polynomial with coefficients lowest first at x
+func rsEval(poly []byte, x byte) byte {
+	var result byte
+	for i := len(poly) - 1; i >= 0; i-- {
+		result = rsMul(result, x) ^ poly[i]
+	}
+	return result
+}
+
+// rsPow raises x to the power n in GF(256)
+func rsPow(x byte, n int) byte {
+	result := byte(1)
+	for i := 0; i < n; i++ {
+		result = rsMul(result, x)
+	}
+	return result
+}
@@ -0,0 +1,52 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestRSRemainder(t *testing.T) {
+	// The data codewords of "HELLO WORLD" in a 1-M QR code, and their error correction codewords
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	expected := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if remainder := rsRemainder(data, rsGenerator(10)); !bytes.Equal(remainder, expected) {
+		t.Errorf("Expected %v, got %v", expected, remainder)
+	}
+}
+
+func TestRSCorrect(t *testing.T) {
+	data := make([]byte, 40)
+	for i := range data {
+		data[i] = byte(i*53 + 7)
+	}
+	const ecLen = 18
+	block := append(append([]byte{}, data...), rsRemainder(data, rsGenerator(ecLen))...)
+
+	// Test case 1: A block without errors is left as it is
+	clean := append([]byte{}, block...)
+	if err := rsCorrect(clean, ecLen); err != nil || !bytes.Equal(clean, block) {
+		t.Errorf("Test 1 failed: Expected the block unchanged (err: %v)", err)
+	}
+
+	// Test case 2: Up to half as many errors as error correction codewords are corrected, in data
+	// or error correction codewords
+	for errs := 1; errs <= ecLen/2; errs++ {
+		damaged := append([]byte{}, block...)
+		for i := 0; i < errs; i++ {
+			damaged[(i*13+errs)%len(damaged)] ^= byte(i*29 + 1)
+		}
+		if err := rsCorrect(damaged, ecLen); err != nil || !bytes.Equal(damaged, block) {
+			t.Errorf("Test 2 failed: Expected %d errors to be corrected (err: %v)", errs, err)
+		}
+	}
+
+	// Test case 3: More errors are reported rather than corrected
+	damaged := append([]byte{}, block...)
+	for i := 0; i < ecLen/2+1; i++ {
+		damaged[i*3] ^= 0x5a
+	}
+	if err := rsCorrect(damaged, ecLen); !errors.Is(err, errTooManyErrors) {
+		t.Errorf("Test 3 failed: Expected too many errors, got %v", err)
+	}
+}
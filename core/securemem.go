@@ -0,0 +1,32 @@
+package core
+
+// SecureBuffer holds a key or secret in memory that's locked into RAM so it's never written to swap,
+// excluded from core dumps, and surrounded by guard pages so overruns fault instead of reading
+// neighbouring memory. Where that isn't supported it falls back to ordinary memory; Locked reports which.
+type SecureBuffer struct {
+	data    []byte
+	mapping []byte // the whole mapping including guard pages, nil for ordinary memory
+	locked  bool
+}
+
+// Bytes returns the buffer's contents. The slice is only valid until Destroy.
+func (b *SecureBuffer) Bytes() []byte {
+	return b.data
+}
+
+// Locked reports whether the buffer is locked into RAM
+func (b *SecureBuffer) Locked() bool {
+	return b.locked
+}
+
+// Destroy wipes the buffer and releases its memory
+func (b *SecureBuffer) Destroy() {
+	if b == nil {
+		return
+	}
+	Wipe(b.data)
+	b.release()
+	b.data = nil
+	b.mapping = nil
+	b.locked = false
+}
@@ -0,0 +1,48 @@
+package core
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// NewSecureBuffer allocates a zeroed buffer of size bytes in locked, guard-paged memory
+func NewSecureBuffer(size int) *SecureBuffer {
+	pageSize := os.Getpagesize()
+	dataPages := (size + pageSize - 1) / pageSize
+	if dataPages == 0 {
+		dataPages = 1
+	}
+	total := (dataPages + 2) * pageSize
+
+	mapping, err := unix.Mmap(-1, 0, total, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_PRIVATE|unix.MAP_ANONYMOUS)
+	if err != nil {
+		return &SecureBuffer{data: make([]byte, size)}
+	}
+
+	// Guard pages either side fault on any access
+	if unix.Mprotect(mapping[:pageSize], unix.PROT_NONE) != nil || unix.Mprotect(mapping[total-pageSize:], unix.PROT_NONE) != nil {
+		unix.Munmap(mapping)
+		return &SecureBuffer{data: make([]byte, size)}
+	}
+
+	region := mapping[pageSize : total-pageSize]
+	unix.Madvise(region, unix.MADV_DONTDUMP)
+	locked := unix.Mlock(region) == nil
+
+	// Put the data at the end of its pages, so running off the end hits the guard page straight away
+	return &SecureBuffer{data: region[len(region)-size:], mapping: mapping, locked: locked}
+}
+
+// release unlocks and unmaps the buffer's memory
+func (b *SecureBuffer) release() {
+	if b.mapping == nil {
+		return
+	}
+	pageSize := os.Getpagesize()
+	region := b.mapping[pageSize : len(b.mapping)-pageSize]
+	if b.locked {
+		unix.Munlock(region)
+	}
+	unix.Munmap(b.mapping)
+}
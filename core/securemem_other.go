@@ -0,0 +1,12 @@
+//go:build !linux
+
+package core
+
+// NewSecureBuffer allocates a zeroed buffer of size bytes. Locked memory is only supported on Linux,
+// so this is ordinary memory that's wiped by Destroy.
+func NewSecureBuffer(size int) *SecureBuffer {
+	return &SecureBuffer{data: make([]byte, size)}
+}
+
+// release does nothing for ordinary memory
+func (b *SecureBuffer) release() {}
@@ -0,0 +1,39 @@
+package core
+
+import (
+	"testing"
+)
+
+func TestSecureBuffer(t *testing.T) {
+	// Test case 1: A zeroed, writable buffer of the requested size
+	buffer := NewSecureBuffer(100)
+	data := buffer.Bytes()
+	if len(data) != 100 {
+		t.Fatalf("Test 1 failed: Expected 100 bytes, got %d", len(data))
+	}
+	for i := range data {
+		if data[i] != 0 {
+			t.Fatalf("Test 1 failed: Expected zeroed memory at %d", i)
+		}
+		data[i] = byte(i)
+	}
+
+	// Test case 2: Destroy wipes the contents
+	buffer.Destroy()
+	if buffer.Bytes() != nil || buffer.Locked() {
+		t.Error("Test 2 failed: Expected a destroyed buffer to be empty and unlocked")
+	}
+	buffer.Destroy()
+
+	// Test case 3: Empty buffers, which still need a page to map
+	empty := NewSecureBuffer(0)
+	if len(empty.Bytes()) != 0 {
+		t.Errorf("Test 3 failed: Expected an empty buffer, got %d bytes", len(empty.Bytes()))
+	}
+	empty.Destroy()
+
+	// Test case 4: Buffers larger than a page
+	large := NewSecureBuffer(10000)
+	large.Bytes()[9999] = 1
+	large.Destroy()
+}
@@ -1,53 +1,148 @@
 package core
 
 import (
+	"crypto/rand"
 	"crypto/rsa"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 )
 
+// ErrSessionExpired is returned when a receiver session is asked to decrypt after its lifetime
+var ErrSessionExpired = errors.New("session expired")
+
 // ReceiverSession represents a session where the user is receiving a secret
 type ReceiverSession struct {
-	privateKey *rsa.PrivateKey
-	publicKey  *rsa.PublicKey
+	mu        sync.Mutex
+	key       receiverKey // an RSA key, or a one-time key from another suite
+	expiresAt time.Time   // zero if the session never expires
+	expiry    *time.Timer // wipes the private key once the session expires
 }
 
 // SenderSession represents a session where the user is sending a secret
 type SenderSession struct {
-	receiverPublicKey *rsa.PublicKey
+	key senderKey // the receiver's public key, from the suite it was shared for
 }
 
 // NewReceiverSession creates a new receiver session with a fresh key pair
 func NewReceiverSession() (*ReceiverSession, error) {
-	privateKey, publicKey, err := GenerateKeyPair()
+	session, err := newSuiteReceiverSession("ssv1")
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate key pair: %w", err)
 	}
+	return session, nil
+}
 
-	return &ReceiverSession{
-		privateKey: privateKey,
-		publicKey:  publicKey,
-	}, nil
+// NewReceiverSessionWithKey creates a receiver session for an existing private key.
+// The session takes ownership of the key, and Destroy wipes it.
+func NewReceiverSessionWithKey(privateKey *rsa.PrivateKey) *ReceiverSession {
+	return &ReceiverSession{key: &rsaKey{privateKey: privateKey, publicKey: &privateKey.PublicKey}}
 }
 
 // NewSenderSession creates a new sender session with the receiver's public key
 func NewSenderSession(receiverPublicKey *rsa.PublicKey) *SenderSession {
-	return &SenderSession{
-		receiverPublicKey: receiverPublicKey,
-	}
+	return &SenderSession{key: &rsaPublicKey{receiverPublicKey}}
 }
 
-// GetPublicKey returns the public key for sharing (receiver session only)
+// GetPublicKey returns the public key for sharing (receiver session only). It's nil for sessions
+// with a key from another suite, such as age.
 func (rs *ReceiverSession) GetPublicKey() *rsa.PublicKey {
-	return rs.publicKey
+	if key, ok := rs.key.(*rsaKey); ok {
+		return key.publicKey
+	}
+	return nil
+}
+
+// SharedKey returns the session's public key formatted for sharing with the sender: a SecretShare
+// key for the session's suite, or an age recipient
+func (rs *ReceiverSession) SharedKey() (string, error) {
+	if rs.key == nil {
+		return "", fmt.Errorf("private key is not set")
+	}
+	return rs.key.share()
+}
+
+// AgeRecipient returns the age recipient for sharing, or an empty string if the session has an
+// RSA key instead
+func (rs *ReceiverSession) AgeRecipient() string {
+	if key, ok := rs.key.(*ageKey); ok {
+		return FormatAgeRecipient(key.recipient)
+	}
+	return ""
+}
+
+// KeyLocked reports whether the session's private key is kept in locked memory, which is never
+// swapped to disk. RSA keys never are, as crypto/rsa allocates them in ordinary memory; age
+// identities and HPKE keys are wherever the platform supports it.
+func (rs *ReceiverSession) KeyLocked() bool {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.key != nil && rs.key.locked()
+}
+
+// SetLifetime limits how long the session can decrypt. Once lifetime has passed the private key
+// is wiped, even if the session is never used again, and DecryptSecret returns ErrSessionExpired.
+// A lifetime of zero or less means the session never expires.
+func (rs *ReceiverSession) SetLifetime(lifetime time.Duration) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rs.expiry != nil {
+		rs.expiry.Stop()
+		rs.expiry = nil
+	}
+	if lifetime <= 0 {
+		rs.expiresAt = time.Time{}
+		return
+	}
+
+	rs.expiresAt = time.Now().Add(lifetime)
+	rs.expiry = time.AfterFunc(lifetime, rs.Destroy)
+}
+
+// ExpiresAt returns when the session expires, or the zero time if it never does
+func (rs *ReceiverSession) ExpiresAt() time.Time {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.expiresAt
+}
+
+// Expired reports whether the session's lifetime has passed
+func (rs *ReceiverSession) Expired() bool {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.expiredLocked()
+}
+
+// expiredLocked reports whether the lifetime has passed, with rs.mu held
+func (rs *ReceiverSession) expiredLocked() bool {
+	return !rs.expiresAt.IsZero() && !time.Now().Before(rs.expiresAt)
+}
+
+// Destroy wipes the private key from memory. The session can't decrypt afterwards.
+func (rs *ReceiverSession) Destroy() {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.destroyLocked()
+}
+
+// destroyLocked wipes the private key, with rs.mu held
+func (rs *ReceiverSession) destroyLocked() {
+	if rs.expiry != nil {
+		rs.expiry.Stop()
+		rs.expiry = nil
+	}
+	if rs.key != nil {
+		rs.key.destroy()
+	}
 }
 
 // EncryptSecret encrypts a secret using the receiver's public key
 func (ss *SenderSession) EncryptSecret(secret []byte) ([]byte, error) {
-	if ss.receiverPublicKey == nil {
+	if ss.key == nil {
 		return nil, fmt.Errorf("receiver public key is not set")
 	}
-
-	encryptedData, err := HybridEncrypt(ss.receiverPublicKey, secret)
+	encryptedData, err := ss.key.encrypt(rand.Reader, secret)
 	if err != nil {
 		return nil, fmt.Errorf("failed to encrypt secret: %w", err)
 	}
@@ -55,16 +150,56 @@ func (ss *SenderSession) EncryptSecret(secret []byte) ([]byte, error) {
 	return encryptedData, nil
 }
 
-// DecryptSecret decrypts a secret using the receiver's private key
-func (rs *ReceiverSession) DecryptSecret(encryptedSecret []byte) ([]byte, error) {
-	if rs.privateKey == nil {
+// CanEncryptJWE reports whether the receiver's key is an RSA key, which JWEs are encrypted to
+func (ss *SenderSession) CanEncryptJWE() bool {
+	_, ok := ss.key.(*rsaPublicKey)
+	return ok
+}
+
+// EncryptSecretJWE encrypts a secret to the receiver's RSA public key as a JWE in compact
+// serialization, for receivers using JOSE libraries
+func (ss *SenderSession) EncryptSecretJWE(secret []byte) (string, error) {
+	key, ok := ss.key.(*rsaPublicKey)
+	if !ok {
+		return "", fmt.Errorf("JWE needs an RSA receiver key")
+	}
+	token, err := JWEEncrypt(key.publicKey, secret)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt secret: %w", err)
+	}
+	return token, nil
+}
+
+// SameReceiver reports whether both sessions encrypt to the same receiver key
+func (ss *SenderSession) SameReceiver(other *SenderSession) bool {
+	return ss.key != nil && other.key != nil && ss.key.equal(other.key)
+}
+
+// DecryptSecret decrypts a secret using the receiver's private key. The secret can be an envelope
+// of any suite the key reads, such as a JWE in compact serialization for an RSA key. It's kept in
+// a SecureBuffer, which the caller destroys once it's done with the secret.
+// Returns ErrSessionExpired once the session's lifetime has passed.
+func (rs *ReceiverSession) DecryptSecret(encryptedSecret []byte) (*SecureBuffer, error) {
+	// Hold the lock while decrypting, so the key isn't wiped part way through
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rs.expiredLocked() {
+		rs.destroyLocked()
+		return nil, ErrSessionExpired
+	}
+	if rs.key == nil || rs.key.wiped() {
 		return nil, fmt.Errorf("private key is not set")
 	}
 
-	decryptedData, err := HybridDecrypt(rs.privateKey, encryptedSecret)
-	if err != nil {
+	var buffer *SecureBuffer
+	alloc := func(size int) []byte {
+		buffer = NewSecureBuffer(size)
+		return buffer.Bytes()
+	}
+	if _, err := openEnvelope(rs.key, encryptedSecret, alloc); err != nil {
+		buffer.Destroy()
 		return nil, fmt.Errorf("failed to decrypt secret: %w", err)
 	}
 
-	return decryptedData, nil
+	return buffer, nil
 }
@@ -0,0 +1,104 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReceiverSessionLifetime(t *testing.T) {
+	session, err := NewReceiverSession()
+	if err != nil {
+		t.Fatalf("Failed to create receiver session: %v", err)
+	}
+	defer session.Destroy()
+	encrypted, err := NewSenderSession(session.GetPublicKey()).EncryptSecret([]byte("test secret"))
+	if err != nil {
+		t.Fatalf("Failed to encrypt secret: %v", err)
+	}
+
+	// Test case 1: Sessions never expire by default
+	if !session.ExpiresAt().IsZero() || session.Expired() {
+		t.Errorf("Test 1 failed: Expected no expiry, got %v", session.ExpiresAt())
+	}
+
+	// Test case 2: A session decrypts before it expires
+	session.SetLifetime(time.Hour)
+	if time.Until(session.ExpiresAt()) <= 59*time.Minute || session.Expired() {
+		t.Errorf("Test 2 failed: Expected to expire in an hour, got %v", session.ExpiresAt())
+	}
+	buffer, err := session.DecryptSecret(encrypted)
+	if err != nil {
+		t.Fatalf("Test 2 failed: Failed to decrypt secret: %v", err)
+	}
+	if string(buffer.Bytes()) != "test secret" {
+		t.Errorf("Test 2 failed: Expected 'test secret', got '%s'", buffer.Bytes())
+	}
+	buffer.Destroy()
+
+	// Test case 3: A lifetime of zero removes the expiry
+	session.SetLifetime(0)
+	if !session.ExpiresAt().IsZero() {
+		t.Errorf("Test 3 failed: Expected no expiry, got %v", session.ExpiresAt())
+	}
+}
+
+func TestReceiverSessionExpiry(t *testing.T) {
+	session, err := NewReceiverSession()
+	if err != nil {
+		t.Fatalf("Failed to create receiver session: %v", err)
+	}
+	defer session.Destroy()
+	encrypted, err := NewSenderSession(session.GetPublicKey()).EncryptSecret([]byte("test secret"))
+	if err != nil {
+		t.Fatalf("Failed to encrypt secret: %v", err)
+	}
+
+	privateKey := session.key.(*rsaKey).privateKey
+	session.SetLifetime(20 * time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+
+	if !session.Expired() {
+		t.Error("Expected the session to have expired")
+	}
+
+	// The key is wiped once the session expires, without waiting for it to be used
+	session.mu.Lock()
+	wiped := session.key.wiped()
+	session.mu.Unlock()
+	if !wiped || privateKey.D.Sign() != 0 {
+		t.Error("Expected the private key to be wiped when the session expired")
+	}
+
+	if _, err := session.DecryptSecret(encrypted); !errors.Is(err, ErrSessionExpired) {
+		t.Errorf("Expected session expired error, got %v", err)
+	}
+}
+
+func TestReceiverSessionExpiredBeforeWipe(t *testing.T) {
+	session, err := NewReceiverSession()
+	if err != nil {
+		t.Fatalf("Failed to create receiver session: %v", err)
+	}
+	encrypted, err := NewSenderSession(session.GetPublicKey()).EncryptSecret([]byte("test secret"))
+	if err != nil {
+		t.Fatalf("Failed to encrypt secret: %v", err)
+	}
+
+	// Decrypting after expiry wipes the key, even if the expiry timer hasn't run yet
+	session.SetLifetime(time.Hour)
+	session.mu.Lock()
+	session.expiry.Stop()
+	session.expiresAt = time.Now().Add(-time.Second)
+	session.mu.Unlock()
+
+	if _, err := session.DecryptSecret(encrypted); !errors.Is(err, ErrSessionExpired) {
+		t.Errorf("Expected session expired error, got %v", err)
+	}
+	session.mu.Lock()
+	wiped := session.key.wiped()
+	session.mu.Unlock()
+	if !wiped {
+		t.Error("Expected the private key to be wiped")
+	}
+}
@@ -0,0 +1,211 @@
+package core
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"io"
+)
+
+// sharePayloadPrefix marks a decrypted payload as one Shamir share of a secret, rather than the
+// secret itself. It starts with a NUL byte, which the sender refuses at the start of a secret, so
+// a secret that happens to start with "sss1" is never taken for a share.
+const sharePayloadPrefix = "\x00sss1"
+
+// shareIDSize is the size of the random ID shared by every share of one secret
+const shareIDSize = 8
+
+// shareHeaderSize is the size of a share payload's header: prefix, ID, threshold and index
+const shareHeaderSize = len(sharePayloadPrefix) + shareIDSize + 2
+
+// MaxShares is the most shares a secret can be split into, one for each nonzero element of GF(256)
+const MaxShares = 255
+
+// Share is one of the shares a secret is split into. Any Threshold shares with the same ID
+// recover the secret, and fewer reveal nothing about it.
+type Share struct {
+	ID        []byte // random, the same for every share of one secret
+	Threshold int    // number of shares needed to recover the secret
+	Index     int    // where the share's polynomials are evaluated, from 1
+	Data      []byte // one byte for each byte of the secret
+}
+
+// Wipe overwrites the share's data with zeros
+func (s Share) Wipe() {
+	Wipe(s.Data)
+}
+
+// SplitSecret splits a secret into count Shamir shares over GF(256), any threshold of which
+// recover it
+func SplitSecret(secret []byte, threshold, count int) ([]Share, error) {
+	return splitSecret(rand.Reader, secret, threshold, count)
+}
+
+// splitSecret implements SplitSecret, reading the ID and then each byte's coefficients from random.
+// Tests use a fixed random to reproduce known answers.
+func splitSecret(random io.Reader, secret []byte, threshold, count int) ([]Share, error) {
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("secret is empty")
+	}
+	if threshold < 2 || threshold > count || count > MaxShares {
+		return nil, fmt.Errorf("invalid split: %d of %d shares", threshold, count)
+	}
+
+	id := make([]byte, shareIDSize)
+	if _, err := io.ReadFull(random, id); err != nil {
+		return nil, fmt.Errorf("failed to generate share ID: %w", err)
+	}
+	shares := make([]Share, count)
+	for i := range shares {
+		shares[i] = Share{ID: id, Threshold: threshold, Index: i + 1, Data: make([]byte, len(secret))}
+	}
+
+	// Each byte of the secret is the constant term of its own random polynomial, of degree
+	// threshold-1, and each share holds every polynomial evaluated at its index
+	coefficients := make([]byte, threshold)
+	defer Wipe(coefficients)
+	for b, value := range secret {
+		coefficients[0] = value
+		if _, err := io.ReadFull(random, coefficients[1:]); err != nil {
+			for _, share := range shares {
+				share.Wipe()
+			}
+			return nil, fmt.Errorf("failed to generate coefficients: %w", err)
+		}
+		for i := range shares {
+			shares[i].Data[b] = evaluatePolynomial(coefficients, byte(shares[i].Index))
+		}
+	}
+	return shares, nil
+}
+
+// CombineShares recovers a secret from at least threshold of its shares into a SecureBuffer,
+// which the caller destroys once it's done with the secret
+func CombineShares(shares []Share) (*SecureBuffer, error) {
+	if len(shares) == 0 {
+		return nil, fmt.Errorf("no shares to combine")
+	}
+
+	first := shares[0]
+	seen := make(map[int]bool, len(shares))
+	for _, share := range shares {
+		if !bytes.Equal(share.ID, first.ID) || share.Threshold != first.Threshold || len(share.Data) != len(first.Data) {
+			return nil, fmt.Errorf("shares are from different secrets")
+		}
+		if share.Index < 1 || share.Index > MaxShares {
+			return nil, fmt.Errorf("invalid share index: %d", share.Index)
+		}
+		if seen[share.Index] {
+			return nil, fmt.Errorf("share %d was given more than once", share.Index)
+		}
+		seen[share.Index] = true
+	}
+	if first.Threshold < 2 || len(shares) < first.Threshold {
+		return nil, fmt.Errorf("need %d shares to recover the secret, got %d", first.Threshold, len(shares))
+	}
+	shares = shares[:first.Threshold]
+
+	// Interpolate each polynomial at zero: the secret is the sum of each share weighted by its
+	// Lagrange basis polynomial at zero
+	secret := NewSecureBuffer(len(first.Data))
+	out := secret.Bytes()
+	for i, share := range shares {
+		xi := byte(share.Index)
+		basis := byte(1)
+		for j, other := range shares {
+			if i == j {
+				continue
+			}
+			xj := byte(other.Index)
+			basis = gfMul(basis, gfMul(xj, gfInverse(xi^xj)))
+		}
+		for b, y := range share.Data {
+			out[b] ^= gfMul(y, basis)
+		}
+	}
+	return secret, nil
+}
+
+// EncodeShare encodes a share as a payload for encryption to its receiver
+func EncodeShare(share Share) ([]byte, error) {
+	if len(share.ID) != shareIDSize || share.Threshold < 2 || share.Threshold > MaxShares ||
+		share.Index < 1 || share.Index > MaxShares || len(share.Data) == 0 {
+		return nil, fmt.Errorf("invalid share")
+	}
+
+	// Format: [sss1][id][threshold][index][data]
+	payload := make([]byte, 0, shareHeaderSize+len(share.Data))
+	payload = append(payload, sharePayloadPrefix...)
+	payload = append(payload, share.ID...)
+	payload = append(payload, byte(share.Threshold), byte(share.Index))
+	return append(payload, share.Data...), nil
+}
+
+// IsReservedSecret reports whether a secret starts with a NUL byte, which marks payloads made by
+// SecretShare rather than typed, such as shares and fields. Senders refuse such secrets.
+func IsReservedSecret(secret []byte) bool {
+	return len(secret) > 0 && secret[0] == 0
+}
+
+// IsSharePayload reports whether a decrypted payload holds a share of a secret
+func IsSharePayload(payload []byte) bool {
+	return bytes.HasPrefix(payload, []byte(sharePayloadPrefix)) && len(payload) > shareHeaderSize
+}
+
+// DecodeShare decodes a share payload produced by EncodeShare. The share's data is copied, so
+// the payload can be wiped.
+func DecodeShare(payload []byte) (Share, error) {
+	if !IsSharePayload(payload) {
+		return Share{}, fmt.Errorf("payload does not contain a share")
+	}
+
+	header := payload[len(sharePayloadPrefix):shareHeaderSize]
+	share := Share{
+		ID:        append([]byte{}, header[:shareIDSize]...),
+		Threshold: int(header[shareIDSize]),
+		Index:     int(header[shareIDSize+1]),
+		Data:      append([]byte{}, payload[shareHeaderSize:]...),
+	}
+	if share.Threshold < 2 || share.Index < 1 {
+		share.Wipe()
+		return Share{}, fmt.Errorf("invalid share")
+	}
+	return share, nil
+}
+
+// evaluatePolynomial evaluates a polynomial over GF(256) at x with Horner's method.
+// coefficients[0] is the constant term.
+func evaluatePolynomial(coefficients []byte, x byte) byte {
+	var result byte
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		result = gfMul(result, x) ^ coefficients[i]
+	}
+	return result
+}
+
+// gfMul multiplies in GF(256) with the AES polynomial, without branching on or indexing by its
+// arguments so it takes the same time for every secret byte
+func gfMul(a, b byte) byte {
+	var product byte
+	for i := 0; i < 8; i++ {
+		// Add a if the low bit of b is set
+		product ^= a & -(b & 1)
+		// Multiply a by x, reducing by x^8 + x^4 + x^3 + x + 1 if it overflows
+		a = a<<1 ^ 0x1b&-(a>>7)
+		b >>= 1
+	}
+	return product
+}
+
+// gfInverse returns the multiplicative inverse of a nonzero a in GF(256), which is a^254 since
+// a^255 = 1
+func gfInverse(a byte) byte {
+	result := byte(1)
+	for exponent := 254; exponent > 0; exponent >>= 1 {
+		if exponent&1 == 1 {
+			result = gfMul(result, a)
+		}
+		a = gfMul(a, a)
+	}
+	return result
+}
@@ -0,0 +1,236 @@
+package core
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSplitCombineSecret(t *testing.T) {
+	secret := []byte("break-glass: Xk2#pQ9!vL7@mN4$wR8&")
+	shares, err := SplitSecret(secret, 2, 3)
+	if err != nil {
+		t.Fatalf("Failed to split secret: %v", err)
+	}
+	if len(shares) != 3 {
+		t.Fatalf("Expected 3 shares, got %d", len(shares))
+	}
+
+	// Test case 1: Any 2 of the 3 shares recover the secret, in any order
+	for _, pair := range [][2]int{{0, 1}, {0, 2}, {1, 2}, {2, 0}} {
+		recovered, err := CombineShares([]Share{shares[pair[0]], shares[pair[1]]})
+		if err != nil {
+			t.Fatalf("Test 1 failed: Failed to combine shares %v: %v", pair, err)
+		}
+		if !bytes.Equal(recovered.Bytes(), secret) {
+			t.Errorf("Test 1 failed: Expected '%s' from shares %v, got '%s'", secret, pair, recovered.Bytes())
+		}
+		recovered.Destroy()
+	}
+
+	// Test case 2: Extra shares are ignored
+	recovered, err := CombineShares(shares)
+	if err != nil {
+		t.Fatalf("Test 2 failed: Failed to combine all shares: %v", err)
+	}
+	if !bytes.Equal(recovered.Bytes(), secret) {
+		t.Errorf("Test 2 failed: Expected '%s', got '%s'", secret, recovered.Bytes())
+	}
+	recovered.Destroy()
+
+	// Test case 3: No single share holds the secret
+	for _, share := range shares {
+		if bytes.Contains(share.Data, secret[:8]) {
+			t.Errorf("Test 3 failed: Share %d holds part of the secret", share.Index)
+		}
+	}
+}
+
+func TestSplitSecretThresholds(t *testing.T) {
+	secret := []byte{0x00, 0x01, 0x7f, 0x80, 0xff}
+	for _, split := range [][2]int{{2, 2}, {3, 5}, {5, 5}, {2, MaxShares}} {
+		threshold, count := split[0], split[1]
+		shares, err := SplitSecret(secret, threshold, count)
+		if err != nil {
+			t.Fatalf("Failed to split %d of %d: %v", threshold, count, err)
+		}
+
+		// The last threshold shares recover the secret, and one fewer can't be combined
+		recovered, err := CombineShares(shares[count-threshold:])
+		if err != nil {
+			t.Fatalf("Failed to combine %d of %d: %v", threshold, count, err)
+		}
+		if !bytes.Equal(recovered.Bytes(), secret) {
+			t.Errorf("Expected %x from %d of %d, got %x", secret, threshold, count, recovered.Bytes())
+		}
+		recovered.Destroy()
+		if _, err := CombineShares(shares[count-threshold+1:]); err == nil {
+			t.Errorf("Expected error combining %d of %d with too few shares", threshold, count)
+		}
+	}
+}
+
+func TestSplitSecretKnownAnswer(t *testing.T) {
+	// With coefficient 0x01 the polynomial is 0x2a + x, and addition in GF(256) is XOR
+	random := bytes.NewReader(append(bytes.Repeat([]byte{0xaa}, shareIDSize), 0x01))
+	shares, err := splitSecret(random, []byte{0x2a}, 2, 3)
+	if err != nil {
+		t.Fatalf("Failed to split secret: %v", err)
+	}
+	for i, expected := range []byte{0x2b, 0x28, 0x29} {
+		if shares[i].Data[0] != expected {
+			t.Errorf("Expected share %d to be %#x, got %#x", i+1, expected, shares[i].Data[0])
+		}
+	}
+}
+
+func TestSplitSecretInvalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    []byte
+		threshold int
+		count     int
+	}{
+		{"empty secret", nil, 2, 3},
+		{"threshold of one", []byte("s"), 1, 3},
+		{"threshold above count", []byte("s"), 4, 3},
+		{"too many shares", []byte("s"), 2, MaxShares + 1},
+	}
+	for _, test := range tests {
+		if _, err := SplitSecret(test.secret, test.threshold, test.count); err == nil {
+			t.Errorf("%s: Expected an error", test.name)
+		}
+	}
+}
+
+func TestCombineSharesInvalid(t *testing.T) {
+	shares, err := SplitSecret([]byte("secret"), 2, 3)
+	if err != nil {
+		t.Fatalf("Failed to split secret: %v", err)
+	}
+	other, err := SplitSecret([]byte("secret"), 2, 3)
+	if err != nil {
+		t.Fatalf("Failed to split secret: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		shares   []Share
+		expected string
+	}{
+		{"no shares", nil, "no shares"},
+		{"too few", shares[:1], "need 2 shares"},
+		{"same share twice", []Share{shares[0], shares[0]}, "more than once"},
+		{"different secrets", []Share{shares[0], other[1]}, "different secrets"},
+	}
+	for _, test := range tests {
+		_, err := CombineShares(test.shares)
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("%s: Expected error containing '%s', got %v", test.name, test.expected, err)
+		}
+	}
+}
+
+func TestEncodeDecodeShare(t *testing.T) {
+	shares, err := SplitSecret([]byte("secret"), 2, 3)
+	if err != nil {
+		t.Fatalf("Failed to split secret: %v", err)
+	}
+
+	// Test case 1: Round trip
+	payload, err := EncodeShare(shares[1])
+	if err != nil {
+		t.Fatalf("Test 1 failed: Failed to encode share: %v", err)
+	}
+	if !IsSharePayload(payload) || IsFieldsPayload(payload) {
+		t.Error("Test 1 failed: Expected the payload to be detected as a share only")
+	}
+	decoded, err := DecodeShare(payload)
+	if err != nil {
+		t.Fatalf("Test 1 failed: Failed to decode share: %v", err)
+	}
+	if !bytes.Equal(decoded.ID, shares[1].ID) || decoded.Threshold != 2 || decoded.Index != 2 || !bytes.Equal(decoded.Data, shares[1].Data) {
+		t.Errorf("Test 1 failed: Expected %+v, got %+v", shares[1], decoded)
+	}
+
+	// Test case 2: The decoded share doesn't use the payload's memory, so it can be wiped
+	Wipe(payload)
+	if !bytes.Equal(decoded.Data, shares[1].Data) {
+		t.Error("Test 2 failed: Expected the share to be copied from the payload")
+	}
+
+	// Test case 3: Plain secrets, even those starting like a share, and invalid shares
+	if IsSharePayload([]byte("sss1-prod-password")) || IsReservedSecret([]byte("sss1-prod-password")) {
+		t.Error("Test 3 failed: Expected a typed secret starting with 'sss1' not to be a share")
+	}
+	if !IsReservedSecret(payload) {
+		t.Error("Test 3 failed: Expected a share payload to be reserved")
+	}
+	invalid := [][]byte{
+		[]byte("just a password"),
+		[]byte("sss1-prod-password"),
+		[]byte(sharePayloadPrefix),
+		append([]byte(sharePayloadPrefix+"12345678\x01\x01"), 'x'),
+		append([]byte(sharePayloadPrefix+"12345678\x02\x00"), 'x'),
+	}
+	for _, data := range invalid {
+		if _, err := DecodeShare(data); err == nil {
+			t.Errorf("Test 3 failed: Expected error decoding %q", data)
+		}
+	}
+}
+
+func TestGFArithmetic(t *testing.T) {
+	// Test case 1: The multiplication example from FIPS-197
+	if product := gfMul(0x57, 0x83); product != 0xc1 {
+		t.Errorf("Test 1 failed: Expected 0xc1, got %#x", product)
+	}
+
+	// Test case 2: Every nonzero element has an inverse
+	for a := 1; a < 256; a++ {
+		if product := gfMul(byte(a), gfInverse(byte(a))); product != 1 {
+			t.Errorf("Test 2 failed: Expected %#x times its inverse to be 1, got %#x", a, product)
+		}
+	}
+}
+
+func FuzzDecodeShare(f *testing.F) {
+	shares, err := SplitSecret([]byte("fuzz secret"), 2, 3)
+	if err != nil {
+		f.Fatalf("Failed to split secret: %v", err)
+	}
+	valid, err := EncodeShare(shares[0])
+	if err != nil {
+		f.Fatalf("Failed to encode share: %v", err)
+	}
+	other, err := EncodeShare(shares[1])
+	if err != nil {
+		f.Fatalf("Failed to encode share: %v", err)
+	}
+
+	f.Add(valid, other)
+	f.Add(valid, valid)
+	f.Add(valid[:shareHeaderSize+1], other)
+	f.Add([]byte(sharePayloadPrefix), other)
+	f.Add([]byte{}, []byte{})
+
+	f.Fuzz(func(t *testing.T, first, second []byte) {
+		a, err := DecodeShare(first)
+		if err != nil {
+			return
+		}
+		encoded, err := EncodeShare(a)
+		if err != nil || !bytes.Equal(encoded, first) {
+			t.Fatalf("Expected a decoded share to encode back to the same payload (err: %v)", err)
+		}
+
+		b, err := DecodeShare(second)
+		if err != nil {
+			return
+		}
+		recovered, err := CombineShares([]Share{a, b})
+		if err == nil {
+			recovered.Destroy()
+		}
+	})
+}
@@ -0,0 +1,382 @@
+package core
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrSSHKeySecret is returned when a secret encrypted to an ssh-ed25519 key is decrypted with a
+// session's own key
+var ErrSSHKeySecret = errors.New("this secret was encrypted to an SSH key")
+
+// ErrWrongSSHKey is returned when an SSH identity is given a secret encrypted to a different key
+var ErrWrongSSHKey = errors.New("this secret was encrypted to a different key")
+
+// ErrSSHKeyEncrypted is returned when an SSH private key is protected by a passphrase that wasn't given
+var ErrSSHKeyEncrypted = errors.New("the SSH key is protected by a passphrase")
+
+// ErrWrongSSHKeyPassphrase is returned when an SSH private key can't be decrypted with the passphrase given
+var ErrWrongSSHKeyPassphrase = errors.New("wrong passphrase for the SSH key")
+
+// minSSHRSABits is the smallest ssh-rsa key secrets are encrypted to
+const minSSHRSABits = 2048
+
+// sshKeyTagSize is the size of the tag identifying the SSH key an ssv3 secret was encrypted to
+const sshKeyTagSize = 4
+
+// x25519HeaderSize is the size of the ssv3 header: version, key tag and ephemeral public key
+const x25519HeaderSize = 4 + sshKeyTagSize + curve25519.PointSize
+
+// x25519KeyInfo binds keys derived for ssv3 secrets to their use
+const x25519KeyInfo = "secret_share ssv3 X25519"
+
+// IsSSHKeySecret reports whether encrypted data was encrypted to an ssh-ed25519 key
+func IsSSHKeySecret(encryptedData []byte) bool {
+	return bytes.HasPrefix(encryptedData, []byte("ssv3"))
+}
+
+// NewSenderSessionForSSHKey creates a sender session for a receiver's OpenSSH public key, as
+// found in authorized_keys. ssh-rsa keys use the same envelope as SecretShare's own keys, and
+// ssh-ed25519 keys are converted to X25519 for an ssv3 envelope.
+func NewSenderSessionForSSHKey(authorizedKey []byte) (*SenderSession, error) {
+	sshKey, _, _, _, err := ssh.ParseAuthorizedKey(authorizedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SSH public key: %w", err)
+	}
+	cryptoKey, ok := sshKey.(ssh.CryptoPublicKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported SSH key type: %s", sshKey.Type())
+	}
+
+	switch publicKey := cryptoKey.CryptoPublicKey().(type) {
+	case *rsa.PublicKey:
+		if publicKey.N.BitLen() < minSSHRSABits {
+			return nil, fmt.Errorf("ssh-rsa key is too short: %d bits, at least %d needed", publicKey.N.BitLen(), minSSHRSABits)
+		}
+		return &SenderSession{key: &rsaPublicKey{publicKey}}, nil
+	case ed25519.PublicKey:
+		recipient, err := ed25519ToX25519PublicKey(publicKey)
+		if err != nil {
+			return nil, err
+		}
+		return &SenderSession{key: &sshX25519Key{recipient: recipient, tag: sshKeyTag(sshKey)}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported SSH key type %s: use an ssh-ed25519 or ssh-rsa key", sshKey.Type())
+	}
+}
+
+// SSHIdentity decrypts secrets sent to an SSH public key with its private key. It stands in for
+// ssh-agent, which can only sign, holding the key in memory until it's destroyed.
+type SSHIdentity struct {
+	mu        sync.Mutex
+	publicKey ssh.PublicKey
+	rsaKey    *rsa.PrivateKey // for ssh-rsa keys
+	x25519Key *SecureBuffer   // the X25519 scalar for ssh-ed25519 keys
+}
+
+// ParseSSHIdentity parses an OpenSSH or PEM private key file. A passphrase protected key returns
+// ErrSSHKeyEncrypted when passphrase is nil, and ErrWrongSSHKeyPassphrase when it's wrong.
+func ParseSSHIdentity(keyFile []byte, passphrase []byte) (*SSHIdentity, error) {
+	var rawKey interface{}
+	var err error
+	if passphrase == nil {
+		rawKey, err = ssh.ParseRawPrivateKey(keyFile)
+	} else {
+		rawKey, err = ssh.ParseRawPrivateKeyWithPassphrase(keyFile, passphrase)
+	}
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		return nil, ErrSSHKeyEncrypted
+	}
+	if errors.Is(err, x509.IncorrectPasswordError) {
+		return nil, ErrWrongSSHKeyPassphrase
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SSH private key: %w", err)
+	}
+
+	switch privateKey := rawKey.(type) {
+	case *rsa.PrivateKey:
+		publicKey, err := ssh.NewPublicKey(&privateKey.PublicKey)
+		if err != nil {
+			wipePrivateKey(privateKey)
+			return nil, fmt.Errorf("failed to read SSH public key: %w", err)
+		}
+		return &SSHIdentity{publicKey: publicKey, rsaKey: privateKey}, nil
+	case *ed25519.PrivateKey:
+		return newEd25519Identity(*privateKey)
+	case ed25519.PrivateKey:
+		return newEd25519Identity(privateKey)
+	default:
+		return nil, fmt.Errorf("unsupported SSH key type %T: use an ssh-ed25519 or ssh-rsa key", rawKey)
+	}
+}
+
+// newEd25519Identity converts an Ed25519 private key to the X25519 scalar it decrypts with,
+// wiping the Ed25519 key
+func newEd25519Identity(privateKey ed25519.PrivateKey) (*SSHIdentity, error) {
+	defer Wipe(privateKey)
+	publicKey, err := ssh.NewPublicKey(privateKey.Public())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SSH public key: %w", err)
+	}
+
+	// Ed25519 signs with the first half of the seed's SHA-512, which is also its X25519 scalar
+	digest := sha512.Sum512(privateKey.Seed())
+	defer clear(digest[:])
+	scalar := NewSecureBuffer(curve25519.ScalarSize)
+	copy(scalar.Bytes(), digest[:curve25519.ScalarSize])
+	return &SSHIdentity{publicKey: publicKey, x25519Key: scalar}, nil
+}
+
+// PublicKey returns the identity's public key as an authorized_keys line, without a newline
+func (id *SSHIdentity) PublicKey() string {
+	return string(bytes.TrimSpace(ssh.MarshalAuthorizedKey(id.publicKey)))
+}
+
+// DecryptSecret decrypts a secret sent to the identity's public key into a SecureBuffer, which the
+// caller destroys once it's done with the secret. Returns ErrWrongSSHKey if it was sent to
+// another key.
+func (id *SSHIdentity) DecryptSecret(encryptedSecret []byte) (*SecureBuffer, error) {
+	id.mu.Lock()
+	defer id.mu.Unlock()
+
+	var buffer *SecureBuffer
+	alloc := func(size int) []byte {
+		buffer = NewSecureBuffer(size)
+		return buffer.Bytes()
+	}
+	if id.rsaKey == nil && id.x25519Key == nil {
+		return nil, fmt.Errorf("SSH key was destroyed")
+	}
+	if _, err := openEnvelope(id, encryptedSecret, alloc); err != nil {
+		buffer.Destroy()
+		return nil, fmt.Errorf("failed to decrypt secret: %w", err)
+	}
+	return buffer, nil
+}
+
+// reads reports whether the identity reads a suite's envelopes: those RSA keys read for ssh-rsa
+// keys, and ssv3 for ssh-ed25519 keys. Either is reported as sent to another key when it's the
+// other kind.
+func (id *SSHIdentity) reads(s *suite) bool {
+	return s.readBy == rsaKeys || s.readBy == sshX25519Keys
+}
+
+// decrypt decrypts an envelope with the identity's key, with id.mu held
+func (id *SSHIdentity) decrypt(s *suite, encryptedData []byte, alloc func(size int) []byte) ([]byte, error) {
+	switch {
+	case s.readBy == sshX25519Keys && id.x25519Key != nil:
+		return x25519Decrypt(id.x25519Key.Bytes(), sshKeyTag(id.publicKey), encryptedData, alloc)
+	case s.readBy == sshX25519Keys || id.rsaKey == nil:
+		return nil, ErrWrongSSHKey
+	}
+	plaintext, err := s.openRSA(id.rsaKey, encryptedData, alloc)
+	if err != nil && s.untagged {
+		// Any untagged secret this key can't open was encrypted to another key, or changed
+		return nil, ErrWrongSSHKey
+	}
+	return plaintext, err
+}
+
+func (id *SSHIdentity) otherSuiteErr() error {
+	return ErrWrongSSHKey
+}
+
+// Destroy wipes the private key from memory. The identity can't decrypt afterwards.
+func (id *SSHIdentity) Destroy() {
+	id.mu.Lock()
+	defer id.mu.Unlock()
+	if id.rsaKey != nil {
+		wipePrivateKey(id.rsaKey)
+		id.rsaKey = nil
+	}
+	if id.x25519Key != nil {
+		id.x25519Key.Destroy()
+		id.x25519Key = nil
+	}
+}
+
+func init() {
+	// ssv3 secrets are decrypted with an SSHIdentity, and receivers share their SSH public key
+	registerSuite(&suite{prefix: "ssv3", readBy: sshX25519Keys, otherKeyErr: &KeyMismatchError{
+		Err:         ErrSSHKeySecret,
+		Explanation: "This secret was encrypted to an SSH key instead of the key above.",
+		Hint:        "Run 'secret_share receive --ssh-key PATH' with the matching private key, such as ~/.ssh/id_ed25519.",
+	}})
+}
+
+// sshX25519Key is a receiver's ssh-ed25519 key converted to X25519, with the tag identifying it
+type sshX25519Key struct {
+	recipient []byte
+	tag       []byte
+}
+
+func (k *sshX25519Key) encrypt(random io.Reader, data []byte) ([]byte, error) {
+	return x25519Encrypt(random, k.recipient, k.tag, data)
+}
+
+func (k *sshX25519Key) equal(other senderKey) bool {
+	otherKey, ok := other.(*sshX25519Key)
+	return ok && bytes.Equal(k.recipient, otherKey.recipient)
+}
+
+// sshKeyTag identifies the SSH key a secret was encrypted to, so a receiver can tell a secret for
+// another key from a changed one
+func sshKeyTag(publicKey ssh.PublicKey) []byte {
+	digest := sha256.Sum256(publicKey.Marshal())
+	return digest[:sshKeyTagSize]
+}
+
+// x25519Encrypt encrypts data to an X25519 public key:
+// 1. Generates an ephemeral X25519 key, and agrees a shared secret with the recipient's key
+// 2. Derives an AES-256 key from the shared secret with HKDF-SHA256
+// 3. Encrypts the data with AES-GCM, authenticating the header as additional data
+// 4. Prepends "ssv3", the recipient's key tag and the ephemeral public key
+//
+// The ephemeral scalar and the nonce are read from random in that order.
+func x25519Encrypt(random io.Reader, recipient, tag, data []byte) ([]byte, error) {
+	scalar := make([]byte, curve25519.ScalarSize)
+	defer Wipe(scalar)
+	if _, err := io.ReadFull(random, scalar); err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+	ephemeral, err := curve25519.X25519(scalar, curve25519.Basepoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+	shared, err := curve25519.X25519(scalar, recipient)
+	if err != nil {
+		return nil, fmt.Errorf("invalid X25519 public key: %w", err)
+	}
+	defer Wipe(shared)
+
+	nonce, err := generateNonce(random)
+	if err != nil {
+		return nil, err
+	}
+
+	// Format: [ssv3][tag][ephemeral][nonce][ciphertext]
+	header := make([]byte, 0, x25519HeaderSize)
+	header = append(header, "ssv3"...)
+	header = append(header, tag...)
+	header = append(header, ephemeral...)
+
+	gcm, err := x25519Cipher(shared, ephemeral, recipient)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]byte, 0, len(header)+len(nonce)+len(data)+gcm.Overhead())
+	result = append(result, header...)
+	result = append(result, nonce...)
+	return gcm.Seal(result, nonce, data, header), nil
+}
+
+// x25519Decrypt decrypts an ssv3 secret with an X25519 scalar, into memory from alloc.
+// Returns ErrWrongSSHKey if the secret's tag isn't tag.
+func x25519Decrypt(scalar, tag, encryptedData []byte, alloc func(size int) []byte) ([]byte, error) {
+	if !IsSSHKeySecret(encryptedData) || len(encryptedData) < x25519HeaderSize+12 {
+		return nil, fmt.Errorf("invalid encrypted data format")
+	}
+
+	header := encryptedData[:x25519HeaderSize]
+	if !bytes.Equal(header[4:4+sshKeyTagSize], tag) {
+		return nil, ErrWrongSSHKey
+	}
+	ephemeral := header[4+sshKeyTagSize:]
+	nonce := encryptedData[x25519HeaderSize : x25519HeaderSize+12]
+	ciphertext := encryptedData[x25519HeaderSize+12:]
+
+	recipient, err := curve25519.X25519(scalar, curve25519.Basepoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid X25519 private key: %w", err)
+	}
+	shared, err := curve25519.X25519(scalar, ephemeral)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encrypted data format")
+	}
+	defer Wipe(shared)
+
+	gcm, err := x25519Cipher(shared, ephemeral, recipient)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decrypt data, straight into the plaintext's memory
+	size := len(ciphertext) - gcm.Overhead()
+	if size < 0 {
+		size = 0
+	}
+	plaintext, err := gcm.Open(alloc(size)[:0], nonce, ciphertext, header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data: %w", err)
+	}
+	return plaintext, nil
+}
+
+// x25519Cipher derives an AES-256-GCM cipher from an X25519 shared secret, bound to both public keys
+func x25519Cipher(shared, ephemeral, recipient []byte) (cipher.AEAD, error) {
+	salt := make([]byte, 0, len(ephemeral)+len(recipient))
+	salt = append(salt, ephemeral...)
+	salt = append(salt, recipient...)
+
+	key := make([]byte, 32)
+	defer Wipe(key)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, []byte(x25519KeyInfo)), key); err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	return newAESGCM(key)
+}
+
+// curve25519Prime is the field prime 2^255 - 19
+var curve25519Prime = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+
+// ed25519ToX25519PublicKey converts an Ed25519 public key to the X25519 public key of the same
+// secret, with the birational map u = (1 + y) / (1 - y) from RFC 7748
+func ed25519ToX25519PublicKey(publicKey ed25519.PublicKey) ([]byte, error) {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid ed25519 public key")
+	}
+
+	// The key is y in little-endian, with the sign of x in the top bit
+	bigEndian := make([]byte, len(publicKey))
+	for i, b := range publicKey {
+		bigEndian[len(publicKey)-1-i] = b
+	}
+	bigEndian[0] &= 0x7f
+	y := new(big.Int).SetBytes(bigEndian)
+	if y.Cmp(curve25519Prime) >= 0 {
+		return nil, fmt.Errorf("invalid ed25519 public key")
+	}
+
+	one := big.NewInt(1)
+	denominator := new(big.Int).Sub(one, y)
+	denominator.Mod(denominator, curve25519Prime)
+	if denominator.Sign() == 0 {
+		return nil, fmt.Errorf("invalid ed25519 public key")
+	}
+	u := new(big.Int).Add(one, y)
+	u.Mul(u, denominator.ModInverse(denominator, curve25519Prime))
+	u.Mod(u, curve25519Prime)
+
+	result := u.FillBytes(make([]byte, curve25519.PointSize))
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result, nil
+}
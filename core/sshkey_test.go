@@ -0,0 +1,313 @@
+package core
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/pem"
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/ssh"
+)
+
+// newSSHKey returns a private key as an OpenSSH key file, and its authorized_keys line
+func newSSHKey(t *testing.T, privateKey interface{}, passphrase []byte) ([]byte, []byte) {
+	t.Helper()
+	var block *pem.Block
+	var err error
+	if passphrase == nil {
+		block, err = ssh.MarshalPrivateKey(privateKey, "test")
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(privateKey, "test", passphrase)
+	}
+	if err != nil {
+		t.Fatalf("Failed to serialize SSH key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(privateKey)
+	if err != nil {
+		t.Fatalf("Failed to read SSH key: %v", err)
+	}
+	return pem.EncodeToMemory(block), ssh.MarshalAuthorizedKey(signer.PublicKey())
+}
+
+// newEd25519SSHKey generates an ssh-ed25519 key, returning its key file and authorized_keys line
+func newEd25519SSHKey(t *testing.T) ([]byte, []byte) {
+	t.Helper()
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	return newSSHKey(t, privateKey, nil)
+}
+
+func TestSSHKeyEncryptDecrypt(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	rsaFile, rsaLine := newSSHKey(t, rsaKey, nil)
+	ed25519File, ed25519Line := newEd25519SSHKey(t)
+	secret := []byte("Xk2#pQ9!vL7@mN4$wR8&")
+
+	tests := []struct {
+		name    string
+		keyFile []byte
+		line    []byte
+		version string
+	}{
+		{"ssh-ed25519", ed25519File, ed25519Line, "ssv3"},
+		{"ssh-rsa", rsaFile, rsaLine, "ssv1"},
+	}
+	for _, test := range tests {
+		// Authorized keys lines can have options before the key, and a comment after it
+		session, err := NewSenderSessionForSSHKey(append([]byte(`from="10.0.0.1" `), bytes.TrimSpace(test.line)...))
+		if err != nil {
+			t.Fatalf("%s: Failed to create sender session: %v", test.name, err)
+		}
+		encrypted, err := session.EncryptSecret(secret)
+		if err != nil {
+			t.Fatalf("%s: Failed to encrypt: %v", test.name, err)
+		}
+		if !bytes.HasPrefix(encrypted, []byte(test.version)) {
+			t.Errorf("%s: Expected an %s envelope, got prefix %q", test.name, test.version, encrypted[:4])
+		}
+
+		identity, err := ParseSSHIdentity(test.keyFile, nil)
+		if err != nil {
+			t.Fatalf("%s: Failed to parse private key: %v", test.name, err)
+		}
+		if identity.PublicKey() != string(bytes.TrimSpace(test.line)) {
+			t.Errorf("%s: Expected public key '%s', got '%s'", test.name, bytes.TrimSpace(test.line), identity.PublicKey())
+		}
+		decrypted, err := identity.DecryptSecret(encrypted)
+		if err != nil {
+			t.Fatalf("%s: Failed to decrypt: %v", test.name, err)
+		}
+		if !bytes.Equal(decrypted.Bytes(), secret) {
+			t.Errorf("%s: Expected '%s', got '%s'", test.name, secret, decrypted.Bytes())
+		}
+		decrypted.Destroy()
+
+		// The key is gone once the identity is destroyed
+		identity.Destroy()
+		if _, err := identity.DecryptSecret(encrypted); err == nil {
+			t.Errorf("%s: Expected an error decrypting after destroy", test.name)
+		}
+	}
+}
+
+func TestSSHIdentityWrongKey(t *testing.T) {
+	keyFile, _ := newEd25519SSHKey(t)
+	_, otherLine := newEd25519SSHKey(t)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	rsaFile, _ := newSSHKey(t, rsaKey, nil)
+
+	identity, err := ParseSSHIdentity(keyFile, nil)
+	if err != nil {
+		t.Fatalf("Failed to parse private key: %v", err)
+	}
+	defer identity.Destroy()
+	rsaIdentity, err := ParseSSHIdentity(rsaFile, nil)
+	if err != nil {
+		t.Fatalf("Failed to parse private key: %v", err)
+	}
+	defer rsaIdentity.Destroy()
+
+	otherSession, err := NewSenderSessionForSSHKey(otherLine)
+	if err != nil {
+		t.Fatalf("Failed to create sender session: %v", err)
+	}
+	forOtherKey, err := otherSession.EncryptSecret([]byte("secret"))
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+	forSessionKey, err := HybridEncrypt(&fuzzPrivateKey(t).PublicKey, []byte("secret"))
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+	forPassphrase, err := passphraseEncrypt(rand.Reader, fastPassphraseParams, []byte("p"), []byte("secret"))
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		identity *SSHIdentity
+		data     []byte
+		expected error
+	}{
+		{"another ssh-ed25519 key", identity, forOtherKey, ErrWrongSSHKey},
+		{"a session key to ssh-ed25519", identity, forSessionKey, ErrWrongSSHKey},
+		{"a session key to ssh-rsa", rsaIdentity, forSessionKey, ErrWrongSSHKey},
+		{"ssh-ed25519 to ssh-rsa", rsaIdentity, forOtherKey, ErrWrongSSHKey},
+		{"a passphrase", identity, forPassphrase, ErrPassphraseSecret},
+		{"a newer version", identity, []byte("ssv9 newer"), ErrNewerVersion},
+	}
+	for _, test := range tests {
+		if _, err := test.identity.DecryptSecret(test.data); !errors.Is(err, test.expected) {
+			t.Errorf("%s: Expected %v, got %v", test.name, test.expected, err)
+		}
+	}
+
+	// Test case: A changed secret for the right key is an error, but not a wrong key
+	session, err := NewSenderSessionForSSHKey([]byte(identity.PublicKey()))
+	if err != nil {
+		t.Fatalf("Failed to create sender session: %v", err)
+	}
+	encrypted, err := session.EncryptSecret([]byte("secret"))
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+	encrypted[len(encrypted)-1] ^= 1
+	if _, err := identity.DecryptSecret(encrypted); err == nil || errors.Is(err, ErrWrongSSHKey) {
+		t.Errorf("Expected a decryption error for a changed secret, got %v", err)
+	}
+}
+
+func TestParseSSHIdentityPassphrase(t *testing.T) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	keyFile, line := newSSHKey(t, privateKey, []byte("key passphrase"))
+
+	// Test case 1: The passphrase is needed
+	if _, err := ParseSSHIdentity(keyFile, nil); !errors.Is(err, ErrSSHKeyEncrypted) {
+		t.Errorf("Test 1 failed: Expected SSH key encrypted error, got %v", err)
+	}
+
+	// Test case 2: Wrong passphrase
+	if _, err := ParseSSHIdentity(keyFile, []byte("wrong")); !errors.Is(err, ErrWrongSSHKeyPassphrase) {
+		t.Errorf("Test 2 failed: Expected wrong passphrase error, got %v", err)
+	}
+
+	// Test case 3: Right passphrase
+	identity, err := ParseSSHIdentity(keyFile, []byte("key passphrase"))
+	if err != nil {
+		t.Fatalf("Test 3 failed: Failed to parse private key: %v", err)
+	}
+	defer identity.Destroy()
+	if identity.PublicKey() != string(bytes.TrimSpace(line)) {
+		t.Errorf("Test 3 failed: Expected public key '%s', got '%s'", bytes.TrimSpace(line), identity.PublicKey())
+	}
+
+	// Test case 4: Not a key
+	if _, err := ParseSSHIdentity([]byte("not a key"), nil); err == nil {
+		t.Error("Test 4 failed: Expected error for an invalid key file")
+	}
+}
+
+func TestNewSenderSessionForSSHKeyInvalid(t *testing.T) {
+	shortKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	_, shortLine := newSSHKey(t, shortKey, nil)
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	_, ecdsaLine := newSSHKey(t, ecdsaKey, nil)
+
+	tests := []struct {
+		name string
+		line []byte
+	}{
+		{"not a key", []byte("ssh-ed25519 not-base64")},
+		{"empty", nil},
+		{"short ssh-rsa", shortLine},
+		{"ecdsa", ecdsaLine},
+	}
+	for _, test := range tests {
+		if _, err := NewSenderSessionForSSHKey(test.line); err == nil {
+			t.Errorf("%s: Expected an error", test.name)
+		}
+	}
+}
+
+func TestEd25519ToX25519(t *testing.T) {
+	for i := 0; i < 8; i++ {
+		publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatalf("Failed to generate key: %v", err)
+		}
+		converted, err := ed25519ToX25519PublicKey(publicKey)
+		if err != nil {
+			t.Fatalf("Failed to convert public key: %v", err)
+		}
+
+		// The converted public key must match the public key of the converted private key
+		identity, err := newEd25519Identity(append(ed25519.PrivateKey{}, privateKey...))
+		if err != nil {
+			t.Fatalf("Failed to convert private key: %v", err)
+		}
+		derived, err := curve25519.X25519(identity.x25519Key.Bytes(), curve25519.Basepoint)
+		identity.Destroy()
+		if err != nil {
+			t.Fatalf("Failed to derive public key: %v", err)
+		}
+		if !bytes.Equal(converted, derived) {
+			t.Errorf("Expected converted keys to match, got %x and %x", converted, derived)
+		}
+	}
+
+	// The identity point, where y = 1, has no X25519 equivalent
+	identityPoint := make([]byte, ed25519.PublicKeySize)
+	identityPoint[0] = 1
+	if _, err := ed25519ToX25519PublicKey(identityPoint); err == nil {
+		t.Error("Expected error converting the identity point")
+	}
+}
+
+func TestSenderSessionSameReceiver(t *testing.T) {
+	_, line := newEd25519SSHKey(t)
+	_, otherLine := newEd25519SSHKey(t)
+	first, err := NewSenderSessionForSSHKey(line)
+	if err != nil {
+		t.Fatalf("Failed to create sender session: %v", err)
+	}
+	second, err := NewSenderSessionForSSHKey(line)
+	if err != nil {
+		t.Fatalf("Failed to create sender session: %v", err)
+	}
+	other, err := NewSenderSessionForSSHKey(otherLine)
+	if err != nil {
+		t.Fatalf("Failed to create sender session: %v", err)
+	}
+	rsaSession := NewSenderSession(&fuzzPrivateKey(t).PublicKey)
+
+	if !first.SameReceiver(second) {
+		t.Error("Expected the same SSH key to be the same receiver")
+	}
+	if first.SameReceiver(other) || first.SameReceiver(rsaSession) || rsaSession.SameReceiver(first) {
+		t.Error("Expected different keys to be different receivers")
+	}
+	if !rsaSession.SameReceiver(NewSenderSession(&fuzzPrivateKey(t).PublicKey)) {
+		t.Error("Expected the same RSA key to be the same receiver")
+	}
+}
+
+func TestHybridDecryptSSHKeySecret(t *testing.T) {
+	_, line := newEd25519SSHKey(t)
+	session, err := NewSenderSessionForSSHKey(line)
+	if err != nil {
+		t.Fatalf("Failed to create sender session: %v", err)
+	}
+	encrypted, err := session.EncryptSecret([]byte("secret"))
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+
+	// Secrets for an SSH key can't be decrypted with a session key, and say so
+	if _, err := HybridDecrypt(fuzzPrivateKey(t), encrypted); !errors.Is(err, ErrSSHKeySecret) {
+		t.Errorf("Expected SSH key secret error, got %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+package core
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// suite is the algorithms behind one envelope format, registered under the prefix its envelopes
+// start with. SecretShare's own versions have an "ssv" prefix, which their shared keys start with
+// too.
+type suite struct {
+	prefix string
+
+	// newKey generates a receiver's one-time key, and parseKey reads a key the receiver shared,
+	// after its prefix. Either is nil for suites without them.
+	newKey   func() (receiverKey, error)
+	parseKey func(data []byte) (senderKey, error)
+
+	// readBy is the kind of key that reads the suite's envelopes, or noKey for suites no key
+	// reads, like passphrase envelopes
+	readBy keyKind
+	// openRSA opens an envelope with an RSA private key, for suites RSA keys read
+	openRSA func(privateKey *rsa.PrivateKey, encryptedData []byte, alloc func(size int) []byte) ([]byte, error)
+	// untagged envelopes don't say which key they're for, so one that doesn't open for a key that
+	// reads the suite is taken as encrypted to another key
+	untagged bool
+
+	// otherKeyErr is returned when the suite's envelope is given to a key that can't read it,
+	// saying what can. When it's nil, the key's own error is returned instead.
+	otherKeyErr *KeyMismatchError
+}
+
+// keyKind is a kind of receiver key, by the suites whose envelopes it reads
+type keyKind int
+
+const (
+	noKey keyKind = iota
+	rsaKeys
+	hpkeKeys
+	ageKeys
+	sshX25519Keys
+)
+
+// KeyMismatchError is returned for a secret encrypted to something other than the receiver's key,
+// such as another kind of key or a passphrase. It wraps an error like ErrSSHKeySecret, and explains
+// the mismatch to the receiver.
+type KeyMismatchError struct {
+	Err error
+	// Explanation says what the secret was encrypted to, and Hint what the receiver can do about
+	// it. Hint is empty when there's nothing to add.
+	Explanation string
+	Hint        string
+}
+
+func (e *KeyMismatchError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *KeyMismatchError) Unwrap() error {
+	return e.Err
+}
+
+// envelopeReader decrypts the envelopes of one or more suites
+type envelopeReader interface {
+	// reads reports whether the reader decrypts envelopes of the suite
+	reads(s *suite) bool
+	// decrypt decrypts an envelope of a suite the reader reads, into memory from alloc
+	decrypt(s *suite, encryptedData []byte, alloc func(size int) []byte) ([]byte, error)
+	// otherSuiteErr is returned for envelopes the reader can't read, when their suite doesn't say why
+	otherSuiteErr() error
+}
+
+// receiverKey is a receiver's private key, held by a ReceiverSession
+type receiverKey interface {
+	envelopeReader
+	// share returns the public key formatted for sharing with the sender
+	share() (string, error)
+	// wiped reports whether the private key has been destroyed
+	wiped() bool
+	// locked reports whether the private key is kept in locked memory
+	locked() bool
+	destroy()
+}
+
+// senderKey is a receiver's public key, which a SenderSession encrypts to
+type senderKey interface {
+	encrypt(random io.Reader, data []byte) ([]byte, error)
+	equal(other senderKey) bool
+}
+
+// suites holds every registered suite by prefix
+var suites = map[string]*suite{}
+
+// registerSuite adds a suite to the registry. Each prefix is registered once, by the file
+// implementing it.
+func registerSuite(s *suite) {
+	if _, ok := suites[s.prefix]; ok {
+		panic("suite registered twice: " + s.prefix)
+	}
+	suites[s.prefix] = s
+}
+
+// suiteFor returns the suite of encrypted data by its prefix, or nil if none matches. Prefixes
+// don't overlap, so at most one does.
+func suiteFor(encryptedData []byte) *suite {
+	for prefix, s := range suites {
+		if bytes.HasPrefix(encryptedData, []byte(prefix)) {
+			return s
+		}
+	}
+	return nil
+}
+
+// openEnvelope decrypts data with a reader, through the suite its prefix picks, into memory from
+// alloc. Envelopes the reader can't open say what can: a passphrase, an SSH key, a newer version.
+func openEnvelope(reader envelopeReader, encryptedData []byte, alloc func(size int) []byte) ([]byte, error) {
+	s := suiteFor(encryptedData)
+	switch {
+	case s == nil && bytes.HasPrefix(encryptedData, []byte("ssv")):
+		// Recognizable format but newer version
+		return nil, ErrNewerVersion
+	case s == nil:
+		return nil, fmt.Errorf("invalid encrypted data format")
+	case reader.reads(s):
+		return reader.decrypt(s, encryptedData, alloc)
+	case s.otherKeyErr != nil:
+		return nil, s.otherKeyErr
+	default:
+		return nil, reader.otherSuiteErr()
+	}
+}
+
+// newSuiteReceiverSession creates a receiver session with a one-time key from a registered suite
+func newSuiteReceiverSession(prefix string) (*ReceiverSession, error) {
+	key, err := suites[prefix].newKey()
+	if err != nil {
+		return nil, err
+	}
+	return &ReceiverSession{key: key}, nil
+}
+
+// formatKey formats an encoded key with its suite's prefix and armored XML-like tags for sharing
+func formatKey(prefix, encodedKey string) string {
+	return armor("secret_share_key", prefix+encodedKey)
+}
+
+// NewSenderSessionForKey creates a sender session for a key shared by a receiver, as found between
+// its <secret_share_key> tags. The key's prefix picks its suite, and keys without one are ssv1
+// keys from the first versions. Returns ErrNewerVersion for keys this version can't encrypt to.
+func NewSenderSessionForKey(key string) (*SenderSession, error) {
+	s := suites["ssv1"]
+	if strings.HasPrefix(key, "ssv") {
+		if len(key) < 4 {
+			return nil, fmt.Errorf("invalid key")
+		}
+		if s = suites[key[:4]]; s == nil || s.parseKey == nil {
+			return nil, ErrNewerVersion
+		}
+		key = key[4:]
+	}
+
+	data, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key: %w", err)
+	}
+	if len(data) == 0 {
+		return nil, errors.New("invalid key: empty")
+	}
+	publicKey, err := s.parseKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key: %w", err)
+	}
+	return &SenderSession{key: publicKey}, nil
+}
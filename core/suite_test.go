@@ -0,0 +1,131 @@
+package core
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSuiteRegistry(t *testing.T) {
+	// Test case 1: Every envelope format is registered once
+	for _, prefix := range []string{"ssv1", "ssv2", "ssv3", "ssv4", jwePrefix, ageVersionLine + "\n"} {
+		if s := suiteFor([]byte(prefix + "data")); s == nil || s.prefix != prefix {
+			t.Errorf("Test 1 failed: Expected a suite for %q", prefix)
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("Test 1 failed: Expected registering a prefix twice to panic")
+		}
+	}()
+	registerSuite(&suite{prefix: "ssv1"})
+}
+
+func TestOpenEnvelopeErrors(t *testing.T) {
+	hpkeSession, err := NewHPKEReceiverSession()
+	if err != nil {
+		t.Fatalf("Failed to create HPKE session: %v", err)
+	}
+	defer hpkeSession.Destroy()
+	rsaSession := NewReceiverSessionWithKey(fuzzPrivateKey(t))
+
+	alloc := func(size int) []byte { return make([]byte, size) }
+	testCases := []struct {
+		reader envelopeReader
+		data   string
+		want   error
+	}{
+		// Suites that say what can read them
+		{rsaSession.key, "ssv2data", ErrPassphraseSecret},
+		{rsaSession.key, "ssv3data", ErrSSHKeySecret},
+		{rsaSession.key, "ssv4data", ErrHPKESecret},
+		{rsaSession.key, ageVersionLine + "\ndata", ErrAgeSecret},
+		{hpkeSession.key, "ssv2data", ErrPassphraseSecret},
+		// Suites that don't, reported by the reader
+		{hpkeSession.key, "ssv1data", ErrNotHPKESecret},
+		{hpkeSession.key, "eyJ.a.b.c.d", ErrNotHPKESecret},
+		// Unknown versions
+		{rsaSession.key, "ssv9data", ErrNewerVersion},
+		{hpkeSession.key, "ssv9data", ErrNewerVersion},
+	}
+	for i, tc := range testCases {
+		if _, err := openEnvelope(tc.reader, []byte(tc.data), alloc); !errors.Is(err, tc.want) {
+			t.Errorf("Test %d failed: Expected %v for %q, got %v", i+1, tc.want, tc.data, err)
+		}
+	}
+
+	// Test case 10: Data without a known prefix is invalid
+	if _, err := openEnvelope(rsaSession.key, []byte("garbage"), alloc); err == nil || errors.Is(err, ErrNewerVersion) {
+		t.Errorf("Test 10 failed: Expected an invalid format error, got %v", err)
+	}
+
+	// Test case 11: Key mismatches explain themselves to the receiver
+	for _, tc := range testCases[:7] {
+		_, err := openEnvelope(tc.reader, []byte(tc.data), alloc)
+		var mismatch *KeyMismatchError
+		if !errors.As(err, &mismatch) || mismatch.Explanation == "" {
+			t.Errorf("Test 11 failed: Expected an explained key mismatch for %q, got %v", tc.data, err)
+		}
+	}
+}
+
+func TestNewSenderSessionForKey(t *testing.T) {
+	rsaSession := NewReceiverSessionWithKey(fuzzPrivateKey(t))
+	rsaKey, err := rsaSession.SharedKey()
+	if err != nil {
+		t.Fatalf("Failed to format key: %v", err)
+	}
+	hpkeSession, err := NewHPKEReceiverSession()
+	if err != nil {
+		t.Fatalf("Failed to create HPKE session: %v", err)
+	}
+	defer hpkeSession.Destroy()
+	hpkeKey, err := hpkeSession.SharedKey()
+	if err != nil {
+		t.Fatalf("Failed to format key: %v", err)
+	}
+	// Test case 1: Shared keys round trip through their suite
+	for _, session := range []*ReceiverSession{rsaSession, hpkeSession} {
+		key, err := session.SharedKey()
+		if err != nil {
+			t.Fatalf("Test 1 failed: Failed to format key: %v", err)
+		}
+		sender, err := NewSenderSessionForKey(unarmor(t, key, "secret_share_key"))
+		if err != nil {
+			t.Fatalf("Test 1 failed: Failed to create sender session for %s: %v", key, err)
+		}
+		encrypted, err := sender.EncryptSecret([]byte("test secret"))
+		if err != nil {
+			t.Fatalf("Test 1 failed: Failed to encrypt: %v", err)
+		}
+		decrypted, err := session.DecryptSecret(encrypted)
+		if err != nil || string(decrypted.Bytes()) != "test secret" {
+			t.Errorf("Test 1 failed: Expected 'test secret' (err: %v)", err)
+		} else {
+			decrypted.Destroy()
+		}
+	}
+
+	// Test case 2: Keys without a version are ssv1 keys from the first versions
+	sender, err := NewSenderSessionForKey(strings.TrimPrefix(unarmor(t, rsaKey, "secret_share_key"), "ssv1"))
+	if err != nil || !sender.SameReceiver(NewSenderSession(rsaSession.GetPublicKey())) {
+		t.Errorf("Test 2 failed: Expected an unversioned RSA key to parse (err: %v)", err)
+	}
+
+	// Test case 3: Versions without shared keys, and unknown versions, need a newer version
+	for _, key := range []string{"ssv9AAAA", "ssv2AAAA", "ssv3AAAA"} {
+		if _, err := NewSenderSessionForKey(key); !errors.Is(err, ErrNewerVersion) {
+			t.Errorf("Test 3 failed: Expected newer version error for %s, got %v", key, err)
+		}
+	}
+
+	// Test case 4: Invalid keys are rejected
+	hpkeBody := strings.TrimPrefix(unarmor(t, hpkeKey, "secret_share_key"), "ssv4")
+	short := base64.StdEncoding.EncodeToString([]byte("short"))
+	for _, key := range []string{"", "ssv", "ssv1", "ssv1!!!", "ssv4" + short, "ssv1" + hpkeBody} {
+		if _, err := NewSenderSessionForKey(key); err == nil || errors.Is(err, ErrNewerVersion) {
+			t.Errorf("Test 4 failed: Expected invalid key error for %q, got %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,719 @@
+package core
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/ssh"
+)
+
+var updateVectors = flag.Bool("update-vectors", false, "regenerate testdata/vectors.json with new keys and randomness")
+
+// vectorsPath holds known-answer test vectors for every envelope version.
+// Other implementations can use the file to check they interoperate.
+const vectorsPath = "testdata/vectors.json"
+
+// vectorFile is the JSON layout of the test vectors file
+type vectorFile struct {
+	Description string      `json:"description"`
+	Keys        []vectorKey `json:"keys"`
+	Vectors     []vector    `json:"vectors"`
+}
+
+// vectorKey is a fixed receiver key pair
+type vectorKey struct {
+	Name       string `json:"name"`
+	PrivateKey string `json:"private_key"` // base64 PKCS#8 DER
+	PublicKey  string `json:"public_key"`  // the key as the receiver shares it, with tags
+	JWK        string `json:"jwk"`         // the public key as a JWK, as the receiver shares it with --jwe
+}
+
+// vector is one known answer: encrypting plaintext to key with the given randomness gives ciphertext
+type vector struct {
+	Name           string `json:"name"`
+	Version        string `json:"version"`
+	Key            string `json:"key,omitempty"`
+	Passphrase     string `json:"passphrase,omitempty"`       // hex
+	SSHSeed        string `json:"ssh_seed,omitempty"`         // hex, the Ed25519 seed of the receiver's ssh-ed25519 key
+	SSHKey         string `json:"ssh_public_key,omitempty"`   // the receiver's ssh-ed25519 key as in authorized_keys
+	AgeIdentity    string `json:"age_identity,omitempty"`     // the receiver's age identity, as written by age-keygen
+	AgeRecipient   string `json:"age_recipient,omitempty"`    // the age identity's recipient
+	HPKEPrivateKey string `json:"hpke_private_key,omitempty"` // hex, the receiver's X25519 private key
+	HPKEPublicKey  string `json:"hpke_public_key,omitempty"`  // the receiver's ssv4 key as it shares it, with tags
+	Plaintext      string `json:"plaintext"`                  // hex
+	Random         string `json:"random"`                     // hex, the randomness consumed by encryption in order
+	Ciphertext     string `json:"ciphertext"`                 // the secret as the sender shares it, with tags, or the JWE
+}
+
+const vectorsDescription = "Known-answer test vectors for secret_share envelopes. " +
+	"ssv1: random is the AES-256 key (32 bytes), the RSA-OAEP-SHA256 seed (32 bytes) and the AES-GCM nonce (12 bytes). " +
+	"The envelope is base64 of \"ssv1\", the RSA-OAEP encrypted key length (4 bytes, big endian), the encrypted key, the nonce and the AES-GCM ciphertext. " +
+	"ssv2: random is the Argon2id salt (16 bytes) and the AES-GCM nonce (12 bytes). " +
+	"The envelope is base64 of the header (\"ssv2\", KDF 0x01 for Argon2id, time and memory in KiB as 4 bytes big endian each, threads as 1 byte, the salt), " +
+	"the nonce and the AES-256-GCM ciphertext with the header as additional data. The key is Argon2id of the passphrase and salt with the header's settings. " +
+	"ssv3: random is the ephemeral X25519 scalar (32 bytes) and the AES-GCM nonce (12 bytes). " +
+	"The receiver's ssh-ed25519 key is converted to X25519 (u = (1 + y) / (1 - y), and the scalar is the first half of SHA-512 of the seed). " +
+	"The envelope is base64 of the header (\"ssv3\", the first 4 bytes of SHA-256 of the SSH key's wire format, the ephemeral public key), " +
+	"the nonce and the AES-256-GCM ciphertext with the header as additional data. " +
+	"The key is HKDF-SHA256 of the X25519 shared secret, with the ephemeral and receiver public keys as salt and \"secret_share ssv3 X25519\" as info. " +
+	"age: random is the file key (16 bytes), the ephemeral X25519 scalar (32 bytes) and the payload nonce (16 bytes). " +
+	"The envelope is base64 of an age v1 file (https://age-encryption.org/v1) with one X25519 recipient stanza, which age itself can decrypt. " +
+	"jwe: random is read as for ssv1 (content key, RSA-OAEP-SHA256 seed, IV). " +
+	"The ciphertext is a JWE (RFC 7516) in compact serialization with alg RSA-OAEP-256, enc A256GCM and kid the key's RFC 7638 thumbprint, which JOSE libraries can decrypt. " +
+	"ssv4: random is the ephemeral key's input keying material (32 bytes), from which HPKE's DeriveKeyPair derives the ephemeral X25519 key. " +
+	"The envelope is base64 of the header (\"ssv4\", the HPKE AEAD identifier as 2 bytes big endian, the encapsulated key) and the ciphertext. " +
+	"It's a single message sealed with HPKE (RFC 9180) in base mode with DHKEM(X25519, HKDF-SHA256), HKDF-SHA256 and AEAD 0x0002 AES-256-GCM or 0x0003 ChaCha20-Poly1305, " +
+	"with \"secret_share ssv4\" as info and the header as additional data. " +
+	"Keys and ciphertexts are shared armored: the tags on their own lines, the content wrapped at 64 columns, " +
+	"and a checksum line of \"=\" and base64 of the content's OpenPGP CRC-24 (RFC 4880 section 6.1)."
+
+func TestVectors(t *testing.T) {
+	if *updateVectors {
+		writeVectors(t)
+	}
+
+	data, err := os.ReadFile(vectorsPath)
+	if err != nil {
+		t.Fatalf("Failed to read vectors: %v", err)
+	}
+	var file vectorFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatalf("Failed to parse vectors: %v", err)
+	}
+	if len(file.Vectors) == 0 {
+		t.Fatal("Expected test vectors")
+	}
+
+	keys := make(map[string]*rsa.PrivateKey)
+	for _, key := range file.Keys {
+		privateKey := parseVectorKey(t, key)
+		keys[key.Name] = privateKey
+	}
+
+	versions := make(map[string]bool)
+	for _, v := range file.Vectors {
+		t.Run(v.Name, func(t *testing.T) {
+			versions[v.Version] = true
+			plaintext, err := hex.DecodeString(v.Plaintext)
+			if err != nil {
+				t.Fatalf("Invalid plaintext hex: %v", err)
+			}
+			random, err := hex.DecodeString(v.Random)
+			if err != nil {
+				t.Fatalf("Invalid random hex: %v", err)
+			}
+
+			switch v.Version {
+			case "ssv1":
+				checkSSV1Vector(t, keys[v.Key], plaintext, random, v.Ciphertext)
+			case "ssv2":
+				passphrase, err := hex.DecodeString(v.Passphrase)
+				if err != nil {
+					t.Fatalf("Invalid passphrase hex: %v", err)
+				}
+				checkSSV2Vector(t, passphrase, plaintext, random, v.Ciphertext)
+			case "ssv3":
+				seed, err := hex.DecodeString(v.SSHSeed)
+				if err != nil || len(seed) != ed25519.SeedSize {
+					t.Fatalf("Invalid ssh seed hex: %v", err)
+				}
+				checkSSV3Vector(t, seed, v.SSHKey, plaintext, random, v.Ciphertext)
+			case "age":
+				checkAgeVector(t, v.AgeIdentity, v.AgeRecipient, plaintext, random, v.Ciphertext)
+			case "jwe":
+				checkJWEVector(t, keys[v.Key], plaintext, random, v.Ciphertext)
+			case "ssv4":
+				privateKey, err := hex.DecodeString(v.HPKEPrivateKey)
+				if err != nil || len(privateKey) != curve25519.ScalarSize {
+					t.Fatalf("Invalid HPKE private key hex: %v", err)
+				}
+				checkSSV4Vector(t, privateKey, v.HPKEPublicKey, plaintext, random, v.Ciphertext)
+			default:
+				t.Fatalf("Unknown envelope version %s", v.Version)
+			}
+		})
+	}
+
+	// Every version this build can write needs a vector
+	for _, version := range []string{"ssv1", "ssv2", "ssv3", "age", "jwe", "ssv4"} {
+		if !versions[version] {
+			t.Errorf("Expected test vectors for %s", version)
+		}
+	}
+}
+
+// parseVectorKey parses a key pair and checks its public key is shared in the expected format
+func parseVectorKey(t *testing.T, key vectorKey) *rsa.PrivateKey {
+	t.Helper()
+	der, err := base64.StdEncoding.DecodeString(key.PrivateKey)
+	if err != nil {
+		t.Fatalf("Invalid private key base64 for %s: %v", key.Name, err)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		t.Fatalf("Invalid private key for %s: %v", key.Name, err)
+	}
+	privateKey, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		t.Fatalf("Expected an RSA private key for %s", key.Name)
+	}
+
+	publicKeyBytes, err := PublicKeyToBytes(&privateKey.PublicKey)
+	if err != nil {
+		t.Fatalf("Failed to serialize public key for %s: %v", key.Name, err)
+	}
+	publicKey := FormatPublicKey([]byte(base64.StdEncoding.EncodeToString(publicKeyBytes)))
+	if publicKey != key.PublicKey {
+		t.Errorf("Expected public key for %s to be shared as '%s', got '%s'", key.Name, key.PublicKey, publicKey)
+	}
+	jwk, err := PublicKeyToJWK(&privateKey.PublicKey)
+	if err != nil {
+		t.Fatalf("Failed to convert public key for %s: %v", key.Name, err)
+	}
+	if string(jwk) != key.JWK {
+		t.Errorf("Expected public key for %s to be shared as JWK '%s', got '%s'", key.Name, key.JWK, jwk)
+	}
+	return privateKey
+}
+
+// checkSSV1Vector checks an ssv1 vector both decrypts and is reproduced exactly by encryption
+func checkSSV1Vector(t *testing.T, privateKey *rsa.PrivateKey, plaintext, random []byte, ciphertext string) {
+	t.Helper()
+	if privateKey == nil {
+		t.Fatal("Unknown key")
+	}
+
+	encoded := unarmor(t, ciphertext, "secret_share_secret")
+	envelope, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatalf("Invalid ciphertext base64: %v", err)
+	}
+
+	decrypted, err := HybridDecrypt(privateKey, envelope)
+	if err != nil {
+		t.Fatalf("Failed to decrypt vector: %v", err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("Expected plaintext %x, got %x", plaintext, decrypted)
+	}
+
+	// Encrypting with the same randomness must give the same bytes, so a change to the layout is caught
+	reader := bytes.NewReader(random)
+	encrypted, err := hybridEncrypt(reader, &privateKey.PublicKey, plaintext)
+	if err != nil {
+		t.Fatalf("Failed to encrypt vector: %v", err)
+	}
+	if reader.Len() != 0 {
+		t.Errorf("Expected all %d random bytes to be used, %d left", len(random), reader.Len())
+	}
+	if formatted := FormatSecret([]byte(base64.StdEncoding.EncodeToString(encrypted))); formatted != ciphertext {
+		t.Errorf("Expected ciphertext '%s', got '%s'", ciphertext, formatted)
+	}
+}
+
+// checkSSV2Vector checks an ssv2 vector both decrypts and is reproduced exactly by encryption
+func checkSSV2Vector(t *testing.T, passphrase, plaintext, random []byte, ciphertext string) {
+	t.Helper()
+	encoded := unarmor(t, ciphertext, "secret_share_secret")
+	envelope, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatalf("Invalid ciphertext base64: %v", err)
+	}
+
+	decrypted, err := PassphraseDecrypt(passphrase, envelope)
+	if err != nil {
+		t.Fatalf("Failed to decrypt vector: %v", err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("Expected plaintext %x, got %x", plaintext, decrypted)
+	}
+
+	// Encrypting with the same settings and randomness must give the same bytes
+	if len(envelope) < passphraseHeaderSize {
+		t.Fatal("Vector is too short for an ssv2 header")
+	}
+	params := PassphraseParams{
+		Time:    binary.BigEndian.Uint32(envelope[5:9]),
+		Memory:  binary.BigEndian.Uint32(envelope[9:13]),
+		Threads: envelope[13],
+	}
+	reader := bytes.NewReader(random)
+	encrypted, err := passphraseEncrypt(reader, params, passphrase, plaintext)
+	if err != nil {
+		t.Fatalf("Failed to encrypt vector: %v", err)
+	}
+	if reader.Len() != 0 {
+		t.Errorf("Expected all %d random bytes to be used, %d left", len(random), reader.Len())
+	}
+	if formatted := FormatSecret([]byte(base64.StdEncoding.EncodeToString(encrypted))); formatted != ciphertext {
+		t.Errorf("Expected ciphertext '%s', got '%s'", ciphertext, formatted)
+	}
+}
+
+// checkSSV3Vector checks an ssv3 vector both decrypts and is reproduced exactly by encryption
+func checkSSV3Vector(t *testing.T, seed []byte, sshKey string, plaintext, random []byte, ciphertext string) {
+	t.Helper()
+	identity, err := newEd25519Identity(ed25519.NewKeyFromSeed(seed))
+	if err != nil {
+		t.Fatalf("Failed to create identity: %v", err)
+	}
+	defer identity.Destroy()
+	if identity.PublicKey() != sshKey {
+		t.Errorf("Expected the seed's public key to be '%s', got '%s'", sshKey, identity.PublicKey())
+	}
+
+	encoded := unarmor(t, ciphertext, "secret_share_secret")
+	envelope, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatalf("Invalid ciphertext base64: %v", err)
+	}
+
+	decrypted, err := identity.DecryptSecret(envelope)
+	if err != nil {
+		t.Fatalf("Failed to decrypt vector: %v", err)
+	}
+	defer decrypted.Destroy()
+	if !bytes.Equal(decrypted.Bytes(), plaintext) {
+		t.Errorf("Expected plaintext %x, got %x", plaintext, decrypted.Bytes())
+	}
+
+	// Encrypting to the public key with the same randomness must give the same bytes
+	session, err := NewSenderSessionForSSHKey([]byte(sshKey))
+	if err != nil {
+		t.Fatalf("Failed to parse ssh public key: %v", err)
+	}
+	reader := bytes.NewReader(random)
+	encrypted, err := session.key.encrypt(reader, plaintext)
+	if err != nil {
+		t.Fatalf("Failed to encrypt vector: %v", err)
+	}
+	if reader.Len() != 0 {
+		t.Errorf("Expected all %d random bytes to be used, %d left", len(random), reader.Len())
+	}
+	if formatted := FormatSecret([]byte(base64.StdEncoding.EncodeToString(encrypted))); formatted != ciphertext {
+		t.Errorf("Expected ciphertext '%s', got '%s'", ciphertext, formatted)
+	}
+}
+
+// checkAgeVector checks an age vector both decrypts and is reproduced exactly by encryption
+func checkAgeVector(t *testing.T, identity, recipient string, plaintext, random []byte, ciphertext string) {
+	t.Helper()
+	scalar, err := parseAgeIdentity(identity)
+	if err != nil {
+		t.Fatalf("Failed to parse age identity: %v", err)
+	}
+	publicKey, err := ParseAgeRecipient(recipient)
+	if err != nil {
+		t.Fatalf("Failed to parse age recipient: %v", err)
+	}
+	derived, err := curve25519.X25519(scalar, curve25519.Basepoint)
+	if err != nil || !bytes.Equal(derived, publicKey) {
+		t.Fatalf("Expected the identity's recipient to be '%s', got '%s' (err: %v)", recipient, FormatAgeRecipient(derived), err)
+	}
+
+	encoded := unarmor(t, ciphertext, "secret_share_secret")
+	envelope, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatalf("Invalid ciphertext base64: %v", err)
+	}
+
+	decrypted, err := ageDecrypt(scalar, publicKey, envelope, func(size int) []byte { return make([]byte, size) })
+	if err != nil {
+		t.Fatalf("Failed to decrypt vector: %v", err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("Expected plaintext %x, got %x", plaintext, decrypted)
+	}
+
+	// Encrypting to the recipient with the same randomness must give the same bytes
+	reader := bytes.NewReader(random)
+	encrypted, err := ageEncrypt(reader, publicKey, plaintext)
+	if err != nil {
+		t.Fatalf("Failed to encrypt vector: %v", err)
+	}
+	if reader.Len() != 0 {
+		t.Errorf("Expected all %d random bytes to be used, %d left", len(random), reader.Len())
+	}
+	if formatted := FormatSecret([]byte(base64.StdEncoding.EncodeToString(encrypted))); formatted != ciphertext {
+		t.Errorf("Expected ciphertext '%s', got '%s'", ciphertext, formatted)
+	}
+}
+
+// checkSSV4Vector checks an ssv4 vector both decrypts and is reproduced exactly by encryption
+func checkSSV4Vector(t *testing.T, privateKey []byte, publicKey string, plaintext, random []byte, ciphertext string) {
+	t.Helper()
+	derived, err := curve25519.X25519(privateKey, curve25519.Basepoint)
+	if err != nil || FormatHPKEPublicKey(derived) != publicKey {
+		t.Fatalf("Expected the private key's public key to be '%s', got '%s' (err: %v)", publicKey, FormatHPKEPublicKey(derived), err)
+	}
+
+	encoded := unarmor(t, ciphertext, "secret_share_secret")
+	envelope, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(envelope) < hpkeHeaderSize {
+		t.Fatalf("Invalid ciphertext base64: %v", err)
+	}
+
+	decrypted, err := hpkeDecrypt(privateKey, derived, envelope, func(size int) []byte { return make([]byte, size) })
+	if err != nil {
+		t.Fatalf("Failed to decrypt vector: %v", err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("Expected plaintext %x, got %x", plaintext, decrypted)
+	}
+
+	// Encrypting to the key with the same AEAD and randomness must give the same bytes
+	reader := bytes.NewReader(random)
+	encrypted, err := hpkeEncrypt(reader, derived, binary.BigEndian.Uint16(envelope[4:6]), plaintext)
+	if err != nil {
+		t.Fatalf("Failed to encrypt vector: %v", err)
+	}
+	if reader.Len() != 0 {
+		t.Errorf("Expected all %d random bytes to be used, %d left", len(random), reader.Len())
+	}
+	if formatted := FormatSecret([]byte(base64.StdEncoding.EncodeToString(encrypted))); formatted != ciphertext {
+		t.Errorf("Expected ciphertext '%s', got '%s'", ciphertext, formatted)
+	}
+}
+
+// checkJWEVector checks a JWE vector both decrypts and is reproduced exactly by encryption
+func checkJWEVector(t *testing.T, privateKey *rsa.PrivateKey, plaintext, random []byte, token string) {
+	t.Helper()
+	if privateKey == nil {
+		t.Fatal("Unknown key")
+	}
+
+	decrypted, err := jweDecrypt(privateKey, []byte(token), func(size int) []byte { return make([]byte, size) })
+	if err != nil {
+		t.Fatalf("Failed to decrypt vector: %v", err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("Expected plaintext %x, got %x", plaintext, decrypted)
+	}
+
+	// Encrypting with the same randomness must give the same token
+	reader := bytes.NewReader(random)
+	encrypted, err := jweEncrypt(reader, &privateKey.PublicKey, plaintext)
+	if err != nil {
+		t.Fatalf("Failed to encrypt vector: %v", err)
+	}
+	if reader.Len() != 0 {
+		t.Errorf("Expected all %d random bytes to be used, %d left", len(random), reader.Len())
+	}
+	if encrypted != token {
+		t.Errorf("Expected JWE '%s', got '%s'", token, encrypted)
+	}
+}
+
+// writeVectors regenerates the vectors file with new keys and randomness
+func writeVectors(t *testing.T) {
+	t.Helper()
+	file := vectorFile{Description: vectorsDescription}
+
+	keys := make(map[string]*rsa.PrivateKey)
+	for _, k := range []struct {
+		name string
+		bits int
+	}{{"rsa-3072", 3072}, {"rsa-2048", 2048}} {
+		privateKey, err := rsa.GenerateKey(rand.Reader, k.bits)
+		if err != nil {
+			t.Fatalf("Failed to generate key: %v", err)
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+		if err != nil {
+			t.Fatalf("Failed to serialize private key: %v", err)
+		}
+		publicKeyBytes, err := PublicKeyToBytes(&privateKey.PublicKey)
+		if err != nil {
+			t.Fatalf("Failed to serialize public key: %v", err)
+		}
+		jwk, err := PublicKeyToJWK(&privateKey.PublicKey)
+		if err != nil {
+			t.Fatalf("Failed to convert public key: %v", err)
+		}
+		keys[k.name] = privateKey
+		file.Keys = append(file.Keys, vectorKey{
+			Name:       k.name,
+			PrivateKey: base64.StdEncoding.EncodeToString(der),
+			PublicKey:  FormatPublicKey([]byte(base64.StdEncoding.EncodeToString(publicKeyBytes))),
+			JWK:        string(jwk),
+		})
+	}
+
+	binary := make([]byte, 1024)
+	if _, err := rand.Read(binary); err != nil {
+		t.Fatalf("Failed to generate plaintext: %v", err)
+	}
+	fields, err := EncodeFields([]Field{{Key: "username", Value: []byte("admin")}, {Key: "password", Value: []byte("hunter2")}})
+	if err != nil {
+		t.Fatalf("Failed to encode fields: %v", err)
+	}
+
+	for _, v := range []struct {
+		name      string
+		key       string
+		plaintext []byte
+	}{
+		{"ssv1 empty secret", "rsa-3072", []byte{}},
+		{"ssv1 password", "rsa-3072", []byte("Xk2#pQ9!vL7@mN4$wR8&")},
+		{"ssv1 multi-line utf-8", "rsa-3072", []byte("-----BEGIN DATA-----\nhéllo wörld 🤫\n-----END DATA-----\n")},
+		{"ssv1 fields payload", "rsa-3072", fields},
+		{"ssv1 binary 1 KiB", "rsa-3072", binary},
+		{"ssv1 password with 2048-bit key", "rsa-2048", []byte("correct horse battery staple")},
+	} {
+		random := make([]byte, 32+32+12)
+		if _, err := rand.Read(random); err != nil {
+			t.Fatalf("Failed to generate randomness: %v", err)
+		}
+		encrypted, err := hybridEncrypt(bytes.NewReader(random), &keys[v.key].PublicKey, v.plaintext)
+		if err != nil {
+			t.Fatalf("Failed to encrypt vector: %v", err)
+		}
+		file.Vectors = append(file.Vectors, vector{
+			Name:       v.name,
+			Version:    "ssv1",
+			Key:        v.key,
+			Plaintext:  hex.EncodeToString(v.plaintext),
+			Random:     hex.EncodeToString(random),
+			Ciphertext: FormatSecret([]byte(base64.StdEncoding.EncodeToString(encrypted))),
+		})
+	}
+
+	file.Vectors = append(file.Vectors, ssv2Vectors(t)...)
+	file.Vectors = append(file.Vectors, ssv3Vectors(t)...)
+	file.Vectors = append(file.Vectors, ageVectors(t)...)
+	file.Vectors = append(file.Vectors, jweVectors(t, keys)...)
+	file.Vectors = append(file.Vectors, ssv4Vectors(t)...)
+
+	// Keep the tags readable rather than escaping < and >
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(file); err != nil {
+		t.Fatalf("Failed to encode vectors: %v", err)
+	}
+	if err := os.MkdirAll("testdata", 0755); err != nil {
+		t.Fatalf("Failed to create testdata: %v", err)
+	}
+	if err := os.WriteFile(vectorsPath, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write vectors: %v", err)
+	}
+}
+
+// ssv2Vectors generates passphrase vectors, with the default settings and with cheaper ones
+func ssv2Vectors(t *testing.T) []vector {
+	t.Helper()
+	var vectors []vector
+	for _, v := range []struct {
+		name       string
+		params     PassphraseParams
+		passphrase string
+		plaintext  []byte
+	}{
+		{"ssv2 password", DefaultPassphraseParams, "correct horse battery staple", []byte("Xk2#pQ9!vL7@mN4$wR8&")},
+		{"ssv2 empty secret", DefaultPassphraseParams, "correct horse battery staple", []byte{}},
+		{"ssv2 utf-8 passphrase and multi-line secret", DefaultPassphraseParams, "pässwörd 🤫", []byte("line1\nline2\n")},
+		{"ssv2 low memory settings", PassphraseParams{Time: 2, Memory: 1024, Threads: 1}, "hunter2", []byte("low memory")},
+	} {
+		random := make([]byte, passphraseSaltSize+12)
+		if _, err := rand.Read(random); err != nil {
+			t.Fatalf("Failed to generate randomness: %v", err)
+		}
+		encrypted, err := passphraseEncrypt(bytes.NewReader(random), v.params, []byte(v.passphrase), v.plaintext)
+		if err != nil {
+			t.Fatalf("Failed to encrypt vector: %v", err)
+		}
+		vectors = append(vectors, vector{
+			Name:       v.name,
+			Version:    "ssv2",
+			Passphrase: hex.EncodeToString([]byte(v.passphrase)),
+			Plaintext:  hex.EncodeToString(v.plaintext),
+			Random:     hex.EncodeToString(random),
+			Ciphertext: FormatSecret([]byte(base64.StdEncoding.EncodeToString(encrypted))),
+		})
+	}
+	return vectors
+}
+
+// ssv3Vectors generates vectors for secrets encrypted to ssh-ed25519 keys
+func ssv3Vectors(t *testing.T) []vector {
+	t.Helper()
+	var vectors []vector
+	for _, v := range []struct {
+		name      string
+		plaintext []byte
+	}{
+		{"ssv3 password", []byte("Xk2#pQ9!vL7@mN4$wR8&")},
+		{"ssv3 empty secret", []byte{}},
+		{"ssv3 multi-line utf-8", []byte("-----BEGIN DATA-----\nhéllo wörld 🤫\n-----END DATA-----\n")},
+	} {
+		publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatalf("Failed to generate key: %v", err)
+		}
+		sshKey, err := ssh.NewPublicKey(publicKey)
+		if err != nil {
+			t.Fatalf("Failed to convert key: %v", err)
+		}
+		authorizedKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshKey)))
+		session, err := NewSenderSessionForSSHKey([]byte(authorizedKey))
+		if err != nil {
+			t.Fatalf("Failed to parse ssh public key: %v", err)
+		}
+
+		random := make([]byte, 32+12)
+		if _, err := rand.Read(random); err != nil {
+			t.Fatalf("Failed to generate randomness: %v", err)
+		}
+		encrypted, err := session.key.encrypt(bytes.NewReader(random), v.plaintext)
+		if err != nil {
+			t.Fatalf("Failed to encrypt vector: %v", err)
+		}
+		vectors = append(vectors, vector{
+			Name:       v.name,
+			Version:    "ssv3",
+			SSHSeed:    hex.EncodeToString(privateKey.Seed()),
+			SSHKey:     authorizedKey,
+			Plaintext:  hex.EncodeToString(v.plaintext),
+			Random:     hex.EncodeToString(random),
+			Ciphertext: FormatSecret([]byte(base64.StdEncoding.EncodeToString(encrypted))),
+		})
+	}
+	return vectors
+}
+
+// ageVectors generates vectors for age files encrypted to an X25519 recipient
+func ageVectors(t *testing.T) []vector {
+	t.Helper()
+	var vectors []vector
+	for _, v := range []struct {
+		name      string
+		plaintext []byte
+	}{
+		{"age password", []byte("Xk2#pQ9!vL7@mN4$wR8&")},
+		{"age empty secret", []byte{}},
+		{"age fields payload", []byte(fieldsPayloadPrefix + `{"fields":[{"key":"username","value":"admin"},{"key":"password","value":"hunter2"}]}`)},
+	} {
+		scalar := make([]byte, curve25519.ScalarSize)
+		if _, err := rand.Read(scalar); err != nil {
+			t.Fatalf("Failed to generate identity: %v", err)
+		}
+		identity, err := formatAgeIdentity(scalar)
+		if err != nil {
+			t.Fatalf("Failed to format identity: %v", err)
+		}
+		publicKey, err := curve25519.X25519(scalar, curve25519.Basepoint)
+		if err != nil {
+			t.Fatalf("Failed to derive recipient: %v", err)
+		}
+
+		random := make([]byte, ageFileKeySize+curve25519.ScalarSize+ageNonceSize)
+		if _, err := rand.Read(random); err != nil {
+			t.Fatalf("Failed to generate randomness: %v", err)
+		}
+		encrypted, err := ageEncrypt(bytes.NewReader(random), publicKey, v.plaintext)
+		if err != nil {
+			t.Fatalf("Failed to encrypt vector: %v", err)
+		}
+		vectors = append(vectors, vector{
+			Name:         v.name,
+			Version:      "age",
+			AgeIdentity:  identity,
+			AgeRecipient: FormatAgeRecipient(publicKey),
+			Plaintext:    hex.EncodeToString(v.plaintext),
+			Random:       hex.EncodeToString(random),
+			Ciphertext:   FormatSecret([]byte(base64.StdEncoding.EncodeToString(encrypted))),
+		})
+	}
+	return vectors
+}
+
+// jweVectors generates vectors for JWEs encrypted to the RSA keys
+func jweVectors(t *testing.T, keys map[string]*rsa.PrivateKey) []vector {
+	t.Helper()
+	var vectors []vector
+	for _, v := range []struct {
+		name      string
+		key       string
+		plaintext []byte
+	}{
+		{"jwe password", "rsa-3072", []byte("Xk2#pQ9!vL7@mN4$wR8&")},
+		{"jwe empty secret", "rsa-3072", []byte{}},
+		{"jwe fields payload", "rsa-3072", []byte(fieldsPayloadPrefix + `{"fields":[{"key":"username","value":"admin"},{"key":"password","value":"hunter2"}]}`)},
+		{"jwe password with 2048-bit key", "rsa-2048", []byte("correct horse battery staple")},
+	} {
+		random := make([]byte, 32+32+12)
+		if _, err := rand.Read(random); err != nil {
+			t.Fatalf("Failed to generate randomness: %v", err)
+		}
+		token, err := jweEncrypt(bytes.NewReader(random), &keys[v.key].PublicKey, v.plaintext)
+		if err != nil {
+			t.Fatalf("Failed to encrypt vector: %v", err)
+		}
+		vectors = append(vectors, vector{
+			Name:       v.name,
+			Version:    "jwe",
+			Key:        v.key,
+			Plaintext:  hex.EncodeToString(v.plaintext),
+			Random:     hex.EncodeToString(random),
+			Ciphertext: token,
+		})
+	}
+	return vectors
+}
+
+// ssv4Vectors generates vectors for HPKE envelopes, with both AEADs
+func ssv4Vectors(t *testing.T) []vector {
+	t.Helper()
+	var vectors []vector
+	for _, v := range []struct {
+		name      string
+		aeadID    uint16
+		plaintext []byte
+	}{
+		{"ssv4 password", hpkeAES256GCM, []byte("Xk2#pQ9!vL7@mN4$wR8&")},
+		{"ssv4 empty secret", hpkeAES256GCM, []byte{}},
+		{"ssv4 fields payload", hpkeAES256GCM, []byte(fieldsPayloadPrefix + `{"fields":[{"key":"username","value":"admin"},{"key":"password","value":"hunter2"}]}`)},
+		{"ssv4 password with ChaCha20-Poly1305", hpkeChaCha20Poly1305, []byte("Xk2#pQ9!vL7@mN4$wR8&")},
+	} {
+		privateKey := make([]byte, curve25519.ScalarSize)
+		if _, err := rand.Read(privateKey); err != nil {
+			t.Fatalf("Failed to generate key: %v", err)
+		}
+		publicKey, err := curve25519.X25519(privateKey, curve25519.Basepoint)
+		if err != nil {
+			t.Fatalf("Failed to derive public key: %v", err)
+		}
+
+		random := make([]byte, curve25519.ScalarSize)
+		if _, err := rand.Read(random); err != nil {
+			t.Fatalf("Failed to generate randomness: %v", err)
+		}
+		encrypted, err := hpkeEncrypt(bytes.NewReader(random), publicKey, v.aeadID, v.plaintext)
+		if err != nil {
+			t.Fatalf("Failed to encrypt vector: %v", err)
+		}
+		vectors = append(vectors, vector{
+			Name:           v.name,
+			Version:        "ssv4",
+			HPKEPrivateKey: hex.EncodeToString(privateKey),
+			HPKEPublicKey:  FormatHPKEPublicKey(publicKey),
+			Plaintext:      hex.EncodeToString(v.plaintext),
+			Random:         hex.EncodeToString(random),
+			Ciphertext:     FormatSecret([]byte(base64.StdEncoding.EncodeToString(encrypted))),
+		})
+	}
+	return vectors
+}
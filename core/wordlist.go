@@ -0,0 +1,13 @@
+package core
+
+import (
+	_ "embed"
+	"strings"
+)
+
+//go:embed wordlist.txt
+var wordlistData string
+
+// Wordlist is the 2048 word BIP39 English word list.
+// Every word is unique within its first four letters, which keeps words easy to tell apart.
+var Wordlist = strings.Fields(wordlistData)
@@ -0,0 +1,348 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrTooLongForWords is returned for keys and secrets with too many words to read aloud
+var ErrTooLongForWords = errors.New("too long to read as words")
+
+// ErrWordChecksum is returned when words don't match their checksum word, because a word is
+// wrong or missing
+var ErrWordChecksum = errors.New("the words don't match their checksum word")
+
+// ErrUnknownWord is returned for a word that's not in the word list, or close to one in it
+var ErrUnknownWord = errors.New("unknown word")
+
+// wordBits is how many bits each word holds, as an index into the 2048 word list
+const wordBits = 11
+
+// wordsHashBits is how many bits of the checksum word are a hash of the data. Its last bit tells
+// the parser how many bytes the other words hold, as some counts of words fit two sizes.
+const wordsHashBits = wordBits - 1
+
+// maxWordsDataSize is the most data shown as words, about 70 words, which is still quick to read
+// aloud. An ssv4 key is 33 bytes, or 25 words with the checksum word.
+const maxWordsDataSize = 96
+
+// wordsKeyFlag is set in the version byte of a key's words, so a key isn't taken for a secret
+const wordsKeyFlag = 0x80
+
+// wordsPerLine is how many words are shown on each numbered line
+const wordsPerLine = 6
+
+// maxWordDistance is how many letters a misspelled word can be away from a word in the list, as
+// letters added, removed, changed or swapped with the next one
+const maxWordDistance = 2
+
+// maxWordReadings limits how many readings of misspelled words close to more than one word in
+// the list are checked against the checksum word. Its 10 bit hash passes about one wrong reading
+// in 1024, so only a few are tried, and input with more is rejected rather than guessed at.
+const maxWordReadings = 8
+
+// wordNumbers maps each word in the word list to its index
+var wordNumbers = func() map[string]int {
+	numbers := make(map[string]int, len(Wordlist))
+	for i, word := range Wordlist {
+		numbers[word] = i
+	}
+	return numbers
+}()
+
+// wordPrefixes maps the first four letters of each word in the word list to its index
+var wordPrefixes = func() map[string]int {
+	prefixes := make(map[string]int, len(Wordlist))
+	for i, word := range Wordlist {
+		prefixes[word[:min(len(word), 4)]] = i
+	}
+	return prefixes
+}()
+
+// FormatKeyWords formats a key, as found between its <secret_share_key> tags, as numbered lines
+// of words from the word list ending with a checksum word, so it can be read aloud or typed from a
+// printout. Only compact keys such as ssv4 keys fit, and others return ErrTooLongForWords.
+func FormatKeyWords(key string) (string, error) {
+	version, ok := wordsVersion([]byte(key))
+	if !ok {
+		return "", fmt.Errorf("invalid key: no version prefix")
+	}
+	data, err := base64.StdEncoding.DecodeString(key[4:])
+	if err != nil {
+		return "", fmt.Errorf("invalid key: %w", err)
+	}
+	return formatWords(append([]byte{version | wordsKeyFlag}, data...))
+}
+
+// FormatSecretWords formats an encrypted secret as numbered lines of words like FormatKeyWords.
+// Only small secrets in SecretShare's own envelopes fit, and others return ErrTooLongForWords.
+func FormatSecretWords(encryptedData []byte) (string, error) {
+	version, ok := wordsVersion(encryptedData)
+	if !ok {
+		return "", fmt.Errorf("only SecretShare envelopes can be shown as words")
+	}
+	return formatWords(append([]byte{version}, encryptedData[4:]...))
+}
+
+// ParseKeyWords parses the words of a key formatted by FormatKeyWords, and returns the key as
+// NewSenderSessionForKey takes it. Misspelled and shortened words are corrected where the checksum
+// word confirms it. Returns ErrUnknownWord or ErrWordChecksum if the words can't be read.
+func ParseKeyWords(input string) (string, error) {
+	data, err := parseWords(input)
+	if err != nil {
+		return "", err
+	}
+	if data[0]&wordsKeyFlag == 0 {
+		return "", fmt.Errorf("these words are an encrypted secret, not a key")
+	}
+	return fmt.Sprintf("ssv%d", data[0]&^wordsKeyFlag) + base64.StdEncoding.EncodeToString(data[1:]), nil
+}
+
+// ParseSecretWords parses the words of an encrypted secret formatted by FormatSecretWords, and
+// returns the encrypted secret. Words are corrected as for ParseKeyWords.
+func ParseSecretWords(input string) ([]byte, error) {
+	data, err := parseWords(input)
+	if err != nil {
+		return nil, err
+	}
+	if data[0]&wordsKeyFlag != 0 {
+		return nil, fmt.Errorf("these words are a key, not an encrypted secret")
+	}
+	return append([]byte(fmt.Sprintf("ssv%d", data[0])), data[1:]...), nil
+}
+
+// IsWords reports whether input looks like a key or secret as words, rather than text: a dozen
+// words or more, with nothing but line numbers and punctuation between them
+func IsWords(input string) bool {
+	words := 0
+	for _, field := range strings.Fields(input) {
+		field = strings.TrimRight(field, ".,:;)")
+		switch {
+		case field == "":
+		case strings.Trim(field, "0123456789") == "":
+		case len(field) <= 12 && strings.Trim(strings.ToLower(field), "abcdefghijklmnopqrstuvwxyz") == "":
+			words++
+		default:
+			return false
+		}
+	}
+	return words >= 12
+}
+
+// wordsVersion returns the version number of data starting with an "ssv" prefix
+func wordsVersion(data []byte) (byte, bool) {
+	if len(data) < 4 || !bytes.HasPrefix(data, []byte("ssv")) || data[3] < '1' || data[3] > '9' {
+		return 0, false
+	}
+	return data[3] - '0', true
+}
+
+// formatWords formats data as words and its checksum word, on numbered lines
+func formatWords(data []byte) (string, error) {
+	if len(data) > maxWordsDataSize {
+		return "", ErrTooLongForWords
+	}
+	indexes := wordIndexes(data)
+	checksum := wordsHash(data)
+	if len(data) != wordsDataSize(len(indexes)) {
+		checksum |= 1 << wordsHashBits
+	}
+	indexes = append(indexes, checksum)
+
+	var b strings.Builder
+	for i := 0; i < len(indexes); i += wordsPerLine {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%2d.", i+1)
+		for _, index := range indexes[i:min(i+wordsPerLine, len(indexes))] {
+			b.WriteString(" " + Wordlist[index])
+		}
+	}
+	return b.String(), nil
+}
+
+// parseWords reads the data in words formatted by formatWords, correcting misspelled words
+func parseWords(input string) ([]byte, error) {
+	tokens := strings.FieldsFunc(strings.ToLower(input), func(r rune) bool {
+		return r < 'a' || r > 'z'
+	})
+	if len(tokens) < 2 {
+		return nil, fmt.Errorf("too few words")
+	}
+	candidates := make([][]int, len(tokens))
+	for i, token := range tokens {
+		if candidates[i] = wordCandidates(token); len(candidates[i]) == 0 {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownWord, token)
+		}
+	}
+
+	// A misspelled word can be close to more than one word, so every reading is checked against
+	// the checksum word, and only one may match it
+	readings := 1
+	for _, words := range candidates {
+		if readings *= len(words); readings > maxWordReadings {
+			return nil, fmt.Errorf("%w: too many misspelled words to check", ErrWordChecksum)
+		}
+	}
+	var found []byte
+	choice := make([]int, len(tokens))
+	indexes := make([]int, len(tokens))
+	for {
+		for i := range indexes {
+			indexes[i] = candidates[i][choice[i]]
+		}
+		if data, ok := wordsData(indexes); ok {
+			if found != nil {
+				return nil, fmt.Errorf("%w: the misspelled words could be more than one key or secret", ErrWordChecksum)
+			}
+			found = data
+		}
+		if !nextWordChoice(choice, candidates) {
+			break
+		}
+	}
+	if found == nil {
+		return nil, ErrWordChecksum
+	}
+	return found, nil
+}
+
+// wordCandidates returns the indexes of the words token could be: the word itself or the word it
+// shortens, or else the word starting with its first four letters and the closest words within
+// maxWordDistance
+func wordCandidates(token string) []int {
+	if index, ok := wordNumbers[token]; ok {
+		return []int{index}
+	}
+	var candidates []int
+	if index, ok := wordPrefixes[token[:min(len(token), 4)]]; ok && len(token) >= 4 {
+		// Words are unique in their first four letters, so a shortened word is only that word
+		if strings.HasPrefix(Wordlist[index], token) {
+			return []int{index}
+		}
+		candidates = append(candidates, index)
+	}
+
+	// Tokens much longer than a word can't be close to it, so their distance isn't worth working out
+	best := maxWordDistance + 1
+	var closest []int
+	for i, word := range Wordlist {
+		if len(token) > len(word)+maxWordDistance {
+			continue
+		}
+		distance := editDistance(token, word)
+		if distance > maxWordDistance {
+			continue
+		}
+		if distance < best {
+			best, closest = distance, closest[:0]
+		}
+		if distance == best {
+			closest = append(closest, i)
+		}
+	}
+	for _, index := range closest {
+		if len(candidates) == 0 || candidates[0] != index {
+			candidates = append(candidates, index)
+		}
+	}
+	return candidates
+}
+
+// nextWordChoice moves choice on to the next combination of candidates, and reports false once
+// every combination was chosen
+func nextWordChoice(choice []int, candidates [][]int) bool {
+	for i := range choice {
+		if choice[i]++; choice[i] < len(candidates[i]) {
+			return true
+		}
+		choice[i] = 0
+	}
+	return false
+}
+
+// editDistance returns how many letters need to be added, removed, changed or swapped with the
+// next one to turn a into b (the optimal string alignment distance)
+func editDistance(a, b string) int {
+	rows := make([][]int, len(a)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(b)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			rows[i][j] = min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+	return rows[len(a)][len(b)]
+}
+
+// wordIndexes splits data into 11 bit word indexes, padding the last one with zero bits
+func wordIndexes(data []byte) []int {
+	var indexes []int
+	acc, bits := 0, 0
+	for _, b := range data {
+		acc, bits = acc<<8|int(b), bits+8
+		if bits >= wordBits {
+			bits -= wordBits
+			indexes = append(indexes, acc>>bits)
+			acc &= 1<<bits - 1
+		}
+	}
+	if bits > 0 {
+		indexes = append(indexes, acc<<(wordBits-bits))
+	}
+	return indexes
+}
+
+// wordsData returns the data in word indexes followed by their checksum word, and false if the
+// checksum word doesn't match them
+func wordsData(indexes []int) ([]byte, bool) {
+	count, checksum := len(indexes)-1, indexes[len(indexes)-1]
+	size := wordsDataSize(count) - checksum>>wordsHashBits
+	if size < 1 || (size*8+wordBits-1)/wordBits != count {
+		return nil, false
+	}
+
+	data := make([]byte, 0, size)
+	acc, bits := 0, 0
+	for _, index := range indexes[:count] {
+		acc, bits = acc<<wordBits|index, bits+wordBits
+		for bits >= 8 && len(data) < size {
+			bits -= 8
+			data = append(data, byte(acc>>bits))
+		}
+		acc &= 1<<bits - 1
+	}
+	// The padding bits are always zero
+	if acc != 0 || wordsHash(data) != checksum&(1<<wordsHashBits-1) {
+		return nil, false
+	}
+	return data, true
+}
+
+// wordsDataSize returns the most bytes count words hold
+func wordsDataSize(count int) int {
+	return count * wordBits / 8
+}
+
+// wordsHash returns the hash bits of the checksum word of data: the first bits of its SHA-256
+func wordsHash(data []byte) int {
+	sum := sha256.Sum256(data)
+	return (int(sum[0])<<8 | int(sum[1])) >> (16 - wordsHashBits)
+}
@@ -0,0 +1,305 @@
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestKeyWords(t *testing.T) {
+	receiver, err := NewHPKEReceiverSession()
+	if err != nil {
+		t.Fatalf("Failed to create receiver session: %v", err)
+	}
+	defer receiver.Destroy()
+	key := "ssv4" + base64.StdEncoding.EncodeToString(receiver.key.(*hpkeKey).publicKey)
+
+	// Test case 1: An ssv4 key is 25 words on numbered lines, the last one its checksum word
+	words, err := FormatKeyWords(key)
+	if err != nil {
+		t.Fatalf("Test 1 failed: Failed to format key: %v", err)
+	}
+	lines := strings.Split(words, "\n")
+	if len(strings.Fields(words)) != 25+len(lines) || !strings.HasPrefix(lines[0], " 1. ") || !strings.HasPrefix(lines[4], "25. ") {
+		t.Errorf("Test 1 failed: Expected 25 numbered words, got '%s'", words)
+	}
+
+	// Test case 2: The words read back as the key, which a sender can encrypt to
+	parsed, err := ParseKeyWords(words)
+	if err != nil || parsed != key {
+		t.Fatalf("Test 2 failed: Expected '%s', got '%s' (err: %v)", key, parsed, err)
+	}
+	if _, err := NewSenderSessionForKey(parsed); err != nil {
+		t.Errorf("Test 2 failed: Failed to create sender session: %v", err)
+	}
+
+	// Test case 3: Words typed without numbers, in any case, on one line
+	typed := strings.ToUpper(strings.Join(strings.FieldsFunc(words, func(r rune) bool {
+		return r < 'a' || r > 'z'
+	}), " "))
+	if parsed, err := ParseKeyWords(typed); err != nil || parsed != key {
+		t.Errorf("Test 3 failed: Expected '%s', got '%s' (err: %v)", key, parsed, err)
+	}
+
+	// Test case 4: Key words aren't a secret
+	if _, err := ParseSecretWords(words); err == nil {
+		t.Error("Test 4 failed: Expected an error for a key's words as a secret")
+	}
+
+	// Test case 5: RSA keys are too long to read aloud
+	rsaReceiver, err := NewReceiverSession()
+	if err != nil {
+		t.Fatalf("Test 5 failed: Failed to create receiver session: %v", err)
+	}
+	defer rsaReceiver.Destroy()
+	rsaKey, err := rsaReceiver.SharedKey()
+	if err != nil {
+		t.Fatalf("Test 5 failed: Failed to format key: %v", err)
+	}
+	rsaKey, err = Dearmor(strings.Join(strings.Split(rsaKey, "\n")[1:strings.Count(rsaKey, "\n")], ""))
+	if err != nil {
+		t.Fatalf("Test 5 failed: Failed to dearmor key: %v", err)
+	}
+	if _, err := FormatKeyWords(rsaKey); !errors.Is(err, ErrTooLongForWords) {
+		t.Errorf("Test 5 failed: Expected ErrTooLongForWords, got %v", err)
+	}
+}
+
+func TestSecretWords(t *testing.T) {
+	receiver, err := NewHPKEReceiverSession()
+	if err != nil {
+		t.Fatalf("Failed to create receiver session: %v", err)
+	}
+	defer receiver.Destroy()
+	sender, err := NewSenderSessionForHPKEKey(receiver.key.(*hpkeKey).publicKey)
+	if err != nil {
+		t.Fatalf("Failed to create sender session: %v", err)
+	}
+
+	// Test case 1: A short secret reads back and decrypts
+	secret := []byte("Xk2#pQ9!vL7@mN4$wR8&")
+	encrypted, err := sender.EncryptSecret(secret)
+	if err != nil {
+		t.Fatalf("Test 1 failed: Failed to encrypt: %v", err)
+	}
+	words, err := FormatSecretWords(encrypted)
+	if err != nil {
+		t.Fatalf("Test 1 failed: Failed to format secret: %v", err)
+	}
+	parsed, err := ParseSecretWords(words)
+	if err != nil || !bytes.Equal(parsed, encrypted) {
+		t.Fatalf("Test 1 failed: Expected the encrypted secret back (err: %v)", err)
+	}
+	decrypted, err := receiver.DecryptSecret(parsed)
+	if err != nil || !bytes.Equal(decrypted.Bytes(), secret) {
+		t.Errorf("Test 1 failed: Expected '%s' to decrypt (err: %v)", secret, err)
+	}
+	if decrypted != nil {
+		decrypted.Destroy()
+	}
+
+	// Test case 2: Secret words aren't a key
+	if _, err := ParseKeyWords(words); err == nil {
+		t.Error("Test 2 failed: Expected an error for a secret's words as a key")
+	}
+
+	// Test case 3: Long secrets, and envelopes that aren't SecretShare's, can't be words
+	long, err := sender.EncryptSecret(make([]byte, 100))
+	if err != nil {
+		t.Fatalf("Test 3 failed: Failed to encrypt: %v", err)
+	}
+	if _, err := FormatSecretWords(long); !errors.Is(err, ErrTooLongForWords) {
+		t.Errorf("Test 3 failed: Expected ErrTooLongForWords, got %v", err)
+	}
+	if _, err := FormatSecretWords([]byte("age-encryption.org/v1")); err == nil {
+		t.Error("Test 3 failed: Expected an error for an age file")
+	}
+}
+
+func TestWordsSizes(t *testing.T) {
+	// Every size reads back, including those where two sizes have the same number of words
+	for size := 1; size <= maxWordsDataSize; size++ {
+		data := make([]byte, size)
+		for i := range data {
+			data[i] = byte(i*37 + size)
+		}
+		words, err := formatWords(data)
+		if err != nil {
+			t.Fatalf("Failed to format %d bytes: %v", size, err)
+		}
+		if count := len(strings.Fields(words)) - (len(strings.Split(words, "\n"))); count != (size*8+10)/11+1 {
+			t.Errorf("Expected %d words for %d bytes, got %d", (size*8+10)/11+1, size, count)
+		}
+		parsed, err := parseWords(words)
+		if err != nil || !bytes.Equal(parsed, data) {
+			t.Errorf("Expected %d bytes to read back (err: %v)", size, err)
+		}
+	}
+	if _, err := formatWords(make([]byte, maxWordsDataSize+1)); !errors.Is(err, ErrTooLongForWords) {
+		t.Errorf("Expected ErrTooLongForWords, got %v", err)
+	}
+}
+
+func TestParseWordsCorrections(t *testing.T) {
+	data := []byte("ssv4 words test data")
+	words, err := formatWords(data)
+	if err != nil {
+		t.Fatalf("Failed to format: %v", err)
+	}
+	list := strings.FieldsFunc(words, func(r rune) bool { return r < 'a' || r > 'z' })
+
+	// replaced returns the words with the word at i replaced
+	replaced := func(i int, word string) string {
+		changed := append([]string{}, list...)
+		changed[i] = word
+		return strings.Join(changed, " ")
+	}
+
+	// Test case 1: Words shortened to their first four letters
+	shortened := make([]string, len(list))
+	for i, word := range list {
+		shortened[i] = word[:min(len(word), 4)]
+	}
+	if parsed, err := parseWords(strings.Join(shortened, " ")); err != nil || !bytes.Equal(parsed, data) {
+		t.Errorf("Test 1 failed: Expected shortened words to read back (err: %v)", err)
+	}
+
+	// Test case 2: Misspelled words, with letters added, removed, changed or swapped
+	misspell := []func(string) string{
+		func(word string) string { return word + "e" },
+		func(word string) string { return word[:1] + word[2:] },
+		func(word string) string { return word[:1] + "q" + word[2:] },
+		func(word string) string { return word[:1] + word[2:3] + word[1:2] + word[3:] },
+	}
+	for i, word := range list {
+		if len(word) < 4 {
+			continue
+		}
+		for j, change := range misspell {
+			if parsed, err := parseWords(replaced(i, change(word))); err != nil || !bytes.Equal(parsed, data) {
+				t.Errorf("Test 2 failed: Expected '%s' to be corrected to '%s' (change %d, err: %v)", change(word), word, j+1, err)
+			}
+		}
+	}
+
+	// Test case 3: Words not close to any in the list are reported
+	if _, err := parseWords(replaced(2, "xyzzyq")); !errors.Is(err, ErrUnknownWord) || !strings.Contains(err.Error(), "xyzzyq") {
+		t.Errorf("Test 3 failed: Expected ErrUnknownWord for 'xyzzyq', got %v", err)
+	}
+
+	// Test case 4: A wrong or missing word fails the checksum
+	other := Wordlist[(wordNumbers[list[3]]+1)%len(Wordlist)]
+	if _, err := parseWords(replaced(3, other)); !errors.Is(err, ErrWordChecksum) {
+		t.Errorf("Test 4 failed: Expected ErrWordChecksum for a wrong word, got %v", err)
+	}
+	if _, err := parseWords(strings.Join(append(append([]string{}, list[:5]...), list[6:]...), " ")); !errors.Is(err, ErrWordChecksum) {
+		t.Errorf("Test 4 failed: Expected ErrWordChecksum for a missing word, got %v", err)
+	}
+}
+
+func TestParseWordsAmbiguous(t *testing.T) {
+	// Test case 1: Several misspellings with many readings are rejected rather than guessed at
+	for n := 0; n < 200; n++ {
+		data := []byte{4, byte(n), byte(n * 7), byte(n * 13), 0x5a, 0xa5, byte(n >> 3), 0x42}
+		words, err := formatWords(data)
+		if err != nil {
+			t.Fatalf("Test 1 failed: Failed to format: %v", err)
+		}
+		list := strings.FieldsFunc(words, func(r rune) bool { return r < 'a' || r > 'z' })
+		for i := 0; i < 3; i++ {
+			list[i] = list[i][:2]
+		}
+		parsed, err := parseWords(strings.Join(list, " "))
+		if !errors.Is(err, ErrWordChecksum) {
+			t.Fatalf("Test 1 failed: Expected ErrWordChecksum, got %x (err: %v)", parsed, err)
+		}
+	}
+
+	// Test case 2: Readings are checked in full, so a wrong one never beats the right one
+	data := []byte("ssv4 ambiguous")
+	words, err := formatWords(data)
+	if err != nil {
+		t.Fatalf("Test 2 failed: Failed to format: %v", err)
+	}
+	list := strings.FieldsFunc(words, func(r rune) bool { return r < 'a' || r > 'z' })
+	for i, word := range list {
+		changed := append([]string{}, list...)
+		changed[i] = word[:1] + word[2:]
+		parsed, err := parseWords(strings.Join(changed, " "))
+		if err == nil && !bytes.Equal(parsed, data) {
+			t.Errorf("Test 2 failed: Expected '%s' to read back or fail, got %x", changed[i], parsed)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		distance int
+	}{
+		{"abandon", "abandon", 0},
+		{"abandn", "abandon", 1},
+		{"abnadon", "abandon", 1},
+		{"abendon", "abandon", 1},
+		{"zoo", "", 3},
+		{"ca", "abc", 3},
+	}
+	for i, tc := range testCases {
+		if distance := editDistance(tc.a, tc.b); distance != tc.distance {
+			t.Errorf("Test %d failed: Expected %d between '%s' and '%s', got %d", i+1, tc.distance, tc.a, tc.b, distance)
+		}
+	}
+}
+
+func TestIsWords(t *testing.T) {
+	// Test case 1: Words on numbered lines, or typed on one line
+	for _, input := range []string{
+		" 1. abandon ability able about above absent\n 7. absorb abstract absurd abuse access accident",
+		"Abandon ability able about above absent absorb abstract absurd abuse access accident",
+	} {
+		if !IsWords(input) {
+			t.Errorf("Test 1 failed: Expected '%s' to be words", input)
+		}
+	}
+
+	// Test case 2: Keys, secrets, paths and too few words aren't
+	for _, input := range []string{
+		"<secret_share_key>\nssv4MCowBQYDK2VuAyEAq7Hb9SPtqsYdWXuxRzvBqxlrN3Tx6HxZ0S8FxBHnvlA=\n=Ab3d\n</secret_share_key>",
+		"ssv4MCowBQYDK2VuAyEAq7Hb9SPtqsYdWXuxRzvBqxlrN3Tx6HxZ0S8FxBHnvlA=",
+		"/tmp/key.png",
+		"abandon ability able about",
+		"",
+	} {
+		if IsWords(input) {
+			t.Errorf("Test 2 failed: Expected '%s' not to be words", input)
+		}
+	}
+}
+
+func FuzzParseWords(f *testing.F) {
+	words, err := formatWords([]byte("ssv4 fuzz"))
+	if err != nil {
+		f.Fatalf("Failed to format: %v", err)
+	}
+	f.Add(words)
+	f.Add("abandon abandon")
+	f.Add("abnadon zoo 1. xyz")
+	f.Add("")
+
+	f.Fuzz(func(t *testing.T, input string) {
+		data, err := parseWords(input)
+		if err != nil {
+			return
+		}
+		// Words that read back format as the same data
+		formatted, err := formatWords(data)
+		if err != nil {
+			t.Fatalf("Failed to format %x: %v", data, err)
+		}
+		if again, err := parseWords(formatted); err != nil || !bytes.Equal(again, data) {
+			t.Errorf("Expected %x to read back (err: %v)", data, err)
+		}
+	})
+}
@@ -6,4 +6,6 @@ toolchain go1.23.5
 
 require golang.org/x/term v0.34.0
 
-require golang.org/x/sys v0.35.0 // indirect
+require golang.org/x/sys v0.35.0
+
+require golang.org/x/crypto v0.41.0
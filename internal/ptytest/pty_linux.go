@@ -0,0 +1,60 @@
+package ptytest
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Supported reports whether pseudo-terminals can be opened on this platform
+const Supported = true
+
+// Open opens a new pseudo-terminal pair, sized like a real terminal
+func Open() (master, slave *os.File, err error) {
+	master, err = os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	fd := int(master.Fd())
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("failed to unlock pseudo-terminal: %w", err)
+	}
+	// A real terminal has a size, which the app needs to redraw lines in place
+	if err := unix.IoctlSetWinsize(fd, unix.TIOCSWINSZ, &unix.Winsize{Row: 24, Col: 80}); err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("failed to set pseudo-terminal size: %w", err)
+	}
+	n, err := unix.IoctlGetInt(fd, unix.TIOCGPTN)
+	if err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("failed to get pseudo-terminal number: %w", err)
+	}
+	slave, err = os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+	return master, slave, nil
+}
+
+// Attach attaches a command's stdin, stdout and stderr to the slave side of a pseudo-terminal, in
+// a new session with it as the controlling terminal so Ctrl-C sends SIGINT
+func Attach(cmd *exec.Cmd, slave *os.File) {
+	cmd.Stdin = slave
+	cmd.Stdout = slave
+	cmd.Stderr = slave
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+}
+
+// LocalModes reports whether a pseudo-terminal echoes input and buffers it by line
+func LocalModes(master *os.File) (echo, canonical bool, err error) {
+	termios, err := unix.IoctlGetTermios(int(master.Fd()), unix.TCGETS)
+	if err != nil {
+		return false, false, err
+	}
+	return termios.Lflag&unix.ECHO != 0, termios.Lflag&unix.ICANON != 0, nil
+}
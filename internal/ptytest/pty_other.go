@@ -0,0 +1,32 @@
+//go:build !linux
+
+package ptytest
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// Supported reports whether pseudo-terminals can be opened on this platform
+const Supported = false
+
+// errUnsupported is returned by everything that needs a pseudo-terminal
+var errUnsupported = errors.New("pseudo-terminals are only supported on Linux")
+
+// Open fails, since pseudo-terminals are only supported on Linux
+func Open() (master, slave *os.File, err error) {
+	return nil, nil, errUnsupported
+}
+
+// Attach attaches a command's stdin, stdout and stderr to the slave side of a pseudo-terminal
+func Attach(cmd *exec.Cmd, slave *os.File) {
+	cmd.Stdin = slave
+	cmd.Stdout = slave
+	cmd.Stderr = slave
+}
+
+// LocalModes fails, since pseudo-terminals are only supported on Linux
+func LocalModes(master *os.File) (echo, canonical bool, err error) {
+	return false, false, errUnsupported
+}
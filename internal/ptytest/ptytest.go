@@ -0,0 +1,4 @@
+// Package ptytest opens pseudo-terminals for tests that drive secret_share like a user at a
+// terminal. Pseudo-terminals are only supported on Linux; elsewhere Supported is false and
+// opening one fails.
+package ptytest
@@ -0,0 +1,81 @@
+package tui
+
+import "time"
+
+// Console is the interactive terminal the app's flows talk to: prompts, output and the clipboard.
+// Terminal is the real implementation; ScriptedConsole replays answers for tests.
+type Console interface {
+	// PromptUser displays a prompt and reads a line of input
+	PromptUser(prompt string) string
+	// PromptBlob displays a prompt and reads a pasted key or encrypted secret
+	PromptBlob(prompt string) string
+	// PromptSecret displays a prompt and reads a line of input without showing it.
+	// Returns nil if the input could not be read.
+	PromptSecret(prompt string) []byte
+	// PromptSecretMultiline displays a prompt and reads multiple lines without showing them.
+	// Returns nil if the input could not be read.
+	PromptSecretMultiline(prompt string) []byte
+	// PromptUserSingleChar displays a prompt and reads a single key press
+	PromptUserSingleChar(prompt string) string
+	// SetDeadline makes prompts stop waiting for input at deadline, showing the time left while
+	// they wait. Prompts that time out are answered with TimeoutAnswer, or nil for secrets.
+	// A zero time waits forever.
+	SetDeadline(deadline time.Time)
+	// TimedOut reports whether a prompt stopped waiting because the deadline passed
+	TimedOut() bool
+
+	PrintMessage(message string)
+	PrintHeader(message string)
+	PrintError(message string)
+	PrintSuccess(message string)
+	PrintInfo(message string)
+	PrintWarning(message string)
+	PrintTable(headers []string, rows [][]string)
+	// PrintSecretTable displays a table of secret values, without copying them to a string
+	PrintSecretTable(headers []string, rows [][][]byte)
+	// PrintQRCode displays a QR code rendered as text, dark modules drawn with block characters
+	PrintQRCode(code string)
+	// PrintSecret displays a success message followed by a secret, without copying it to a string
+	PrintSecret(message string, secret []byte)
+	// PrintStatus displays a transient status message, removed by ClearStatus
+	PrintStatus(message string)
+	ClearStatus()
+
+	// SetClipboard copies text to the clipboard
+	SetClipboard(text string) error
+}
+
+// Terminal is the Console for the user's real terminal, using stdin, the configured output and the system clipboard
+type Terminal struct{}
+
+// NewTerminal returns a Console for the user's real terminal
+func NewTerminal() Console {
+	return Terminal{}
+}
+
+func (Terminal) PromptUser(prompt string) string                    { return PromptUser(prompt) }
+func (Terminal) PromptBlob(prompt string) string                    { return PromptBlob(prompt) }
+func (Terminal) PromptSecret(prompt string) []byte                  { return PromptSecret(prompt) }
+func (Terminal) PromptSecretMultiline(prompt string) []byte         { return PromptSecretMultiline(prompt) }
+func (Terminal) PromptUserSingleChar(prompt string) string          { return PromptUserSingleChar(prompt) }
+func (Terminal) SetDeadline(deadline time.Time)                     { SetDeadline(deadline) }
+func (Terminal) TimedOut() bool                                     { return TimedOut() }
+func (Terminal) PrintMessage(message string)                        { PrintMessage(message) }
+func (Terminal) PrintHeader(message string)                         { PrintHeader(message) }
+func (Terminal) PrintError(message string)                          { PrintError(message) }
+func (Terminal) PrintSuccess(message string)                        { PrintSuccess(message) }
+func (Terminal) PrintInfo(message string)                           { PrintInfo(message) }
+func (Terminal) PrintWarning(message string)                        { PrintWarning(message) }
+func (Terminal) PrintTable(headers []string, rows [][]string)       { PrintTable(headers, rows) }
+func (Terminal) PrintSecretTable(headers []string, rows [][][]byte) { PrintSecretTable(headers, rows) }
+func (Terminal) PrintQRCode(code string)                            { PrintQRCode(code) }
+func (Terminal) PrintSecret(message string, secret []byte)          { PrintSecret(message, secret) }
+func (Terminal) PrintStatus(message string)                         { PrintStatus(message) }
+func (Terminal) ClearStatus()                                       { ClearStatus() }
+func (Terminal) SetClipboard(text string) error                     { return SetClipboard(text) }
+
+// Both consoles must implement the full interface
+var (
+	_ Console = Terminal{}
+	_ Console = (*ScriptedConsole)(nil)
+)
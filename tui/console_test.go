@@ -0,0 +1,109 @@
+package tui
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScriptedConsoleAnswers(t *testing.T) {
+	console := NewScriptedConsole("first", "s")
+	console.AnswerWith(func(prompt string) string {
+		return "computed for " + prompt
+	})
+
+	if answer := console.PromptUser("Name: "); answer != "first" {
+		t.Errorf("Expected 'first', got '%s'", answer)
+	}
+	if answer := console.PromptUserSingleChar("Choice: "); answer != "s" {
+		t.Errorf("Expected 's', got '%s'", answer)
+	}
+	if answer := console.PromptBlob("Key: "); answer != "computed for Key: " {
+		t.Errorf("Expected 'computed for Key: ', got '%s'", answer)
+	}
+	if console.Remaining() != 0 {
+		t.Errorf("Expected no remaining answers, got %d", console.Remaining())
+	}
+
+	// Once the script runs out, prompts are answered with quit
+	if answer := console.PromptSecret("Secret: "); !IsQuitSecret(answer) {
+		t.Errorf("Expected a quit answer, got '%s'", answer)
+	}
+	if len(console.Unanswered) != 1 || console.Unanswered[0] != "Secret: " {
+		t.Errorf("Expected 'Secret: ' to be recorded as unanswered, got %v", console.Unanswered)
+	}
+}
+
+func TestScriptedConsoleTranscript(t *testing.T) {
+	console := NewScriptedConsole("answer")
+	console.PrintInfo("Some info")
+	console.PromptUser("Question? ")
+	console.PrintError("Something failed")
+	console.PrintTable([]string{"NAME", "VALUE"}, [][]string{{"a", "1"}})
+
+	expected := "Some info\nQuestion? \nError: Something failed\nNAME  VALUE\na     1\n\n"
+	if console.Transcript() != expected {
+		t.Errorf("Expected transcript %q, got %q", expected, console.Transcript())
+	}
+	if strings.Contains(console.Transcript(), "\033[") {
+		t.Error("Transcript should not contain terminal escape codes")
+	}
+}
+
+func TestScriptedConsoleClipboard(t *testing.T) {
+	console := NewScriptedConsole()
+	if err := console.SetClipboard("copied"); err != nil {
+		t.Fatalf("Failed to set clipboard: %v", err)
+	}
+	if console.Clipboard != "copied" {
+		t.Errorf("Expected clipboard to be 'copied', got '%s'", console.Clipboard)
+	}
+
+	// Simulate a machine without a clipboard tool
+	console.ClipboardErr = errors.New("no clipboard")
+	if err := console.SetClipboard("other"); err == nil {
+		t.Error("Expected clipboard error")
+	}
+	if console.Clipboard != "copied" {
+		t.Errorf("Clipboard should be unchanged after an error, got '%s'", console.Clipboard)
+	}
+}
+
+func TestScriptedConsoleTimeout(t *testing.T) {
+	// Test case 1: A scripted timeout answers as quit, and every later prompt times out too
+	console := NewScriptedConsole("s")
+	console.AnswerTimeout()
+	console.Answer("never used")
+	if answer := console.PromptUserSingleChar("Choice: "); answer != "s" || console.TimedOut() {
+		t.Errorf("Test 1 failed: Expected 's' without a timeout, got '%s'", answer)
+	}
+	if answer := console.PromptBlob("Key: "); answer != TimeoutAnswer || !IsQuit(answer) {
+		t.Errorf("Test 1 failed: Expected a timeout answer, got '%s'", answer)
+	}
+	if !console.TimedOut() {
+		t.Error("Test 1 failed: Expected the console to report a timeout")
+	}
+	if secret := console.PromptSecret("Secret: "); secret != nil {
+		t.Errorf("Test 1 failed: Expected no secret after the timeout, got '%s'", secret)
+	}
+	if console.Remaining() != 1 {
+		t.Errorf("Test 1 failed: Expected the last answer to be left, %d left", console.Remaining())
+	}
+
+	// Test case 2: Prompts shown after the deadline time out
+	console = NewScriptedConsole("answer")
+	console.SetDeadline(time.Now().Add(-time.Second))
+	if answer := console.PromptUser("Name: "); answer != TimeoutAnswer || !console.TimedOut() {
+		t.Errorf("Test 2 failed: Expected a timeout, got '%s'", answer)
+	}
+	if !strings.Contains(console.Transcript(), "(timed out)") {
+		t.Errorf("Test 2 failed: Expected the timeout in the transcript:\n%s", console.Transcript())
+	}
+
+	// Test case 3: Clearing the deadline answers prompts again
+	console.SetDeadline(time.Time{})
+	if answer := console.PromptUser("Name: "); answer != "answer" || console.TimedOut() {
+		t.Errorf("Test 3 failed: Expected 'answer', got '%s'", answer)
+	}
+}
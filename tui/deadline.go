@@ -0,0 +1,268 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// errTimedOut is returned by input reads once the prompt deadline has passed
+var errTimedOut = errors.New("timed out waiting for input")
+
+// TimeoutAnswer is what a prompt returns when it times out or the app is interrupted, so flows
+// stop as if the user quit
+const TimeoutAnswer = "q"
+
+var (
+	// deadline is when prompts stop waiting for input, or zero to wait forever
+	deadline time.Time
+	// timedOut is set once a prompt stops waiting because the deadline passed
+	timedOut bool
+	// activeCountdown is the countdown shown above the prompt waiting for input, if any
+	activeCountdown *countdown
+)
+
+var (
+	// interruptMu guards interrupted and interrupts, which Interrupt sets from a signal handler
+	interruptMu sync.Mutex
+	// interrupted is set once the app is interrupted
+	interrupted bool
+	// interrupts is closed when the app is interrupted, waking the read a prompt is waiting on
+	interrupts = make(chan struct{})
+)
+
+// SetDeadline makes prompts stop waiting for input at d, showing the time left above each prompt
+// while it waits. A zero time waits forever.
+func SetDeadline(d time.Time) {
+	deadline = d
+	timedOut = false
+}
+
+// TimedOut reports whether a prompt stopped waiting because the deadline passed
+func TimedOut() bool {
+	return timedOut
+}
+
+// Interrupt makes the prompt waiting for input, and every prompt after it, stop as if the user
+// quit, so flows unwind through their cleanup. Called when the app is asked to shut down.
+func Interrupt() {
+	interruptMu.Lock()
+	defer interruptMu.Unlock()
+	if !interrupted {
+		interrupted = true
+		close(interrupts)
+	}
+}
+
+// Interrupted reports whether Interrupt was called, or Ctrl+C was pressed at a prompt
+func Interrupted() bool {
+	interruptMu.Lock()
+	defer interruptMu.Unlock()
+	return interrupted
+}
+
+// interruptSignal returns a channel closed by Interrupt
+func interruptSignal() <-chan struct{} {
+	interruptMu.Lock()
+	defer interruptMu.Unlock()
+	return interrupts
+}
+
+// promptsStopped reports whether prompts stop waiting for input because the app was interrupted
+// or the deadline passed
+func promptsStopped() bool {
+	return Interrupted() || deadlinePassed()
+}
+
+// stoppedWaiting reports whether a read stopped because the app was interrupted or the deadline
+// passed, recording a Ctrl+C pressed in raw mode as an interrupt
+func stoppedWaiting(err error) bool {
+	if errors.Is(err, errInterrupted) {
+		Interrupt()
+		return true
+	}
+	return errors.Is(err, errTimedOut)
+}
+
+// deadlinePassed reports whether the deadline has passed, and if so records that prompts timed out
+func deadlinePassed() bool {
+	if deadline.IsZero() || time.Now().Before(deadline) {
+		return false
+	}
+	timedOut = true
+	return true
+}
+
+// readResult is the outcome of one read of the underlying input
+type readResult struct {
+	data []byte
+	err  error
+}
+
+// deadlineReader reads input in the background, so a prompt can stop waiting at the deadline or
+// when the app is interrupted, and update its countdown while it waits. Input is only read when a
+// prompt asks for it, so nothing is taken from commands that share the terminal later.
+type deadlineReader struct {
+	r       io.Reader
+	pending chan readResult // the read in progress, or nil
+}
+
+func (d *deadlineReader) Read(p []byte) (int, error) {
+	if Interrupted() {
+		return 0, errInterrupted
+	}
+
+	if d.pending == nil {
+		// A read that outlives the deadline completes in the background, and is returned next time
+		d.pending = make(chan readResult, 1)
+		go func(result chan<- readResult, size int) {
+			buf := make([]byte, size)
+			n, err := d.r.Read(buf)
+			result <- readResult{buf[:n], err}
+		}(d.pending, len(p))
+	}
+
+	var expired <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		expired = timer.C
+	}
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case result := <-d.pending:
+			d.pending = nil
+			n := copy(p, result.data)
+			clear(result.data)
+			return n, result.err
+		case <-interruptSignal():
+			return 0, errInterrupted
+		case <-expired:
+			timedOut = true
+			return 0, errTimedOut
+		case <-ticker.C:
+			if activeCountdown != nil {
+				activeCountdown.redraw()
+			}
+		}
+	}
+}
+
+// countdown shows the time left before the deadline on its own line above a prompt, and redraws
+// it while the prompt waits. Prompts write through it so it knows how far below the line they are.
+type countdown struct {
+	out   io.Writer
+	below []byte // everything written since the countdown line
+}
+
+// beginPrompt starts a prompt on a new line, first showing the time left if a deadline is set.
+// The prompt writes through the returned writer, and calls endPrompt once it has its input.
+func beginPrompt() io.Writer {
+	fmt.Fprintln(output)
+	if deadline.IsZero() {
+		return output
+	}
+
+	fmt.Fprintln(output, countdownText(time.Until(deadline)))
+	activeCountdown = &countdown{out: output}
+	return activeCountdown
+}
+
+// endPrompt stops updating the countdown shown by beginPrompt
+func endPrompt() {
+	activeCountdown = nil
+}
+
+func (c *countdown) Write(p []byte) (int, error) {
+	c.below = append(c.below, p...)
+	return c.out.Write(p)
+}
+
+// redraw updates the countdown line in place, leaving the cursor where the prompt left it
+func (c *countdown) redraw() {
+	file, ok := c.out.(*os.File)
+	if !ok {
+		return
+	}
+	width, _, err := term.GetSize(int(file.Fd()))
+	if err != nil || width <= 0 {
+		return
+	}
+
+	// Save the cursor, go up to the countdown line, rewrite it and restore the cursor
+	rows := screenRows(c.below, width)
+	fmt.Fprintf(c.out, "\0337\033[%dF\033[2K%s\0338", rows+1, countdownText(time.Until(deadline)))
+}
+
+// countdownText formats the time left before prompts time out
+func countdownText(left time.Duration) string {
+	if left < 0 {
+		left = 0
+	}
+	seconds := int(left.Round(time.Second) / time.Second)
+	return Yellow + fmt.Sprintf("⏱ Times out in %d:%02d", seconds/60, seconds%60) + Reset
+}
+
+// screenRows returns how many rows the cursor moved down while text was written to a terminal
+// width columns wide, starting at the first column. Escape sequences are skipped, and wide
+// characters are counted as one column.
+func screenRows(text []byte, width int) int {
+	rows, col := 0, 0
+	for i := 0; i < len(text); {
+		switch b := text[i]; {
+		case b == 0x1b:
+			i += escapeLength(text[i:])
+			continue
+		case b == '\n':
+			rows++
+			col = 0
+		case b == '\r':
+			col = 0
+		case b == '\b':
+			if col > 0 {
+				col--
+			}
+		case b < 0x20:
+			// Other control characters don't move the cursor
+		default:
+			// Terminals wrap when a character is written past the last column
+			if col == width {
+				rows++
+				col = 0
+			}
+			col++
+			_, size := utf8.DecodeRune(text[i:])
+			i += size
+			continue
+		}
+		i++
+	}
+	return rows
+}
+
+// escapeLength returns the length of the escape sequence at the start of text
+func escapeLength(text []byte) int {
+	if len(text) < 2 {
+		return len(text)
+	}
+	if text[1] != '[' {
+		// Two byte sequence, such as saving the cursor
+		return 2
+	}
+	for i := 2; i < len(text); i++ {
+		// CSI sequences end with a byte in the range 0x40-0x7e
+		if text[i] >= 0x40 && text[i] <= 0x7e {
+			return i + 1
+		}
+	}
+	return len(text)
+}
@@ -0,0 +1,204 @@
+package tui
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withDeadline sets the prompt deadline for a test, clearing it afterwards
+func withDeadline(t *testing.T, d time.Time) {
+	t.Helper()
+	SetDeadline(d)
+	t.Cleanup(func() { SetDeadline(time.Time{}) })
+}
+
+func TestDeadlineReaderTimesOut(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	reader := bufio.NewReader(&deadlineReader{r: pr})
+
+	// Test case 1: A read with no input stops at the deadline
+	withDeadline(t, time.Now().Add(50*time.Millisecond))
+	start := time.Now()
+	_, err := readLine(reader)
+	if !errors.Is(err, errTimedOut) {
+		t.Fatalf("Test 1 failed: Expected timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Test 1 failed: Expected the read to stop at the deadline, took %v", elapsed)
+	}
+	if !TimedOut() || !deadlinePassed() {
+		t.Error("Test 1 failed: Expected the timeout to be recorded")
+	}
+
+	// Test case 2: Input that arrives after the timeout isn't lost
+	SetDeadline(time.Time{})
+	if TimedOut() {
+		t.Error("Test 2 failed: Expected setting a deadline to clear the timeout")
+	}
+	go pw.Write([]byte("late answer\n"))
+	line, err := readLine(reader)
+	if err != nil {
+		t.Fatalf("Test 2 failed: Failed to read input: %v", err)
+	}
+	if line != "late answer" {
+		t.Errorf("Test 2 failed: Expected 'late answer', got '%s'", line)
+	}
+}
+
+func TestDeadlineReaderReadsBeforeDeadline(t *testing.T) {
+	withDeadline(t, time.Now().Add(time.Hour))
+	reader := bufio.NewReader(&deadlineReader{r: strings.NewReader("first\nsecond\n")})
+
+	for _, expected := range []string{"first", "second"} {
+		line, err := readLine(reader)
+		if err != nil {
+			t.Fatalf("Failed to read input: %v", err)
+		}
+		if line != expected {
+			t.Errorf("Expected '%s', got '%s'", expected, line)
+		}
+	}
+	if _, err := readLine(reader); err != io.EOF {
+		t.Errorf("Expected end of input, got %v", err)
+	}
+	if TimedOut() {
+		t.Error("Expected no timeout")
+	}
+}
+
+// withInterruptCleared clears the interrupt recorded by a test afterwards
+func withInterruptCleared(t *testing.T) {
+	t.Helper()
+	t.Cleanup(func() {
+		interruptMu.Lock()
+		defer interruptMu.Unlock()
+		interrupted = false
+		interrupts = make(chan struct{})
+	})
+}
+
+func TestDeadlineReaderInterrupted(t *testing.T) {
+	withInterruptCleared(t)
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	reader := bufio.NewReader(&deadlineReader{r: pr})
+
+	// Test case 1: A read waiting for input without a deadline stops when the app is interrupted
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		Interrupt()
+	}()
+	_, err := readLine(reader)
+	if !errors.Is(err, errInterrupted) {
+		t.Fatalf("Test 1 failed: Expected interrupted error, got %v", err)
+	}
+	if !Interrupted() || !promptsStopped() {
+		t.Error("Test 1 failed: Expected the interrupt to be recorded")
+	}
+
+	// Test case 2: Later reads stop straight away, even once input arrives
+	go pw.Write([]byte("late answer\n"))
+	if _, err := readLine(reader); !errors.Is(err, errInterrupted) {
+		t.Errorf("Test 2 failed: Expected interrupted error, got %v", err)
+	}
+}
+
+func TestStoppedWaiting(t *testing.T) {
+	withInterruptCleared(t)
+
+	// Test case 1: Other errors don't stop prompts
+	if stoppedWaiting(io.EOF) || stoppedWaiting(nil) || Interrupted() {
+		t.Error("Test 1 failed: Expected only timeouts and interrupts to stop prompts")
+	}
+
+	// Test case 2: Timeouts stop the prompt without interrupting the app
+	if !stoppedWaiting(errTimedOut) || Interrupted() {
+		t.Error("Test 2 failed: Expected a timeout to stop the prompt only")
+	}
+
+	// Test case 3: Ctrl+C in raw mode interrupts the app
+	if !stoppedWaiting(errInterrupted) || !Interrupted() {
+		t.Error("Test 3 failed: Expected Ctrl+C to interrupt the app")
+	}
+}
+
+func TestCountdownText(t *testing.T) {
+	tests := []struct {
+		left     time.Duration
+		expected string
+	}{
+		{15 * time.Minute, "15:00"},
+		{90*time.Second + 400*time.Millisecond, "1:30"},
+		{9 * time.Second, "0:09"},
+		{-time.Second, "0:00"},
+	}
+	for _, test := range tests {
+		text := countdownText(test.left)
+		if !strings.Contains(text, "Times out in "+test.expected) {
+			t.Errorf("Expected %v to show %s, got %q", test.left, test.expected, text)
+		}
+	}
+}
+
+func TestScreenRows(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected int
+	}{
+		{"empty", "", 0},
+		{"prompt on one line", promptText("Name: ") + "al", 0},
+		{"newlines", "line1\r\nline2\r\n", 2},
+		{"exactly the width", "1234567890", 0},
+		{"wraps past the width", "12345678901", 1},
+		{"newline after a full line", "1234567890\n", 1},
+		{"long paste", strings.Repeat("a", 35), 3},
+		{"status redrawn in place", "\r\033[K [1 line]\r\033[K [2 lines]", 0},
+		{"status redrawn after wrapping", "\r\033[K  [1 lines, 5 bytes]\r\033[K  [2 lines, 10 bytes]", 3},
+		{"backspace", "abc\b \b", 0},
+		{"multi-byte characters", "❯ ℹ ✔", 0},
+	}
+	for _, test := range tests {
+		if rows := screenRows([]byte(test.text), 10); rows != test.expected {
+			t.Errorf("%s: Expected %d rows, got %d", test.name, test.expected, rows)
+		}
+	}
+}
+
+func TestCountdownTracksOutput(t *testing.T) {
+	var out strings.Builder
+	previous := output
+	SetOutput(&out)
+	defer SetOutput(previous)
+	withDeadline(t, time.Now().Add(time.Hour))
+
+	w := beginPrompt()
+	if activeCountdown == nil {
+		t.Fatal("Expected a countdown while a deadline is set")
+	}
+	w.Write([]byte("prompt: "))
+	endPrompt()
+
+	if activeCountdown != nil {
+		t.Error("Expected the countdown to stop once the prompt ends")
+	}
+	if string(activeCountdownBelow(w)) != "prompt: " {
+		t.Errorf("Expected the countdown to track the prompt, got %q", activeCountdownBelow(w))
+	}
+	if !strings.Contains(out.String(), "Times out in 59:59") && !strings.Contains(out.String(), "Times out in 60:00") {
+		t.Errorf("Expected the countdown above the prompt, got %q", out.String())
+	}
+}
+
+// activeCountdownBelow returns what a prompt wrote below its countdown line
+func activeCountdownBelow(w io.Writer) []byte {
+	if c, ok := w.(*countdown); ok {
+		return c.below
+	}
+	return nil
+}
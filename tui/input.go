@@ -0,0 +1,192 @@
+package tui
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+
+	"golang.org/x/term"
+)
+
+// stdin is shared by all prompts, so input buffered by one prompt isn't lost to the next
+var stdin = bufio.NewReader(&deadlineReader{r: os.Stdin})
+
+// PromptBlob displays a prompt and reads a pasted key or encrypted secret of any length.
+// Pastes wrapped over several lines are joined into one; the whitespace between them is
+// removed when the content is extracted with ExtractPublicKey or ExtractSecret.
+func PromptBlob(prompt string) string {
+	if promptsStopped() {
+		return TimeoutAnswer
+	}
+	out := beginPrompt()
+	fmt.Fprint(out, promptText(prompt))
+	defer endPrompt()
+
+	// Terminals limit how long a typed line can be (as little as 1024 bytes on macOS),
+	// so read keys directly in raw mode when we can
+	fd := int(syscall.Stdin)
+	var oldState *term.State
+	var err error
+	if term.IsTerminal(fd) {
+		oldState, err = term.MakeRaw(fd)
+	}
+	if oldState == nil || err != nil {
+		blob, err := readBlob(stdin)
+		if stoppedWaiting(err) {
+			fmt.Fprintln(output)
+			return TimeoutAnswer
+		}
+		exitOnInputError(err)
+		return blob
+	}
+	fmt.Fprint(output, enableBracketedPaste)
+
+	blob, err := readBlobRaw(stdin, out)
+
+	fmt.Fprint(output, disableBracketedPaste)
+	term.Restore(fd, oldState)
+	fmt.Fprint(output, "\r\n")
+
+	if stoppedWaiting(err) {
+		return TimeoutAnswer
+	}
+	exitOnInputError(err)
+	return blob
+}
+
+// readLine reads one line of any length, without its line ending.
+// A final line without a newline is returned without error.
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readBlob reads pasted input that may have been wrapped over several lines. Lines are joined
+// while a secret_share tag is open but not yet closed, or while the last line looks like wrapped base64.
+func readBlob(r *bufio.Reader) (string, error) {
+	blob, err := readLine(r)
+	if err != nil {
+		return "", err
+	}
+
+	lastLine := blob
+	for needsMoreLines(blob, lastLine) {
+		line, err := readLine(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		blob += "\n" + line
+		lastLine = line
+	}
+
+	return blob, nil
+}
+
+// readBlobRaw reads a pasted key or encrypted secret from a terminal in raw mode, echoing it to echo.
+// Enter submits the input, unless a secret_share tag, armor block or JSON object is still open or
+// the last line looks like wrapped base64, in which case it starts a new line.
+func readBlobRaw(r *bufio.Reader, echo io.Writer) (string, error) {
+	editor := &rawEditor{echo: echo}
+	onEnter := func() bool {
+		return !needsMoreLines(string(editor.buf), editor.currentLine())
+	}
+
+	if err := editor.run(r, onEnter, func() {}); err != nil {
+		return "", err
+	}
+	if len(editor.buf) == 0 {
+		// Ctrl+D on an empty prompt closes input, like it would for a normal line
+		return "", io.EOF
+	}
+	return string(editor.buf), nil
+}
+
+// readSecretRaw reads one masked line from a terminal in raw mode. Unlike term.ReadPassword,
+// the input goes through stdin, so the read stops at the prompt deadline or when the app is
+// interrupted.
+func readSecretRaw(fd int) ([]byte, error) {
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	editor := &rawEditor{}
+	err = editor.run(stdin, func() bool { return true }, func() {})
+	term.Restore(fd, oldState)
+
+	if err != nil {
+		clear(editor.buf)
+		return nil, err
+	}
+	return editor.buf, nil
+}
+
+// needsMoreLines reports whether pasted input continues on the next line: a secret_share tag,
+// armor block or JSON object is open but not yet closed, or the last line is a full line of
+// wrapped base64
+func needsMoreLines(blob string, lastLine string) bool {
+	return hasOpenTag(blob) || hasOpenArmor(blob) || hasOpenObject(blob) || isWrappedBase64Line(lastLine)
+}
+
+// isWrappedBase64Line reports whether a line looks like one full line of base64 wrapped at the
+// standard 64 or 76 columns, without padding that would mark the end
+func isWrappedBase64Line(line string) bool {
+	if len(line) != 64 && len(line) != 76 {
+		return false
+	}
+	for _, c := range line {
+		isBase64 := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '+' || c == '/'
+		if !isBase64 {
+			return false
+		}
+	}
+	return true
+}
+
+// hasOpenTag reports whether input opens a secret_share tag without closing it
+func hasOpenTag(input string) bool {
+	return strings.Contains(input, "<secret_share_") && !strings.Contains(input, "</secret_share_")
+}
+
+// hasOpenArmor reports whether input begins an ASCII armor block, such as an armored age file,
+// without ending it
+func hasOpenArmor(input string) bool {
+	return strings.Contains(input, "-----BEGIN ") && !strings.Contains(input, "-----END ")
+}
+
+// hasOpenObject reports whether input is a JSON object, such as a pretty printed JWK, with more
+// braces opened than closed
+func hasOpenObject(input string) bool {
+	return strings.HasPrefix(strings.TrimSpace(input), "{") && strings.Count(input, "{") > strings.Count(input, "}")
+}
+
+// exitOnInputError reports a failure to read from the terminal and exits, since no prompt can
+// be answered once input is closed or broken
+func exitOnInputError(err error) {
+	if err == nil {
+		return
+	}
+	if err == io.EOF {
+		PrintError("Input was closed before SecretShare finished.")
+	} else {
+		PrintError(fmt.Sprintf("Could not read input: %v", err))
+	}
+	os.Exit(1)
+}
+
+// removeWhitespace removes all whitespace, such as line breaks inserted when wrapping base64
+func removeWhitespace(input string) string {
+	return strings.Join(strings.Fields(input), "")
+}
@@ -0,0 +1,183 @@
+package tui
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReadLineLongInput(t *testing.T) {
+	// Lines well over bufio.Scanner's 64KB limit must be read in full
+	long := strings.Repeat("A", 200*1024)
+	r := bufio.NewReader(strings.NewReader(long + "\nnext\n"))
+
+	line, err := readLine(r)
+	if err != nil {
+		t.Fatalf("Failed to read line: %v", err)
+	}
+	if line != long {
+		t.Errorf("Expected a line of %d bytes, got %d bytes", len(long), len(line))
+	}
+
+	// The following line must not be lost
+	line, err = readLine(r)
+	if err != nil || line != "next" {
+		t.Errorf("Expected 'next', got '%s' (err: %v)", line, err)
+	}
+}
+
+func TestReadLineEndOfInput(t *testing.T) {
+	// Test case 1: Final line without a newline
+	r := bufio.NewReader(strings.NewReader("last\r\n"))
+	line, err := readLine(r)
+	if err != nil || line != "last" {
+		t.Errorf("Expected 'last', got '%s' (err: %v)", line, err)
+	}
+
+	// Test case 2: Closed input is reported
+	_, err = readLine(r)
+	if err != io.EOF {
+		t.Errorf("Expected io.EOF once input is closed, got %v", err)
+	}
+}
+
+func TestReadBlobWrappedTags(t *testing.T) {
+	input := "<secret_share_secret>c3N2MQAA\nAYAjCTKX\n  KoPxZnTM==</secret_share_secret>\nnext prompt\n"
+	r := bufio.NewReader(strings.NewReader(input))
+
+	blob, err := readBlob(r)
+	if err != nil {
+		t.Fatalf("Failed to read blob: %v", err)
+	}
+
+	if ExtractSecret(blob) != "c3N2MQAAAYAjCTKXKoPxZnTM==" {
+		t.Errorf("Expected wrapped lines to be joined, got '%s'", ExtractSecret(blob))
+	}
+
+	// Input after the closing tag belongs to the next prompt
+	line, _ := readLine(r)
+	if line != "next prompt" {
+		t.Errorf("Expected 'next prompt', got '%s'", line)
+	}
+}
+
+func TestReadBlobWrappedBase64(t *testing.T) {
+	line1 := strings.Repeat("QUJD", 16) // 64 columns
+	line2 := "RA=="
+	r := bufio.NewReader(strings.NewReader(line1 + "\n" + line2 + "\nnext prompt\n"))
+
+	blob, err := readBlob(r)
+	if err != nil {
+		t.Fatalf("Failed to read blob: %v", err)
+	}
+
+	if ExtractSecret(blob) != line1+line2 {
+		t.Errorf("Expected wrapped base64 to be joined, got '%s'", ExtractSecret(blob))
+	}
+
+	// Short lines are complete on their own
+	r = bufio.NewReader(strings.NewReader("q\nnext prompt\n"))
+	blob, _ = readBlob(r)
+	if blob != "q" {
+		t.Errorf("Expected 'q', got '%s'", blob)
+	}
+}
+
+func TestReadBlobArmor(t *testing.T) {
+	armor := "-----BEGIN AGE ENCRYPTED FILE-----\nYWdlLWVuY3J5cHRpb24ub3JnL3YxCg==\n-----END AGE ENCRYPTED FILE-----"
+	r := bufio.NewReader(strings.NewReader(armor + "\nnext prompt\n"))
+
+	blob, err := readBlob(r)
+	if err != nil {
+		t.Fatalf("Failed to read blob: %v", err)
+	}
+	if blob != armor {
+		t.Errorf("Expected the whole armor block, got '%s'", blob)
+	}
+
+	line, _ := readLine(r)
+	if line != "next prompt" {
+		t.Errorf("Expected 'next prompt', got '%s'", line)
+	}
+}
+
+func TestReadBlobObject(t *testing.T) {
+	object := "{\n  \"kty\": \"RSA\",\n  \"e\": \"AQAB\"\n}"
+	r := bufio.NewReader(strings.NewReader(object + "\nnext prompt\n"))
+
+	blob, err := readBlob(r)
+	if err != nil {
+		t.Fatalf("Failed to read blob: %v", err)
+	}
+	if blob != object {
+		t.Errorf("Expected the whole object, got '%s'", blob)
+	}
+
+	line, _ := readLine(r)
+	if line != "next prompt" {
+		t.Errorf("Expected 'next prompt', got '%s'", line)
+	}
+}
+
+func TestReadBlobRaw(t *testing.T) {
+	var echo bytes.Buffer
+
+	// Test case 1: A tagged key pasted over several lines, then Enter
+	input := "\x1b[200~<secret_share_key>ssv1AB\r\nCD</secret_share_key>\x1b[201~\r"
+	blob, err := readBlobRaw(bufio.NewReader(strings.NewReader(input)), &echo)
+	if err != nil {
+		t.Fatalf("Failed to read blob: %v", err)
+	}
+	if ExtractPublicKey(blob) != "ssv1ABCD" {
+		t.Errorf("Expected 'ssv1ABCD', got '%s'", ExtractPublicKey(blob))
+	}
+
+	// Test case 2: Without bracketed paste, Enter continues while the tag is open
+	input = "<secret_share_key>ssv1AB\rCD</secret_share_key>\rignored"
+	blob, err = readBlobRaw(bufio.NewReader(strings.NewReader(input)), &echo)
+	if err != nil {
+		t.Fatalf("Failed to read blob: %v", err)
+	}
+	if ExtractPublicKey(blob) != "ssv1ABCD" {
+		t.Errorf("Expected 'ssv1ABCD', got '%s'", ExtractPublicKey(blob))
+	}
+
+	// Test case 3: Input longer than a terminal's line limit
+	long := strings.Repeat("B", 10000)
+	blob, err = readBlobRaw(bufio.NewReader(strings.NewReader(long+"\r")), &echo)
+	if err != nil || blob != long {
+		t.Errorf("Expected %d bytes, got %d bytes (err: %v)", len(long), len(blob), err)
+	}
+
+	// Test case 4: Ctrl+D on an empty prompt closes input
+	_, err = readBlobRaw(bufio.NewReader(strings.NewReader("\x04")), &echo)
+	if err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+}
+
+func TestExtractRemovesWrappingWhitespace(t *testing.T) {
+	input := "<secret_share_key>\nssv1MIIB\n  IjAN\r\nBgkq\n</secret_share_key>"
+	expected := "ssv1MIIBIjANBgkq"
+	if result := ExtractPublicKey(input); result != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, result)
+	}
+}
+
+func TestExtractRemovesQuoting(t *testing.T) {
+	// Test case 1: A secret quoted in an email reply
+	input := "> <secret_share_secret>\n> ssv1MIIB\n>  IjAN\n> =abcd\n> </secret_share_secret>"
+	expected := "ssv1MIIBIjAN=abcd"
+	if result := ExtractSecret(input); result != expected {
+		t.Errorf("Test 1 failed: Expected '%s', got '%s'", expected, result)
+	}
+
+	// Test case 2: A key quoted twice, without tags
+	input = ">> ssv1MIIB\n>> IjAN"
+	expected = "ssv1MIIBIjAN"
+	if result := ExtractPublicKey(input); result != expected {
+		t.Errorf("Test 2 failed: Expected '%s', got '%s'", expected, result)
+	}
+}
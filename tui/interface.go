@@ -1,11 +1,13 @@
 package tui
 
 import (
-	"bufio"
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 	"syscall"
+	"unicode/utf8"
 
 	"golang.org/x/term"
 )
@@ -23,6 +25,18 @@ const (
 	Bold   = "\033[1m"
 )
 
+// qrColors draws QR codes black on bright white, so they scan whatever the terminal's colors
+const qrColors = "\033[30;107m"
+
+// output is where all prompts and messages are written
+var output io.Writer = os.Stdout
+
+// SetOutput changes where prompts and messages are written.
+// Used to keep stdout clean when it carries machine readable output.
+func SetOutput(w io.Writer) {
+	output = w
+}
+
 // Styled text functions
 func headerText(text string) string {
 	return Bold + Cyan + text + Reset
@@ -44,38 +58,64 @@ func infoText(text string) string {
 	return Yellow + "ℹ " + text + Reset
 }
 
+func warningText(text string) string {
+	return Yellow + "⚠ Warning: " + text + Reset
+}
+
 // PromptUser displays a prompt and waits for user input
 // Adds proper spacing and styling
 func PromptUser(prompt string) string {
-	fmt.Println()
-	fmt.Print(promptText(prompt))
-	scanner := bufio.NewScanner(os.Stdin)
-	scanner.Scan()
-	return scanner.Text()
+	if promptsStopped() {
+		return TimeoutAnswer
+	}
+	out := beginPrompt()
+	fmt.Fprint(out, promptText(prompt))
+
+	line, err := readLine(stdin)
+	endPrompt()
+	if stoppedWaiting(err) {
+		fmt.Fprintln(output)
+		return TimeoutAnswer
+	}
+	exitOnInputError(err)
+	return line
 }
 
-// PromptSecret displays a prompt and waits for user input, masking the characters
+// PromptSecret displays a prompt and waits for user input, masking the characters.
+// The secret is returned as bytes so the caller can wipe it; nil if it could not be read.
 // Adds proper spacing and styling
-func PromptSecret(prompt string) string {
-	fmt.Println()
-	fmt.Print(promptText(prompt))
+func PromptSecret(prompt string) []byte {
+	if promptsStopped() {
+		return nil
+	}
+	out := beginPrompt()
+	fmt.Fprint(out, promptText(prompt))
 
-	// Read password (masked input)
-	bytes, err := term.ReadPassword(int(syscall.Stdin))
-	fmt.Println() // Add a newline after the masked input
+	// Read password (masked input) in raw mode, so the read can stop at the deadline or Ctrl+C
+	secret, err := readSecretRaw(int(syscall.Stdin))
+	endPrompt()
+	fmt.Fprintln(output) // Add a newline after the masked input
 
 	if err != nil {
-		return ""
+		stoppedWaiting(err)
+		return nil
+	}
+	if secret == nil {
+		secret = []byte{}
 	}
 
-	return string(bytes)
+	return secret
 }
 
 // PromptUserSingleChar displays a prompt and waits for a single character input
 // Adds proper spacing and styling
 func PromptUserSingleChar(prompt string) string {
-	fmt.Println()
-	fmt.Print(promptText(prompt))
+	if promptsStopped() {
+		return TimeoutAnswer
+	}
+	out := beginPrompt()
+	fmt.Fprint(out, promptText(prompt))
+	defer endPrompt()
 
 	// Put terminal in raw mode to read single character
 	oldState, err := term.MakeRaw(int(syscall.Stdin))
@@ -86,63 +126,220 @@ func PromptUserSingleChar(prompt string) string {
 
 	// Read a single character
 	bytes := make([]byte, 1)
-	_, err = os.Stdin.Read(bytes)
+	_, err = io.ReadFull(stdin, bytes)
+	if err == nil && bytes[0] == 3 {
+		// Ctrl+C interrupts the app
+		err = errInterrupted
+	}
+	if stoppedWaiting(err) {
+		fmt.Fprint(output, "\r\n")
+		return TimeoutAnswer
+	}
 	if err != nil {
 		return ""
 	}
 
-	// Check for Ctrl+C interrupt (byte value 3)
-	if bytes[0] == 3 {
-		// Restore terminal state before exiting
-		term.Restore(int(syscall.Stdin), oldState)
-		// Exit gracefully
-		os.Exit(0)
-	}
-
 	// Convert to string and return
 	char := string(bytes)
 
 	// Echo the character to the terminal since we read it directly
-	fmt.Print(char)
-	fmt.Println()
+	fmt.Fprint(output, char)
+	fmt.Fprintln(output)
 
 	return char
 }
 
 // PrintMessage displays a message to the user with better formatting
 func PrintMessage(message string) {
-	fmt.Println()
-	fmt.Println(message)
+	fmt.Fprintln(output)
+	fmt.Fprintln(output, message)
+}
+
+// PrintStatus displays a transient status message on its own line, to be removed by ClearStatus
+func PrintStatus(message string) {
+	fmt.Fprint(output, "\n"+message)
+}
+
+// ClearStatus removes the message shown by PrintStatus and moves back up a line
+func ClearStatus() {
+	fmt.Fprint(output, "\r\033[K\033[F")
+}
+
+// PrintSecret displays a success message followed by a secret, starting multi-line secrets on their
+// own line. The secret is written from its bytes, without copying it to a string.
+func PrintSecret(message string, secret []byte) {
+	fmt.Fprintln(output)
+	if IsMultilineSecret(secret) {
+		fmt.Fprintln(output, successText(message))
+		fmt.Fprintln(output)
+		output.Write(secret)
+		fmt.Fprintln(output)
+		return
+	}
+	fmt.Fprint(output, Green+"✔ "+message+" ")
+	output.Write(secret)
+	fmt.Fprintln(output, Reset)
+}
+
+// IsMultilineSecret reports whether a secret has more than one line, ignoring trailing newlines
+func IsMultilineSecret(secret []byte) bool {
+	return bytes.Contains(bytes.TrimRight(secret, "\n"), []byte("\n"))
 }
 
 // PrintHeader displays a header message with styling
 func PrintHeader(message string) {
-	fmt.Println(headerText(message))
+	fmt.Fprintln(output, headerText(message))
 }
 
 // PrintError displays an error message to the user with better formatting and colors
 func PrintError(message string) {
-	fmt.Println()
-	fmt.Println(errorText(message))
+	fmt.Fprintln(output)
+	fmt.Fprintln(output, errorText(message))
 }
 
 // PrintSuccess displays a success message to the user with better formatting and colors
 func PrintSuccess(message string) {
-	fmt.Println()
-	fmt.Println(successText(message))
+	fmt.Fprintln(output)
+	fmt.Fprintln(output, successText(message))
 }
 
 // PrintInfo displays an info message to the user with better formatting and colors
 func PrintInfo(message string) {
-	fmt.Println()
-	fmt.Println(infoText(message))
+	fmt.Fprintln(output)
+	fmt.Fprintln(output, infoText(message))
+}
+
+// PrintWarning displays a warning message to the user with better formatting and colors
+func PrintWarning(message string) {
+	fmt.Fprintln(output)
+	fmt.Fprintln(output, warningText(message))
+}
+
+// PrintTable displays rows of values as aligned columns, with a bold header row
+func PrintTable(headers []string, rows [][]string) {
+	fmt.Fprintln(output)
+	fmt.Fprint(output, FormatTable(headers, rows))
+}
+
+// PrintSecretTable displays rows of secret values like PrintTable, without copying them to a
+// string. The formatted table is wiped once it's written.
+func PrintSecretTable(headers []string, rows [][][]byte) {
+	table := formatTableBytes(headers, rows)
+	defer clear(table)
+	fmt.Fprintln(output)
+	output.Write(table)
+}
+
+// PrintQRCode displays a QR code rendered as text, black on white
+func PrintQRCode(code string) {
+	fmt.Fprintln(output)
+	fmt.Fprint(output, formatQRCode(code))
+}
+
+// formatQRCode colors each line of a QR code rendered as text black on white, resetting the
+// colors at the end of each line so the background doesn't run on past the code
+func formatQRCode(code string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(code, "\n") {
+		b.WriteString(qrColors + line + Reset + "\n")
+	}
+	return b.String()
+}
+
+// FormatTable formats rows of values as aligned columns separated by two spaces
+func FormatTable(headers []string, rows [][]string) string {
+	byteRows := make([][][]byte, len(rows))
+	for i, row := range rows {
+		for _, cell := range row {
+			byteRows[i] = append(byteRows[i], []byte(cell))
+		}
+	}
+	return string(formatTableBytes(headers, byteRows))
 }
 
+// formatTableBytes formats rows of values as aligned columns separated by two spaces, into one
+// buffer sized up front so the values aren't left behind in ones outgrown. The caller wipes it.
+func formatTableBytes(headers []string, rows [][][]byte) []byte {
+	headerRow := make([][]byte, len(headers))
+	widths := make([]int, len(headers))
+	for i, header := range headers {
+		headerRow[i] = []byte(header)
+		widths[i] = utf8.RuneCountInString(header)
+	}
+	for _, row := range rows {
+		for i := 0; i < len(row) && i < len(widths); i++ {
+			if w := utf8.RuneCount(row[i]); w > widths[i] {
+				widths[i] = w
+			}
+		}
+	}
+
+	// cellAt returns a row's cell in column i, and the padding after it
+	cellAt := func(cells [][]byte, i int) ([]byte, int) {
+		var cell []byte
+		if i < len(cells) {
+			cell = cells[i]
+		}
+		if i == len(widths)-1 {
+			return cell, 0
+		}
+		return cell, widths[i] - utf8.RuneCount(cell) + 2
+	}
+	size := len(Bold) + len(Reset)
+	for _, row := range append([][][]byte{headerRow}, rows...) {
+		for i := range widths {
+			cell, padding := cellAt(row, i)
+			size += len(cell) + padding
+		}
+		size++
+	}
+
+	table := make([]byte, 0, size)
+	writeRow := func(cells [][]byte, style string) {
+		table = append(table, style...)
+		for i := range widths {
+			cell, padding := cellAt(cells, i)
+			table = append(table, cell...)
+			for ; padding > 0; padding-- {
+				table = append(table, ' ')
+			}
+		}
+		if style != "" {
+			table = append(table, Reset...)
+		}
+		table = append(table, '\n')
+	}
+
+	writeRow(headerRow, Bold)
+	for _, row := range rows {
+		writeRow(row, "")
+	}
+	return table
+}
+
+// quitWords are the inputs that quit the app, in any case
+var quitWords = []string{"q", "quit", "[q]", "exit"}
+
 // IsQuit checks if the user input is a quit command
 func IsQuit(input string) bool {
 	trimmed := strings.TrimSpace(input)
-	lower := strings.ToLower(trimmed)
-	return lower == "q" || lower == "quit" || lower == "[q]" || lower == "exit"
+	for _, word := range quitWords {
+		if strings.EqualFold(trimmed, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsQuitSecret checks if a secret entered by the user is a quit command, without copying it to a string
+func IsQuitSecret(secret []byte) bool {
+	trimmed := bytes.TrimSpace(secret)
+	for _, word := range quitWords {
+		if bytes.EqualFold(trimmed, []byte(word)) {
+			return true
+		}
+	}
+	return false
 }
 
 // ParseRoleInput parses the user's role selection input
@@ -163,14 +360,31 @@ func ParseRoleInput(input string) string {
 	return ""
 }
 
-// ExtractPublicKey extracts the public key from XML-like tags
+// ExtractPublicKey extracts the public key from XML-like tags.
+// Whitespace inside the key, such as line breaks from wrapping, and quote prefixes are removed.
 func ExtractPublicKey(input string) string {
-	return extractTagContent(input, "secret_share_key")
+	return removeWhitespace(extractTagContent(removeQuoting(input), "secret_share_key"))
 }
 
-// ExtractSecret extracts the secret from XML-like tags
+// ExtractSecret extracts the secret from XML-like tags.
+// Whitespace inside the secret, such as line breaks from wrapping, and quote prefixes are removed.
 func ExtractSecret(input string) string {
-	return extractTagContent(input, "secret_share_secret")
+	return removeWhitespace(extractTagContent(removeQuoting(input), "secret_share_secret"))
+}
+
+// ExtractShare extracts a share of a split secret from XML-like tags.
+// Whitespace inside the share, such as line breaks from wrapping, and quote prefixes are removed.
+func ExtractShare(input string) string {
+	return removeWhitespace(extractTagContent(removeQuoting(input), "secret_share_share"))
+}
+
+// removeQuoting removes the "> " prefixes email clients add to each line of a quoted reply
+func removeQuoting(input string) string {
+	lines := strings.Split(input, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimLeft(line, "> \t")
+	}
+	return strings.Join(lines, "\n")
 }
 
 // extractTagContent extracts content from XML-like tags with tolerance for formatting errors
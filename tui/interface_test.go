@@ -1,6 +1,7 @@
 package tui
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -100,4 +101,108 @@ func TestExtractTagContent(t *testing.T) {
 	if result12 != expected12 {
 		t.Errorf("Test 12 failed: Expected '%s', got '%s'", expected12, result12)
 	}
+
+	// Test case 13: Share tags, wrapped across lines
+	input13 := "<secret_share_share>TEST_SHARE\nCONTENT</secret_share_share>"
+	expected13 := "TEST_SHARECONTENT"
+	result13 := ExtractShare(input13)
+	if result13 != expected13 {
+		t.Errorf("Test 13 failed: Expected '%s', got '%s'", expected13, result13)
+	}
+}
+
+func TestFormatTable(t *testing.T) {
+	result := FormatTable([]string{"NAME", "VALUE"}, [][]string{
+		{"username", "admin"},
+		{"pw", "héllo"},
+	})
+
+	expected := Bold + "NAME      VALUE" + Reset + "\n" +
+		"username  admin\n" +
+		"pw        héllo\n"
+	if result != expected {
+		t.Errorf("Expected '%q', got '%q'", expected, result)
+	}
+}
+
+func TestFormatTableBytes(t *testing.T) {
+	// Secret tables format like other tables, in a buffer that was never outgrown
+	table := formatTableBytes([]string{"NAME", "VALUE"}, [][][]byte{
+		{[]byte("username"), []byte("admin")},
+		{[]byte("pw"), []byte("héllo")},
+	})
+	expected := FormatTable([]string{"NAME", "VALUE"}, [][]string{{"username", "admin"}, {"pw", "héllo"}})
+	if string(table) != expected {
+		t.Errorf("Expected '%q', got '%q'", expected, table)
+	}
+	if cap(table) != len(table) {
+		t.Errorf("Expected the table to be sized up front, got length %d and capacity %d", len(table), cap(table))
+	}
+}
+
+func TestFormatQRCode(t *testing.T) {
+	result := formatQRCode("█▀▄ \n ▄▀█")
+
+	expected := qrColors + "█▀▄ " + Reset + "\n" +
+		qrColors + " ▄▀█" + Reset + "\n"
+	if result != expected {
+		t.Errorf("Expected '%q', got '%q'", expected, result)
+	}
+}
+
+func TestIsQuitSecret(t *testing.T) {
+	// Test case 1: Quit words in any case, with surrounding whitespace
+	for _, input := range []string{"q", "Q", " quit ", "[q]", "EXIT"} {
+		if !IsQuitSecret([]byte(input)) {
+			t.Errorf("Test 1 failed: Expected '%s' to quit", input)
+		}
+	}
+
+	// Test case 2: Secrets that only contain a quit word
+	for _, input := range []string{"qq", "quitter", "exit1", ""} {
+		if IsQuitSecret([]byte(input)) {
+			t.Errorf("Test 2 failed: Expected '%s' not to quit", input)
+		}
+	}
+}
+
+func TestIsMultilineSecret(t *testing.T) {
+	if IsMultilineSecret([]byte("password\n")) {
+		t.Error("Expected a trailing newline to be ignored")
+	}
+	if !IsMultilineSecret([]byte("line1\nline2")) {
+		t.Error("Expected two lines to be multi-line")
+	}
+}
+
+func FuzzExtractTagContent(f *testing.F) {
+	f.Add("<secret_share_key>TEST_KEY_CONTENT</secret_share_key>")
+	f.Add("secret_share_key>TEST_KEY_CONTENT</secret_share_key>")
+	f.Add("<secret_share_key>TEST_KEY_CONTENT</secret_share_key")
+	f.Add("Some extra data <secret_share_key>TEST</secret_share_key> More extra data")
+	f.Add("VALIDKEY</secret_share_key>")
+	f.Add("</secret_share_key><secret_share_key>")
+	f.Add("<<>>")
+	f.Add("</")
+	f.Add(">")
+	f.Add("   ")
+	f.Add("")
+
+	f.Fuzz(func(t *testing.T, input string) {
+		result := extractTagContent(input, "secret_share_key")
+		if result != strings.TrimSpace(result) {
+			t.Errorf("Expected trimmed result, got '%q'", result)
+		}
+		if len(result) > len(input) {
+			t.Errorf("Result '%q' is longer than input '%q'", result, input)
+		}
+
+		// Content without tag characters is always recovered from properly formatted tags
+		if !strings.ContainsAny(input, "<>") {
+			wrapped := extractTagContent("<secret_share_key>"+input+"</secret_share_key>", "secret_share_key")
+			if wrapped != strings.TrimSpace(input) {
+				t.Errorf("Expected '%q', got '%q'", strings.TrimSpace(input), wrapped)
+			}
+		}
+	})
 }
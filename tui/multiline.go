@@ -0,0 +1,114 @@
+package tui
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"syscall"
+
+	"golang.org/x/term"
+)
+
+// MultilineSentinel is the line that ends multi-line input when typed on its own
+const MultilineSentinel = "."
+
+// PromptSecretMultiline displays a prompt and reads masked input spanning multiple lines, such as a
+// PEM private key or JSON file. Input ends with a line containing only ".", or Ctrl+D or the end
+// of piped input. Only a running count of lines and bytes is shown. Returns nil if the input could not be read.
+func PromptSecretMultiline(prompt string) []byte {
+	if promptsStopped() {
+		return nil
+	}
+	out := beginPrompt()
+	fmt.Fprintln(out, promptText(prompt))
+	fmt.Fprintln(out, infoText(fmt.Sprintf("Paste or type the secret. End with a line containing only '%s', or Ctrl+D.", MultilineSentinel)))
+
+	defer endPrompt()
+	fd := int(syscall.Stdin)
+	if !term.IsTerminal(fd) {
+		// Piped input is read as is, up to the sentinel line
+		data, err := readPipedMultiline(stdin)
+		if err != nil {
+			stoppedWaiting(err)
+			return nil
+		}
+		return data
+	}
+
+	// Raw mode so Enter, Ctrl+D and pasted text reach us without echo
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil
+	}
+	fmt.Fprint(output, enableBracketedPaste)
+
+	data, err := readMultiline(stdin, func(lines, size int) {
+		fmt.Fprintf(out, "\r\033[K  [%d lines, %d bytes]", lines, size)
+	})
+
+	fmt.Fprint(output, disableBracketedPaste)
+	term.Restore(fd, oldState)
+	fmt.Fprint(output, "\r\n")
+
+	if err != nil {
+		stoppedWaiting(err)
+		return nil
+	}
+	return data
+}
+
+// readMultiline reads masked multi-line input from a terminal in raw mode, calling status after each
+// change. Typed input ends at a line equal to MultilineSentinel, Ctrl+D, or end of input.
+// Text inside a bracketed paste is kept literally, with line endings normalized to "\n".
+func readMultiline(r *bufio.Reader, status func(lines, size int)) ([]byte, error) {
+	editor := &rawEditor{}
+	onEnter := func() bool {
+		if editor.currentLine() == MultilineSentinel {
+			editor.buf = editor.buf[:editor.lineStart]
+			return true
+		}
+		return false
+	}
+	onChange := func() {
+		status(countLines(editor.buf), len(editor.buf))
+	}
+
+	if err := editor.run(r, onEnter, onChange); err != nil {
+		return nil, err
+	}
+	return editor.buf, nil
+}
+
+// readPipedMultiline reads multi-line input that isn't from a terminal, such as a file piped to
+// stdin. Lines are kept exactly as they are, line endings included, and input ends at a line equal
+// to MultilineSentinel or end of input.
+func readPipedMultiline(r *bufio.Reader) ([]byte, error) {
+	editor := &rawEditor{}
+	for {
+		b, err := r.ReadByte()
+		if err != nil && err != io.EOF {
+			clear(editor.buf)
+			return nil, err
+		}
+		if err == nil {
+			editor.add(b)
+			if b != '\n' {
+				continue
+			}
+		}
+
+		if string(bytes.TrimRight(editor.buf[editor.lineStart:], "\r\n")) == MultilineSentinel {
+			clear(editor.buf[editor.lineStart:])
+			editor.buf = editor.buf[:editor.lineStart]
+			err = io.EOF
+		}
+		if err == io.EOF {
+			if editor.buf == nil {
+				return []byte{}, nil
+			}
+			return editor.buf, nil
+		}
+		editor.lineStart = len(editor.buf)
+	}
+}
@@ -0,0 +1,176 @@
+package tui
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// Bracketed paste mode control sequences. When enabled, the terminal wraps pasted text
+// in pasteStart/pasteEnd so it can be told apart from typed keys.
+const (
+	enableBracketedPaste  = "\033[?2004h"
+	disableBracketedPaste = "\033[?2004l"
+	pasteStart            = "[200~"
+	pasteEnd              = "[201~"
+)
+
+// rawEditorSize is how much input a rawEditor holds before its buffer first grows
+const rawEditorSize = 256
+
+// errInterrupted is returned when the user presses Ctrl+C while input is read in raw mode
+var errInterrupted = errors.New("interrupted")
+
+// rawEditor collects input read from a terminal in raw mode. It handles bracketed paste,
+// backspace, Ctrl+C and Ctrl+D, and can echo input or keep it masked.
+type rawEditor struct {
+	buf       []byte
+	lineStart int       // index in buf where the current line starts
+	echo      io.Writer // where input is echoed, or nil to keep it masked
+}
+
+// currentLine returns the line being typed
+func (e *rawEditor) currentLine() string {
+	return string(e.buf[e.lineStart:])
+}
+
+// add appends b to the input. A full buffer is copied to a larger one and then wiped, so growing
+// it never leaves a copy of the input behind in memory.
+func (e *rawEditor) add(b byte) {
+	if len(e.buf) == cap(e.buf) {
+		grown := make([]byte, len(e.buf), max(2*cap(e.buf), rawEditorSize))
+		copy(grown, e.buf)
+		clear(e.buf)
+		e.buf = grown
+	}
+	e.buf = append(e.buf, b)
+}
+
+// newline ends the current line
+func (e *rawEditor) newline() {
+	e.add('\n')
+	e.lineStart = len(e.buf)
+	e.write("\r\n")
+}
+
+// write echoes text if echo is enabled
+func (e *rawEditor) write(text string) {
+	if e.echo != nil {
+		fmt.Fprint(e.echo, text)
+	}
+}
+
+// run reads input until onEnter returns true when Enter is typed, or until Ctrl+D or the end of
+// input. If onEnter returns false the Enter starts a new line. onChange is called after each
+// change to the input. Text inside a bracketed paste is kept literally, with line endings
+// normalized to "\n", and never triggers onEnter.
+func (e *rawEditor) run(r *bufio.Reader, onEnter func() bool, onChange func()) error {
+	inPaste := false
+	lastWasCR := false
+
+	for {
+		b, err := r.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		wasCR := lastWasCR
+		lastWasCR = false
+
+		switch {
+		case b == 0x1b:
+			// Escape sequences only toggle paste mode; others (like arrow keys) are ignored
+			seq, err := readEscapeSequence(r)
+			if err != nil {
+				return err
+			}
+			if seq == pasteStart {
+				inPaste = true
+			} else if seq == pasteEnd {
+				inPaste = false
+			}
+			continue
+		case inPaste:
+			switch b {
+			case '\r':
+				e.newline()
+				lastWasCR = true
+			case '\n':
+				// Skip the LF of a CRLF pair, already added for the CR
+				if !wasCR {
+					e.newline()
+				}
+			default:
+				e.add(b)
+				e.write(string(b))
+			}
+		case b == 3:
+			return errInterrupted
+		case b == 4:
+			return nil
+		case b == '\r' || b == '\n':
+			if onEnter() {
+				return nil
+			}
+			e.newline()
+		case b == 0x7f || b == 0x08:
+			// Backspace removes the last character typed on the current line
+			if len(e.buf) > e.lineStart {
+				_, size := utf8.DecodeLastRune(e.buf[e.lineStart:])
+				clear(e.buf[len(e.buf)-size:])
+				e.buf = e.buf[:len(e.buf)-size]
+				e.write("\b \b")
+			}
+		default:
+			e.add(b)
+			e.write(string(b))
+		}
+
+		onChange()
+	}
+}
+
+// readEscapeSequence reads the rest of an escape sequence after the ESC byte,
+// returning it without the ESC (for example "[200~")
+func readEscapeSequence(r *bufio.Reader) (string, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	if b != '[' {
+		// Two byte sequence, such as Alt+key
+		return string(b), nil
+	}
+
+	seq := []byte{b}
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		seq = append(seq, b)
+		// CSI sequences end with a byte in the range 0x40-0x7e
+		if b >= 0x40 && b <= 0x7e {
+			return string(seq), nil
+		}
+	}
+}
+
+// countLines counts the lines in buf, including a final line without a newline
+func countLines(buf []byte) int {
+	lines := 0
+	for _, b := range buf {
+		if b == '\n' {
+			lines++
+		}
+	}
+	if len(buf) > 0 && buf[len(buf)-1] != '\n' {
+		lines++
+	}
+	return lines
+}
@@ -0,0 +1,156 @@
+package tui
+
+import (
+	"strings"
+	"time"
+)
+
+// ScriptedConsole is an in-memory Console that answers prompts from a script, for testing flows
+// end to end. Everything shown is recorded as plain text in the transcript, and text copied to the
+// clipboard is kept. Once the script runs out, every prompt is answered with "q".
+// Prompts shown after the deadline time out, as they would on a terminal.
+type ScriptedConsole struct {
+	answers    []func(prompt string) string
+	transcript strings.Builder
+	deadline   time.Time
+	timedOut   bool
+
+	// Clipboard holds the last text copied to the clipboard
+	Clipboard string
+	// ClipboardErr, if set, is returned by SetClipboard to simulate a missing clipboard tool
+	ClipboardErr error
+	// Unanswered lists prompts shown after the script ran out
+	Unanswered []string
+}
+
+// NewScriptedConsole creates a console that answers prompts in order with the given answers
+func NewScriptedConsole(answers ...string) *ScriptedConsole {
+	c := &ScriptedConsole{}
+	c.Answer(answers...)
+	return c
+}
+
+// Answer appends fixed answers to the script
+func (c *ScriptedConsole) Answer(answers ...string) {
+	for _, answer := range answers {
+		answer := answer
+		c.AnswerWith(func(string) string { return answer })
+	}
+}
+
+// AnswerWith appends an answer computed when its prompt is shown, for answers that depend on
+// earlier output (like a key printed by the other side of the conversation)
+func (c *ScriptedConsole) AnswerWith(answer func(prompt string) string) {
+	c.answers = append(c.answers, answer)
+}
+
+// AnswerTimeout appends an answer that times out, as if the deadline passed while the prompt
+// waited. Every later prompt times out too.
+func (c *ScriptedConsole) AnswerTimeout() {
+	c.AnswerWith(func(string) string {
+		c.deadline = time.Now()
+		return c.timeout()
+	})
+}
+
+// Transcript returns everything shown on the console so far
+func (c *ScriptedConsole) Transcript() string {
+	return c.transcript.String()
+}
+
+// Remaining returns the number of answers not yet used
+func (c *ScriptedConsole) Remaining() int {
+	return len(c.answers)
+}
+
+// next records the prompt and returns the next scripted answer
+func (c *ScriptedConsole) next(prompt string) string {
+	c.transcript.WriteString(prompt + "\n")
+	if !c.deadline.IsZero() && !time.Now().Before(c.deadline) {
+		return c.timeout()
+	}
+	if len(c.answers) == 0 {
+		c.Unanswered = append(c.Unanswered, prompt)
+		return "q"
+	}
+
+	answer := c.answers[0]
+	c.answers = c.answers[1:]
+	return answer(prompt)
+}
+
+// timeout records a prompt that timed out and returns its answer
+func (c *ScriptedConsole) timeout() string {
+	c.timedOut = true
+	c.print("(timed out)")
+	return TimeoutAnswer
+}
+
+// nextSecret returns the next scripted answer for a secret prompt, or nil if it timed out
+func (c *ScriptedConsole) nextSecret(prompt string) []byte {
+	answer := c.next(prompt)
+	if c.timedOut {
+		return nil
+	}
+	return []byte(answer)
+}
+
+func (c *ScriptedConsole) PromptUser(prompt string) string { return c.next(prompt) }
+func (c *ScriptedConsole) PromptBlob(prompt string) string { return c.next(prompt) }
+func (c *ScriptedConsole) PromptSecret(prompt string) []byte {
+	return c.nextSecret(prompt)
+}
+func (c *ScriptedConsole) PromptSecretMultiline(prompt string) []byte {
+	return c.nextSecret(prompt)
+}
+func (c *ScriptedConsole) PromptUserSingleChar(prompt string) string { return c.next(prompt) }
+func (c *ScriptedConsole) TimedOut() bool                            { return c.timedOut }
+func (c *ScriptedConsole) PrintMessage(message string)               { c.print(message) }
+func (c *ScriptedConsole) PrintHeader(message string)                { c.print(message) }
+func (c *ScriptedConsole) PrintError(message string)                 { c.print("Error: " + message) }
+func (c *ScriptedConsole) PrintSuccess(message string)               { c.print(message) }
+func (c *ScriptedConsole) PrintInfo(message string)                  { c.print(message) }
+func (c *ScriptedConsole) PrintWarning(message string)               { c.print("Warning: " + message) }
+func (c *ScriptedConsole) PrintStatus(message string)                {}
+func (c *ScriptedConsole) ClearStatus()                              {}
+
+func (c *ScriptedConsole) SetDeadline(deadline time.Time) {
+	c.deadline = deadline
+	c.timedOut = false
+}
+
+func (c *ScriptedConsole) PrintTable(headers []string, rows [][]string) {
+	table := FormatTable(headers, rows)
+	c.print(strings.ReplaceAll(strings.ReplaceAll(table, Bold, ""), Reset, ""))
+}
+
+func (c *ScriptedConsole) PrintSecretTable(headers []string, rows [][][]byte) {
+	table := formatTableBytes(headers, rows)
+	defer clear(table)
+	c.print(strings.ReplaceAll(strings.ReplaceAll(string(table), Bold, ""), Reset, ""))
+}
+
+func (c *ScriptedConsole) PrintQRCode(code string) {
+	c.print(code)
+}
+
+func (c *ScriptedConsole) PrintSecret(message string, secret []byte) {
+	if IsMultilineSecret(secret) {
+		c.print(message + "\n" + string(secret))
+		return
+	}
+	c.print(message + " " + string(secret))
+}
+
+func (c *ScriptedConsole) SetClipboard(text string) error {
+	if c.ClipboardErr != nil {
+		return c.ClipboardErr
+	}
+	c.Clipboard = text
+	return nil
+}
+
+// print records a message in the transcript
+func (c *ScriptedConsole) print(message string) {
+	c.transcript.WriteString(message + "\n")
+}